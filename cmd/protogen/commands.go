@@ -0,0 +1,395 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/muxinc/protogen/proto3"
+	"github.com/muxinc/protogen/proto3/lint"
+)
+
+// newFlagSet creates the flags of a subcommand, reporting flag errors to stderr.
+func newFlagSet(name, args string, stderr io.Writer) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "usage: protogen %s [flags] %s\n", name, args)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// parseFlags parses the arguments of a subcommand and checks that between min and max files are named,
+// where a negative max allows any number. Usage is reported when the arguments are invalid.
+func parseFlags(flags *flag.FlagSet, args []string, min, max int) bool {
+	if err := flags.Parse(args); err != nil {
+		return false // already reported by the flag set
+	}
+	if flags.NArg() < min || (max >= 0 && flags.NArg() > max) {
+		flags.Usage()
+		return false
+	}
+	return true
+}
+
+// styles are the layouts .proto files can be written in, named by the -style flag.
+var styles = map[string]proto3.FormatOptions{
+	"default": proto3.DefaultFormat,
+	"buf":     proto3.BufFormat,
+}
+
+// orders are the arrangements of the elements of messages and enums, named by the -order flag.
+var orders = map[string]proto3.Ordering{
+	"grouped":  proto3.OrderGrouped,
+	"declared": proto3.OrderDeclared,
+	"tag":      proto3.OrderByTag,
+}
+
+// formatFlags defines the -style and -order flags of a subcommand that writes .proto files, with elements
+// in the named order by default.
+func formatFlags(flags *flag.FlagSet, defaultOrder string) (style, order *string) {
+	style = flags.String("style", "default", "layout of the .proto files written: default or buf, the layout of buf format")
+	order = flags.String("order", defaultOrder, "order of the elements of messages and enums: grouped by kind, declared, or by tag")
+	return style, order
+}
+
+// lookupFormat returns the layout named by the -style flag with elements in the order named by the -order
+// flag, reporting an unknown name to stderr.
+func lookupFormat(style, order string, stderr io.Writer) (proto3.FormatOptions, bool) {
+	format, ok := styles[style]
+	if !ok {
+		fmt.Fprintf(stderr, "protogen: unknown style %q\n", style)
+		return format, false
+	}
+	if format.Order, ok = orders[order]; !ok {
+		fmt.Fprintf(stderr, "protogen: unknown order %q\n", order)
+	}
+	return format, ok
+}
+
+// writeSpec returns the text of a spec laid out in the given format.
+func writeSpec(spec *proto3.Spec, format proto3.FormatOptions) (string, error) {
+	var buffer bytes.Buffer
+	if _, err := spec.WriteFormatted(&buffer, format); err != nil {
+		return "", err
+	}
+	return buffer.String(), nil
+}
+
+// lostContents parses the text a spec was written as and returns the contents of the spec that it leaves
+// out, such as comments and elements.
+func lostContents(spec *proto3.Spec, text string) ([]string, error) {
+	written, err := proto3.Parse(strings.NewReader(text))
+	if err != nil {
+		return nil, err
+	}
+	return specContents(spec).lost(specContents(written)), nil
+}
+
+// lintConfig returns a configuration of the style rules that checks only those named by the -rules flag,
+// or every rule for all, reporting unknown names to stderr.
+func lintConfig(names string, stderr io.Writer) (lint.Config, bool) {
+	enabled := make(map[string]bool)
+	for _, name := range strings.Split(names, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			enabled[name] = true
+		}
+	}
+	config := lint.Config{Rules: make(map[string]lint.Setting)}
+	for _, r := range lint.Rules() {
+		if !enabled["all"] && !enabled[r.Name] {
+			config.Rules[r.Name] = lint.Off
+		}
+		delete(enabled, r.Name)
+	}
+	delete(enabled, "all")
+	for name := range enabled {
+		fmt.Fprintf(stderr, "protogen: unknown lint rule %q\n", name)
+		return config, false
+	}
+	return config, true
+}
+
+// loaded is a definition file that was read and checked successfully.
+type loaded struct {
+	path string
+	spec *proto3.Spec
+}
+
+// loadAll reads and validates every definition file, including against the field registry when one is
+// given, reporting problems to stderr. It reports whether every file was free of problems.
+func loadAll(paths []string, registry *proto3.FieldRegistry, stderr io.Writer) ([]loaded, bool) {
+	ok := true
+	specs := make([]loaded, 0, len(paths))
+	for _, path := range paths {
+		spec, err := loadSpec(path)
+		if err == nil {
+			err = spec.Validate()
+		}
+		if err == nil && registry != nil {
+			err = registry.Validate(spec)
+		}
+		if err != nil {
+			report(stderr, path, err)
+			ok = false
+			continue
+		}
+		specs = append(specs, loaded{path: path, spec: spec})
+	}
+	return specs, ok
+}
+
+// protoName returns the name of the .proto file written for a definition.
+func protoName(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)) + ".proto"
+}
+
+// newProject returns the project of the definitions, each in a .proto file named after its definition
+// within the directory of its package.
+func newProject(specs []loaded) *proto3.Project {
+	project := &proto3.Project{Files: make([]proto3.ProjectFile, 0, len(specs))}
+	for _, l := range specs {
+		project.Files = append(project.Files, proto3.ProjectFile{Name: protoName(l.path), Spec: l.spec})
+	}
+	return project
+}
+
+// hasErrors reports whether any of the problems is an error rather than a warning.
+func hasErrors(err error) bool {
+	errs, ok := err.(proto3.ValidationErrors)
+	if !ok {
+		return true
+	}
+	for _, e := range errs {
+		if e.Severity == proto3.SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// runGenerate writes each definition as a .proto file to the directory of its package within the output
+// directory, importing the other definitions it refers to. Nothing is written unless every definition is
+// valid.
+func runGenerate(args []string, stdout, stderr io.Writer) int {
+	flags := newFlagSet("generate", "<files...>", stderr)
+	out := flags.String("out", ".", "directory to write .proto files to")
+	registryPath := flags.String("registry", "", "definition file whose message fields form the field registry")
+	descriptorSet := flags.String("descriptor_set_out", "", "file to write a FileDescriptorSet of the definitions to")
+	style, order := formatFlags(flags, "grouped")
+	if !parseFlags(flags, args, 1, -1) {
+		return exitUsage
+	}
+	format, ok := lookupFormat(*style, *order, stderr)
+	if !ok {
+		return exitUsage
+	}
+	registry, err := loadRegistry(*registryPath)
+	if err != nil {
+		report(stderr, *registryPath, err)
+		return exitProblems
+	}
+	specs, ok := loadAll(flags.Args(), registry, stderr)
+	if !ok {
+		return exitProblems
+	}
+
+	project := newProject(specs)
+	if err := project.Validate(); err != nil {
+		report(stderr, "", err)
+		return exitProblems
+	}
+	var files []proto3.DescriptorFile
+	for i, f := range project.Files {
+		spec := *f.Spec
+		spec.Imports = project.Imports(f)
+		v, err := writeSpec(&spec, format)
+		if err != nil {
+			report(stderr, specs[i].path, err)
+			return exitProblems
+		}
+		path := filepath.Join(*out, filepath.FromSlash(f.Path()))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			fmt.Fprintf(stderr, "protogen: %s\n", err)
+			return exitProblems
+		}
+		if err := ioutil.WriteFile(path, []byte(v), 0644); err != nil {
+			fmt.Fprintf(stderr, "protogen: %s\n", err)
+			return exitProblems
+		}
+		files = append(files, proto3.DescriptorFile{Name: f.Path(), Spec: &spec})
+	}
+
+	if *descriptorSet != "" {
+		set, err := proto3.DescriptorSet(files...)
+		if err != nil {
+			report(stderr, "", err)
+			return exitProblems
+		}
+		if err := ioutil.WriteFile(*descriptorSet, set, 0644); err != nil {
+			fmt.Fprintf(stderr, "protogen: %s\n", err)
+			return exitProblems
+		}
+	}
+	return exitOK
+}
+
+// runValidate reports the problems with each definition.
+func runValidate(args []string, stdout, stderr io.Writer) int {
+	flags := newFlagSet("validate", "<files...>", stderr)
+	registryPath := flags.String("registry", "", "definition file whose message fields form the field registry")
+	if !parseFlags(flags, args, 1, -1) {
+		return exitUsage
+	}
+	registry, err := loadRegistry(*registryPath)
+	if err != nil {
+		report(stderr, *registryPath, err)
+		return exitProblems
+	}
+	if _, ok := loadAll(flags.Args(), registry, stderr); !ok {
+		return exitProblems
+	}
+	return exitOK
+}
+
+// runLint reports the problems with each definition, type references that do not resolve against the
+// definitions they import, fields that share a name across definitions but are declared inconsistently,
+// and breaches of the style rules named by -rules.
+// Definitions are imported by the path of the .proto file generate writes for them.
+func runLint(args []string, stdout, stderr io.Writer) int {
+	flags := newFlagSet("lint", "<files...>", stderr)
+	registryPath := flags.String("registry", "", "definition file whose message fields form the field registry")
+	rules := flags.String("rules", "", "style rules to check, separated by commas, or all")
+	if !parseFlags(flags, args, 1, -1) {
+		return exitUsage
+	}
+	config, ok := lintConfig(*rules, stderr)
+	if !ok {
+		return exitUsage
+	}
+	registry, err := loadRegistry(*registryPath)
+	if err != nil {
+		report(stderr, *registryPath, err)
+		return exitProblems
+	}
+	specs, ok := loadAll(flags.Args(), nil, stderr)
+	values := make([]*proto3.Spec, 0, len(specs))
+	for _, l := range specs {
+		values = append(values, l.spec)
+	}
+	if err := registry.Validate(values...); err != nil {
+		report(stderr, "", err)
+		ok = false
+	}
+	files := make([]proto3.DescriptorFile, 0, len(specs))
+	for _, f := range newProject(specs).Files {
+		files = append(files, proto3.DescriptorFile{Name: f.Path(), Spec: f.Spec})
+	}
+	resolver := proto3.NewResolver(files...)
+	linter := lint.New(config)
+	for _, l := range specs {
+		if err := resolver.Validate(l.spec); err != nil {
+			report(stderr, l.path, err)
+			ok = ok && !hasErrors(err)
+		}
+		if err := linter.Lint(l.path, l.spec); err != nil {
+			report(stderr, l.path, err)
+			ok = ok && !hasErrors(err)
+		}
+	}
+	if !ok {
+		return exitProblems
+	}
+	return exitOK
+}
+
+// runDiff reports the changes between a previous and a current definition, failing when any change breaks
+// the wire format.
+func runDiff(args []string, stdout, stderr io.Writer) int {
+	flags := newFlagSet("diff", "<previous> <current>", stderr)
+	strict := flags.Bool("strict", false, "also fail on changes that are wire-compatible")
+	if !parseFlags(flags, args, 2, 2) {
+		return exitUsage
+	}
+	previousPath, currentPath := flags.Arg(0), flags.Arg(1)
+	previous, err := loadSpec(previousPath)
+	if err != nil {
+		report(stderr, previousPath, err)
+		return exitProblems
+	}
+	current, err := loadSpec(currentPath)
+	if err != nil {
+		report(stderr, currentPath, err)
+		return exitProblems
+	}
+
+	changes := proto3.Compare(previous, current)
+	for _, c := range changes {
+		fmt.Fprintf(stdout, "%s: %s: %s\n", currentPath, c.Severity, c)
+	}
+	if changes.Breaking() || (*strict && len(changes) > 0) {
+		return exitProblems
+	}
+	return exitOK
+}
+
+// runFmt prints each definition in canonical form, or rewrites the files in place.
+func runFmt(args []string, stdout, stderr io.Writer) int {
+	flags := newFlagSet("fmt", "<files...>", stderr)
+	write := flags.Bool("w", false, "write the result to the source .proto file instead of printing it")
+	style, order := formatFlags(flags, "declared")
+	if !parseFlags(flags, args, 1, -1) {
+		return exitUsage
+	}
+	format, ok := lookupFormat(*style, *order, stderr)
+	if !ok {
+		return exitUsage
+	}
+	status := exitOK
+	for _, path := range flags.Args() {
+		spec, err := loadSpec(path)
+		if err != nil {
+			report(stderr, path, err)
+			status = exitProblems
+			continue
+		}
+		v, err := writeSpec(spec, format)
+		if err != nil {
+			report(stderr, path, err)
+			status = exitProblems
+			continue
+		}
+		if !*write {
+			fmt.Fprint(stdout, v)
+			continue
+		}
+		if filepath.Ext(path) != ".proto" {
+			fmt.Fprintf(stderr, "%s: fmt -w can only rewrite .proto files\n", path)
+			status = exitProblems
+			continue
+		}
+		if lost, err := lostContents(spec, v); err != nil || len(lost) > 0 {
+			if err == nil {
+				err = fmt.Errorf("the result would lose %s", strings.Join(lost, ", "))
+			}
+			fmt.Fprintf(stderr, "%s: fmt -w did not rewrite the file: %s\n", path, err)
+			status = exitProblems
+			continue
+		}
+		src, err := ioutil.ReadFile(path)
+		if err == nil && !bytes.Equal(src, []byte(v)) {
+			err = ioutil.WriteFile(path, []byte(v), 0644)
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "protogen: %s\n", err)
+			status = exitProblems
+		}
+	}
+	return status
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/muxinc/protogen/proto3"
+)
+
+// contents counts the words of the comments of a spec and its elements, each described by its path and
+// its declaration without comments, so that a spec written in another layout can be checked for anything
+// it leaves out.
+type contents map[string]int
+
+// specContents returns the contents of a spec.
+func specContents(spec *proto3.Spec) contents {
+	c := make(contents)
+	c.comment(spec.FileComment)
+	c.add("package %s", spec.Package)
+	c.add("option go_package = %q", spec.GoPackage)
+	c.add("option java_package = %q", spec.JavaPackage)
+	for _, i := range spec.Imports {
+		c.add("import %q", i)
+	}
+	c.options(spec.Package, spec.Options)
+	c.messages(spec.Package, spec.Messages)
+	c.enums(spec.Package, spec.Enums)
+	c.extends(spec.Package, spec.Extends)
+	for _, s := range spec.Services {
+		path := joinPath(spec.Package, string(s.Name))
+		c.add("service %s", path)
+		c.comment(s.Comment)
+		c.options(path, s.Options)
+		for _, m := range s.Methods {
+			c.comment(m.Comment)
+			m.Comment = ""
+			v, err := m.Write()
+			c.declaration(path, v, err)
+		}
+	}
+	return c
+}
+
+// lost returns the contents of c that other holds fewer of, in order.
+func (c contents) lost(other contents) []string {
+	var lost []string
+	for k, n := range c {
+		if other[k] < n {
+			lost = append(lost, k)
+		}
+	}
+	sort.Strings(lost)
+	return lost
+}
+
+func (c contents) add(format string, args ...interface{}) {
+	c[fmt.Sprintf(format, args...)]++
+}
+
+func (c contents) comment(text string) {
+	for _, word := range strings.Fields(text) {
+		c.add("comment %q", word)
+	}
+}
+
+func (c contents) declaration(path, text string, err error) {
+	if err != nil {
+		text = err.Error()
+	}
+	c.add("%s: %s", path, text)
+}
+
+func (c contents) options(path string, options []proto3.Option) {
+	for _, o := range options {
+		c.comment(o.Comment)
+		v, err := o.Write()
+		c.declaration(path, "option "+v, err)
+	}
+}
+
+func (c contents) reserved(path string, reserved []proto3.Reserved) {
+	for _, r := range reserved {
+		v, err := r.Write()
+		c.declaration(path, "reserved "+v, err)
+	}
+}
+
+func (c contents) fields(path string, fields []proto3.Field) {
+	for _, f := range fields {
+		switch v := f.(type) {
+		case proto3.ScalarField:
+			c.comment(v.Comment)
+			v.Comment = ""
+			f = v
+		case proto3.CustomField:
+			c.comment(v.Comment)
+			v.Comment = ""
+			f = v
+		case proto3.MapField:
+			c.comment(v.Comment)
+			v.Comment = ""
+			f = v
+		case proto3.CustomMapField:
+			c.comment(v.Comment)
+			v.Comment = ""
+			f = v
+		}
+		v, err := f.Write()
+		c.declaration(path, v, err)
+	}
+}
+
+func (c contents) messages(prefix string, messages []proto3.Message) {
+	for _, m := range messages {
+		path := joinPath(prefix, m.Name)
+		c.add("message %s", path)
+		c.comment(m.Comment)
+		c.options(path, m.Options)
+		c.reserved(path, m.ReservedValues)
+		c.fields(path, m.Fields)
+		for _, o := range m.OneOfs {
+			c.add("oneof %s.%s", path, o.Name)
+			c.comment(o.Comment)
+			c.options(joinPath(path, string(o.Name)), o.Options)
+			c.fields(joinPath(path, string(o.Name)), o.Fields)
+		}
+		c.messages(path, m.Messages)
+		c.enums(path, m.Enums)
+		c.extends(path, m.Extends)
+	}
+}
+
+func (c contents) enums(prefix string, enums []proto3.Enum) {
+	for _, e := range enums {
+		path := joinPath(prefix, string(e.Name))
+		c.add("enum %s allow_alias=%t", path, e.AllowAlias)
+		c.comment(e.Comment)
+		c.options(path, e.Options)
+		c.reserved(path, e.ReservedValues)
+		for _, v := range e.Values {
+			c.add("%s: %s = %d", path, v.Name, v.Tag)
+			c.comment(v.Comment)
+			c.options(joinPath(path, string(v.Name)), v.Options)
+		}
+	}
+}
+
+func (c contents) extends(prefix string, extends []proto3.Extend) {
+	for _, e := range extends {
+		c.add("extend %s %s", prefix, e.Typing)
+		c.comment(e.Comment)
+		c.fields(joinPath(prefix, e.Typing), e.Fields)
+	}
+}
+
+// joinPath joins the name of an element to the path of the element it is declared in.
+func joinPath(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/muxinc/protogen/proto3"
+)
+
+// loaders parse a definition file into a spec, keyed by file extension.
+var loaders = map[string]func(r io.Reader) (*proto3.Spec, error){
+	".proto": proto3.Parse,
+	".yaml":  proto3.LoadDefinition,
+	".yml":   proto3.LoadDefinition,
+	".json":  proto3.LoadDefinition,
+	".pb":    loadDescriptorSet,
+	".binpb": loadDescriptorSet,
+}
+
+// loadSpec reads the definition file at path using the loader for its extension.
+func loadSpec(path string) (*proto3.Spec, error) {
+	load, ok := loaders[filepath.Ext(path)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported definition file extension %q", filepath.Ext(path))
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return load(f)
+}
+
+// loadDescriptorSet reads the spec of the last file in a FileDescriptorSet, which is the file protoc was
+// asked to compile when the set also holds its imports.
+func loadDescriptorSet(r io.Reader) (*proto3.Spec, error) {
+	files, err := proto3.LoadDescriptorSet(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("descriptor set holds no proto3 files")
+	}
+	return files[len(files)-1].Spec, nil
+}
+
+// loadRegistry builds a field registry from a YAML or JSON field catalog, or from every field declared in
+// the messages of a .proto file. Without a path, the registry is empty.
+func loadRegistry(path string) (*proto3.FieldRegistry, error) {
+	registry := proto3.NewFieldRegistry()
+	if path == "" {
+		return registry, nil
+	}
+	if filepath.Ext(path) != ".proto" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return proto3.LoadFieldRegistry(f)
+	}
+	spec, err := loadSpec(path)
+	if err != nil {
+		return nil, err
+	}
+	var register func(messages []proto3.Message) error
+	register = func(messages []proto3.Message) error {
+		for _, m := range messages {
+			if err := registry.Register(m.Fields...); err != nil {
+				return err
+			}
+			for _, o := range m.OneOfs {
+				if err := registry.Register(o.Fields...); err != nil {
+					return err
+				}
+			}
+			if err := register(m.Messages); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := register(spec.Messages); err != nil {
+		return nil, err
+	}
+	return registry, nil
+}
+
+// report writes the problems described by err, one per line, each prefixed with the file it was found in.
+// Problems that span several files are reported without a file.
+func report(w io.Writer, file string, err error) {
+	if file == "" {
+		file = "protogen"
+	}
+	switch err := err.(type) {
+	case proto3.ValidationErrors:
+		for _, e := range err {
+			position := file
+			if e.Line > 0 {
+				position = fmt.Sprintf("%s:%d:%d", file, e.Line, e.Column)
+			}
+			if e.Path == "" {
+				fmt.Fprintf(w, "%s: %s: %s\n", position, e.Severity, e.Message)
+			} else {
+				fmt.Fprintf(w, "%s: %s: %s: %s\n", position, e.Severity, e.Path, e.Message)
+			}
+		}
+	case *proto3.ParseError:
+		fmt.Fprintf(w, "%s:%s\n", file, err)
+	default:
+		fmt.Fprintf(w, "%s: %s\n", file, err)
+	}
+}
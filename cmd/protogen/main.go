@@ -0,0 +1,106 @@
+// Command protogen validates, formats, compares and generates Protobuf specifications from definition
+// files, so that specifications can be maintained without writing a Go program.
+//
+// Usage:
+//
+//	protogen <command> [flags] <files...>
+//
+// The commands are:
+//
+//	generate  write each definition as a .proto file to the directory of its package
+//	validate  report problems with each definition
+//	lint      report problems with each definition, unresolved types and inconsistencies between them
+//	diff      report the changes between a previous and a current definition
+//	fmt       print each definition in canonical form
+//
+// Definitions are read from .proto files, from YAML (.yaml, .yml) and JSON (.json) files in the format
+// described by proto3.LoadDefinition, or from the last file of a binary FileDescriptorSet (.pb, .binpb).
+// When a field registry is given with -registry, it is read as a field catalog in the format described by
+// proto3.LoadFieldRegistry, or for a .proto file from every field declared in its messages. Fields of the
+// same name in the definitions must match the registered ones.
+//
+// generate writes each definition to a .proto file named after it within a directory for each component of
+// its package, such as mux/events/events.proto for events.yaml of package mux.events, and imports the
+// other definitions it refers to by those paths.
+//
+// lint resolves the type references of each definition against the definitions it imports, which are
+// known by the path of the .proto file generate writes for them, and warns about imports that are unused
+// or missing.
+//
+// fmt and generate write .proto files in the layout named by -style: default, or buf for the layout of buf
+// format. The elements of messages and enums are grouped by kind, or with -order declared kept in the order
+// they are declared in, or with -order tag written with fields and oneofs in order of tag. fmt keeps them
+// in declaration order unless -order is given, and fmt -w leaves a file unchanged and fails when the result
+// would lose any of its comments or elements.
+//
+// lint also checks the style rules of package proto3/lint named by -rules, separated by commas, or every
+// rule for -rules all.
+//
+// generate can also write a FileDescriptorSet of the definitions with -descriptor_set_out, for tools that
+// consume compiled descriptors.
+//
+// protogen exits with status 1 when problems are found and status 2 when it is invoked incorrectly.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// Exit statuses
+const (
+	exitOK       = 0
+	exitProblems = 1
+	exitUsage    = 2
+)
+
+// command runs a subcommand with its arguments and returns the exit status.
+type command struct {
+	name    string
+	summary string
+	run     func(args []string, stdout, stderr io.Writer) int
+}
+
+var commands = []command{
+	{"generate", "write each definition as a .proto file to the directory of its package", runGenerate},
+	{"validate", "report problems with each definition", runValidate},
+	{"lint", "report problems with each definition, unresolved types and inconsistencies between them", runLint},
+	{"diff", "report the changes between a previous and a current definition", runDiff},
+	{"fmt", "print each definition in canonical form", runFmt},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run dispatches to the subcommand named by the first argument.
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return exitUsage
+	}
+	for _, c := range commands {
+		if c.name == args[0] {
+			return c.run(args[1:], stdout, stderr)
+		}
+	}
+	if args[0] == "help" || args[0] == "-h" || args[0] == "-help" {
+		usage(stdout)
+		return exitOK
+	}
+	fmt.Fprintf(stderr, "protogen: unknown command %q\n", args[0])
+	usage(stderr)
+	return exitUsage
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: protogen <command> [flags] <files...>")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-9s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `Run "protogen <command> -h" for the flags of a command.`)
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/muxinc/protogen/proto3"
+)
+
+const beaconProto = `syntax = "proto3";
+package mux;
+
+message Beacon {
+  string view_id = 1;
+  int32 seq = 2;
+
+}
+`
+
+// writeFiles creates a temporary directory holding the given files, keyed by name.
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "protogen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, src := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// descriptorSet encodes a FileDescriptorSet holding a single spec.
+func descriptorSet(t *testing.T, src string) string {
+	spec, err := proto3.Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	set, err := proto3.DescriptorSet(proto3.DescriptorFile{Name: "beacon.proto", Spec: spec})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(set)
+}
+
+func TestRun(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"beacon.proto":   beaconProto,
+		"event.proto":    "syntax = \"proto3\";\npackage mux;\nmessage Event { bytes view_id = 1; }\n",
+		"invalid.proto":  "syntax = \"proto3\";\nmessage Beacon { string view_id = 0; }\n",
+		"broken.proto":   "syntax = \"proto3\";\nmessage Beacon {\n  string view_id = ;\n}\n",
+		"removed.proto":  "syntax = \"proto3\";\npackage mux;\nmessage Beacon { string view_id = 1; }\n",
+		"widened.proto":  "syntax = \"proto3\";\npackage mux;\nmessage Beacon { string view_id = 1; int64 seq = 2; }\n",
+		"registry.proto": "syntax = \"proto3\";\nmessage Fields { string view_id = 1; }\n",
+		"ordered.proto":  "syntax = \"proto3\";\npackage mux;\nmessage Beacon { string view_id = 1; message Event { string name = 1; } }\n",
+		"beacon.yaml":    "package: mux\nmessages:\n  - name: Beacon\n    fields:\n      - {name: view_id, type: string, tag: 1}\n",
+		"invalid.yaml":   "package: mux\nmessages:\n  - name: Beacon\n    fields:\n      - {name: view_id, type: string, tag: 0}\n",
+		"fields.yaml":    "fields:\n  - {name: view_id, type: bytes}\n",
+		"beacon.pb":      descriptorSet(t, beaconProto),
+		"session.proto":  "syntax = \"proto3\";\npackage mux;\nimport \"mux/event.proto\";\nmessage Session { repeated Event events = 1; Evnet last = 2; }\n",
+		"unused.proto":   "syntax = \"proto3\";\npackage mux;\nimport \"mux/beacon.proto\";\nmessage Session { string id = 1; }\n",
+	})
+	defer os.RemoveAll(dir)
+	path := func(name string) string { return filepath.Join(dir, name) }
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantStatus int
+		wantOutput string
+	}{
+		{"No command", nil, exitUsage, "usage: protogen"},
+		{"Unknown command", []string{"build"}, exitUsage, `unknown command "build"`},
+		{"Validate", []string{"validate", path("beacon.proto")}, exitOK, ""},
+		{"Validate invalid spec", []string{"validate", path("invalid.proto")}, exitProblems, "invalid.proto: error: Beacon.view_id: Field must have a tag"},
+		{"Validate syntax error", []string{"validate", path("broken.proto")}, exitProblems, "broken.proto:3:"},
+		{"Validate unsupported file", []string{"validate", path("beacon.txt")}, exitProblems, "unsupported definition file extension"},
+		{"Validate against registry", []string{"validate", "-registry", path("registry.proto"), path("event.proto")}, exitProblems, "mux.Event.view_id: Field has type bytes"},
+		{"Validate YAML definition", []string{"validate", path("beacon.yaml")}, exitOK, ""},
+		{"Validate invalid YAML definition", []string{"validate", path("invalid.yaml")}, exitProblems, "invalid.yaml:5:9: error: mux.Beacon.view_id: Field must have a tag"},
+		{"Validate against YAML registry", []string{"validate", "-registry", path("fields.yaml"), path("beacon.yaml")}, exitProblems, "mux.Beacon.view_id: Field has type string but is registered with type bytes"},
+		{"Validate descriptor set", []string{"validate", path("beacon.pb")}, exitOK, ""},
+		{"Diff descriptor set", []string{"diff", path("beacon.pb"), path("removed.proto")}, exitProblems, "error: mux.Beacon.seq: Field seq was removed"},
+		{"Validate without files", []string{"validate"}, exitUsage, "usage: protogen validate"},
+		{"Lint", []string{"lint", path("beacon.proto"), path("removed.proto")}, exitOK, ""},
+		{"Lint inconsistent fields", []string{"lint", path("beacon.proto"), path("event.proto")}, exitProblems, "mux.Event.view_id: Field has type bytes but mux.Beacon.view_id has type string"},
+		{"Lint unresolved type", []string{"lint", path("event.proto"), path("session.proto")}, exitProblems, "session.proto: error: mux.Session.last: Type Evnet does not resolve"},
+		{"Lint unused import", []string{"lint", path("beacon.proto"), path("unused.proto")}, exitOK, "unused.proto: warning: mux: Import mux/beacon.proto is not used"},
+		{"Lint style rules", []string{"lint", "-rules", "field-comment,message-pascal-case", path("beacon.proto")}, exitOK, "beacon.proto: warning: mux.Beacon.view_id: Field should have a comment"},
+		{"Lint all style rules", []string{"lint", "-rules", "all", path("beacon.proto")}, exitProblems, "error: File " + path("beacon.proto") + " of package mux should be in a directory mux"},
+		{"Lint unknown style rule", []string{"lint", "-rules", "field-names", path("beacon.proto")}, exitUsage, `unknown lint rule "field-names"`},
+		{"Diff compatible", []string{"diff", path("beacon.proto"), path("widened.proto")}, exitOK, "warning: mux.Beacon.seq: Type changed"},
+		{"Diff strict", []string{"diff", "-strict", path("beacon.proto"), path("widened.proto")}, exitProblems, "warning: mux.Beacon.seq"},
+		{"Diff breaking", []string{"diff", path("beacon.proto"), path("removed.proto")}, exitProblems, "error: mux.Beacon.seq: Field seq was removed"},
+		{"Diff one file", []string{"diff", path("beacon.proto")}, exitUsage, "usage: protogen diff"},
+		{"Fmt", []string{"fmt", path("removed.proto")}, exitOK, "message Beacon {\n  string view_id = 1;\n"},
+		{"Fmt buf style", []string{"fmt", "-style", "buf", path("removed.proto")}, exitOK, "syntax = \"proto3\";\npackage mux;\nmessage Beacon {\n  string view_id = 1;\n}\n"},
+		{"Fmt unknown style", []string{"fmt", "-style", "google", path("removed.proto")}, exitUsage, `unknown style "google"`},
+		{"Fmt declared order", []string{"fmt", "-style", "buf", "-order", "declared", path("ordered.proto")}, exitOK, "message Beacon {\n  string view_id = 1;\n  message Event {\n    string name = 1;\n  }\n}\n"},
+		{"Fmt unknown order", []string{"fmt", "-order", "name", path("removed.proto")}, exitUsage, `unknown order "name"`},
+	}
+	for _, tt := range tests {
+		var output bytes.Buffer
+		status := run(tt.args, &output, &output)
+		if status != tt.wantStatus {
+			t.Errorf("%q. run() = %d, want %d\n%s", tt.name, status, tt.wantStatus, output.String())
+		}
+		if !strings.Contains(output.String(), tt.wantOutput) || (tt.wantOutput == "" && output.Len() > 0) {
+			t.Errorf("%q. run() output = %q, want %q", tt.name, output.String(), tt.wantOutput)
+		}
+	}
+}
+
+func TestRun_Generate(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"beacon.proto": strings.Replace(beaconProto, "\n\n}", "\n}", 1)})
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "out")
+
+	var output bytes.Buffer
+	if status := run([]string{"generate", "-out", out, filepath.Join(dir, "beacon.proto")}, &output, &output); status != exitOK {
+		t.Fatalf("run() = %d\n%s", status, output.String())
+	}
+	got, err := ioutil.ReadFile(filepath.Join(out, "mux", "beacon.proto"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != beaconProto {
+		t.Errorf("generated spec = %q, want %q", got, beaconProto)
+	}
+
+	set := filepath.Join(dir, "beacon.pb")
+	if status := run([]string{"generate", "-out", out, "-descriptor_set_out", set, filepath.Join(dir, "beacon.proto")}, &output, &output); status != exitOK {
+		t.Fatalf("run() = %d\n%s", status, output.String())
+	}
+	if got, err := ioutil.ReadFile(set); err != nil || !bytes.Contains(got, []byte("\x0a\x10mux/beacon.proto")) {
+		t.Errorf("descriptor set = %q, %v, want a descriptor of beacon.proto", got, err)
+	}
+}
+
+func TestRun_GeneratePackages(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"events.proto": "syntax = \"proto3\";\npackage mux.video;\nmessage Event { string name = 1; }\n",
+		"events.yaml":  "package: mux.audio\nmessages:\n  - name: Event\n    fields:\n      - {name: video, type: mux.video.Event, tag: 1}\n",
+	})
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "out")
+
+	var output bytes.Buffer
+	if status := run([]string{"generate", "-out", out, filepath.Join(dir, "events.proto"), filepath.Join(dir, "events.yaml")}, &output, &output); status != exitOK {
+		t.Fatalf("run() = %d\n%s", status, output.String())
+	}
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"mux/video/events.proto", "package mux.video;\n"},
+		{"mux/audio/events.proto", "import \"mux/video/events.proto\";\n"},
+	}
+	for _, tt := range tests {
+		got, err := ioutil.ReadFile(filepath.Join(out, filepath.FromSlash(tt.path)))
+		if err != nil || !strings.Contains(string(got), tt.want) {
+			t.Errorf("%q. generated spec = %q, %v, want %q", tt.path, got, err, tt.want)
+		}
+	}
+}
+
+func TestRun_FmtWrite(t *testing.T) {
+	src := `// Beacons
+syntax = "proto3";
+package mux;
+
+message Beacon {
+  string view_id = 1; // Unique view identifier
+  option deprecated = true; // Replaced by Session
+
+  // Events of the view
+  message Event { string name = 1; }
+  reserved 3, 5 to 9; // Retired fields
+  int64 seq = 2;
+}
+`
+	want := `// Beacons
+syntax = "proto3";
+package mux;
+
+message Beacon {
+  string view_id = 1;   // Unique view identifier
+
+  option deprecated = true;   // Replaced by Session
+
+  // Events of the view
+  message Event {
+    string name = 1;
+  }
+
+  reserved 3, 5 to 9;   // Retired fields
+
+  int64 seq = 2;
+}
+`
+	dir := writeFiles(t, map[string]string{"beacon.proto": src})
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "beacon.proto")
+
+	var output bytes.Buffer
+	if status := run([]string{"fmt", "-w", path}, &output, &output); status != exitOK {
+		t.Fatalf("run() = %d\n%s", status, output.String())
+	}
+	got, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("formatted spec = %q, want %q", got, want)
+	}
+}
+
+func TestLostContents(t *testing.T) {
+	spec, err := proto3.Parse(strings.NewReader(`syntax = "proto3";
+// Beacons
+message Beacon {
+  option deprecated = true; // Replaced
+  string view_id = 1;
+  reserved 2;
+}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{
+			name: "Nothing lost",
+			text: "syntax = \"proto3\";\nmessage Beacon {\n  reserved 2;\n  string view_id = 1;   // Beacons\n  option deprecated = true;   // Replaced\n}\n",
+		},
+		{
+			name: "Comments lost",
+			text: "syntax = \"proto3\";\nmessage Beacon {\n  option deprecated = true;\n  string view_id = 1;\n  reserved 2;\n}\n",
+			want: []string{`comment "Beacons"`, `comment "Replaced"`},
+		},
+		{
+			name: "Elements lost",
+			text: "syntax = \"proto3\";\n// Beacons\nmessage Beacon {\n  option deprecated = true;   // Replaced\n}\n",
+			want: []string{"Beacon: reserved 2", "Beacon: string view_id = 1;"},
+		},
+	}
+	for _, tt := range tests {
+		got, err := lostContents(spec, tt.text)
+		if err != nil {
+			t.Errorf("%q. lostContents() error = %v", tt.name, err)
+			continue
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("%q. lostContents() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
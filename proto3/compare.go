@@ -0,0 +1,365 @@
+package proto3
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ChangeKind is a machine-readable identifier for a class of difference between two specs.
+type ChangeKind string
+
+// Kinds of change reported by Compare
+const (
+	ChangePackage          ChangeKind = "package-changed"
+	ChangeMessageRemoved   ChangeKind = "message-removed"
+	ChangeEnumRemoved      ChangeKind = "enum-removed"
+	ChangeFieldRemoved     ChangeKind = "field-removed"
+	ChangeFieldRenamed     ChangeKind = "field-renamed"
+	ChangeTagReused        ChangeKind = "tag-reused"
+	ChangeTypeCompatible   ChangeKind = "type-changed-compatible"
+	ChangeTypeIncompatible ChangeKind = "type-changed"
+	ChangeRule             ChangeKind = "rule-changed"
+	ChangeEnumValueRemoved ChangeKind = "enum-value-removed"
+	ChangeEnumValueRenamed ChangeKind = "enum-value-renamed"
+)
+
+// Change describes a single difference between two versions of a spec that may affect existing readers or
+// writers of its messages. Changes that break the wire format have SeverityError; changes that are safe on
+// the wire but may break the JSON encoding or generated code have SeverityWarning. Path identifies the
+// element within the previous spec by its dotted, package-qualified name.
+type Change struct {
+	Path     string
+	Kind     ChangeKind
+	Severity Severity
+	Message  string
+}
+
+// Changes lists every difference found between two specs.
+type Changes []*Change
+
+// String reports the change prefixed with the path of the element it applies to.
+func (c *Change) String() string {
+	if c.Path == "" {
+		return c.Message
+	}
+	return fmt.Sprintf("%s: %s", c.Path, c.Message)
+}
+
+// Breaking reports whether any of the changes break the wire format.
+func (c Changes) Breaking() bool {
+	for _, v := range c {
+		if v.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// add records a change at the given path.
+func (c *Changes) add(path string, kind ChangeKind, severity Severity, format string, args ...interface{}) {
+	*c = append(*c, &Change{Path: path, Kind: kind, Severity: severity, Message: fmt.Sprintf(format, args...)})
+}
+
+// Compare reports the changes between a previously released spec and its current version that could break
+// compatibility with data or clients built against the previous spec: removed fields whose tag or name is
+// not reserved, reused tags, changed field types and rules, renamed fields and enum values, removed enum
+// values whose tag or name is not reserved and a changed package. Messages and enums are matched by their
+// name relative to the package, and fields and enum values by their tag. The types of fields are compared
+// by the message or enum they resolve to, so that a reference may change how it is qualified.
+func Compare(previous, current *Spec) Changes {
+	previous, current = previous.withTypeNames(), current.withTypeNames()
+	previousSymbols, symbols := compareSymbols(previous), compareSymbols(current)
+	var changes Changes
+	if previous.Package != current.Package {
+		changes.add(previous.Package, ChangePackage, SeverityError, "Package changed from %q to %q", previous.Package, current.Package)
+	}
+
+	messages := indexMessages("", current.Messages)
+	previousMessages := indexMessages("", previous.Messages)
+	names := make([]string, 0, len(previousMessages))
+	for name := range previousMessages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		path := joinPath(previous.Package, name)
+		m, ok := messages[name]
+		if !ok {
+			changes.add(path, ChangeMessageRemoved, SeverityError, "Message %s was removed", name)
+			continue
+		}
+		previousTypes := fieldTypes{symbols: previousSymbols, scope: path}
+		types := fieldTypes{symbols: symbols, scope: joinPath(current.Package, name)}
+		changes = append(changes, compareMessage(path, previousMessages[name], m, previousTypes, types)...)
+	}
+
+	enums := indexEnums("", current.Enums, current.Messages)
+	previousEnums := indexEnums("", previous.Enums, previous.Messages)
+	names = make([]string, 0, len(previousEnums))
+	for name := range previousEnums {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		path := joinPath(previous.Package, name)
+		e, ok := enums[name]
+		if !ok {
+			changes.add(path, ChangeEnumRemoved, SeverityError, "Enum %s was removed", name)
+			continue
+		}
+		changes = append(changes, compareEnum(path, previousEnums[name], e)...)
+	}
+	return changes
+}
+
+// compareSymbols returns the messages and enums that the fields of a spec can refer to: those of the spec
+// and of the well-known types it imports.
+func compareSymbols(s *Spec) *symbolTable {
+	symbols := newSymbolTable()
+	symbols.addFile("", s)
+	for _, i := range s.Imports {
+		if _, ok := wellKnownTypes[i]; ok {
+			symbols.addWellKnown(i)
+		}
+	}
+	return symbols
+}
+
+// fieldTypes resolves the types that the fields of a message refer to from within its scope, which is its
+// package-qualified name.
+type fieldTypes struct {
+	symbols *symbolTable
+	scope   string
+}
+
+// name returns the type of a field as fieldTypeName does, but with references to known messages and enums
+// fully qualified. References that do not resolve, such as those to other imports, are left as they are.
+func (t fieldTypes) name(f Field) string {
+	resolve := func(ref string) string {
+		if name, ok := t.symbols.lookup(t.scope, ref); ok {
+			return "." + name
+		}
+		return ref
+	}
+	switch f := f.(type) {
+	case CustomField:
+		return resolve(f.Typing)
+	case CustomMapField:
+		return fmt.Sprintf("map<%s, %s>", f.KeyTyping.Write(), resolve(f.ValueTyping))
+	default:
+		return fieldTypeName(f)
+	}
+}
+
+// compareMessage reports the changes to the fields of a message, which are matched by tag. The types of
+// the fields are resolved with previousTypes and types.
+func compareMessage(path string, previous, current Message, previousTypes, types fieldTypes) Changes {
+	var changes Changes
+	fields := make(map[TagType]Field)
+	names := make(map[NameType]TagType)
+	for _, f := range messageFields(current) {
+		if name, tag, ok := fieldNameTag(f); ok {
+			fields[tag] = f
+			names[name] = tag
+		}
+	}
+
+	for _, old := range messageFields(previous) {
+		oldName, tag, ok := fieldNameTag(old)
+		if !ok {
+			continue
+		}
+		fieldPath := joinPath(path, string(oldName))
+		f, exists := fields[tag]
+		if !exists {
+			switch {
+			case !reservesTag(current.ReservedValues, tag):
+				changes.add(fieldPath, ChangeFieldRemoved, SeverityError, "Field %s was removed without reserving tag %d", oldName, tag)
+			case !reservesName(current.ReservedValues, oldName):
+				changes.add(fieldPath, ChangeFieldRemoved, SeverityWarning, "Field %s was removed without reserving its name", oldName)
+			}
+			continue
+		}
+
+		name, _, _ := fieldNameTag(f)
+		if name != oldName {
+			if other, moved := names[oldName]; moved {
+				changes.add(fieldPath, ChangeTagReused, SeverityError, "Tag %d is reused by field %s while field %s moved to tag %d", tag, name, oldName, other)
+				continue
+			}
+			changes.add(fieldPath, ChangeFieldRenamed, SeverityWarning, "Field %s was renamed to %s, which changes its JSON name", oldName, name)
+		}
+		changes = append(changes, compareField(fieldPath, old, f, previousTypes, types)...)
+	}
+
+	for _, f := range messageFields(current) {
+		name, tag, ok := fieldNameTag(f)
+		if ok && reservesTag(previous.ReservedValues, tag) {
+			changes.add(joinPath(path, string(name)), ChangeTagReused, SeverityError, "Field %s uses tag %d, which was previously reserved", name, tag)
+		}
+	}
+	return changes
+}
+
+// compareField reports changes to the type and rule of a field that kept its tag.
+func compareField(path string, previous, current Field, previousTypes, types fieldTypes) Changes {
+	var changes Changes
+	oldType, newType := fieldTypeName(previous), fieldTypeName(current)
+	if previousTypes.name(previous) != types.name(current) {
+		old, oldScalar := previous.(ScalarField)
+		f, newScalar := current.(ScalarField)
+		if oldScalar && newScalar && wireCompatible(old.Typing, f.Typing) {
+			changes.add(path, ChangeTypeCompatible, SeverityWarning, "Type changed from %s to %s, which is wire-compatible but may change the JSON encoding or truncate values", oldType, newType)
+		} else {
+			changes.add(path, ChangeTypeIncompatible, SeverityError, "Type changed from %s to %s", oldType, newType)
+		}
+	}
+
+	oldRule, newRule := fieldRule(previous), fieldRule(current)
+	switch {
+	case oldRule == newRule:
+	case oldRule == Repeated || newRule == Repeated:
+		changes.add(path, ChangeRule, SeverityError, "Rule changed from %s to %s", ruleName(oldRule), ruleName(newRule))
+	default:
+		changes.add(path, ChangeRule, SeverityWarning, "Rule changed from %s to %s, which changes field presence in generated code", ruleName(oldRule), ruleName(newRule))
+	}
+	return changes
+}
+
+// compareEnum reports the changes to the values of an enum, which are matched by tag. As for fields, a
+// value may be removed once its tag is reserved.
+func compareEnum(path string, previous, current Enum) Changes {
+	var changes Changes
+	values := make(map[TagType][]NameType)
+	for _, v := range current.Values {
+		values[v.Tag] = append(values[v.Tag], v.Name)
+	}
+	for _, old := range previous.Values {
+		names, exists := values[old.Tag]
+		switch {
+		case !exists && !reservesTag(current.ReservedValues, old.Tag):
+			changes.add(joinPath(path, string(old.Name)), ChangeEnumValueRemoved, SeverityError, "Enum value %s (%d) was removed without reserving its tag", old.Name, old.Tag)
+		case !exists && !reservesName(current.ReservedValues, old.Name):
+			changes.add(joinPath(path, string(old.Name)), ChangeEnumValueRemoved, SeverityWarning, "Enum value %s was removed without reserving its name", old.Name)
+		case !exists:
+		case !containsName(names, old.Name):
+			changes.add(joinPath(path, string(old.Name)), ChangeEnumValueRenamed, SeverityWarning, "Enum value %s was renamed to %s, which changes its JSON name", old.Name, names[0])
+		}
+	}
+
+	for _, v := range current.Values {
+		if reservesTag(previous.ReservedValues, v.Tag) {
+			changes.add(joinPath(path, string(v.Name)), ChangeTagReused, SeverityError, "Enum value %s uses tag %d, which was previously reserved", v.Name, v.Tag)
+		}
+	}
+	return changes
+}
+
+// wireGroups assigns each built-in type to a group of types that share an encoding, so that a field can
+// change between types of the same group without breaking the wire format.
+// https://developers.google.com/protocol-buffers/docs/proto3#updating
+var wireGroups = map[FieldType]int{
+	Int32Type:    1,
+	Int64Type:    1,
+	UInt32Type:   1,
+	UInt64Type:   1,
+	BoolType:     1,
+	SInt32Type:   2,
+	SInt64Type:   2,
+	StringType:   3,
+	BytesType:    3,
+	Fixed32Type:  4,
+	SFixed32Type: 4,
+	Fixed64Type:  5,
+	SFixed64Type: 5,
+	DoubleType:   6,
+	FloatType:    7,
+}
+
+// wireCompatible reports whether a field can change between two built-in types without breaking the wire
+// format.
+func wireCompatible(a, b FieldType) bool {
+	ga, ok := wireGroups[a]
+	return ok && ga == wireGroups[b]
+}
+
+// indexMessages collects every message and nested message, keyed by its name relative to the package.
+func indexMessages(prefix string, messages []Message) map[string]Message {
+	index := make(map[string]Message)
+	for _, m := range messages {
+		name := joinPath(prefix, m.Name)
+		index[name] = m
+		for k, v := range indexMessages(name, m.Messages) {
+			index[k] = v
+		}
+	}
+	return index
+}
+
+// indexEnums collects every top-level and nested enum, keyed by its name relative to the package.
+func indexEnums(prefix string, enums []Enum, messages []Message) map[string]Enum {
+	index := make(map[string]Enum)
+	for _, e := range enums {
+		index[joinPath(prefix, string(e.Name))] = e
+	}
+	for _, m := range messages {
+		for k, v := range indexEnums(joinPath(prefix, m.Name), m.Enums, m.Messages) {
+			index[k] = v
+		}
+	}
+	return index
+}
+
+// messageFields returns the fields of a message, including those declared within its oneofs.
+func messageFields(m Message) []Field {
+	fields := append([]Field{}, m.Fields...)
+	for _, o := range m.OneOfs {
+		fields = append(fields, o.Fields...)
+	}
+	return fields
+}
+
+// reservesTag reports whether a tag is reserved by a single value or a range.
+func reservesTag(reserved []Reserved, tag TagType) bool {
+	for _, r := range reserved {
+		switch r := r.(type) {
+		case ReservedTagValue:
+			if r.Tag == tag {
+				return true
+			}
+		case ReservedTagRange:
+			if tag >= r.LowerTag && tag <= r.UpperTag {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// reservesName reports whether a field or enum value name is reserved.
+func reservesName(reserved []Reserved, name NameType) bool {
+	for _, r := range reserved {
+		if r, ok := r.(ReservedName); ok && r.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ruleName describes a field rule for use in a message.
+func ruleName(r FieldRule) string {
+	if r == None {
+		return "singular"
+	}
+	return strings.TrimSpace(r.Write())
+}
+
+func containsName(names []NameType, name NameType) bool {
+	for _, v := range names {
+		if v == name {
+			return true
+		}
+	}
+	return false
+}
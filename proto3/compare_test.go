@@ -0,0 +1,282 @@
+package proto3_test
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/muxinc/protogen/proto3"
+)
+
+func TestCompare(t *testing.T) {
+	beacon := func(reserved []Reserved, fields ...Field) *Spec {
+		return &Spec{Package: "mux", Messages: []Message{{Name: "Beacon", ReservedValues: reserved, Fields: fields}}}
+	}
+	viewID := ScalarField{Name: "view_id", Typing: StringType, Tag: 1}
+	seq := ScalarField{Name: "seq", Typing: Int32Type, Tag: 2}
+	tests := []struct {
+		name         string
+		previous     *Spec
+		current      *Spec
+		wantKind     ChangeKind
+		wantSeverity Severity
+	}{
+		{
+			name:     "Unchanged",
+			previous: beacon(nil, viewID, seq),
+			current:  beacon(nil, viewID, seq),
+		},
+		{
+			name:     "Field removed with tag and name reserved",
+			previous: beacon(nil, viewID, seq),
+			current:  beacon([]Reserved{ReservedTagValue{Tag: 2}, ReservedName{Name: "seq"}}, viewID),
+		},
+		{
+			name:         "Field removed without reservation",
+			previous:     beacon(nil, viewID, seq),
+			current:      beacon(nil, viewID),
+			wantKind:     ChangeFieldRemoved,
+			wantSeverity: SeverityError,
+		},
+		{
+			name:         "Field removed without reserving its name",
+			previous:     beacon(nil, viewID, seq),
+			current:      beacon([]Reserved{ReservedTagRange{LowerTag: 2, UpperTag: MaxTag}}, viewID),
+			wantKind:     ChangeFieldRemoved,
+			wantSeverity: SeverityWarning,
+		},
+		{
+			name:         "Reserved tag reused",
+			previous:     beacon([]Reserved{ReservedTagValue{Tag: 3}}, viewID),
+			current:      beacon(nil, viewID, ScalarField{Name: "player", Typing: StringType, Tag: 3}),
+			wantKind:     ChangeTagReused,
+			wantSeverity: SeverityError,
+		},
+		{
+			name:         "Tags swapped",
+			previous:     beacon(nil, viewID, seq),
+			current:      beacon(nil, ScalarField{Name: "view_id", Typing: StringType, Tag: 2}, ScalarField{Name: "seq", Typing: Int32Type, Tag: 1}),
+			wantKind:     ChangeTagReused,
+			wantSeverity: SeverityError,
+		},
+		{
+			name:         "Field renamed",
+			previous:     beacon(nil, viewID, seq),
+			current:      beacon(nil, viewID, ScalarField{Name: "sequence", Typing: Int32Type, Tag: 2}),
+			wantKind:     ChangeFieldRenamed,
+			wantSeverity: SeverityWarning,
+		},
+		{
+			name:         "Wire-compatible type change",
+			previous:     beacon(nil, viewID, seq),
+			current:      beacon(nil, viewID, ScalarField{Name: "seq", Typing: Int64Type, Tag: 2}),
+			wantKind:     ChangeTypeCompatible,
+			wantSeverity: SeverityWarning,
+		},
+		{
+			name:         "Breaking type change",
+			previous:     beacon(nil, viewID, seq),
+			current:      beacon(nil, viewID, ScalarField{Name: "seq", Typing: SInt32Type, Tag: 2}),
+			wantKind:     ChangeTypeIncompatible,
+			wantSeverity: SeverityError,
+		},
+		{
+			name:         "Scalar changed to message",
+			previous:     beacon(nil, viewID, seq),
+			current:      beacon(nil, viewID, CustomField{Name: "seq", Typing: "Sequence", Tag: 2}),
+			wantKind:     ChangeTypeIncompatible,
+			wantSeverity: SeverityError,
+		},
+		{
+			name:         "Field made repeated",
+			previous:     beacon(nil, viewID, seq),
+			current:      beacon(nil, viewID, ScalarField{Name: "seq", Typing: Int32Type, Rule: Repeated, Tag: 2}),
+			wantKind:     ChangeRule,
+			wantSeverity: SeverityError,
+		},
+		{
+			name:         "Field made optional",
+			previous:     beacon(nil, viewID, seq),
+			current:      beacon(nil, viewID, ScalarField{Name: "seq", Typing: Int32Type, Rule: Optional, Tag: 2}),
+			wantKind:     ChangeRule,
+			wantSeverity: SeverityWarning,
+		},
+		{
+			name:         "Package changed",
+			previous:     beacon(nil, viewID),
+			current:      &Spec{Package: "mux.data", Messages: beacon(nil, viewID).Messages},
+			wantKind:     ChangePackage,
+			wantSeverity: SeverityError,
+		},
+		{
+			name:         "Message removed",
+			previous:     beacon(nil, viewID),
+			current:      &Spec{Package: "mux", Messages: []Message{{Name: "Event", Fields: []Field{viewID}}}},
+			wantKind:     ChangeMessageRemoved,
+			wantSeverity: SeverityError,
+		},
+	}
+	for _, tt := range tests {
+		changes := Compare(tt.previous, tt.current)
+		if tt.wantKind == "" {
+			if len(changes) != 0 {
+				t.Errorf("%q. Compare() = %v, want no changes", tt.name, changes)
+			}
+			continue
+		}
+		matches := len(changes) > 0
+		for _, c := range changes {
+			matches = matches && c.Kind == tt.wantKind && c.Severity == tt.wantSeverity
+		}
+		if !matches {
+			t.Errorf("%q. Compare() = %v, want only %s changes with severity %s", tt.name, changes, tt.wantKind, tt.wantSeverity)
+		}
+		if changes.Breaking() != (tt.wantSeverity == SeverityError) {
+			t.Errorf("%q. Changes.Breaking() = %v", tt.name, changes.Breaking())
+		}
+	}
+}
+
+func TestCompare_qualifiedTypes(t *testing.T) {
+	beacon := func(typing string) *Spec {
+		return &Spec{
+			Package: "mux",
+			Messages: []Message{
+				{
+					Name:     "Beacon",
+					Messages: []Message{{Name: "Event"}},
+					Fields: []Field{
+						CustomField{Name: "event", Typing: typing, Tag: 1},
+						CustomMapField{Name: "events", KeyTyping: StringType, ValueTyping: typing, Tag: 2},
+					},
+				},
+				{Name: "Event"},
+			},
+		}
+	}
+	tests := []struct {
+		previous, current string
+		wantChanges       int
+	}{
+		{"Event", "Beacon.Event", 0},
+		{"Event", ".mux.Beacon.Event", 0},
+		{"Beacon.Event", "mux.Beacon.Event", 0},
+		{"Event", ".mux.Event", 2},
+		{"Beacon.Event", "Other", 2},
+	}
+	for _, tt := range tests {
+		if changes := Compare(beacon(tt.previous), beacon(tt.current)); len(changes) != tt.wantChanges {
+			t.Errorf("%s to %s. Compare() = %v, want %d changes", tt.previous, tt.current, changes, tt.wantChanges)
+		}
+	}
+
+	timestamp := func(typing string) *Spec {
+		return &Spec{
+			Package:  "mux",
+			Imports:  []ImportType{"google/protobuf/timestamp.proto"},
+			Messages: []Message{{Name: "Beacon", Fields: []Field{CustomField{Name: "at", Typing: typing, Tag: 1}}}},
+		}
+	}
+	if changes := Compare(timestamp("google.protobuf.Timestamp"), timestamp(".google.protobuf.Timestamp")); len(changes) != 0 {
+		t.Errorf("Compare() = %v, want no changes", changes)
+	}
+}
+
+func TestCompare_Enums(t *testing.T) {
+	previous := &Spec{Package: "mux", Messages: []Message{{
+		Name:  "Beacon",
+		Enums: []Enum{{Name: "Kind", Values: []EnumValue{{Name: "UNKNOWN", Tag: 0}, {Name: "VIEW", Tag: 1}, {Name: "AD", Tag: 2}}}},
+	}}}
+	current := &Spec{Package: "mux", Messages: []Message{{
+		Name:  "Beacon",
+		Enums: []Enum{{Name: "Kind", Values: []EnumValue{{Name: "UNKNOWN", Tag: 0}, {Name: "PAGE_VIEW", Tag: 1}}}},
+	}}}
+
+	changes := Compare(previous, current)
+	want := []struct {
+		path string
+		kind ChangeKind
+	}{
+		{"mux.Beacon.Kind.VIEW", ChangeEnumValueRenamed},
+		{"mux.Beacon.Kind.AD", ChangeEnumValueRemoved},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("Compare() = %v, want %d changes", changes, len(want))
+	}
+	for i, w := range want {
+		if changes[i].Path != w.path || changes[i].Kind != w.kind {
+			t.Errorf("Compare()[%d] = %s %s, want %s %s", i, changes[i].Path, changes[i].Kind, w.path, w.kind)
+		}
+	}
+}
+
+func TestCompare_enumReserved(t *testing.T) {
+	kind := func(reserved []Reserved, values ...EnumValue) *Spec {
+		return &Spec{Package: "mux", Enums: []Enum{{Name: "Kind", ReservedValues: reserved, Values: values}}}
+	}
+	unknown, ad := EnumValue{Name: "UNKNOWN", Tag: 0}, EnumValue{Name: "AD", Tag: 2}
+	tests := []struct {
+		name         string
+		previous     *Spec
+		current      *Spec
+		wantKind     ChangeKind
+		wantSeverity Severity
+	}{
+		{
+			name:     "Value removed with tag and name reserved",
+			previous: kind(nil, unknown, ad),
+			current:  kind([]Reserved{ReservedTagRange{LowerTag: 2, UpperTag: MaxTag}, ReservedName{Name: "AD"}}, unknown),
+		},
+		{
+			name:         "Value removed without reserving its name",
+			previous:     kind(nil, unknown, ad),
+			current:      kind([]Reserved{ReservedTagValue{Tag: 2}}, unknown),
+			wantKind:     ChangeEnumValueRemoved,
+			wantSeverity: SeverityWarning,
+		},
+		{
+			name:         "Reserved tag reused",
+			previous:     kind([]Reserved{ReservedTagValue{Tag: 2}}, unknown),
+			current:      kind(nil, unknown, ad),
+			wantKind:     ChangeTagReused,
+			wantSeverity: SeverityError,
+		},
+	}
+	for _, tt := range tests {
+		changes := Compare(tt.previous, tt.current)
+		if tt.wantKind == "" {
+			if len(changes) != 0 {
+				t.Errorf("%q. Compare() = %v, want no changes", tt.name, changes)
+			}
+			continue
+		}
+		if len(changes) != 1 || changes[0].Kind != tt.wantKind || changes[0].Severity != tt.wantSeverity {
+			t.Errorf("%q. Compare() = %v, want a %s change with severity %s", tt.name, changes, tt.wantKind, tt.wantSeverity)
+		}
+	}
+}
+
+func ExampleCompare() {
+	previous := &Spec{
+		Package: "mux",
+		Messages: []Message{{Name: "Beacon", Fields: []Field{
+			ScalarField{Name: "view_id", Typing: StringType, Tag: 1},
+			ScalarField{Name: "seq", Typing: Int32Type, Tag: 2},
+			ScalarField{Name: "player", Typing: StringType, Tag: 3},
+		}}},
+	}
+	current := &Spec{
+		Package: "mux",
+		Messages: []Message{{Name: "Beacon", Fields: []Field{
+			ScalarField{Name: "view_id", Typing: StringType, Tag: 1},
+			ScalarField{Name: "seq", Typing: Int64Type, Tag: 2},
+		}}},
+	}
+
+	for _, c := range Compare(previous, current) {
+		fmt.Printf("%s [%s] %s\n", c.Severity, c.Kind, c)
+	}
+
+	// Output:
+	// warning [type-changed-compatible] mux.Beacon.seq: Type changed from int32 to int64, which is wire-compatible but may change the JSON encoding or truncate values
+	// error [field-removed] mux.Beacon.player: Field player was removed without reserving tag 3
+}
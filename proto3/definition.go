@@ -0,0 +1,889 @@
+package proto3
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// LoadDefinition reads a spec from a YAML or JSON definition and validates it. Syntax errors and values of
+// the wrong kind are reported as a *ParseError, and problems found by Spec.Validate as ValidationErrors
+// whose Line and Column locate the offending element in the definition. The schema mirrors Spec:
+//
+//	comment: Beacon messages         # Spec.FileComment
+//	package: mux
+//	go_package: github.com/muxinc/beacon
+//	java_package: com.mux.beacon
+//	imports: [google/protobuf/timestamp.proto]
+//	options: {java_multiple_files: true}
+//	enums: [...]                     # as within messages
+//	messages:
+//	  - name: Beacon
+//	    comment: A single beacon
+//	    options: {deprecated: true}
+//	    reserved:
+//	      - name: player_id
+//	      - tag: 4
+//	      - {from: 9, to: max, comment: Retired fields}
+//	    fields:
+//	      - {name: view_id, type: string, tag: 1, comment: Unique view identifier}
+//	      - {name: events, type: Event, tag: 2, rule: repeated}
+//	      - {name: tags, key: string, value: string, tag: 3, options: {deprecated: true}}
+//	    oneofs:
+//	      - name: source
+//	        fields: [...]
+//	    order:                       # declaration order, by kind and index within the list of the kind
+//	      - {kind: field, index: 0}
+//	      - {kind: blank, index: 0}  # layout marker: blank, comma or trailing
+//	      - {kind: oneof, index: 0}
+//	    enums:
+//	      - name: Kind
+//	        allow_alias: false
+//	        reserved: [{tag: 2}, {name: SEEN}] # as for messages
+//	        values: [{name: UNKNOWN, tag: 0}, {name: VIEW, tag: 1}]
+//	    messages: [...]              # nested messages
+//	    extends: [...]               # as at the top level
+//	extends:
+//	  - type: google.protobuf.FieldOptions
+//	    fields: [{name: field_owner, type: string, tag: 50000}]
+//	services:
+//	  - name: Tracker
+//	    methods:
+//	      - {name: Track, request: Beacon, response: Ack, streaming: client}
+//	    order: [...]                 # as for messages, and likewise for oneofs and the spec
+//
+// Each field has a kind of scalar, custom, map or custom-map, which is inferred from its type, key and
+// value when it is omitted. Rules are repeated or optional, and streaming modes are unary, client, server or
+// bidi. Option values that are quoted are strings, except that any value can be given to an option of a
+// built-in string type. Unquoted values are booleans, numbers or identifiers, and mappings are aggregates.
+func LoadDefinition(r io.Reader) (*Spec, error) {
+	src, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	root, err := parseYAML(src)
+	if err != nil {
+		return nil, err
+	}
+	d := &definitionDecoder{root: root, nodes: make(map[string]*yamlNode)}
+	spec, err := d.spec(root)
+	if err != nil {
+		return nil, err
+	}
+	if err := spec.Validate(); err != nil {
+		return nil, d.locate(err)
+	}
+	return spec, nil
+}
+
+// LoadFieldRegistry reads a catalog of field definitions from a YAML or JSON document of the form
+//
+//	fields:
+//	  - {name: view_id, type: string, comment: Unique view identifier}
+//
+// and registers each of them. Fields are written as in LoadDefinition, and their tags may be omitted.
+func LoadFieldRegistry(r io.Reader) (*FieldRegistry, error) {
+	src, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	root, err := parseYAML(src)
+	if err != nil {
+		return nil, err
+	}
+	d := &definitionDecoder{root: root, nodes: make(map[string]*yamlNode)}
+	v, err := d.mapping(root, "field catalog", "fields")
+	if err != nil {
+		return nil, err
+	}
+	items, err := d.sequence(v["fields"], "fields")
+	if err != nil {
+		return nil, err
+	}
+	registry := NewFieldRegistry()
+	for _, n := range items {
+		f, err := d.field("", n)
+		if err != nil {
+			return nil, err
+		}
+		if err := registry.Register(f); err != nil {
+			return nil, d.errorf(n, "%s", err)
+		}
+	}
+	return registry, nil
+}
+
+// definitionDecoder converts the nodes of a definition into a Spec, recording the node of every element
+// by its package-qualified path so that validation problems can be located in the source.
+type definitionDecoder struct {
+	root  *yamlNode
+	nodes map[string]*yamlNode
+}
+
+// locate sets the position of each validation problem to that of the closest enclosing element.
+func (d *definitionDecoder) locate(err error) error {
+	errs, ok := err.(ValidationErrors)
+	if !ok {
+		return err
+	}
+	located := make(ValidationErrors, 0, len(errs))
+	for _, e := range errs {
+		n := d.root
+		for path := e.Path; ; {
+			if v, found := d.nodes[path]; found {
+				n = v
+				break
+			}
+			i := strings.LastIndex(path, ".")
+			if i < 0 {
+				break
+			}
+			path = path[:i]
+		}
+		v := *e
+		v.Line, v.Column = n.line, n.column
+		located = append(located, &v)
+	}
+	return located
+}
+
+func (d *definitionDecoder) errorf(n *yamlNode, format string, args ...interface{}) error {
+	return &ParseError{Line: n.line, Column: n.column, Message: fmt.Sprintf(format, args...)}
+}
+
+// mapping checks that a node is a mapping using only the given keys, and returns its non-null values by key.
+func (d *definitionDecoder) mapping(n *yamlNode, what string, keys ...string) (map[string]*yamlNode, error) {
+	if n.kind != yamlMapping {
+		return nil, d.errorf(n, "%s must be a mapping", what)
+	}
+	values := make(map[string]*yamlNode)
+	for i, k := range n.keys {
+		if !containsString(keys, k.value) {
+			return nil, d.errorf(k, "unknown key %q in %s; expected one of %s", k.value, what, strings.Join(keys, ", "))
+		}
+		if !n.values[i].isNull() {
+			values[k.value] = n.values[i]
+		}
+	}
+	return values, nil
+}
+
+// sequence returns the items of a sequence node, or nothing for a missing node.
+func (d *definitionDecoder) sequence(n *yamlNode, what string) ([]*yamlNode, error) {
+	if n == nil {
+		return nil, nil
+	}
+	if n.kind != yamlSequence {
+		return nil, d.errorf(n, "%s must be a list", what)
+	}
+	return n.values, nil
+}
+
+// str returns the value of a scalar node, or an empty string for a missing node.
+func (d *definitionDecoder) str(n *yamlNode, what string) (string, error) {
+	if n == nil {
+		return "", nil
+	}
+	if n.kind != yamlScalar {
+		return "", d.errorf(n, "%s must be a string", what)
+	}
+	return n.value, nil
+}
+
+// boolean returns the value of an unquoted true or false scalar, or false for a missing node.
+func (d *definitionDecoder) boolean(n *yamlNode, what string) (bool, error) {
+	if n == nil {
+		return false, nil
+	}
+	if n.kind != yamlScalar || n.quoted || (n.value != "true" && n.value != "false") {
+		return false, d.errorf(n, "%s must be true or false", what)
+	}
+	return n.value == "true", nil
+}
+
+// tag returns the value of an unquoted integer scalar, or zero for a missing node. When allowMax is set
+// the value may also be max, which may be quoted as JSON requires.
+func (d *definitionDecoder) tag(n *yamlNode, what string, allowMax bool) (TagType, error) {
+	if n == nil {
+		return 0, nil
+	}
+	if n.kind == yamlScalar && allowMax && n.value == "max" {
+		return MaxTag, nil
+	}
+	if n.kind != yamlScalar || n.quoted {
+		return 0, d.errorf(n, "%s must be an integer", what)
+	}
+	v, err := strconv.ParseInt(n.value, 10, 32)
+	if err != nil {
+		return 0, d.errorf(n, "%s must be a 32-bit integer", what)
+	}
+	return TagType(v), nil
+}
+
+// spec decodes the top level of a definition.
+func (d *definitionDecoder) spec(n *yamlNode) (*Spec, error) {
+	v, err := d.mapping(n, "spec", "comment", "package", "go_package", "java_package", "imports", "options",
+		"messages", "enums", "extends", "services", "order")
+	if err != nil {
+		return nil, err
+	}
+	s := &Spec{}
+	if s.FileComment, err = d.str(v["comment"], "comment"); err != nil {
+		return nil, err
+	}
+	if s.Package, err = d.str(v["package"], "package"); err != nil {
+		return nil, err
+	}
+	if s.GoPackage, err = d.str(v["go_package"], "go_package"); err != nil {
+		return nil, err
+	}
+	if s.JavaPackage, err = d.str(v["java_package"], "java_package"); err != nil {
+		return nil, err
+	}
+	d.nodes[s.Package] = n
+	if v["package"] != nil {
+		d.nodes[s.Package] = v["package"]
+	}
+	if v["go_package"] != nil {
+		d.nodes[joinPath(s.Package, "go_package")] = v["go_package"]
+	}
+	if v["java_package"] != nil {
+		d.nodes[joinPath(s.Package, "java_package")] = v["java_package"]
+	}
+
+	imports, err := d.sequence(v["imports"], "imports")
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range imports {
+		value, err := d.str(i, "import")
+		if err != nil {
+			return nil, err
+		}
+		s.Imports = append(s.Imports, ImportType(value))
+	}
+	if s.Options, err = d.options(s.Package, fileScope, v["options"]); err != nil {
+		return nil, err
+	}
+	if s.Messages, err = d.messages(s.Package, v["messages"]); err != nil {
+		return nil, err
+	}
+	if s.Enums, err = d.enums(s.Package, v["enums"]); err != nil {
+		return nil, err
+	}
+	if s.Extends, err = d.extends(s.Package, v["extends"]); err != nil {
+		return nil, err
+	}
+	services, err := d.sequence(v["services"], "services")
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range services {
+		svc, err := d.service(s.Package, n)
+		if err != nil {
+			return nil, err
+		}
+		s.Services = append(s.Services, svc)
+	}
+	if s.Order, err = d.order(v["order"]); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// messages decodes a list of messages declared within the given scope.
+func (d *definitionDecoder) messages(prefix string, n *yamlNode) ([]Message, error) {
+	items, err := d.sequence(n, "messages")
+	if err != nil {
+		return nil, err
+	}
+	var messages []Message
+	for _, n := range items {
+		v, err := d.mapping(n, "message", "name", "comment", "options", "messages", "enums", "extends", "reserved",
+			"fields", "oneofs", "order")
+		if err != nil {
+			return nil, err
+		}
+		var m Message
+		if m.Name, err = d.str(v["name"], "message name"); err != nil {
+			return nil, err
+		}
+		if m.Comment, err = d.str(v["comment"], "message comment"); err != nil {
+			return nil, err
+		}
+		path := joinPath(prefix, m.Name)
+		d.nodes[path] = n
+		if m.Options, err = d.options(path, messageScope, v["options"]); err != nil {
+			return nil, err
+		}
+		if m.Messages, err = d.messages(path, v["messages"]); err != nil {
+			return nil, err
+		}
+		if m.Enums, err = d.enums(path, v["enums"]); err != nil {
+			return nil, err
+		}
+		if m.Extends, err = d.extends(path, v["extends"]); err != nil {
+			return nil, err
+		}
+		if m.ReservedValues, err = d.reserved(v["reserved"]); err != nil {
+			return nil, err
+		}
+		if m.Fields, err = d.fields(path, v["fields"]); err != nil {
+			return nil, err
+		}
+		oneofs, err := d.sequence(v["oneofs"], "oneofs")
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range oneofs {
+			o, err := d.oneof(path, n)
+			if err != nil {
+				return nil, err
+			}
+			m.OneOfs = append(m.OneOfs, o)
+		}
+		if m.Order, err = d.order(v["order"]); err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	return messages, nil
+}
+
+// reserved decodes the reserved names, tags and tag ranges of a message or enum.
+func (d *definitionDecoder) reserved(n *yamlNode) ([]Reserved, error) {
+	items, err := d.sequence(n, "reserved")
+	if err != nil {
+		return nil, err
+	}
+	var reserved []Reserved
+	for _, n := range items {
+		v, err := d.mapping(n, "reserved entry", "kind", "name", "tag", "from", "to", "comment")
+		if err != nil {
+			return nil, err
+		}
+		kind, err := d.str(v["kind"], "reserved kind")
+		if err != nil {
+			return nil, err
+		}
+		comment, err := d.str(v["comment"], "reserved comment")
+		if err != nil {
+			return nil, err
+		}
+		delete(v, "kind")
+		delete(v, "comment")
+		switch {
+		case kind != "" && kind != reservedNameKind && kind != reservedTagKind && kind != reservedRangeKind:
+			return nil, d.errorf(n, "unknown reserved kind %q; expected name, tag or range", kind)
+		case len(v) == 1 && v["name"] != nil && (kind == "" || kind == reservedNameKind):
+			name, err := d.str(v["name"], "reserved name")
+			if err != nil {
+				return nil, err
+			}
+			reserved = append(reserved, ReservedName{Name: NameType(name), Comment: comment})
+		case len(v) == 1 && v["tag"] != nil && (kind == "" || kind == reservedTagKind):
+			tag, err := d.tag(v["tag"], "reserved tag", false)
+			if err != nil {
+				return nil, err
+			}
+			reserved = append(reserved, ReservedTagValue{Tag: tag, Comment: comment})
+		case len(v) == 2 && v["from"] != nil && v["to"] != nil && (kind == "" || kind == reservedRangeKind):
+			lower, err := d.tag(v["from"], "reserved range start", false)
+			if err != nil {
+				return nil, err
+			}
+			upper, err := d.tag(v["to"], "reserved range end", true)
+			if err != nil {
+				return nil, err
+			}
+			reserved = append(reserved, ReservedTagRange{LowerTag: lower, UpperTag: upper, Comment: comment})
+		default:
+			return nil, d.errorf(n, "reserved entry must have either a name, a tag, or a from and to matching its kind")
+		}
+	}
+	return reserved, nil
+}
+
+// order decodes the declaration order of the elements of a spec, message, enum, oneof or service.
+func (d *definitionDecoder) order(n *yamlNode) ([]Element, error) {
+	items, err := d.sequence(n, "order")
+	if err != nil {
+		return nil, err
+	}
+	var order []Element
+	for _, n := range items {
+		v, err := d.mapping(n, "order entry", "kind", "index")
+		if err != nil {
+			return nil, err
+		}
+		kind, err := d.str(v["kind"], "order kind")
+		if err != nil {
+			return nil, err
+		}
+		index, err := d.tag(v["index"], "order index", false)
+		if err != nil {
+			return nil, err
+		}
+		order = append(order, Element{Kind: ElementKind(kind), Index: int(index)})
+	}
+	return order, nil
+}
+
+// fields decodes a list of fields declared within the given message.
+func (d *definitionDecoder) fields(prefix string, n *yamlNode) ([]Field, error) {
+	items, err := d.sequence(n, "fields")
+	if err != nil {
+		return nil, err
+	}
+	var fields []Field
+	for _, n := range items {
+		f, err := d.field(prefix, n)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// field decodes a single field of any kind.
+func (d *definitionDecoder) field(prefix string, n *yamlNode) (Field, error) {
+	v, err := d.mapping(n, "field", "kind", "name", "type", "key", "value", "tag", "rule", "comment", "options")
+	if err != nil {
+		return nil, err
+	}
+	var values [6]string
+	for i, key := range []string{"kind", "name", "type", "key", "value", "comment"} {
+		if values[i], err = d.str(v[key], "field "+key); err != nil {
+			return nil, err
+		}
+	}
+	kind, name, typing, key, value, comment := values[0], NameType(values[1]), values[2], values[3], values[4], values[5]
+	tag, err := d.tag(v["tag"], "field tag", false)
+	if err != nil {
+		return nil, err
+	}
+	rule, err := d.rule(v["rule"])
+	if err != nil {
+		return nil, err
+	}
+	path := joinPath(prefix, string(name))
+	d.nodes[path] = n
+	options, err := d.options(path, fieldScope, v["options"])
+	if err != nil {
+		return nil, err
+	}
+
+	scalar, isScalar := parseFieldType(typing)
+	keyType, isScalarKey := parseFieldType(key)
+	valueType, isScalarValue := parseFieldType(value)
+	if kind == "" {
+		switch {
+		case v["key"] != nil || v["value"] != nil:
+			kind = "custom-map"
+			if isScalarValue {
+				kind = "map"
+			}
+		case isScalar:
+			kind = "scalar"
+		default:
+			kind = "custom"
+		}
+	}
+
+	var unused []string
+	switch kind {
+	case "scalar", "custom":
+		unused = []string{"key", "value"}
+	case "map", "custom-map":
+		unused = []string{"type"}
+		if !isScalarKey {
+			return nil, d.errorf(d.at(v, "key", n), "map key must be one of the built-in types")
+		}
+	default:
+		return nil, d.errorf(v["kind"], "unknown field kind %q; expected scalar, custom, map or custom-map", kind)
+	}
+	for _, k := range unused {
+		if v[k] != nil {
+			return nil, d.errorf(v[k], "%s does not apply to %s fields", k, kind)
+		}
+	}
+
+	switch kind {
+	case "scalar":
+		if !isScalar {
+			return nil, d.errorf(d.at(v, "type", n), "scalar field type must be one of the built-in types")
+		}
+		return ScalarField{Name: name, Tag: tag, Rule: rule, Comment: comment, Typing: scalar, Options: options}, nil
+	case "custom":
+		return CustomField{Name: name, Tag: tag, Rule: rule, Comment: comment, Typing: typing, Options: options}, nil
+	case "map":
+		if !isScalarValue {
+			return nil, d.errorf(d.at(v, "value", n), "map value must be one of the built-in types")
+		}
+		return MapField{Name: name, Tag: tag, Rule: rule, Comment: comment, KeyTyping: keyType, ValueTyping: valueType, Options: options}, nil
+	default:
+		return CustomMapField{Name: name, Tag: tag, Rule: rule, Comment: comment, KeyTyping: keyType, ValueTyping: value, Options: options}, nil
+	}
+}
+
+// at returns the node of the given key, or the enclosing node when the key is missing.
+func (d *definitionDecoder) at(v map[string]*yamlNode, key string, n *yamlNode) *yamlNode {
+	if v[key] != nil {
+		return v[key]
+	}
+	return n
+}
+
+// rule decodes a field rule.
+func (d *definitionDecoder) rule(n *yamlNode) (FieldRule, error) {
+	value, err := d.str(n, "field rule")
+	if err != nil {
+		return None, err
+	}
+	switch value {
+	case "":
+		return None, nil
+	case "repeated":
+		return Repeated, nil
+	case "optional":
+		return Optional, nil
+	default:
+		return None, d.errorf(n, "unknown field rule %q; expected repeated or optional", value)
+	}
+}
+
+// oneof decodes a oneof declared within the given message.
+func (d *definitionDecoder) oneof(prefix string, n *yamlNode) (OneOf, error) {
+	var o OneOf
+	v, err := d.mapping(n, "oneof", "name", "comment", "options", "fields", "order")
+	if err != nil {
+		return o, err
+	}
+	name, err := d.str(v["name"], "oneof name")
+	if err != nil {
+		return o, err
+	}
+	o.Name = NameType(name)
+	if o.Comment, err = d.str(v["comment"], "oneof comment"); err != nil {
+		return o, err
+	}
+	path := joinPath(prefix, name)
+	d.nodes[path] = n
+	if o.Options, err = d.options(path, oneofScope, v["options"]); err != nil {
+		return o, err
+	}
+	if o.Fields, err = d.fields(prefix, v["fields"]); err != nil {
+		return o, err
+	}
+	o.Order, err = d.order(v["order"])
+	return o, err
+}
+
+// enums decodes a list of enums declared within the given scope.
+func (d *definitionDecoder) enums(prefix string, n *yamlNode) ([]Enum, error) {
+	items, err := d.sequence(n, "enums")
+	if err != nil {
+		return nil, err
+	}
+	var enums []Enum
+	for _, n := range items {
+		v, err := d.mapping(n, "enum", "name", "comment", "allow_alias", "options", "reserved", "values", "order")
+		if err != nil {
+			return nil, err
+		}
+		var e Enum
+		name, err := d.str(v["name"], "enum name")
+		if err != nil {
+			return nil, err
+		}
+		e.Name = NameType(name)
+		if e.Comment, err = d.str(v["comment"], "enum comment"); err != nil {
+			return nil, err
+		}
+		if e.AllowAlias, err = d.boolean(v["allow_alias"], "allow_alias"); err != nil {
+			return nil, err
+		}
+		path := joinPath(prefix, name)
+		d.nodes[path] = n
+		if e.Options, err = d.options(path, enumScope, v["options"]); err != nil {
+			return nil, err
+		}
+		if e.ReservedValues, err = d.reserved(v["reserved"]); err != nil {
+			return nil, err
+		}
+		values, err := d.sequence(v["values"], "enum values")
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range values {
+			v, err := d.mapping(n, "enum value", "name", "tag", "comment", "options")
+			if err != nil {
+				return nil, err
+			}
+			var value EnumValue
+			name, err := d.str(v["name"], "enum value name")
+			if err != nil {
+				return nil, err
+			}
+			value.Name = NameType(name)
+			if value.Tag, err = d.tag(v["tag"], "enum value tag", false); err != nil {
+				return nil, err
+			}
+			if value.Comment, err = d.str(v["comment"], "enum value comment"); err != nil {
+				return nil, err
+			}
+			d.nodes[joinPath(path, name)] = n
+			if value.Options, err = d.options(joinPath(path, name), enumValueScope, v["options"]); err != nil {
+				return nil, err
+			}
+			e.Values = append(e.Values, value)
+		}
+		if e.Order, err = d.order(v["order"]); err != nil {
+			return nil, err
+		}
+		enums = append(enums, e)
+	}
+	return enums, nil
+}
+
+// extends decodes a list of extend blocks declared within the given scope.
+func (d *definitionDecoder) extends(prefix string, n *yamlNode) ([]Extend, error) {
+	items, err := d.sequence(n, "extends")
+	if err != nil {
+		return nil, err
+	}
+	var extends []Extend
+	for _, n := range items {
+		v, err := d.mapping(n, "extend", "type", "comment", "fields")
+		if err != nil {
+			return nil, err
+		}
+		var e Extend
+		if e.Typing, err = d.str(v["type"], "extend type"); err != nil {
+			return nil, err
+		}
+		if e.Comment, err = d.str(v["comment"], "extend comment"); err != nil {
+			return nil, err
+		}
+		if e.Fields, err = d.fields(prefix, v["fields"]); err != nil {
+			return nil, err
+		}
+		extends = append(extends, e)
+	}
+	return extends, nil
+}
+
+// service decodes a service and its methods.
+func (d *definitionDecoder) service(prefix string, n *yamlNode) (Service, error) {
+	var svc Service
+	v, err := d.mapping(n, "service", "name", "comment", "options", "methods", "order")
+	if err != nil {
+		return svc, err
+	}
+	name, err := d.str(v["name"], "service name")
+	if err != nil {
+		return svc, err
+	}
+	svc.Name = NameType(name)
+	if svc.Comment, err = d.str(v["comment"], "service comment"); err != nil {
+		return svc, err
+	}
+	path := joinPath(prefix, name)
+	d.nodes[path] = n
+	if svc.Options, err = d.options(path, serviceScope, v["options"]); err != nil {
+		return svc, err
+	}
+	methods, err := d.sequence(v["methods"], "methods")
+	if err != nil {
+		return svc, err
+	}
+	for _, n := range methods {
+		v, err := d.mapping(n, "method", "name", "comment", "request", "response", "streaming", "options")
+		if err != nil {
+			return svc, err
+		}
+		var values [5]string
+		for i, key := range []string{"name", "comment", "request", "response", "streaming"} {
+			if values[i], err = d.str(v[key], "method "+key); err != nil {
+				return svc, err
+			}
+		}
+		m := Method{Name: NameType(values[0]), Comment: values[1], RequestType: values[2], ResponseType: values[3]}
+		switch values[4] {
+		case "", "unary":
+			m.Streaming = Unary
+		case "client":
+			m.Streaming = ClientStreaming
+		case "server":
+			m.Streaming = ServerStreaming
+		case "bidi":
+			m.Streaming = BidiStreaming
+		default:
+			return svc, d.errorf(v["streaming"], "unknown streaming mode %q; expected unary, client, server or bidi", values[4])
+		}
+		methodPath := joinPath(path, values[0])
+		d.nodes[methodPath] = n
+		if m.Options, err = d.options(methodPath, methodScope, v["options"]); err != nil {
+			return svc, err
+		}
+		svc.Methods = append(svc.Methods, m)
+	}
+	svc.Order, err = d.order(v["order"])
+	return svc, err
+}
+
+// options decodes a mapping of option names to values set on the element at the given path.
+func (d *definitionDecoder) options(path string, scope optionScope, n *yamlNode) ([]Option, error) {
+	if n == nil {
+		return nil, nil
+	}
+	if n.kind == yamlSequence {
+		return d.optionList(path, scope, n)
+	}
+	if n.kind != yamlMapping {
+		return nil, d.errorf(n, "options must be a mapping of option names to values, or a list of options")
+	}
+	var options []Option
+	for i, k := range n.keys {
+		_, isString := builtinOptions[scope][k.value].value.(StringValue)
+		value, err := d.optionValue(n.values[i], isString)
+		if err != nil {
+			return nil, err
+		}
+		d.nodes[joinPath(path, k.value)] = k
+		options = append(options, Option{Name: k.value, Value: value})
+	}
+	return options, nil
+}
+
+// optionList decodes options written as a list of entries with a name, a value and optionally the kind of
+// the value, as they are encoded by Option.MarshalJSON.
+func (d *definitionDecoder) optionList(path string, scope optionScope, n *yamlNode) ([]Option, error) {
+	var options []Option
+	for _, item := range n.values {
+		v, err := d.mapping(item, "option", "name", "kind", "value", "comment")
+		if err != nil {
+			return nil, err
+		}
+		name, err := d.str(v["name"], "option name")
+		if err != nil {
+			return nil, err
+		}
+		comment, err := d.str(v["comment"], "option comment")
+		if err != nil {
+			return nil, err
+		}
+		kind, err := d.str(v["kind"], "option kind")
+		if err != nil {
+			return nil, err
+		}
+		value := v["value"]
+		if value == nil {
+			return nil, d.errorf(item, "option must have a value")
+		}
+
+		var o OptionValue
+		switch kind {
+		case "":
+			_, isString := builtinOptions[scope][name].value.(StringValue)
+			o, err = d.optionValue(value, isString)
+		case aggregateValueKind:
+			var entries []Option
+			if value.kind != yamlSequence {
+				return nil, d.errorf(value, "aggregate option value must be a list of options")
+			}
+			entries, err = d.optionList("", scope, value)
+			o = AggregateValue(entries)
+		default:
+			if value.kind != yamlScalar {
+				return nil, d.errorf(value, "%s option value must be a scalar", kind)
+			}
+			if o, err = parseOptionScalar(kind, value.value); err != nil {
+				return nil, d.errorf(value, "%s", err)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if path != "" {
+			d.nodes[joinPath(path, name)] = item
+		}
+		options = append(options, Option{Name: name, Value: o, Comment: comment})
+	}
+	return options, nil
+}
+
+// parseOptionScalar converts the text of a scalar option value of the given kind.
+func parseOptionScalar(kind, text string) (OptionValue, error) {
+	switch kind {
+	case stringValueKind:
+		return StringValue(text), nil
+	case identValueKind:
+		return IdentValue(text), nil
+	case boolValueKind:
+		v, err := strconv.ParseBool(text)
+		return BoolValue(v), err
+	case intValueKind:
+		v, err := strconv.ParseInt(text, 0, 64)
+		if err != nil {
+			if u, uerr := strconv.ParseUint(text, 0, 64); uerr == nil {
+				return UintValue(u), nil
+			}
+		}
+		return IntValue(v), err
+	case floatValueKind:
+		v, err := strconv.ParseFloat(text, 64)
+		return FloatValue(v), err
+	default:
+		return nil, fmt.Errorf("unknown option kind %q; expected string, int, float, bool, ident or aggregate", kind)
+	}
+}
+
+// optionValue decodes the value of an option. Scalars are read as strings when isString is set.
+func (d *definitionDecoder) optionValue(n *yamlNode, isString bool) (OptionValue, error) {
+	switch {
+	case n.kind == yamlSequence:
+		return nil, d.errorf(n, "option value cannot be a list")
+	case n.kind == yamlMapping:
+		var aggregate AggregateValue
+		for i, entry := range n.values {
+			items := []*yamlNode{entry}
+			if entry.kind == yamlSequence {
+				items = entry.values
+			}
+			for _, item := range items {
+				value, err := d.optionValue(item, false)
+				if err != nil {
+					return nil, err
+				}
+				aggregate = append(aggregate, Option{Name: n.keys[i].value, Value: value})
+			}
+		}
+		return aggregate, nil
+	case n.quoted || isString:
+		return StringValue(n.value), nil
+	case n.isNull():
+		return nil, d.errorf(n, "option must have a value")
+	}
+
+	switch n.value {
+	case "true", "false":
+		return BoolValue(n.value == "true"), nil
+	case "inf", "-inf", "nan":
+		v, _ := strconv.ParseFloat(n.value, 64)
+		return FloatValue(v), nil
+	}
+	if v, err := strconv.ParseInt(n.value, 0, 64); err == nil {
+		return IntValue(v), nil
+	}
+	if v, err := strconv.ParseUint(n.value, 0, 64); err == nil {
+		return UintValue(v), nil
+	}
+	if v, err := strconv.ParseFloat(n.value, 64); err == nil {
+		return FloatValue(v), nil
+	}
+	return IdentValue(n.value), nil
+}
@@ -0,0 +1,299 @@
+package proto3_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	. "github.com/muxinc/protogen/proto3"
+)
+
+const beaconDefinition = `# Beacon messages
+comment: Beacon messages
+package: mux
+go_package: github.com/muxinc/beacon
+imports:
+  - google/protobuf/timestamp.proto
+options:
+  java_multiple_files: true
+  java_outer_classname: BeaconProto
+messages:
+- name: Beacon
+  comment: A single beacon
+  reserved:
+    - name: player_id
+    - tag: 4
+    - {from: 9, to: max}
+  fields:
+    - name: view_id
+      type: string
+      tag: 1
+      comment: "Unique view identifier"
+    - {name: events, type: Event, tag: 2, rule: repeated}
+    - {name: tags, key: string, value: string, tag: 3, options: {deprecated: true}}
+    - {kind: custom-map, name: sessions, key: int64, value: Session, tag: 5}
+  oneofs:
+    - name: source
+      fields:
+        - {name: page_url, type: string, tag: 6, options: {json_name: 'pageURL'}}
+        - {name: app_id, type: int32, tag: 7}
+  order:
+    - {kind: field, index: 0}
+    - {kind: oneof, index: 0}
+    - {kind: field, index: 1}
+  enums:
+    - name: Kind
+      reserved: [{from: 2, to: max}, {name: AD}]
+      values:
+        - {name: UNKNOWN, tag: 0}
+        - {name: VIEW, tag: 1, options: {deprecated: true}}
+services:
+  - name: Tracker
+    methods:
+      - {name: Track, request: Beacon, response: Beacon, streaming: client}
+`
+
+const beaconJSON = `{
+	"comment": "Beacon messages",
+	"package": "mux",
+	"go_package": "github.com/muxinc/beacon",
+	"imports": ["google/protobuf/timestamp.proto"],
+	"options": {"java_multiple_files": true, "java_outer_classname": "BeaconProto"},
+	"messages": [{
+		"name": "Beacon",
+		"comment": "A single beacon",
+		"reserved": [{"name": "player_id"}, {"tag": 4}, {"from": 9, "to": "max"}],
+		"fields": [
+			{"name": "view_id", "type": "string", "tag": 1, "comment": "Unique view identifier"},
+			{"name": "events", "type": "Event", "tag": 2, "rule": "repeated"},
+			{"name": "tags", "key": "string", "value": "string", "tag": 3, "options": {"deprecated": true}},
+			{"kind": "custom-map", "name": "sessions", "key": "int64", "value": "Session", "tag": 5}
+		],
+		"oneofs": [{
+			"name": "source",
+			"fields": [
+				{"name": "page_url", "type": "string", "tag": 6, "options": {"json_name": "pageURL"}},
+				{"name": "app_id", "type": "int32", "tag": 7}
+			]
+		}],
+		"order": [{"kind": "field", "index": 0}, {"kind": "oneof", "index": 0}, {"kind": "field", "index": 1}],
+		"enums": [{
+			"name": "Kind",
+			"reserved": [{"from": 2, "to": "max"}, {"name": "AD"}],
+			"values": [{"name": "UNKNOWN", "tag": 0}, {"name": "VIEW", "tag": 1, "options": {"deprecated": true}}]
+		}]
+	}],
+	"services": [{
+		"name": "Tracker",
+		"methods": [{"name": "Track", "request": "Beacon", "response": "Beacon", "streaming": "client"}]
+	}]
+}`
+
+func TestLoadDefinition(t *testing.T) {
+	want := &Spec{
+		FileComment: "Beacon messages",
+		Package:     "mux",
+		GoPackage:   "github.com/muxinc/beacon",
+		Imports:     []ImportType{"google/protobuf/timestamp.proto"},
+		Options: []Option{
+			{Name: "java_multiple_files", Value: BoolValue(true)},
+			{Name: "java_outer_classname", Value: StringValue("BeaconProto")},
+		},
+		Messages: []Message{{
+			Name:           "Beacon",
+			Comment:        "A single beacon",
+			ReservedValues: []Reserved{ReservedName{Name: "player_id"}, ReservedTagValue{Tag: 4}, ReservedTagRange{LowerTag: 9, UpperTag: MaxTag}},
+			Fields: []Field{
+				ScalarField{Name: "view_id", Typing: StringType, Tag: 1, Comment: "Unique view identifier"},
+				CustomField{Name: "events", Typing: "Event", Tag: 2, Rule: Repeated},
+				MapField{Name: "tags", KeyTyping: StringType, ValueTyping: StringType, Tag: 3, Options: []Option{{Name: "deprecated", Value: BoolValue(true)}}},
+				CustomMapField{Name: "sessions", KeyTyping: Int64Type, ValueTyping: "Session", Tag: 5},
+			},
+			OneOfs: []OneOf{{
+				Name: "source",
+				Fields: []Field{
+					ScalarField{Name: "page_url", Typing: StringType, Tag: 6, Options: []Option{{Name: "json_name", Value: StringValue("pageURL")}}},
+					ScalarField{Name: "app_id", Typing: Int32Type, Tag: 7},
+				},
+			}},
+			Order: []Element{{Kind: ElementField, Index: 0}, {Kind: ElementOneOf, Index: 0}, {Kind: ElementField, Index: 1}},
+			Enums: []Enum{{
+				Name:           "Kind",
+				ReservedValues: []Reserved{ReservedTagRange{LowerTag: 2, UpperTag: MaxTag}, ReservedName{Name: "AD"}},
+				Values:         []EnumValue{{Name: "UNKNOWN", Tag: 0}, {Name: "VIEW", Tag: 1, Options: []Option{{Name: "deprecated", Value: BoolValue(true)}}}},
+			}},
+		}},
+		Services: []Service{{
+			Name:    "Tracker",
+			Methods: []Method{{Name: "Track", RequestType: "Beacon", ResponseType: "Beacon", Streaming: ClientStreaming}},
+		}},
+	}
+
+	for name, src := range map[string]string{"YAML": beaconDefinition, "JSON": beaconJSON} {
+		got, err := LoadDefinition(strings.NewReader(src))
+		if err != nil {
+			t.Errorf("%s. LoadDefinition() error = %v", name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s. LoadDefinition() = %+v, want %+v", name, got, want)
+		}
+	}
+}
+
+func TestLoadDefinition_Errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		src        string
+		wantLine   int
+		wantColumn int
+		wantError  string
+	}{
+		{
+			name:       "Unknown key",
+			src:        "package: mux\nmessages:\n  - name: Beacon\n    feilds: []\n",
+			wantLine:   4,
+			wantColumn: 5,
+			wantError:  `unknown key "feilds" in message`,
+		},
+		{
+			name:       "Bad indentation",
+			src:        "package: mux\nmessages:\n  - name: Beacon\n      comment: x\n",
+			wantLine:   4,
+			wantColumn: 7,
+			wantError:  "unexpected indentation",
+		},
+		{
+			name:       "Tab indentation",
+			src:        "package: mux\nmessages:\n\t- name: Beacon\n",
+			wantLine:   3,
+			wantColumn: 1,
+			wantError:  "tabs cannot be used",
+		},
+		{
+			name:       "Unterminated string",
+			src:        "package: \"mux\n",
+			wantLine:   1,
+			wantColumn: 10,
+			wantError:  "unterminated quoted string",
+		},
+		{
+			name:       "Unterminated JSON object",
+			src:        "{\"package\": \"mux\",\n \"messages\": [{\"name\": \"Beacon\"}]\n",
+			wantLine:   1,
+			wantColumn: 1,
+			wantError:  "unterminated flow collection",
+		},
+		{
+			name:       "Duplicate key",
+			src:        "package: mux\npackage: foo\n",
+			wantLine:   2,
+			wantColumn: 1,
+			wantError:  `duplicate key "package"`,
+		},
+		{
+			name:       "Duplicate key in a field",
+			src:        "messages:\n  - name: Beacon\n    fields:\n      - {name: view_id, type: string, \"name\": seq, tag: 1}\n",
+			wantLine:   4,
+			wantColumn: 39,
+			wantError:  `duplicate key "name"`,
+		},
+		{
+			name:       "Duplicate key in JSON",
+			src:        "{\"messages\": [{\"name\": \"Beacon\",\n  \"name\": \"Event\"}]}\n",
+			wantLine:   2,
+			wantColumn: 3,
+			wantError:  `duplicate key "name"`,
+		},
+		{
+			name:       "Quoted tag",
+			src:        "messages:\n  - name: Beacon\n    fields:\n      - {name: view_id, type: string, tag: \"1\"}\n",
+			wantLine:   4,
+			wantColumn: 44,
+			wantError:  "field tag must be an integer",
+		},
+		{
+			name:       "Scalar kind with custom type",
+			src:        "messages:\n  - name: Beacon\n    fields:\n      - {kind: scalar, name: view_id, type: ViewID, tag: 1}\n",
+			wantLine:   4,
+			wantColumn: 45,
+			wantError:  "scalar field type must be one of the built-in types",
+		},
+		{
+			name:       "Unknown rule",
+			src:        "messages:\n  - name: Beacon\n    fields:\n      - {name: view_id, type: string, tag: 1, rule: required}\n",
+			wantLine:   4,
+			wantColumn: 53,
+			wantError:  `unknown field rule "required"`,
+		},
+	}
+	for _, tt := range tests {
+		_, err := LoadDefinition(strings.NewReader(tt.src))
+		perr, ok := err.(*ParseError)
+		if !ok {
+			t.Errorf("%q. LoadDefinition() error = %#v, want *ParseError", tt.name, err)
+			continue
+		}
+		if perr.Line != tt.wantLine || perr.Column != tt.wantColumn || !strings.Contains(perr.Message, tt.wantError) {
+			t.Errorf("%q. LoadDefinition() error = %v, want %d:%d: %s", tt.name, err, tt.wantLine, tt.wantColumn, tt.wantError)
+		}
+	}
+}
+
+func TestLoadDefinition_ValidationErrors(t *testing.T) {
+	src := `package: mux
+messages:
+  - name: Beacon
+    fields:
+      - {name: view_id, type: string, tag: 1}
+      - {name: seq, type: int32, tag: 1}
+      - name: player
+        type: string
+        tag: 2
+        options:
+          packed: true
+`
+	_, err := LoadDefinition(strings.NewReader(src))
+	errs, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("LoadDefinition() error = %#v, want ValidationErrors", err)
+	}
+	want := []struct {
+		path         string
+		line, column int
+	}{
+		{"mux.Beacon.player.packed", 11, 11},
+		{"mux.Beacon.seq", 6, 9},
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("LoadDefinition() error = %v, want %d problems", err, len(want))
+	}
+	for i, w := range want {
+		if errs[i].Path != w.path || errs[i].Line != w.line || errs[i].Column != w.column {
+			t.Errorf("LoadDefinition() error %d = %v, want %s at %d:%d", i, errs[i], w.path, w.line, w.column)
+		}
+	}
+}
+
+func TestLoadFieldRegistry(t *testing.T) {
+	src := `fields:
+  - {name: view_id, type: string, comment: Unique view identifier}
+  - {name: timestamps, type: int64, rule: repeated}
+`
+	registry, err := LoadFieldRegistry(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("LoadFieldRegistry() error = %v", err)
+	}
+	f, err := registry.Field("view_id", 3)
+	if err != nil {
+		t.Fatalf("FieldRegistry.Field() error = %v", err)
+	}
+	if want := (ScalarField{Name: "view_id", Typing: StringType, Tag: 3, Comment: "Unique view identifier"}); !reflect.DeepEqual(f, want) {
+		t.Errorf("FieldRegistry.Field() = %+v, want %+v", f, want)
+	}
+
+	_, err = LoadFieldRegistry(strings.NewReader("fields:\n  - {name: view_id, type: string}\n  - {name: view_id, type: bytes}\n"))
+	if perr, ok := err.(*ParseError); !ok || perr.Line != 3 {
+		t.Errorf("LoadFieldRegistry() of duplicate fields error = %v, want error on line 3", err)
+	}
+}
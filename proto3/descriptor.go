@@ -0,0 +1,1042 @@
+package proto3
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Descriptors are encoded in the protobuf wire format as the messages defined by
+// google/protobuf/descriptor.proto, so that tools which consume compiled descriptors can be given a spec
+// without running protoc.
+// https://github.com/protocolbuffers/protobuf/blob/main/src/google/protobuf/descriptor.proto
+
+// DescriptorFile is a spec along with the name of the .proto file it describes, as it would be imported.
+type DescriptorFile struct {
+	Name string
+	Spec *Spec
+}
+
+// Descriptor encodes the spec as a google.protobuf.FileDescriptorProto named by the path of its .proto
+// file. Type references are written fully-qualified when they resolve to a type declared in the spec or
+// in an imported well-known type; other references are left as written and without a type, as in the
+// output of protoc before imports are resolved. Use DescriptorSet to resolve references between specs.
+func (s *Spec) Descriptor(name string) ([]byte, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	s = s.withTypeNames()
+	symbols := newSymbolTable()
+	symbols.addFile(name, s)
+	for _, i := range s.Imports {
+		symbols.addWellKnown(i)
+	}
+	return s.descriptor(name, symbols)
+}
+
+// DescriptorSet encodes a google.protobuf.FileDescriptorSet holding the descriptor of each file, in the
+// order given. References to types and extensions declared by an imported file of the set are resolved.
+func DescriptorSet(files ...DescriptorFile) ([]byte, error) {
+	specs := make(map[string]*Spec)
+	for _, f := range files {
+		if _, exists := specs[f.Name]; exists {
+			return nil, fmt.Errorf("Descriptor set has more than one file named %s", f.Name)
+		}
+		if err := f.Spec.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: %s", f.Name, err)
+		}
+		specs[f.Name] = f.Spec.withTypeNames()
+	}
+
+	var set protoBuffer
+	for _, f := range files {
+		spec := specs[f.Name]
+		symbols := newSymbolTable()
+		symbols.addFile(f.Name, spec)
+		for _, i := range spec.Imports {
+			if dep, ok := specs[string(i)]; ok {
+				symbols.addFile(string(i), dep)
+			} else {
+				symbols.addWellKnown(i)
+			}
+		}
+		d, err := spec.descriptor(f.Name, symbols)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", f.Name, err)
+		}
+		set.bytesField(1, d) // file
+	}
+	return set.Bytes(), nil
+}
+
+// Values of FieldDescriptorProto.Type
+var descriptorFieldTypes = map[FieldType]uint64{
+	DoubleType:   1,
+	FloatType:    2,
+	Int64Type:    3,
+	UInt64Type:   4,
+	Int32Type:    5,
+	Fixed64Type:  6,
+	Fixed32Type:  7,
+	BoolType:     8,
+	StringType:   9,
+	BytesType:    12,
+	UInt32Type:   13,
+	SFixed32Type: 15,
+	SFixed64Type: 16,
+	SInt32Type:   17,
+	SInt64Type:   18,
+}
+
+// Values of FieldDescriptorProto.Type and FieldDescriptorProto.Label that are not scalar types
+const (
+	messageFieldType = 11
+	enumFieldType    = 14
+	optionalLabel    = 1
+	repeatedLabel    = 3
+)
+
+// builtinOptionNumbers are the field numbers of the builtin options within each google.protobuf.*Options
+// message.
+var builtinOptionNumbers = map[optionScope]map[string]int{
+	fileScope: {
+		"java_package":           1,
+		"java_outer_classname":   8,
+		"optimize_for":           9,
+		"java_multiple_files":    10,
+		"go_package":             11,
+		"cc_generic_services":    16,
+		"java_generic_services":  17,
+		"py_generic_services":    18,
+		"deprecated":             23,
+		"java_string_check_utf8": 27,
+		"cc_enable_arenas":       31,
+		"objc_class_prefix":      36,
+		"csharp_namespace":       37,
+		"swift_prefix":           39,
+		"php_class_prefix":       40,
+		"php_namespace":          41,
+		"php_metadata_namespace": 44,
+		"ruby_package":           45,
+	},
+	messageScope: {
+		"message_set_wire_format":         1,
+		"no_standard_descriptor_accessor": 2,
+		"deprecated":                      3,
+		"map_entry":                       7,
+	},
+	fieldScope: {
+		"ctype":      1,
+		"packed":     2,
+		"deprecated": 3,
+		"lazy":       5,
+		"jstype":     6,
+	},
+	enumScope: {
+		"allow_alias": 2,
+		"deprecated":  3,
+	},
+	enumValueScope: {
+		"deprecated": 1,
+	},
+	serviceScope: {
+		"deprecated": 33,
+	},
+	methodScope: {
+		"deprecated":        33,
+		"idempotency_level": 34,
+	},
+}
+
+// builtinOptionEnums are the numbers of the enum values accepted by builtin options.
+var builtinOptionEnums = map[string]uint64{
+	"SPEED":               1,
+	"CODE_SIZE":           2,
+	"LITE_RUNTIME":        3,
+	"STRING":              0,
+	"CORD":                1,
+	"STRING_PIECE":        2,
+	"JS_NORMAL":           0,
+	"JS_STRING":           1,
+	"JS_NUMBER":           2,
+	"IDEMPOTENCY_UNKNOWN": 0,
+	"NO_SIDE_EFFECTS":     1,
+	"IDEMPOTENT":          2,
+}
+
+// descriptorEncoder encodes the elements of a spec, resolving references against a symbol table.
+type descriptorEncoder struct {
+	symbols *symbolTable
+	pkg     string
+}
+
+// descriptor encodes the spec as a FileDescriptorProto.
+func (s *Spec) descriptor(name string, symbols *symbolTable) ([]byte, error) {
+	e := descriptorEncoder{symbols: symbols, pkg: s.Package}
+	var b protoBuffer
+	b.stringField(1, name)
+	if s.Package != "" {
+		b.stringField(2, s.Package)
+	}
+	for _, i := range s.Imports {
+		b.stringField(3, string(i)) // dependency
+	}
+	for i := range s.Messages {
+		m, err := e.message(s.Package, &s.Messages[i])
+		if err != nil {
+			return nil, err
+		}
+		b.bytesField(4, m) // message_type
+	}
+	for i := range s.Enums {
+		v, err := e.enum(s.Package, &s.Enums[i])
+		if err != nil {
+			return nil, err
+		}
+		b.bytesField(5, v) // enum_type
+	}
+	for _, svc := range s.Services {
+		v, err := e.service(svc)
+		if err != nil {
+			return nil, err
+		}
+		b.bytesField(6, v) // service
+	}
+	if err := e.extensions(&b, 7, s.Package, s.Extends); err != nil {
+		return nil, err
+	}
+
+	var options []Option
+	if s.GoPackage != "" {
+		options = append(options, Option{Name: "go_package", Value: StringValue(s.GoPackage)})
+	}
+	if s.JavaPackage != "" {
+		options = append(options, Option{Name: "java_package", Value: StringValue(s.JavaPackage)})
+	}
+	options = append(options, s.Options...)
+	if err := e.options(&b, 8, s.Package, fileScope, options); err != nil {
+		return nil, err
+	}
+	b.stringField(12, "proto3") // syntax
+	return b.Bytes(), nil
+}
+
+// message encodes a DescriptorProto. Map fields are given a nested entry message, and optional fields a
+// synthetic oneof, as protoc does.
+func (e *descriptorEncoder) message(scope string, m *Message) ([]byte, error) {
+	name := joinPath(scope, m.Name)
+	var b protoBuffer
+	b.stringField(1, m.Name)
+
+	var synthetic []string
+	for _, f := range m.Fields {
+		oneof := -1
+		if fieldRule(f) == Optional {
+			fieldName, _, _ := fieldNameTag(f)
+			oneof = len(m.OneOfs) + len(synthetic)
+			synthetic = append(synthetic, "_"+string(fieldName))
+		}
+		v, err := e.field(name, f, "", oneof)
+		if err != nil {
+			return nil, err
+		}
+		b.bytesField(2, v) // field
+	}
+	for i, o := range m.OneOfs {
+		for _, f := range o.Fields {
+			v, err := e.field(name, f, "", i)
+			if err != nil {
+				return nil, err
+			}
+			b.bytesField(2, v) // field
+		}
+	}
+
+	for i := range m.Messages {
+		v, err := e.message(name, &m.Messages[i])
+		if err != nil {
+			return nil, err
+		}
+		b.bytesField(3, v) // nested_type
+	}
+	for _, f := range m.Fields {
+		v, ok, err := e.mapEntry(name, f)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			b.bytesField(3, v) // nested_type
+		}
+	}
+	for i := range m.Enums {
+		v, err := e.enum(name, &m.Enums[i])
+		if err != nil {
+			return nil, err
+		}
+		b.bytesField(4, v) // enum_type
+	}
+	if err := e.extensions(&b, 6, name, m.Extends); err != nil {
+		return nil, err
+	}
+	if err := e.options(&b, 7, name, messageScope, m.Options); err != nil {
+		return nil, err
+	}
+	for _, o := range m.OneOfs {
+		var oneof protoBuffer
+		oneof.stringField(1, string(o.Name))
+		if err := e.options(&oneof, 2, joinPath(name, string(o.Name)), oneofScope, o.Options); err != nil {
+			return nil, err
+		}
+		b.bytesField(8, oneof.Bytes()) // oneof_decl
+	}
+	for _, o := range synthetic {
+		var oneof protoBuffer
+		oneof.stringField(1, o)
+		b.bytesField(8, oneof.Bytes()) // oneof_decl
+	}
+
+	for _, r := range m.ReservedValues {
+		var start, end TagType
+		switch r := r.(type) {
+		case ReservedTagValue:
+			start, end = r.Tag, r.Tag
+		case ReservedTagRange:
+			start, end = r.LowerTag, r.UpperTag
+			if end == MaxTag {
+				end = MaxFieldTag
+			}
+		default:
+			continue
+		}
+		var reserved protoBuffer
+		reserved.varintField(1, uint64(start))
+		reserved.varintField(2, uint64(end)+1) // the end of a ReservedRange is exclusive
+		b.bytesField(9, reserved.Bytes())      // reserved_range
+	}
+	for _, r := range m.ReservedValues {
+		if r, ok := r.(ReservedName); ok {
+			b.stringField(10, string(r.Name)) // reserved_name
+		}
+	}
+	return b.Bytes(), nil
+}
+
+// field encodes a FieldDescriptorProto for a field declared within scope. Extension fields are given the
+// message they extend, and fields within a oneof the index of their oneof.
+func (e *descriptorEncoder) field(scope string, f Field, extendee string, oneof int) ([]byte, error) {
+	name, tag, _ := fieldNameTag(f)
+	var b protoBuffer
+	b.stringField(1, string(name))
+	if extendee != "" {
+		b.stringField(2, e.qualify(scope, extendee))
+	}
+	b.varintField(3, uint64(tag))
+
+	label := uint64(optionalLabel)
+	switch f := f.(type) {
+	case ScalarField:
+		if f.Rule == Repeated {
+			label = repeatedLabel
+		}
+		b.varintField(4, label)
+		b.varintField(5, descriptorFieldTypes[f.Typing])
+	case CustomField:
+		if f.Rule == Repeated {
+			label = repeatedLabel
+		}
+		b.varintField(4, label)
+		if name, sym, ok := e.symbols.resolve(scope, f.Typing); ok {
+			if sym.kind == enumSymbol {
+				b.varintField(5, enumFieldType)
+			} else {
+				b.varintField(5, messageFieldType)
+			}
+			b.stringField(6, "."+name)
+		} else {
+			b.stringField(6, f.Typing)
+		}
+	case MapField, CustomMapField:
+		b.varintField(4, repeatedLabel)
+		b.varintField(5, messageFieldType)
+		b.stringField(6, "."+joinPath(scope, mapEntryName(name)))
+	default:
+		return nil, fmt.Errorf("Field of type %T cannot be encoded as a descriptor", f)
+	}
+
+	path := joinPath(scope, string(name))
+	jsonName := lowerCamelCase(string(name))
+	var options []Option
+	for _, o := range fieldOptions(f) {
+		if v, ok := o.Value.(StringValue); ok && o.Name == "json_name" {
+			jsonName = string(v)
+			continue
+		}
+		options = append(options, o)
+	}
+	if err := e.options(&b, 8, path, fieldScope, options); err != nil {
+		return nil, err
+	}
+	if oneof >= 0 {
+		b.varintField(9, uint64(oneof)) // oneof_index
+	}
+	b.stringField(10, jsonName)
+	if fieldRule(f) == Optional {
+		b.boolField(17, true) // proto3_optional
+	}
+	return b.Bytes(), nil
+}
+
+// qualify returns the fully-qualified name of a type reference made from within scope, or the reference as
+// written when it does not resolve.
+func (e *descriptorEncoder) qualify(scope, typing string) string {
+	if name, _, ok := e.symbols.resolve(scope, typing); ok {
+		return "." + name
+	}
+	return typing
+}
+
+// mapEntry encodes the nested message protoc generates to hold the key and value of a map field.
+func (e *descriptorEncoder) mapEntry(scope string, f Field) ([]byte, bool, error) {
+	var name NameType
+	var key, value Field
+	switch f := f.(type) {
+	case MapField:
+		name = f.Name
+		key = ScalarField{Name: "key", Typing: f.KeyTyping, Tag: 1}
+		value = ScalarField{Name: "value", Typing: f.ValueTyping, Tag: 2}
+	case CustomMapField:
+		name = f.Name
+		key = ScalarField{Name: "key", Typing: f.KeyTyping, Tag: 1}
+		value = CustomField{Name: "value", Typing: f.ValueTyping, Tag: 2}
+	default:
+		return nil, false, nil
+	}
+
+	var b protoBuffer
+	b.stringField(1, mapEntryName(name))
+	for _, f := range []Field{key, value} {
+		v, err := e.field(scope, f, "", -1)
+		if err != nil {
+			return nil, false, err
+		}
+		b.bytesField(2, v) // field
+	}
+	var options protoBuffer
+	options.boolField(builtinOptionNumbers[messageScope]["map_entry"], true)
+	b.bytesField(7, options.Bytes())
+	return b.Bytes(), true, nil
+}
+
+// extensions writes each field of the extend blocks as a FieldDescriptorProto at the given field number.
+func (e *descriptorEncoder) extensions(b *protoBuffer, field int, scope string, extends []Extend) error {
+	for _, ext := range extends {
+		for _, f := range ext.Fields {
+			v, err := e.field(scope, f, ext.Typing, -1)
+			if err != nil {
+				return err
+			}
+			b.bytesField(field, v)
+		}
+	}
+	return nil
+}
+
+// enum encodes an EnumDescriptorProto, with its values in the order they are written.
+func (e *descriptorEncoder) enum(scope string, en *Enum) ([]byte, error) {
+	name := joinPath(scope, string(en.Name))
+	var b protoBuffer
+	b.stringField(1, string(en.Name))
+	values := Enum{Values: append([]EnumValue(nil), en.Values...)}
+	sort.Stable(values)
+	for _, v := range values.Values {
+		var value protoBuffer
+		value.stringField(1, string(v.Name))
+		value.varintField(2, uint64(int64(v.Tag)))
+		if err := e.options(&value, 3, joinPath(name, string(v.Name)), enumValueScope, v.Options); err != nil {
+			return nil, err
+		}
+		b.bytesField(2, value.Bytes()) // value
+	}
+	var options []Option
+	if en.AllowAlias && !hasOption(en.Options, "allow_alias") {
+		options = append(options, Option{Name: "allow_alias", Value: BoolValue(true)})
+	}
+	options = append(options, en.Options...)
+	if err := e.options(&b, 3, name, enumScope, options); err != nil {
+		return nil, err
+	}
+
+	for _, r := range en.ReservedValues {
+		var start, end TagType
+		switch r := r.(type) {
+		case ReservedTagValue:
+			start, end = r.Tag, r.Tag
+		case ReservedTagRange:
+			start, end = r.LowerTag, r.UpperTag
+		default:
+			continue
+		}
+		var reserved protoBuffer
+		reserved.varintField(1, uint64(int64(start)))
+		reserved.varintField(2, uint64(int64(end))) // unlike a ReservedRange, the end of an EnumReservedRange is inclusive
+		b.bytesField(4, reserved.Bytes())           // reserved_range
+	}
+	for _, r := range en.ReservedValues {
+		if r, ok := r.(ReservedName); ok {
+			b.stringField(5, string(r.Name)) // reserved_name
+		}
+	}
+	return b.Bytes(), nil
+}
+
+// service encodes a ServiceDescriptorProto.
+func (e *descriptorEncoder) service(s Service) ([]byte, error) {
+	name := joinPath(e.pkg, string(s.Name))
+	var b protoBuffer
+	b.stringField(1, string(s.Name))
+	for _, m := range s.Methods {
+		var method protoBuffer
+		method.stringField(1, string(m.Name))
+		method.stringField(2, e.qualify(e.pkg, m.RequestType))  // input_type
+		method.stringField(3, e.qualify(e.pkg, m.ResponseType)) // output_type
+		if err := e.options(&method, 4, joinPath(name, string(m.Name)), methodScope, m.Options); err != nil {
+			return nil, err
+		}
+		if m.Streaming == ClientStreaming || m.Streaming == BidiStreaming {
+			method.boolField(5, true) // client_streaming
+		}
+		if m.Streaming == ServerStreaming || m.Streaming == BidiStreaming {
+			method.boolField(6, true) // server_streaming
+		}
+		b.bytesField(2, method.Bytes()) // method
+	}
+	if err := e.options(&b, 3, name, serviceScope, s.Options); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// optionField is the encoding of a single option within an options message.
+type optionField struct {
+	number int
+	data   []byte
+}
+
+// options writes a google.protobuf.*Options message holding the options at the given field number, unless
+// there are no options. Builtin options are encoded by their field in descriptor.proto and custom options
+// by the extension they refer to, which must be declared in the spec or an imported file of the set.
+func (e *descriptorEncoder) options(b *protoBuffer, field int, path string, scope optionScope, options []Option) error {
+	if len(options) == 0 {
+		return nil
+	}
+	var fields []optionField
+	for _, o := range options {
+		v, number, err := e.option(scope, o)
+		if err != nil {
+			return fmt.Errorf("%s: Option %s cannot be encoded: %s", path, o.Name, err)
+		}
+		fields = append(fields, optionField{number, v})
+	}
+	sort.SliceStable(fields, func(i, j int) bool { return fields[i].number < fields[j].number })
+
+	var message protoBuffer
+	for _, f := range fields {
+		message.Write(f.data)
+	}
+	b.bytesField(field, message.Bytes())
+	return nil
+}
+
+// option encodes a single option, returning its encoding and field number.
+func (e *descriptorEncoder) option(scope optionScope, o Option) ([]byte, int, error) {
+	var b protoBuffer
+	ref, custom := customOptionName(o.Name)
+	if !custom {
+		number, ok := builtinOptionNumbers[scope][o.Name]
+		if !ok {
+			return nil, 0, fmt.Errorf("unknown option")
+		}
+		switch v := o.Value.(type) {
+		case StringValue:
+			b.stringField(number, string(v))
+		case BoolValue:
+			b.boolField(number, bool(v))
+		case IdentValue:
+			n, ok := builtinOptionEnums[string(v)]
+			if !ok {
+				return nil, 0, fmt.Errorf("unknown value %s", v)
+			}
+			b.varintField(number, n)
+		default:
+			return nil, 0, fmt.Errorf("unsupported value %s", o.Value.Write())
+		}
+		return b.Bytes(), number, nil
+	}
+
+	name, ext, ok := resolveExtension(e.symbols.extensions, e.pkg, ref)
+	if !ok {
+		return nil, 0, fmt.Errorf("extension %s is not declared in the spec or its imports", ref)
+	}
+	var selectors []string
+	if rest := o.Name[len(ref)+2:]; rest != "" {
+		selectors = strings.Split(rest[1:], ".")
+	}
+	_, tag, _ := fieldNameTag(ext.field)
+	if err := e.value(&b, parentScope(name), ext.field, selectors, o.Value); err != nil {
+		return nil, 0, err
+	}
+	return b.Bytes(), int(tag), nil
+}
+
+// value writes an option value to a field declared within scope. Selectors name a path of fields within
+// a message field that the value is assigned to.
+func (e *descriptorEncoder) value(b *protoBuffer, scope string, f Field, selectors []string, value OptionValue) error {
+	name, tag, _ := fieldNameTag(f)
+	switch f := f.(type) {
+	case ScalarField:
+		if len(selectors) > 0 {
+			return fmt.Errorf("field %s is not a message", name)
+		}
+		return b.scalarField(int(tag), f.Typing, value)
+	case CustomField:
+		typeName, sym, ok := e.symbols.resolve(scope, f.Typing)
+		if !ok {
+			return fmt.Errorf("type %s of field %s is not declared in the spec or its imports", f.Typing, name)
+		}
+		if sym.kind == enumSymbol {
+			if len(selectors) > 0 {
+				return fmt.Errorf("field %s is not a message", name)
+			}
+			n, err := enumNumber(sym.enum, value)
+			if err != nil {
+				return err
+			}
+			b.varintField(int(tag), uint64(n))
+			return nil
+		}
+		if sym.message == nil {
+			return fmt.Errorf("fields of message %s cannot be set", typeName)
+		}
+		fields := messageFields(*sym.message)
+		var message protoBuffer
+		if len(selectors) > 0 {
+			sub, ok := findField(fields, selectors[0])
+			if !ok {
+				return fmt.Errorf("message %s has no field %s", typeName, selectors[0])
+			}
+			if err := e.value(&message, typeName, sub, selectors[1:], value); err != nil {
+				return err
+			}
+		} else if err := e.aggregate(&message, typeName, fields, value); err != nil {
+			return err
+		}
+		b.bytesField(int(tag), message.Bytes())
+		return nil
+	case MapField:
+		key := ScalarField{Name: "key", Typing: f.KeyTyping, Tag: 1}
+		v := ScalarField{Name: "value", Typing: f.ValueTyping, Tag: 2}
+		var entry protoBuffer
+		if err := e.aggregate(&entry, scope, []Field{key, v}, value); err != nil {
+			return err
+		}
+		b.bytesField(int(tag), entry.Bytes())
+		return nil
+	case CustomMapField:
+		key := ScalarField{Name: "key", Typing: f.KeyTyping, Tag: 1}
+		v := CustomField{Name: "value", Typing: f.ValueTyping, Tag: 2}
+		var entry protoBuffer
+		if err := e.aggregate(&entry, scope, []Field{key, v}, value); err != nil {
+			return err
+		}
+		b.bytesField(int(tag), entry.Bytes())
+		return nil
+	default:
+		return fmt.Errorf("field of type %T cannot be set", f)
+	}
+}
+
+// aggregate writes the entries of an aggregate value as the fields of a message.
+func (e *descriptorEncoder) aggregate(b *protoBuffer, scope string, fields []Field, value OptionValue) error {
+	entries, ok := value.(AggregateValue)
+	if !ok {
+		return fmt.Errorf("value %s must be an aggregate", value.Write())
+	}
+	for _, entry := range entries {
+		f, ok := findField(fields, entry.Name)
+		if !ok {
+			return fmt.Errorf("%s is not a field of %s", entry.Name, scope)
+		}
+		if err := e.value(b, scope, f, nil, entry.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findField looks up a field by name.
+func findField(fields []Field, name string) (Field, bool) {
+	for _, f := range fields {
+		if n, _, _ := fieldNameTag(f); string(n) == name {
+			return f, true
+		}
+	}
+	return nil, false
+}
+
+// enumNumber returns the number of the enum value named by an identifier, or given as an integer.
+func enumNumber(en *Enum, value OptionValue) (TagType, error) {
+	switch v := value.(type) {
+	case IntValue:
+		return TagType(v), nil
+	case IdentValue:
+		if en != nil {
+			for _, ev := range en.Values {
+				if string(ev.Name) == string(v) {
+					return ev.Tag, nil
+				}
+			}
+		}
+		return 0, fmt.Errorf("%s is not a value of the enum", v)
+	default:
+		return 0, fmt.Errorf("value %s must be an enum value", value.Write())
+	}
+}
+
+// mapEntryName returns the name protoc gives the entry message of a map field: the field name in
+// CamelCase followed by Entry.
+func mapEntryName(name NameType) string {
+	return lowerCamelCase("_"+string(name)) + "Entry"
+}
+
+// lowerCamelCase converts a field name to the JSON name protoc derives from it, removing underscores and
+// capitalizing the letter following each.
+func lowerCamelCase(name string) string {
+	var b bytes.Buffer
+	upper := false
+	for _, c := range name {
+		switch {
+		case c == '_':
+			upper = true
+		case upper && c >= 'a' && c <= 'z':
+			b.WriteRune(c - 'a' + 'A')
+			upper = false
+		default:
+			b.WriteRune(c)
+			upper = false
+		}
+	}
+	return b.String()
+}
+
+// protoBuffer accumulates a message in the protobuf wire format.
+// https://developers.google.com/protocol-buffers/docs/encoding
+type protoBuffer struct {
+	bytes.Buffer
+}
+
+// Wire types
+const (
+	varintWire  = 0
+	fixed64Wire = 1
+	bytesWire   = 2
+	fixed32Wire = 5
+)
+
+func (b *protoBuffer) key(field, wire int) {
+	b.varint(uint64(field)<<3 | uint64(wire))
+}
+
+func (b *protoBuffer) varint(v uint64) {
+	for v >= 0x80 {
+		b.WriteByte(byte(v) | 0x80)
+		v >>= 7
+	}
+	b.WriteByte(byte(v))
+}
+
+func (b *protoBuffer) varintField(field int, v uint64) {
+	b.key(field, varintWire)
+	b.varint(v)
+}
+
+func (b *protoBuffer) boolField(field int, v bool) {
+	if v {
+		b.varintField(field, 1)
+	} else {
+		b.varintField(field, 0)
+	}
+}
+
+func (b *protoBuffer) fixed32Field(field int, v uint32) {
+	b.key(field, fixed32Wire)
+	b.Write([]byte{byte(v), byte(v >> 8), byte(v >> 16), byte(v >> 24)})
+}
+
+func (b *protoBuffer) fixed64Field(field int, v uint64) {
+	b.key(field, fixed64Wire)
+	for i := uint(0); i < 64; i += 8 {
+		b.WriteByte(byte(v >> i))
+	}
+}
+
+func (b *protoBuffer) bytesField(field int, v []byte) {
+	b.key(field, bytesWire)
+	b.varint(uint64(len(v)))
+	b.Write(v)
+}
+
+func (b *protoBuffer) stringField(field int, v string) {
+	b.bytesField(field, []byte(v))
+}
+
+// scalarField writes an option value to a field of a built-in type.
+func (b *protoBuffer) scalarField(field int, t FieldType, value OptionValue) error {
+	switch v := value.(type) {
+	case FloatValue:
+		return b.floatField(field, t, float64(v))
+	case IntValue:
+		switch t {
+		case DoubleType, FloatType:
+			return b.floatField(field, t, float64(v))
+		case Int32Type, Int64Type, UInt32Type, UInt64Type:
+			b.varintField(field, uint64(v))
+			return nil
+		case SInt32Type, SInt64Type:
+			b.varintField(field, uint64(v<<1)^uint64(v>>63))
+			return nil
+		case Fixed32Type, SFixed32Type:
+			b.fixed32Field(field, uint32(v))
+			return nil
+		case Fixed64Type, SFixed64Type:
+			b.fixed64Field(field, uint64(v))
+			return nil
+		}
+	case UintValue:
+		switch t {
+		case DoubleType, FloatType:
+			return b.floatField(field, t, float64(v))
+		case UInt64Type:
+			b.varintField(field, uint64(v))
+			return nil
+		case Fixed64Type:
+			b.fixed64Field(field, uint64(v))
+			return nil
+		}
+	case BoolValue:
+		if t == BoolType {
+			b.boolField(field, bool(v))
+			return nil
+		}
+	case StringValue:
+		if t == StringType || t == BytesType {
+			b.stringField(field, string(v))
+			return nil
+		}
+	}
+	return fmt.Errorf("value %s cannot be assigned to a field of type %s", value.Write(), t.Write())
+}
+
+// floatField writes a floating-point option value to a double or float field.
+func (b *protoBuffer) floatField(field int, t FieldType, v float64) error {
+	switch t {
+	case DoubleType:
+		b.fixed64Field(field, math.Float64bits(v))
+	case FloatType:
+		b.fixed32Field(field, math.Float32bits(float32(v)))
+	default:
+		return fmt.Errorf("value %s cannot be assigned to a field of type %s", FloatValue(v).Write(), t.Write())
+	}
+	return nil
+}
+
+// symbolKind distinguishes the kinds of type a reference can resolve to.
+type symbolKind uint8
+
+const (
+	messageSymbol symbolKind = iota + 1
+	enumSymbol
+)
+
+// symbol is a message or enum type. The definition is only known for types declared by a spec.
+type symbol struct {
+	kind    symbolKind
+	message *Message
+	enum    *Enum
+	files   []string // the files declaring the type, more than one when its name is declared twice
+}
+
+// symbolTable holds the types and extensions that can be referenced from a spec, keyed by fully-qualified
+// name, along with the packages they are declared in.
+type symbolTable struct {
+	types          map[string]symbol
+	extensions     map[string]extension
+	extensionFiles map[string]string
+	packages       map[string]bool
+}
+
+func newSymbolTable() *symbolTable {
+	return &symbolTable{
+		types:          make(map[string]symbol),
+		extensions:     make(map[string]extension),
+		extensionFiles: make(map[string]string),
+		packages:       make(map[string]bool),
+	}
+}
+
+// addFile adds the types and extensions declared by the spec of a file.
+func (t *symbolTable) addFile(file string, s *Spec) {
+	var add func(prefix string, messages []Message, enums []Enum)
+	add = func(prefix string, messages []Message, enums []Enum) {
+		for i := range enums {
+			t.add(joinPath(prefix, string(enums[i].Name)), file, symbol{kind: enumSymbol, enum: &enums[i]})
+		}
+		for i := range messages {
+			name := joinPath(prefix, messages[i].Name)
+			t.add(name, file, symbol{kind: messageSymbol, message: &messages[i]})
+			add(name, messages[i].Messages, messages[i].Enums)
+		}
+	}
+	add(s.Package, s.Messages, s.Enums)
+	t.addPackage(s.Package)
+	for name, ext := range s.extensions() {
+		t.extensions[name] = ext
+		t.extensionFiles[name] = file
+	}
+}
+
+// addWellKnown adds the types declared by an import of one of the well-known types.
+func (t *symbolTable) addWellKnown(i ImportType) {
+	for name, kind := range wellKnownTypes[i] {
+		t.add(name, string(i), symbol{kind: kind})
+		t.addPackage(parentScope(name))
+	}
+}
+
+// add declares a type in a file, keeping track of every file that declares the same name.
+func (t *symbolTable) add(name, file string, sym symbol) {
+	sym.files = append(t.types[name].files, file)
+	t.types[name] = sym
+}
+
+// addPackage declares a package along with the packages enclosing it.
+func (t *symbolTable) addPackage(pkg string) {
+	for ; pkg != ""; pkg = parentScope(pkg) {
+		t.packages[pkg] = true
+	}
+}
+
+// resolve looks up a type reference made from within scope using protobuf scoping rules: a reference with
+// a leading dot is fully-qualified, otherwise it is resolved relative to each enclosing scope in turn.
+func (t *symbolTable) resolve(scope, ref string) (string, symbol, bool) {
+	name, ok := t.lookup(scope, ref)
+	if !ok {
+		return "", symbol{}, false
+	}
+	return name, t.types[name], true
+}
+
+// lookup resolves a type reference as protoc does: the first component of a relative reference is looked
+// up in each enclosing scope in turn, innermost first, and the rest of the reference must then be declared
+// within the message or package it names. When that fails, lookup returns the name the reference was
+// taken to mean, which is empty unless the first component matched.
+func (t *symbolTable) lookup(scope, ref string) (string, bool) {
+	if strings.HasPrefix(ref, ".") {
+		name := strings.TrimPrefix(ref, ".")
+		_, ok := t.types[name]
+		return name, ok
+	}
+	first := ref
+	if i := strings.Index(ref, "."); i >= 0 {
+		first = ref[:i]
+	}
+	for {
+		name := joinPath(scope, ref)
+		if _, ok := t.types[name]; ok {
+			return name, true
+		}
+		if first != ref {
+			prefix := joinPath(scope, first)
+			if sym, ok := t.types[prefix]; (ok && sym.kind == messageSymbol) || t.packages[prefix] {
+				return name, false
+			}
+		}
+		if scope == "" {
+			return "", false
+		}
+		scope = parentScope(scope)
+	}
+}
+
+// wellKnownTypes lists the types declared by the imports of the well-known types that are distributed
+// with protoc, so that references to them can be resolved without their definitions.
+var wellKnownTypes = map[ImportType]map[string]symbolKind{
+	"google/protobuf/any.proto": {
+		"google.protobuf.Any": messageSymbol,
+	},
+	"google/protobuf/api.proto": {
+		"google.protobuf.Api":    messageSymbol,
+		"google.protobuf.Method": messageSymbol,
+		"google.protobuf.Mixin":  messageSymbol,
+	},
+	DescriptorImport: {
+		"google.protobuf.FileDescriptorSet":        messageSymbol,
+		"google.protobuf.FileDescriptorProto":      messageSymbol,
+		"google.protobuf.DescriptorProto":          messageSymbol,
+		"google.protobuf.FieldDescriptorProto":     messageSymbol,
+		"google.protobuf.OneofDescriptorProto":     messageSymbol,
+		"google.protobuf.EnumDescriptorProto":      messageSymbol,
+		"google.protobuf.EnumValueDescriptorProto": messageSymbol,
+		"google.protobuf.ServiceDescriptorProto":   messageSymbol,
+		"google.protobuf.MethodDescriptorProto":    messageSymbol,
+		"google.protobuf.FileOptions":              messageSymbol,
+		"google.protobuf.MessageOptions":           messageSymbol,
+		"google.protobuf.FieldOptions":             messageSymbol,
+		"google.protobuf.OneofOptions":             messageSymbol,
+		"google.protobuf.EnumOptions":              messageSymbol,
+		"google.protobuf.EnumValueOptions":         messageSymbol,
+		"google.protobuf.ServiceOptions":           messageSymbol,
+		"google.protobuf.MethodOptions":            messageSymbol,
+	},
+	"google/protobuf/duration.proto": {
+		"google.protobuf.Duration": messageSymbol,
+	},
+	"google/protobuf/empty.proto": {
+		"google.protobuf.Empty": messageSymbol,
+	},
+	"google/protobuf/field_mask.proto": {
+		"google.protobuf.FieldMask": messageSymbol,
+	},
+	"google/protobuf/source_context.proto": {
+		"google.protobuf.SourceContext": messageSymbol,
+	},
+	"google/protobuf/struct.proto": {
+		"google.protobuf.Struct":    messageSymbol,
+		"google.protobuf.Value":     messageSymbol,
+		"google.protobuf.ListValue": messageSymbol,
+		"google.protobuf.NullValue": enumSymbol,
+	},
+	"google/protobuf/timestamp.proto": {
+		"google.protobuf.Timestamp": messageSymbol,
+	},
+	"google/protobuf/type.proto": {
+		"google.protobuf.Type":      messageSymbol,
+		"google.protobuf.Field":     messageSymbol,
+		"google.protobuf.Enum":      messageSymbol,
+		"google.protobuf.EnumValue": messageSymbol,
+		"google.protobuf.Option":    messageSymbol,
+		"google.protobuf.Syntax":    enumSymbol,
+	},
+	"google/protobuf/wrappers.proto": {
+		"google.protobuf.DoubleValue": messageSymbol,
+		"google.protobuf.FloatValue":  messageSymbol,
+		"google.protobuf.Int64Value":  messageSymbol,
+		"google.protobuf.UInt64Value": messageSymbol,
+		"google.protobuf.Int32Value":  messageSymbol,
+		"google.protobuf.UInt32Value": messageSymbol,
+		"google.protobuf.BoolValue":   messageSymbol,
+		"google.protobuf.StringValue": messageSymbol,
+		"google.protobuf.BytesValue":  messageSymbol,
+	},
+}
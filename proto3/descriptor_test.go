@@ -0,0 +1,120 @@
+package proto3_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "github.com/muxinc/protogen/proto3"
+)
+
+func TestSpec_Descriptor(t *testing.T) {
+	spec := &Spec{
+		Package:  "mux",
+		Messages: []Message{{Name: "Beacon", Fields: []Field{ScalarField{Name: "view_id", Typing: StringType, Tag: 1}}}},
+	}
+	want := "\x0a\x0cbeacon.proto" + // name
+		"\x12\x03mux" + // package
+		"\x22\x21" + // message_type
+		"\x0a\x06Beacon" + // name
+		"\x12\x17" + // field
+		"\x0a\x07view_id\x18\x01\x20\x01\x28\x09" + // name, number, label, type
+		"\x52\x06viewId" + // json_name
+		"\x62\x06proto3" // syntax
+
+	got, err := spec.Descriptor("beacon.proto")
+	if err != nil {
+		t.Fatalf("Spec.Descriptor() error = %v", err)
+	}
+	if string(got) != want {
+		t.Errorf("Spec.Descriptor() = %q, want %q", got, want)
+	}
+}
+
+func TestSpec_DescriptorTypes(t *testing.T) {
+	spec := &Spec{
+		Package: "mux",
+		Imports: []ImportType{"google/protobuf/timestamp.proto", "events.proto"},
+		Messages: []Message{{
+			Name:     "Beacon",
+			Messages: []Message{{Name: "Player", Fields: []Field{ScalarField{Name: "name", Typing: StringType, Tag: 1}}}},
+			Enums:    []Enum{{Name: "Kind", Values: []EnumValue{{Name: "UNKNOWN", Tag: 0}}}},
+			Fields: []Field{
+				CustomField{Name: "player", Typing: "Player", Tag: 1},
+				CustomField{Name: "kind", Typing: "Beacon.Kind", Tag: 2},
+				CustomField{Name: "at", Typing: "google.protobuf.Timestamp", Tag: 3},
+				CustomField{Name: "events", Typing: "Event", Tag: 4, Rule: Repeated},
+				CustomMapField{Name: "player_sessions", KeyTyping: StringType, ValueTyping: "Player", Tag: 5},
+				ScalarField{Name: "score", Typing: DoubleType, Tag: 6, Rule: Optional},
+			},
+		}},
+	}
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Nested message", "\x28\x0b\x32\x12.mux.Beacon.Player"},
+		{"Enum", "\x28\x0e\x32\x10.mux.Beacon.Kind"},
+		{"Well-known type", "\x28\x0b\x32\x1a.google.protobuf.Timestamp"},
+		{"Unresolved type", "\x20\x03\x32\x05Event"},
+		{"Map field", "\x20\x03\x28\x0b\x32\x1f.mux.Beacon.PlayerSessionsEntry"},
+		{"Map entry", "\x0a\x13PlayerSessionsEntry"},
+		{"Map entry option", "\x3a\x02\x38\x01"},
+		{"Optional field", "\x48\x00\x52\x05score\x88\x01\x01"},
+		{"Synthetic oneof", "\x42\x08\x0a\x06_score"},
+	}
+	got, err := spec.Descriptor("beacon.proto")
+	if err != nil {
+		t.Fatalf("Spec.Descriptor() error = %v", err)
+	}
+	for _, tt := range tests {
+		if !bytes.Contains(got, []byte(tt.want)) {
+			t.Errorf("%q. Spec.Descriptor() = %q, want it to contain %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDescriptorSet(t *testing.T) {
+	events := &Spec{
+		Package:  "mux.events",
+		Imports:  []ImportType{DescriptorImport},
+		Messages: []Message{{Name: "Event", Fields: []Field{ScalarField{Name: "name", Typing: StringType, Tag: 1}}}},
+		Extends:  []Extend{{Typing: "google.protobuf.FieldOptions", Fields: []Field{ScalarField{Name: "owner", Typing: StringType, Tag: 50000}}}},
+	}
+	beacon := &Spec{
+		Package: "mux",
+		Imports: []ImportType{"events.proto"},
+		Messages: []Message{{
+			Name: "Beacon",
+			Fields: []Field{
+				CustomField{Name: "events", Typing: "events.Event", Tag: 1, Rule: Repeated, Options: []Option{{Name: "(events.owner)", Value: StringValue("video")}}},
+			},
+		}},
+	}
+
+	got, err := DescriptorSet(DescriptorFile{Name: "events.proto", Spec: events}, DescriptorFile{Name: "beacon.proto", Spec: beacon})
+	if err != nil {
+		t.Fatalf("DescriptorSet() error = %v", err)
+	}
+	for _, want := range []string{
+		"\x0a\x0cevents.proto",                    // first file
+		"\x12\x1d.google.protobuf.FieldOptions",   // extendee
+		"\x28\x0b\x32\x11.mux.events.Event",       // resolved type
+		"\x42\x09\x82\xb5\x18\x05video",           // custom option
+		"\x0a\x0cbeacon.proto\x12\x03mux\x1a\x0c", // second file and its dependency
+	} {
+		if !bytes.Contains(got, []byte(want)) {
+			t.Errorf("DescriptorSet() = %q, want it to contain %q", got, want)
+		}
+	}
+
+	_, err = beacon.Descriptor("beacon.proto")
+	if err == nil || !strings.Contains(err.Error(), "extension events.owner is not declared") {
+		t.Errorf("Spec.Descriptor() error = %v, want unresolved extension", err)
+	}
+	_, err = DescriptorSet(DescriptorFile{Name: "beacon.proto", Spec: beacon}, DescriptorFile{Name: "beacon.proto", Spec: beacon})
+	if err == nil || !strings.Contains(err.Error(), "more than one file named beacon.proto") {
+		t.Errorf("DescriptorSet() error = %v, want duplicate file", err)
+	}
+}
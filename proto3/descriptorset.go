@@ -0,0 +1,940 @@
+package proto3
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LoadDescriptorSet reads a binary google.protobuf.FileDescriptorSet, such as one written by protoc with
+// --descriptor_set_out, and reconstructs the spec of each file in it. Map fields are restored from their
+// entry messages, optional fields from their synthetic oneofs, and comments from source_code_info when the
+// set was written with it. Type references within the package of a file are written relative to the
+// package. Files of the well-known types, which protoc adds to a set with --include_imports, are skipped
+// since specs refer to them by import. Files in proto2 syntax that other files of the set import are left
+// out too; their types and extensions are only used to decode the files that import them, where a Spec
+// can represent them. Any other file in proto2 syntax is an error. The specs are not validated.
+func LoadDescriptorSet(r io.Reader) ([]DescriptorFile, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	set, err := decodeProto(data)
+	if err != nil {
+		return nil, err
+	}
+	files, err := set.messages(1)
+	if err != nil {
+		return nil, err
+	}
+
+	// Custom options can only be decoded once the extensions they refer to are known, so the files are
+	// decoded a second time with the types and extensions found by the first pass.
+	dependencies := proto2Dependencies(files)
+	first, err := decodeFiles(files, dependencies, nil)
+	if err != nil {
+		return nil, err
+	}
+	specs := make(map[string]*Spec)
+	for _, f := range first {
+		specs[f.Name] = f.Spec
+	}
+	decoded, err := decodeFiles(files, dependencies, specs)
+	if err != nil {
+		return nil, err
+	}
+	var requested []DescriptorFile
+	for _, f := range decoded {
+		if !dependencies[f.Name] {
+			requested = append(requested, f)
+		}
+	}
+	return requested, nil
+}
+
+// proto2Dependencies returns the names of the files in proto2 syntax that another file of the set imports.
+func proto2Dependencies(files []protoMessage) map[string]bool {
+	imported := make(map[string]bool)
+	for _, f := range files {
+		for _, i := range f.strs(3) {
+			imported[i] = true
+		}
+	}
+	dependencies := make(map[string]bool)
+	for _, f := range files {
+		if name := f.str(1); f.str(12) != "proto3" && imported[name] {
+			dependencies[name] = true
+		}
+	}
+	return dependencies
+}
+
+// decodeFiles decodes each FileDescriptorProto that is not a well-known type. Custom options are only
+// decoded when the specs of a previous pass are given to resolve them against. The proto2 dependencies
+// are decoded as far as a Spec can represent them, and are left out when it cannot.
+func decodeFiles(files []protoMessage, dependencies map[string]bool, specs map[string]*Spec) ([]DescriptorFile, error) {
+	var decoded []DescriptorFile
+	for _, f := range files {
+		name := f.str(1)
+		if _, ok := wellKnownTypes[ImportType(name)]; ok {
+			continue
+		}
+		d := descriptorDecoder{pkg: f.str(2), dependency: dependencies[name]}
+		if specs != nil && specs[name] == nil {
+			continue // a proto2 dependency the first pass could not decode
+		}
+		if specs != nil {
+			d.symbols = newSymbolTable()
+			d.symbols.addFile(name, specs[name])
+			for _, i := range f.strs(3) {
+				if dep, ok := specs[i]; ok {
+					d.symbols.addFile(i, dep)
+				} else {
+					d.symbols.addWellKnown(ImportType(i))
+				}
+			}
+		}
+		spec, err := d.file(f)
+		if err != nil && d.dependency {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err)
+		}
+		decoded = append(decoded, DescriptorFile{Name: name, Spec: spec})
+	}
+	return decoded, nil
+}
+
+// descriptorDecoder reconstructs the elements of a spec from a FileDescriptorProto.
+type descriptorDecoder struct {
+	symbols    *symbolTable // nil when custom options are not decoded
+	pkg        string
+	comments   map[string]string
+	dependency bool // a proto2 file only decoded for the types and extensions it declares
+}
+
+// file decodes a FileDescriptorProto.
+func (d *descriptorDecoder) file(f protoMessage) (*Spec, error) {
+	if syntax := f.str(12); syntax != "proto3" && !d.dependency {
+		if syntax == "" {
+			syntax = "proto2"
+		}
+		return nil, fmt.Errorf("File uses %s syntax, but only proto3 can be represented by a Spec", syntax)
+	}
+	info, err := f.message(9)
+	if err != nil {
+		return nil, err
+	}
+	if d.comments, err = decodeComments(info); err != nil {
+		return nil, err
+	}
+
+	s := &Spec{Package: d.pkg, FileComment: d.comment([]int{12})}
+	for _, i := range f.strs(3) {
+		s.Imports = append(s.Imports, ImportType(i))
+	}
+	messages, err := f.messages(4)
+	if err != nil {
+		return nil, err
+	}
+	for i, m := range messages {
+		v, err := d.message(d.pkg, []int{4, i}, m)
+		if err != nil {
+			return nil, err
+		}
+		s.Messages = append(s.Messages, v)
+	}
+	enums, err := f.messages(5)
+	if err != nil {
+		return nil, err
+	}
+	for i, e := range enums {
+		v, err := d.enum([]int{5, i}, e)
+		if err != nil {
+			return nil, err
+		}
+		s.Enums = append(s.Enums, v)
+	}
+	services, err := f.messages(6)
+	if err != nil {
+		return nil, err
+	}
+	for i, svc := range services {
+		v, err := d.service([]int{6, i}, svc)
+		if err != nil {
+			return nil, err
+		}
+		s.Services = append(s.Services, v)
+	}
+	if s.Extends, err = d.extends(d.pkg, nil, f, 7); err != nil {
+		return nil, err
+	}
+
+	options, err := d.options(f, 8, fileScope)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range options {
+		switch v, _ := o.Value.(StringValue); {
+		case o.Name == "go_package" && v != "":
+			s.GoPackage = string(v)
+		case o.Name == "java_package" && v != "":
+			s.JavaPackage = string(v)
+		default:
+			s.Options = append(s.Options, o)
+		}
+	}
+	return s, nil
+}
+
+// message decodes a DescriptorProto declared within scope.
+func (d *descriptorDecoder) message(scope string, path []int, m protoMessage) (Message, error) {
+	msg := Message{Name: m.str(1), Comment: d.comment(path)}
+	name := joinPath(scope, msg.Name)
+
+	nested, err := m.messages(3)
+	if err != nil {
+		return msg, err
+	}
+	entries := make(map[string]protoMessage)
+	for i, n := range nested {
+		options, err := n.message(7)
+		if err != nil {
+			return msg, err
+		}
+		if options.bool(builtinOptionNumbers[messageScope]["map_entry"]) {
+			entries["."+joinPath(name, n.str(1))] = n
+			continue
+		}
+		v, err := d.message(name, appendPath(path, 3, i), n)
+		if err != nil {
+			return msg, err
+		}
+		msg.Messages = append(msg.Messages, v)
+	}
+
+	enums, err := m.messages(4)
+	if err != nil {
+		return msg, err
+	}
+	for i, e := range enums {
+		v, err := d.enum(appendPath(path, 4, i), e)
+		if err != nil {
+			return msg, err
+		}
+		msg.Enums = append(msg.Enums, v)
+	}
+
+	// Fields are either declared directly in the message or within one of its oneofs. Oneofs that only
+	// hold an optional field were synthesized for it by protoc.
+	fields, err := m.messages(2)
+	if err != nil {
+		return msg, err
+	}
+	synthetic := make(map[int]bool)
+	for _, f := range fields {
+		if i, ok := f.uint(9); ok && f.bool(17) {
+			synthetic[int(i)] = true
+		}
+	}
+	decls, err := m.messages(8)
+	if err != nil {
+		return msg, err
+	}
+	oneofs := make(map[int]int)
+	for i, o := range decls {
+		if synthetic[i] {
+			continue
+		}
+		options, err := d.options(o, 2, oneofScope)
+		if err != nil {
+			return msg, err
+		}
+		oneofs[i] = len(msg.OneOfs)
+		msg.OneOfs = append(msg.OneOfs, OneOf{Name: NameType(o.str(1)), Comment: d.comment(appendPath(path, 8, i)), Options: options})
+	}
+	for i, f := range fields {
+		v, err := d.field(name, appendPath(path, 2, i), f, entries)
+		if err != nil {
+			return msg, err
+		}
+		if o, ok := f.uint(9); ok && !synthetic[int(o)] {
+			if int(o) >= len(decls) {
+				return msg, fmt.Errorf("Field %s.%s refers to oneof %d, which is not declared", name, f.str(1), o)
+			}
+			oneof := &msg.OneOfs[oneofs[int(o)]]
+			oneof.Fields = append(oneof.Fields, v)
+			continue
+		}
+		msg.Fields = append(msg.Fields, v)
+	}
+
+	if msg.Extends, err = d.extends(name, path, m, 6); err != nil {
+		return msg, err
+	}
+	if msg.Options, err = d.options(m, 7, messageScope); err != nil {
+		return msg, err
+	}
+
+	ranges, err := m.messages(9)
+	if err != nil {
+		return msg, err
+	}
+	for _, r := range ranges {
+		start, _ := r.uint(1)
+		end, _ := r.uint(2)
+		switch {
+		case TagType(end) == MaxFieldTag+1:
+			msg.ReservedValues = append(msg.ReservedValues, ReservedTagRange{LowerTag: TagType(start), UpperTag: MaxTag})
+		case end == start+1:
+			msg.ReservedValues = append(msg.ReservedValues, ReservedTagValue{Tag: TagType(start)})
+		default:
+			msg.ReservedValues = append(msg.ReservedValues, ReservedTagRange{LowerTag: TagType(start), UpperTag: TagType(end - 1)})
+		}
+	}
+	for _, r := range m.strs(10) {
+		msg.ReservedValues = append(msg.ReservedValues, ReservedName{Name: NameType(r)})
+	}
+	return msg, nil
+}
+
+// field decodes a FieldDescriptorProto declared within scope. A repeated field of one of the map entry
+// messages of its message is decoded as a map field.
+func (d *descriptorDecoder) field(scope string, path []int, f protoMessage, entries map[string]protoMessage) (Field, error) {
+	name := NameType(f.str(1))
+	number, _ := f.uint(3)
+	tag := TagType(number)
+	rule := None
+	if label, _ := f.uint(4); label == repeatedLabel {
+		rule = Repeated
+	} else if f.bool(17) {
+		rule = Optional
+	}
+
+	options, err := d.options(f, 8, fieldScope)
+	if err != nil {
+		return nil, err
+	}
+	if f.has(10) && f.str(10) != lowerCamelCase(string(name)) {
+		options = append([]Option{{Name: "json_name", Value: StringValue(f.str(10))}}, options...)
+	}
+	comment := d.comment(path)
+
+	if entry, ok := entries[f.str(6)]; ok && rule == Repeated {
+		entryFields, err := entry.messages(2)
+		if err != nil {
+			return nil, err
+		}
+		var key, value Field
+		for _, ef := range entryFields {
+			v, err := d.field(scope, nil, ef, nil)
+			if err != nil {
+				return nil, err
+			}
+			if n, _ := ef.uint(3); n == 1 {
+				key = v
+			} else if n == 2 {
+				value = v
+			}
+		}
+		k, ok := key.(ScalarField)
+		if !ok {
+			return nil, fmt.Errorf("Map field %s.%s has an entry without a scalar key", scope, name)
+		}
+		switch v := value.(type) {
+		case ScalarField:
+			return MapField{Name: name, Tag: tag, Comment: comment, KeyTyping: k.Typing, ValueTyping: v.Typing, Options: options}, nil
+		case CustomField:
+			return CustomMapField{Name: name, Tag: tag, Comment: comment, KeyTyping: k.Typing, ValueTyping: v.Typing, Options: options}, nil
+		default:
+			return nil, fmt.Errorf("Map field %s.%s has an entry without a value", scope, name)
+		}
+	}
+
+	typ, _ := f.uint(5)
+	switch typ {
+	case 0, messageFieldType, enumFieldType:
+		return CustomField{Name: name, Tag: tag, Rule: rule, Comment: comment, Typing: d.typeRef(f.str(6)), Options: options}, nil
+	}
+	for t, n := range descriptorFieldTypes {
+		if n == typ {
+			return ScalarField{Name: name, Tag: tag, Rule: rule, Comment: comment, Typing: t, Options: options}, nil
+		}
+	}
+	return nil, fmt.Errorf("Field %s.%s has type %d, which cannot be represented in proto3", scope, name, typ)
+}
+
+// typeRef returns a type reference written relative to the package of the file, when it is within it.
+func (d *descriptorDecoder) typeRef(name string) string {
+	name = strings.TrimPrefix(name, ".")
+	if d.pkg != "" && strings.HasPrefix(name, d.pkg+".") {
+		return strings.TrimPrefix(name, d.pkg+".")
+	}
+	return name
+}
+
+// extends decodes the extension fields held by a field of a file or message into extend blocks, one for
+// each run of fields that extend the same message.
+func (d *descriptorDecoder) extends(scope string, path []int, m protoMessage, field int) ([]Extend, error) {
+	fields, err := m.messages(field)
+	if err != nil {
+		return nil, err
+	}
+	var extends []Extend
+	for i, f := range fields {
+		v, err := d.field(scope, appendPath(path, field, i), f, nil)
+		if err != nil {
+			return nil, err
+		}
+		typing := strings.TrimPrefix(f.str(2), ".")
+		if n := len(extends); n > 0 && extends[n-1].Typing == typing {
+			extends[n-1].Fields = append(extends[n-1].Fields, v)
+			continue
+		}
+		extends = append(extends, Extend{Typing: typing, Fields: []Field{v}})
+	}
+	return extends, nil
+}
+
+// enum decodes an EnumDescriptorProto.
+func (d *descriptorDecoder) enum(path []int, e protoMessage) (Enum, error) {
+	en := Enum{Name: NameType(e.str(1)), Comment: d.comment(path)}
+	values, err := e.messages(2)
+	if err != nil {
+		return en, err
+	}
+	for i, v := range values {
+		number, _ := v.uint(2)
+		options, err := d.options(v, 3, enumValueScope)
+		if err != nil {
+			return en, err
+		}
+		en.Values = append(en.Values, EnumValue{
+			Name:    NameType(v.str(1)),
+			Tag:     TagType(int32(number)),
+			Comment: d.comment(appendPath(path, 2, i)),
+			Options: options,
+		})
+	}
+	options, err := d.options(e, 3, enumScope)
+	if err != nil {
+		return en, err
+	}
+	for _, o := range options {
+		if v, ok := o.Value.(BoolValue); ok && o.Name == "allow_alias" && bool(v) {
+			en.AllowAlias = true
+			continue
+		}
+		en.Options = append(en.Options, o)
+	}
+
+	ranges, err := e.messages(4)
+	if err != nil {
+		return en, err
+	}
+	for _, r := range ranges {
+		start, _ := r.uint(1)
+		end, _ := r.uint(2)
+		if lower, upper := TagType(int32(start)), TagType(int32(end)); lower == upper {
+			en.ReservedValues = append(en.ReservedValues, ReservedTagValue{Tag: lower})
+		} else {
+			en.ReservedValues = append(en.ReservedValues, ReservedTagRange{LowerTag: lower, UpperTag: upper})
+		}
+	}
+	for _, r := range e.strs(5) {
+		en.ReservedValues = append(en.ReservedValues, ReservedName{Name: NameType(r)})
+	}
+	return en, nil
+}
+
+// service decodes a ServiceDescriptorProto.
+func (d *descriptorDecoder) service(path []int, s protoMessage) (Service, error) {
+	svc := Service{Name: NameType(s.str(1)), Comment: d.comment(path)}
+	methods, err := s.messages(2)
+	if err != nil {
+		return svc, err
+	}
+	for i, m := range methods {
+		options, err := d.options(m, 4, methodScope)
+		if err != nil {
+			return svc, err
+		}
+		method := Method{
+			Name:         NameType(m.str(1)),
+			Comment:      d.comment(appendPath(path, 2, i)),
+			RequestType:  d.typeRef(m.str(2)),
+			ResponseType: d.typeRef(m.str(3)),
+			Options:      options,
+		}
+		switch client, server := m.bool(5), m.bool(6); {
+		case client && server:
+			method.Streaming = BidiStreaming
+		case client:
+			method.Streaming = ClientStreaming
+		case server:
+			method.Streaming = ServerStreaming
+		}
+		svc.Methods = append(svc.Methods, method)
+	}
+	if svc.Options, err = d.options(s, 3, serviceScope); err != nil {
+		return svc, err
+	}
+	return svc, nil
+}
+
+// options decodes the google.protobuf.*Options message held by a field of m. Builtin options are decoded
+// by their field in descriptor.proto and custom options by the extension of the options message with the
+// same tag, in order of their field numbers.
+func (d *descriptorDecoder) options(m protoMessage, field int, scope optionScope) ([]Option, error) {
+	message, err := m.message(field)
+	if err != nil {
+		return nil, err
+	}
+	var options []Option
+	for _, number := range message.fields() {
+		name, builtin := builtinOptionName(scope, number)
+		for _, v := range message[number] {
+			if builtin {
+				o, err := decodeBuiltinOption(scope, name, v)
+				if err != nil {
+					return nil, err
+				}
+				options = append(options, o)
+				continue
+			}
+			if d.symbols == nil {
+				continue
+			}
+			name, ext, ok := d.symbols.extensionByTag(scope, TagType(number))
+			if !ok {
+				return nil, fmt.Errorf("Option with field number %d does not resolve to a builtin option or an extension in the set", number)
+			}
+			values, err := d.value(parentScope(name), ext.field, v)
+			if err != nil {
+				return nil, fmt.Errorf("Option (%s): %s", name, err)
+			}
+			for _, value := range values {
+				options = append(options, Option{Name: "(" + name + ")", Value: value})
+			}
+		}
+	}
+	return options, nil
+}
+
+// value decodes the value of a field declared within scope. A packed repeated field holds several values.
+func (d *descriptorDecoder) value(scope string, f Field, v protoValue) ([]OptionValue, error) {
+	switch f := f.(type) {
+	case ScalarField:
+		return scalarValues(f.Typing, v)
+	case CustomField:
+		typeName, sym, ok := d.symbols.resolve(scope, f.Typing)
+		if !ok {
+			return nil, fmt.Errorf("type %s is not declared in the set", f.Typing)
+		}
+		if sym.kind == enumSymbol {
+			numbers, err := scalarValues(Int32Type, v)
+			if err != nil {
+				return nil, err
+			}
+			for i, n := range numbers {
+				numbers[i] = enumIdent(sym.enum, TagType(n.(IntValue)))
+			}
+			return numbers, nil
+		}
+		if sym.message == nil {
+			return nil, fmt.Errorf("fields of message %s cannot be decoded", typeName)
+		}
+		value, err := d.aggregate(typeName, messageFields(*sym.message), v)
+		return []OptionValue{value}, err
+	case MapField:
+		key := ScalarField{Name: "key", Typing: f.KeyTyping, Tag: 1}
+		value, err := d.aggregate(scope, []Field{key, ScalarField{Name: "value", Typing: f.ValueTyping, Tag: 2}}, v)
+		return []OptionValue{value}, err
+	case CustomMapField:
+		key := ScalarField{Name: "key", Typing: f.KeyTyping, Tag: 1}
+		value, err := d.aggregate(scope, []Field{key, CustomField{Name: "value", Typing: f.ValueTyping, Tag: 2}}, v)
+		return []OptionValue{value}, err
+	default:
+		return nil, fmt.Errorf("field of type %T cannot be decoded", f)
+	}
+}
+
+// aggregate decodes a message value with the given fields into an aggregate, in order of the fields.
+func (d *descriptorDecoder) aggregate(scope string, fields []Field, v protoValue) (AggregateValue, error) {
+	if v.wire != bytesWire {
+		return nil, errors.New("message value is not length-delimited")
+	}
+	message, err := decodeProto(v.data)
+	if err != nil {
+		return nil, err
+	}
+	var entries AggregateValue
+	for _, f := range fields {
+		name, tag, _ := fieldNameTag(f)
+		for _, fv := range message[int(tag)] {
+			values, err := d.value(scope, f, fv)
+			if err != nil {
+				return nil, err
+			}
+			for _, value := range values {
+				entries = append(entries, Option{Name: string(name), Value: value})
+			}
+		}
+	}
+	return entries, nil
+}
+
+// builtinOptionName returns the name of the builtin option with a field number.
+func builtinOptionName(scope optionScope, number int) (string, bool) {
+	for name, n := range builtinOptionNumbers[scope] {
+		if n == number {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// decodeBuiltinOption decodes the value of a builtin option according to the type it is declared with.
+func decodeBuiltinOption(scope optionScope, name string, v protoValue) (Option, error) {
+	builtin, ok := builtinOptions[scope][name]
+	if !ok {
+		return Option{}, fmt.Errorf("Option %s cannot be represented", name)
+	}
+	switch builtin.value.(type) {
+	case StringValue:
+		return Option{Name: name, Value: StringValue(v.data)}, nil
+	case BoolValue:
+		return Option{Name: name, Value: BoolValue(v.n != 0)}, nil
+	default:
+		for _, ident := range builtin.idents {
+			if builtinOptionEnums[ident] == v.n {
+				return Option{Name: name, Value: IdentValue(ident)}, nil
+			}
+		}
+		return Option{}, fmt.Errorf("Option %s has unknown value %d", name, v.n)
+	}
+}
+
+// extensionByTag looks up the extension of the options message for a scope with a tag.
+func (t *symbolTable) extensionByTag(scope optionScope, tag TagType) (string, extension, bool) {
+	var names []string
+	for name, ext := range t.extensions {
+		if _, extTag, _ := fieldNameTag(ext.field); ext.scope == scope && extTag == tag {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "", extension{}, false
+	}
+	sort.Strings(names)
+	return names[0], t.extensions[names[0]], true
+}
+
+// parentScope returns the scope a fully-qualified name is declared within.
+func parentScope(name string) string {
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		return name[:i]
+	}
+	return ""
+}
+
+// enumIdent returns the name of the enum value with a number, or the number when it has no name.
+func enumIdent(en *Enum, number TagType) OptionValue {
+	if en != nil {
+		for _, v := range en.Values {
+			if v.Tag == number {
+				return IdentValue(v.Name)
+			}
+		}
+	}
+	return IntValue(number)
+}
+
+// scalarValues decodes a value of a built-in type. Numeric values may be packed into a single
+// length-delimited value.
+func scalarValues(t FieldType, v protoValue) ([]OptionValue, error) {
+	if t == StringType || t == BytesType {
+		if v.wire != bytesWire {
+			return nil, fmt.Errorf("%s value is not length-delimited", t.Write())
+		}
+		return []OptionValue{StringValue(v.data)}, nil
+	}
+	values := []protoValue{v}
+	if v.wire == bytesWire {
+		var err error
+		if values, err = unpack(t, v.data); err != nil {
+			return nil, err
+		}
+	}
+
+	var decoded []OptionValue
+	for _, v := range values {
+		n := v.n
+		switch t {
+		case DoubleType:
+			decoded = append(decoded, FloatValue(math.Float64frombits(n)))
+		case FloatType:
+			decoded = append(decoded, FloatValue(math.Float32frombits(uint32(n))))
+		case Int32Type, SFixed32Type:
+			decoded = append(decoded, IntValue(int32(n)))
+		case UInt32Type, Fixed32Type:
+			decoded = append(decoded, IntValue(uint32(n)))
+		case SInt32Type, SInt64Type:
+			decoded = append(decoded, IntValue(int64(n>>1)^-int64(n&1)))
+		case BoolType:
+			decoded = append(decoded, BoolValue(n != 0))
+		case UInt64Type, Fixed64Type:
+			decoded = append(decoded, intValue(n))
+		default:
+			decoded = append(decoded, IntValue(n))
+		}
+	}
+	return decoded, nil
+}
+
+// unpack splits the packed encoding of repeated values of a built-in numeric type.
+func unpack(t FieldType, data []byte) ([]protoValue, error) {
+	wire := varintWire
+	switch t {
+	case DoubleType, Fixed64Type, SFixed64Type:
+		wire = fixed64Wire
+	case FloatType, Fixed32Type, SFixed32Type:
+		wire = fixed32Wire
+	}
+	var values []protoValue
+	for len(data) > 0 {
+		v, n, err := readValue(wire, data)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+		data = data[n:]
+	}
+	return values, nil
+}
+
+// decodeComments collects the comments of source_code_info, keyed by the path of the element they are
+// attached to. Comments are joined onto a single line, since that is how specs hold them.
+func decodeComments(info protoMessage) (map[string]string, error) {
+	comments := make(map[string]string)
+	locations, err := info.messages(1)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range locations {
+		path, err := l.packed(1)
+		if err != nil {
+			return nil, err
+		}
+		comment := l.str(3)
+		if comment == "" {
+			comment = l.str(4)
+		}
+		if detached := l.strs(6); comment == "" && len(detached) > 0 && len(path) == 1 && path[0] == 12 {
+			comment = detached[len(detached)-1] // the file comment is detached from the syntax statement by a blank line
+		}
+		var lines []string
+		for _, line := range strings.Split(comment, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				lines = append(lines, line)
+			}
+		}
+		if len(lines) > 0 {
+			comments[pathKey(path)] = strings.Join(lines, " ")
+		}
+	}
+	return comments, nil
+}
+
+// comment returns the comment attached to the element at a path of source_code_info.
+func (d *descriptorDecoder) comment(path []int) string {
+	if path == nil {
+		return ""
+	}
+	keys := make([]uint64, len(path))
+	for i, p := range path {
+		keys[i] = uint64(p)
+	}
+	return d.comments[pathKey(keys)]
+}
+
+func pathKey(path []uint64) string {
+	parts := make([]string, len(path))
+	for i, p := range path {
+		parts[i] = strconv.FormatUint(p, 10)
+	}
+	return strings.Join(parts, ".")
+}
+
+// appendPath returns a copy of path extended with elements.
+func appendPath(path []int, elements ...int) []int {
+	return append(append([]int{}, path...), elements...)
+}
+
+// protoValue is a single value read from the protobuf wire format. Varint and fixed-width values are held
+// in n, and length-delimited values in data.
+type protoValue struct {
+	wire int
+	n    uint64
+	data []byte
+}
+
+// protoMessage holds the values read for each field number of a message, in the order they were read.
+type protoMessage map[int][]protoValue
+
+var errTruncated = errors.New("Descriptor is truncated or malformed")
+
+// decodeProto reads a message in the protobuf wire format.
+func decodeProto(data []byte) (protoMessage, error) {
+	m := make(protoMessage)
+	for len(data) > 0 {
+		key, n := readVarint(data)
+		if n == 0 || key>>3 == 0 {
+			return nil, errTruncated
+		}
+		v, size, err := readValue(int(key&7), data[n:])
+		if err != nil {
+			return nil, err
+		}
+		m[int(key>>3)] = append(m[int(key>>3)], v)
+		data = data[n+size:]
+	}
+	return m, nil
+}
+
+// readValue reads a value of a wire type, returning its size.
+func readValue(wire int, data []byte) (protoValue, int, error) {
+	v := protoValue{wire: wire}
+	switch wire {
+	case varintWire:
+		var n int
+		if v.n, n = readVarint(data); n == 0 {
+			return v, 0, errTruncated
+		}
+		return v, n, nil
+	case fixed64Wire:
+		if len(data) < 8 {
+			return v, 0, errTruncated
+		}
+		for i := uint(0); i < 8; i++ {
+			v.n |= uint64(data[i]) << (8 * i)
+		}
+		return v, 8, nil
+	case fixed32Wire:
+		if len(data) < 4 {
+			return v, 0, errTruncated
+		}
+		for i := uint(0); i < 4; i++ {
+			v.n |= uint64(data[i]) << (8 * i)
+		}
+		return v, 4, nil
+	case bytesWire:
+		size, n := readVarint(data)
+		if n == 0 || size > uint64(len(data)-n) {
+			return v, 0, errTruncated
+		}
+		v.data = data[n : n+int(size)]
+		return v, n + int(size), nil
+	default:
+		return v, 0, fmt.Errorf("Descriptor uses unsupported wire type %d", wire)
+	}
+}
+
+// readVarint reads a varint, returning its size, or zero when it is malformed.
+func readVarint(data []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < len(data) && i < 10; i++ {
+		v |= uint64(data[i]&0x7f) << (7 * uint(i))
+		if data[i] < 0x80 {
+			return v, i + 1
+		}
+	}
+	return 0, 0
+}
+
+// fields returns the field numbers present in the message, in ascending order.
+func (m protoMessage) fields() []int {
+	var numbers []int
+	for n := range m {
+		numbers = append(numbers, n)
+	}
+	sort.Ints(numbers)
+	return numbers
+}
+
+func (m protoMessage) has(field int) bool {
+	return len(m[field]) > 0
+}
+
+// str returns the last value of a string field, or an empty string.
+func (m protoMessage) str(field int) string {
+	if values := m[field]; len(values) > 0 {
+		return string(values[len(values)-1].data)
+	}
+	return ""
+}
+
+func (m protoMessage) strs(field int) []string {
+	var values []string
+	for _, v := range m[field] {
+		values = append(values, string(v.data))
+	}
+	return values
+}
+
+// uint returns the last value of a numeric field.
+func (m protoMessage) uint(field int) (uint64, bool) {
+	if values := m[field]; len(values) > 0 {
+		return values[len(values)-1].n, true
+	}
+	return 0, false
+}
+
+func (m protoMessage) bool(field int) bool {
+	v, _ := m.uint(field)
+	return v != 0
+}
+
+// message decodes the last value of a message field, which is empty when the field is not set.
+func (m protoMessage) message(field int) (protoMessage, error) {
+	if values := m[field]; len(values) > 0 {
+		return decodeProto(values[len(values)-1].data)
+	}
+	return protoMessage{}, nil
+}
+
+func (m protoMessage) messages(field int) ([]protoMessage, error) {
+	var messages []protoMessage
+	for _, v := range m[field] {
+		message, err := decodeProto(v.data)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+// packed returns the values of a repeated varint field, whether or not they are packed.
+func (m protoMessage) packed(field int) ([]uint64, error) {
+	var values []uint64
+	for _, v := range m[field] {
+		if v.wire != bytesWire {
+			values = append(values, v.n)
+			continue
+		}
+		unpacked, err := unpack(UInt64Type, v.data)
+		if err != nil {
+			return nil, err
+		}
+		for _, u := range unpacked {
+			values = append(values, u.n)
+		}
+	}
+	return values, nil
+}
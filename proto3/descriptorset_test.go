@@ -0,0 +1,182 @@
+package proto3_test
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	. "github.com/muxinc/protogen/proto3"
+)
+
+func TestLoadDescriptorSet(t *testing.T) {
+	common := &Spec{
+		Package:  "mux.common",
+		Imports:  []ImportType{DescriptorImport},
+		Messages: []Message{{Name: "Limits", Fields: []Field{ScalarField{Name: "min", Typing: SInt32Type, Tag: 1}, ScalarField{Name: "max", Typing: DoubleType, Tag: 2}}}},
+		Enums:    []Enum{{Name: "Level", Values: []EnumValue{{Name: "LOW", Tag: 0}, {Name: "HIGH", Tag: 1}}}},
+		Extends: []Extend{{
+			Typing: "google.protobuf.FieldOptions",
+			Fields: []Field{
+				CustomField{Name: "limits", Typing: "Limits", Tag: 50000},
+				CustomField{Name: "level", Typing: "Level", Tag: 50001},
+				ScalarField{Name: "owners", Typing: StringType, Tag: 50002, Rule: Repeated},
+			},
+		}},
+	}
+	beacon := &Spec{
+		Package:   "mux",
+		GoPackage: "github.com/muxinc/beacon",
+		Imports:   []ImportType{"google/protobuf/timestamp.proto", "common.proto"},
+		Options:   []Option{{Name: "optimize_for", Value: IdentValue("CODE_SIZE")}, {Name: "java_multiple_files", Value: BoolValue(true)}},
+		Messages: []Message{{
+			Name:           "Beacon",
+			ReservedValues: []Reserved{ReservedTagValue{Tag: 4}, ReservedTagRange{LowerTag: 20, UpperTag: 29}, ReservedTagRange{LowerTag: 100, UpperTag: MaxTag}, ReservedName{Name: "player_id"}},
+			Messages:       []Message{{Name: "Event", Fields: []Field{ScalarField{Name: "name", Typing: StringType, Tag: 1}}}},
+			Enums:          []Enum{{Name: "Kind", AllowAlias: true, ReservedValues: []Reserved{ReservedTagRange{LowerTag: -9, UpperTag: -2}, ReservedTagValue{Tag: 5}, ReservedTagRange{LowerTag: 10, UpperTag: MaxTag}, ReservedName{Name: "AD"}}, Values: []EnumValue{{Name: "UNKNOWN", Tag: 0}, {Name: "NEGATIVE", Tag: -1}, {Name: "VIEW", Tag: 1}, {Name: "SEEN", Tag: 1}}}},
+			Options:        []Option{{Name: "deprecated", Value: BoolValue(true)}},
+			Fields: []Field{
+				ScalarField{Name: "view_id", Typing: StringType, Tag: 1, Options: []Option{
+					{Name: "json_name", Value: StringValue("viewID")},
+					{Name: "deprecated", Value: BoolValue(true)},
+					{Name: "(mux.common.owners)", Value: StringValue("video")},
+				}},
+				CustomField{Name: "events", Typing: "Beacon.Event", Tag: 2, Rule: Repeated},
+				MapField{Name: "tags", KeyTyping: StringType, ValueTyping: StringType, Tag: 3},
+				CustomMapField{Name: "sessions", KeyTyping: Int64Type, ValueTyping: "Beacon.Event", Tag: 5},
+				CustomField{Name: "at", Typing: "google.protobuf.Timestamp", Tag: 6},
+				ScalarField{Name: "score", Typing: DoubleType, Tag: 7, Rule: Optional, Options: []Option{
+					{Name: "(mux.common.limits)", Value: AggregateValue{{Name: "min", Value: IntValue(-1)}, {Name: "max", Value: FloatValue(10)}}},
+					{Name: "(mux.common.level)", Value: IdentValue("HIGH")},
+				}},
+			},
+			OneOfs: []OneOf{{
+				Name:   "source",
+				Fields: []Field{ScalarField{Name: "page_url", Typing: StringType, Tag: 10}, ScalarField{Name: "app_id", Typing: Int32Type, Tag: 11}},
+			}},
+		}},
+		Services: []Service{{
+			Name: "Tracker",
+			Methods: []Method{
+				{Name: "Track", RequestType: "Beacon", ResponseType: "Beacon.Event", Streaming: BidiStreaming},
+				{Name: "Get", RequestType: "Beacon.Event", ResponseType: "Beacon", Options: []Option{{Name: "idempotency_level", Value: IdentValue("NO_SIDE_EFFECTS")}}},
+			},
+		}},
+	}
+	want := []DescriptorFile{{Name: "common.proto", Spec: common}, {Name: "beacon.proto", Spec: beacon}}
+
+	set, err := DescriptorSet(want...)
+	if err != nil {
+		t.Fatalf("DescriptorSet() error = %v", err)
+	}
+	got, err := LoadDescriptorSet(bytes.NewReader(set))
+	if err != nil {
+		t.Fatalf("LoadDescriptorSet() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		for i := range got {
+			t.Errorf("LoadDescriptorSet() file %d = %+v", i, *got[i].Spec)
+		}
+		t.Errorf("LoadDescriptorSet() = %+v, want %+v", got, want)
+	}
+}
+
+// protoBytes builds a field of the protobuf wire format holding a string or nested message, for fields
+// numbered below 16.
+func protoBytes(field int, value string) string {
+	b := []byte{byte(field<<3 | 2)}
+	n := len(value)
+	for ; n >= 0x80; n >>= 7 {
+		b = append(b, byte(n)|0x80)
+	}
+	return string(append(b, byte(n))) + value
+}
+
+func TestLoadDescriptorSet_Comments(t *testing.T) {
+	spec := &Spec{
+		Package:  "mux",
+		Messages: []Message{{Name: "Beacon", Fields: []Field{ScalarField{Name: "view_id", Typing: StringType, Tag: 1}}}},
+	}
+	file, err := spec.Descriptor("beacon.proto")
+	if err != nil {
+		t.Fatalf("Spec.Descriptor() error = %v", err)
+	}
+	location := func(path, comment string, field int) string {
+		return protoBytes(1, protoBytes(1, path)+"\x12\x04\x00\x00\x00\x01"+protoBytes(field, comment))
+	}
+	info := location("\x0c", " Beacon messages\n", 3) +
+		location("\x04\x00", " A single beacon,\n sent periodically\n", 3) +
+		location("\x04\x00\x02\x00", " Unique view identifier\n", 4)
+	set := protoBytes(1, string(file)+protoBytes(9, info))
+
+	files, err := LoadDescriptorSet(strings.NewReader(set))
+	if err != nil {
+		t.Fatalf("LoadDescriptorSet() error = %v", err)
+	}
+	got := files[0].Spec
+	if got.FileComment != "Beacon messages" {
+		t.Errorf("LoadDescriptorSet() file comment = %q, want %q", got.FileComment, "Beacon messages")
+	}
+	if got.Messages[0].Comment != "A single beacon, sent periodically" {
+		t.Errorf("LoadDescriptorSet() message comment = %q, want %q", got.Messages[0].Comment, "A single beacon, sent periodically")
+	}
+	if f := got.Messages[0].Fields[0].(ScalarField); f.Comment != "Unique view identifier" {
+		t.Errorf("LoadDescriptorSet() field comment = %q, want %q", f.Comment, "Unique view identifier")
+	}
+}
+
+func TestLoadDescriptorSet_proto2Dependencies(t *testing.T) {
+	common := &Spec{
+		Package:  "mux.common",
+		Imports:  []ImportType{DescriptorImport},
+		Messages: []Message{{Name: "Owner", Fields: []Field{ScalarField{Name: "team", Typing: StringType, Tag: 1}}}},
+		Extends:  []Extend{{Typing: "google.protobuf.FieldOptions", Fields: []Field{ScalarField{Name: "owner", Typing: StringType, Tag: 50000}}}},
+	}
+	beacon := &Spec{
+		Package: "mux",
+		Imports: []ImportType{DescriptorImport, "common.proto", "legacy.proto"},
+		Messages: []Message{{Name: "Beacon", Fields: []Field{
+			ScalarField{Name: "view_id", Typing: StringType, Tag: 1, Options: []Option{{Name: "(mux.common.owner)", Value: StringValue("video")}}},
+		}}},
+	}
+	set, err := DescriptorSet(DescriptorFile{Name: "common.proto", Spec: common}, DescriptorFile{Name: "beacon.proto", Spec: beacon})
+	if err != nil {
+		t.Fatalf("DescriptorSet() error = %v", err)
+	}
+	// common.proto is rewritten without a syntax, as protoc writes proto2 files, and legacy.proto declares
+	// a group, which a Spec cannot represent.
+	file, err := common.Descriptor("common.proto")
+	if err != nil {
+		t.Fatalf("Spec.Descriptor() error = %v", err)
+	}
+	proto2 := strings.Replace(string(set), protoBytes(1, string(file)), protoBytes(1, strings.Replace(string(file), protoBytes(12, "proto3"), "", 1)), 1)
+	descriptor := protoBytes(1, protoBytes(1, "google/protobuf/descriptor.proto")+protoBytes(2, "google.protobuf"))
+	legacy := protoBytes(1, protoBytes(1, "legacy.proto")+protoBytes(4, protoBytes(1, "Legacy")+protoBytes(2, protoBytes(1, "g")+"\x18\x01\x28\x0a")))
+
+	got, err := LoadDescriptorSet(strings.NewReader(descriptor + legacy + proto2))
+	if err != nil {
+		t.Fatalf("LoadDescriptorSet() error = %v", err)
+	}
+	want := []DescriptorFile{{Name: "beacon.proto", Spec: beacon}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LoadDescriptorSet() = %+v, want %+v", got, want)
+	}
+}
+
+func TestLoadDescriptorSet_Errors(t *testing.T) {
+	tests := []struct {
+		name      string
+		set       string
+		wantError string
+	}{
+		{"Truncated", "\x0a\x10\x0a\x0cbeacon", "truncated"},
+		{"Proto2 syntax", protoBytes(1, protoBytes(1, "beacon.proto")), "beacon.proto: File uses proto2 syntax"},
+		{"Group field", protoBytes(1, protoBytes(1, "beacon.proto")+protoBytes(4, protoBytes(1, "Beacon")+protoBytes(2, protoBytes(1, "g")+"\x18\x01\x28\x0a"))+protoBytes(12, "proto3")), "Field Beacon.g has type 10"},
+	}
+	for _, tt := range tests {
+		_, err := LoadDescriptorSet(strings.NewReader(tt.set))
+		if err == nil || !strings.Contains(err.Error(), tt.wantError) {
+			t.Errorf("%q. LoadDescriptorSet() error = %v, want %q", tt.name, err, tt.wantError)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package proto3
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Severity ranks how serious a validation problem is.
+type Severity uint8
+
+// Severities of validation problems
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+// ErrorCode is a machine-readable identifier for a class of validation problem.
+type ErrorCode string
+
+// Codes reported by the validators
+const (
+	CodeInvalid           ErrorCode = "invalid"
+	CodeEmptySpec         ErrorCode = "empty-spec"
+	CodeEmptyName         ErrorCode = "empty-name"
+	CodeEmptyValues       ErrorCode = "empty-values"
+	CodeInvalidTag        ErrorCode = "invalid-tag"
+	CodeInvalidRange      ErrorCode = "invalid-range"
+	CodeInvalidType       ErrorCode = "invalid-type"
+	CodeInvalidMapKey     ErrorCode = "invalid-map-key"
+	CodeInvalidRule       ErrorCode = "invalid-rule"
+	CodeDuplicateName     ErrorCode = "duplicate-name"
+	CodeDuplicateTag      ErrorCode = "duplicate-tag"
+	CodeReservedName      ErrorCode = "reserved-name"
+	CodeReservedTag       ErrorCode = "reserved-tag"
+	CodeReservedOverlap   ErrorCode = "reserved-overlap"
+	CodeUnresolvedType    ErrorCode = "unresolved-type"
+	CodeAmbiguousType     ErrorCode = "ambiguous-type"
+	CodeMissingImport     ErrorCode = "missing-import"
+	CodeUnusedImport      ErrorCode = "unused-import"
+	CodeImportCycle       ErrorCode = "import-cycle"
+	CodeImplReservedTag   ErrorCode = "implementation-reserved-tag"
+	CodeUnknownStreaming  ErrorCode = "unknown-streaming"
+	CodeInvalidOption     ErrorCode = "invalid-option"
+	CodeUnknownOption     ErrorCode = "unknown-option"
+	CodeDuplicateOption   ErrorCode = "duplicate-option"
+	CodeUnresolvedOption  ErrorCode = "unresolved-option"
+	CodeInvalidExtension  ErrorCode = "invalid-extension"
+	CodeInconsistentField ErrorCode = "inconsistent-field"
+	CodeInvalidOrder      ErrorCode = "invalid-order"
+)
+
+// ValidationError describes a single problem found within a specification. Path identifies the offending
+// element by its dotted, package-qualified name (e.g. foo.Beacon.Event.Habitat). When the specification
+// was loaded from a definition, Line and Column locate the element in its source.
+type ValidationError struct {
+	Path     string
+	Code     ErrorCode
+	Severity Severity
+	Message  string
+	Line     int
+	Column   int
+}
+
+// ValidationErrors collects every problem found while validating a specification.
+type ValidationErrors []*ValidationError
+
+// String reports the name of the severity.
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	default:
+		return fmt.Sprintf("severity(%d)", uint8(s))
+	}
+}
+
+// Error reports the problem prefixed with the path of the element it applies to, and its position when
+// known.
+func (e *ValidationError) Error() string {
+	v := e.Message
+	if e.Path != "" {
+		v = fmt.Sprintf("%s: %s", e.Path, v)
+	}
+	if e.Line > 0 {
+		v = fmt.Sprintf("%d:%d: %s", e.Line, e.Column, v)
+	}
+	return v
+}
+
+// Error reports every problem on its own line.
+func (e ValidationErrors) Error() string {
+	var buffer bytes.Buffer
+	for i, err := range e {
+		if i > 0 {
+			buffer.WriteString("\n")
+		}
+		buffer.WriteString(err.Error())
+	}
+	return buffer.String()
+}
+
+// add records a problem with error severity at the given path.
+func (e *ValidationErrors) add(path string, code ErrorCode, format string, args ...interface{}) {
+	*e = append(*e, &ValidationError{Path: path, Code: code, Severity: SeverityError, Message: fmt.Sprintf(format, args...)})
+}
+
+// warn records a problem with warning severity at the given path.
+func (e *ValidationErrors) warn(path string, code ErrorCode, format string, args ...interface{}) {
+	*e = append(*e, &ValidationError{Path: path, Code: code, Severity: SeverityWarning, Message: fmt.Sprintf(format, args...)})
+}
+
+// merge records the problems reported by a child element, qualifying their paths with prefix. Errors that
+// are not ValidationErrors are recorded with the CodeInvalid code.
+func (e *ValidationErrors) merge(prefix string, err error) {
+	switch err := err.(type) {
+	case nil:
+	case ValidationErrors:
+		for _, v := range err {
+			e.merge(prefix, v)
+		}
+	case *ValidationError:
+		qualified := *err
+		qualified.Path = joinPath(prefix, err.Path)
+		*e = append(*e, &qualified)
+	default:
+		*e = append(*e, &ValidationError{Path: prefix, Code: CodeInvalid, Severity: SeverityError, Message: err.Error()})
+	}
+}
+
+// err returns the collected problems as an error, or nil when there are none.
+func (e ValidationErrors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// joinPath joins two dotted element paths, either of which may be empty.
+func joinPath(prefix, path string) string {
+	switch {
+	case prefix == "":
+		return path
+	case path == "":
+		return prefix
+	default:
+		return prefix + "." + path
+	}
+}
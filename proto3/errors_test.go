@@ -0,0 +1,66 @@
+package proto3_test
+
+import (
+	"testing"
+
+	. "github.com/muxinc/protogen/proto3"
+)
+
+func TestSpec_ValidateCollectsAllErrors(t *testing.T) {
+	spec := &Spec{
+		Package: "foo",
+		Messages: []Message{
+			{
+				Name: "Beacon",
+				Messages: []Message{
+					{
+						Name: "Event",
+						Fields: []Field{
+							CustomField{Name: "Habitat", Typing: "string", Tag: 0},
+							ScalarField{Name: "Continent", Typing: StringType, Tag: 2},
+							ScalarField{Name: "Country", Typing: StringType, Tag: 2},
+						},
+					},
+				},
+				Fields: []Field{
+					MapField{Name: "LanguageMap", KeyTyping: FloatType, ValueTyping: StringType, Tag: 1},
+				},
+			},
+		},
+		Enums: []Enum{{Name: "Empty"}},
+	}
+
+	err := spec.Validate()
+	errs, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("Spec.Validate() error = %#v, want ValidationErrors", err)
+	}
+	want := []struct {
+		path string
+		code ErrorCode
+	}{
+		{"foo.Beacon.LanguageMap", CodeInvalidMapKey},
+		{"foo.Beacon.Event.Habitat", CodeInvalidTag},
+		{"foo.Beacon.Event.Country", CodeDuplicateTag},
+		{"foo.Empty", CodeEmptyValues},
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("Spec.Validate() returned %d errors, want %d:\n%v", len(errs), len(want), errs)
+	}
+	for i, w := range want {
+		if errs[i].Path != w.path || errs[i].Code != w.code || errs[i].Severity != SeverityError {
+			t.Errorf("Spec.Validate() error %d = %+v, want path %q and code %q", i, errs[i], w.path, w.code)
+		}
+	}
+}
+
+func TestValidationError_Error(t *testing.T) {
+	err := &ValidationError{Path: "foo.Beacon.Habitat", Code: CodeInvalidTag, Message: "Field must have a tag"}
+	if got, want := err.Error(), "foo.Beacon.Habitat: Field must have a tag"; got != want {
+		t.Errorf("ValidationError.Error() = %q, want %q", got, want)
+	}
+	errs := ValidationErrors{err, &ValidationError{Code: CodeEmptySpec, Message: "Spec is empty"}}
+	if got, want := errs.Error(), "foo.Beacon.Habitat: Field must have a tag\nSpec is empty"; got != want {
+		t.Errorf("ValidationErrors.Error() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,348 @@
+package proto3
+
+import (
+	"sort"
+	"strings"
+)
+
+// DescriptorImport is the import that declares the google.protobuf.*Options messages extended by custom
+// options.
+const DescriptorImport ImportType = "google/protobuf/descriptor.proto"
+
+// MinExtensionTag is the lowest tag available to extensions of the google.protobuf.*Options messages.
+const MinExtensionTag TagType = 1000
+
+// optionMessages maps each descriptor options message that may be extended to the scope of its options.
+var optionMessages = map[string]optionScope{
+	"google.protobuf.FileOptions":      fileScope,
+	"google.protobuf.MessageOptions":   messageScope,
+	"google.protobuf.FieldOptions":     fieldScope,
+	"google.protobuf.OneofOptions":     oneofScope,
+	"google.protobuf.EnumOptions":      enumScope,
+	"google.protobuf.EnumValueOptions": enumValueScope,
+	"google.protobuf.ServiceOptions":   serviceScope,
+	"google.protobuf.MethodOptions":    methodScope,
+}
+
+// Extend declares extension fields on one of the google.protobuf.*Options messages, which defines custom
+// options that can then be referenced by a parenthesised Option name such as (mux.field_owner). Proto3
+// only permits extensions for the purpose of defining custom options.
+// https://developers.google.com/protocol-buffers/docs/proto3#custom_options
+type Extend struct {
+	Typing  string  `json:"type"` // extended message, e.g. google.protobuf.FieldOptions
+	Comment string  `json:"comment,omitempty"`
+	Fields  []Field `json:"fields,omitempty"`
+}
+
+// WRITERS
+
+// Write the extend block as a string at a given indentation level.
+func (e Extend) Write(level int) (string, error) {
+	return printString(func(p *printer) { p.extend(level, e, false) })
+}
+
+// VALIDATORS
+
+// Validate extend attributes
+func (e Extend) Validate() error {
+	var errs ValidationErrors
+	if _, ok := optionMessages[strings.TrimPrefix(e.Typing, ".")]; !ok {
+		errs.add("", CodeInvalidExtension, "Extend %s must extend one of the google.protobuf.*Options messages", e.Typing)
+	}
+	if len(e.Fields) == 0 {
+		errs.add("", CodeEmptyValues, "Extend %s must have non-empty set of fields", e.Typing)
+	}
+	names := make(map[NameType]bool)
+	tags := make(map[TagType]NameType)
+	for _, f := range e.Fields {
+		errs.merge("", f.Validate())
+		switch f.(type) {
+		case MapField, CustomMapField, MessageMapField, EnumMapField:
+			name, _, _ := fieldNameTag(f)
+			errs.add(string(name), CodeInvalidExtension, "Extension fields cannot be maps")
+			continue
+		}
+		name, tag, ok := fieldNameTag(f)
+		if !ok {
+			continue
+		}
+		if tag < MinExtensionTag {
+			errs.add(string(name), CodeInvalidTag, "Extension field must have a tag between %d and %d", MinExtensionTag, MaxFieldTag)
+		}
+		if names[name] && name != "" {
+			errs.add(string(name), CodeDuplicateName, "Extend has more than one field named %s", name)
+		}
+		names[name] = true
+		if other, exists := tags[tag]; exists {
+			errs.add(string(name), CodeDuplicateTag, "Field uses tag %d, which is already used by field %s", tag, other)
+		} else {
+			tags[tag] = name
+		}
+	}
+	return errs.err()
+}
+
+// extension describes an extension field declared within a spec.
+type extension struct {
+	scope optionScope
+	field Field
+}
+
+// extensions collects every extension field declared in the spec, keyed by fully-qualified name.
+func (s *Spec) extensions() map[string]extension {
+	exts := make(map[string]extension)
+	var collect func(prefix string, extends []Extend, messages []Message)
+	collect = func(prefix string, extends []Extend, messages []Message) {
+		for _, e := range extends {
+			scope, ok := optionMessages[strings.TrimPrefix(e.Typing, ".")]
+			if !ok {
+				continue
+			}
+			for _, f := range e.Fields {
+				if name, _, ok := fieldNameTag(f); ok {
+					exts[joinPath(prefix, string(name))] = extension{scope: scope, field: f}
+				}
+			}
+		}
+		for _, m := range messages {
+			collect(joinPath(prefix, m.Name), m.Extends, m.Messages)
+		}
+	}
+	collect(s.Package, s.Extends, s.Messages)
+	return exts
+}
+
+// validateExtensions checks that extensions are only declared when descriptor.proto is imported, that no
+// two extensions of the same message share a tag, and that every custom option refers to an extension of
+// the right options message. Custom options that are not declared in the spec are assumed to come from an
+// import, so they are only reported when the spec has nothing but descriptor.proto to import them from.
+func (s *Spec) validateExtensions() error {
+	var errs ValidationErrors
+	exts := s.extensions()
+
+	if len(exts) > 0 && !hasImport(s.Imports, DescriptorImport) {
+		errs.add(s.Package, CodeInvalidExtension, "Spec declares extensions without importing %s", DescriptorImport)
+	}
+	type extensionTag struct {
+		scope optionScope
+		tag   TagType
+	}
+	names := make([]string, 0, len(exts))
+	for name := range exts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	tags := make(map[extensionTag]string)
+	for _, name := range names {
+		ext := exts[name]
+		_, tag, _ := fieldNameTag(ext.field)
+		key := extensionTag{ext.scope, tag}
+		if other, exists := tags[key]; exists {
+			errs.add(name, CodeDuplicateTag, "Extension uses tag %d, which is already used by extension %s", tag, other)
+		}
+		tags[key] = name
+	}
+
+	canImport := false
+	for _, i := range s.Imports {
+		if i != DescriptorImport {
+			canImport = true
+		}
+	}
+	s.walkOptions(func(path string, scope optionScope, o Option) {
+		ref, ok := customOptionName(o.Name)
+		if !ok {
+			return
+		}
+		name, ext, found := resolveExtension(exts, s.Package, ref)
+		switch {
+		case !found && !canImport:
+			errs.add(joinPath(path, o.Name), CodeUnresolvedOption, "Custom option %s does not resolve to an extension in the spec or its imports", ref)
+		case found && ext.scope != scope:
+			errs.add(joinPath(path, o.Name), CodeInvalidOption, "Custom option %s does not apply to this kind of element", name)
+		case found && o.Name == "("+ref+")" && !optionValueMatches(ext.field, o.Value):
+			errs.add(joinPath(path, o.Name), CodeInvalidOption, "Custom option %s has a value of the wrong type", name)
+		}
+	})
+	return errs.err()
+}
+
+// walkOptions calls fn for every option set anywhere in the spec, along with the path and scope of the
+// element it is set on.
+func (s *Spec) walkOptions(fn func(path string, scope optionScope, o Option)) {
+	each := func(path string, scope optionScope, options []Option) {
+		for _, o := range options {
+			fn(path, scope, o)
+		}
+	}
+	enums := func(prefix string, values []Enum) {
+		for _, e := range values {
+			path := joinPath(prefix, string(e.Name))
+			each(path, enumScope, e.Options)
+			for _, v := range e.Values {
+				each(joinPath(path, string(v.Name)), enumValueScope, v.Options)
+			}
+		}
+	}
+	fields := func(prefix string, values []Field) {
+		for _, f := range values {
+			name, _, _ := fieldNameTag(f)
+			each(joinPath(prefix, string(name)), fieldScope, fieldOptions(f))
+		}
+	}
+	var messages func(prefix string, values []Message)
+	messages = func(prefix string, values []Message) {
+		for _, m := range values {
+			path := joinPath(prefix, m.Name)
+			each(path, messageScope, m.Options)
+			fields(path, m.Fields)
+			for _, o := range m.OneOfs {
+				each(joinPath(path, string(o.Name)), oneofScope, o.Options)
+				fields(path, o.Fields)
+			}
+			for _, e := range m.Extends {
+				fields(path, e.Fields)
+			}
+			enums(path, m.Enums)
+			messages(path, m.Messages)
+		}
+	}
+
+	each(s.Package, fileScope, s.Options)
+	for _, e := range s.Extends {
+		fields(s.Package, e.Fields)
+	}
+	enums(s.Package, s.Enums)
+	messages(s.Package, s.Messages)
+	for _, svc := range s.Services {
+		path := joinPath(s.Package, string(svc.Name))
+		each(path, serviceScope, svc.Options)
+		for _, m := range svc.Methods {
+			each(joinPath(path, string(m.Name)), methodScope, m.Options)
+		}
+	}
+}
+
+// fieldOptions returns the options of any of the field types defined in this package.
+func fieldOptions(f Field) []Option {
+	switch f := f.(type) {
+	case ScalarField:
+		return f.Options
+	case CustomField:
+		return f.Options
+	case MapField:
+		return f.Options
+	case CustomMapField:
+		return f.Options
+	case MessageField:
+		return f.Options
+	case EnumField:
+		return f.Options
+	case MessageMapField:
+		return f.Options
+	case EnumMapField:
+		return f.Options
+	default:
+		return nil
+	}
+}
+
+// customOptionName extracts the extension name from a custom option name such as (foo.bar).baz.
+func customOptionName(name string) (string, bool) {
+	if !strings.HasPrefix(name, "(") {
+		return "", false
+	}
+	end := strings.Index(name, ")")
+	if end < 0 {
+		return "", false
+	}
+	return name[1:end], true
+}
+
+// resolveExtension looks up an extension reference using protobuf scoping rules: a reference with a
+// leading dot is fully-qualified, otherwise it is resolved relative to each enclosing package in turn.
+func resolveExtension(exts map[string]extension, pkg, ref string) (string, extension, bool) {
+	if strings.HasPrefix(ref, ".") {
+		name := strings.TrimPrefix(ref, ".")
+		ext, ok := exts[name]
+		return name, ext, ok
+	}
+	scope := pkg
+	for {
+		name := joinPath(scope, ref)
+		if ext, ok := exts[name]; ok {
+			return name, ext, true
+		}
+		if scope == "" {
+			return "", extension{}, false
+		}
+		if i := strings.LastIndex(scope, "."); i >= 0 {
+			scope = scope[:i]
+		} else {
+			scope = ""
+		}
+	}
+}
+
+// optionValueMatches reports whether a value can be assigned to an extension field.
+func optionValueMatches(f Field, value OptionValue) bool {
+	switch f := f.(type) {
+	case ScalarField:
+		switch value.(type) {
+		case StringValue:
+			return f.Typing == StringType || f.Typing == BytesType
+		case BoolValue:
+			return f.Typing == BoolType
+		case FloatValue:
+			return f.Typing == DoubleType || f.Typing == FloatType
+		case IntValue:
+			return f.Typing != StringType && f.Typing != BytesType && f.Typing != BoolType
+		case UintValue:
+			return f.Typing == UInt64Type || f.Typing == Fixed64Type || f.Typing == DoubleType || f.Typing == FloatType
+		default:
+			return false
+		}
+	case CustomField:
+		switch value.(type) {
+		case IdentValue, AggregateValue, IntValue:
+			return true
+		default:
+			return false
+		}
+	case MessageField:
+		_, ok := value.(AggregateValue)
+		return ok
+	case EnumField:
+		switch value.(type) {
+		case IdentValue, IntValue:
+			return true
+		default:
+			return false
+		}
+	default:
+		return true
+	}
+}
+
+// isOptionName reports whether name is a plain option name or a custom option name of the form
+// (full.ident) optionally followed by .sub.field selectors.
+func isOptionName(name string) bool {
+	ref, ok := customOptionName(name)
+	if !ok {
+		return isFullIdent(name)
+	}
+	rest := name[len(ref)+2:]
+	if !isFullIdent(strings.TrimPrefix(ref, ".")) {
+		return false
+	}
+	return rest == "" || (strings.HasPrefix(rest, ".") && isFullIdent(rest[1:]))
+}
+
+func hasImport(imports []ImportType, i ImportType) bool {
+	for _, v := range imports {
+		if v == i {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,214 @@
+package proto3_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	. "github.com/muxinc/protogen/proto3"
+)
+
+func TestSpec_ValidateExtensions(t *testing.T) {
+	fieldOwner := Extend{
+		Typing: "google.protobuf.FieldOptions",
+		Fields: []Field{ScalarField{Name: "field_owner", Typing: StringType, Tag: 50000}},
+	}
+	owned := func(name string, value OptionValue) []Message {
+		return []Message{{Name: "Beacon", Fields: []Field{ScalarField{Name: "view_id", Typing: StringType, Tag: 1, Options: []Option{{Name: name, Value: value}}}}}}
+	}
+	mapped := owned("deprecated", BoolValue(true))
+	mapped[0].Enums = []Enum{{Name: "Kind", Values: []EnumValue{{Name: "KIND_UNSPECIFIED"}}}}
+	mapExtension := func(f Field) Spec {
+		return Spec{
+			Imports:  []ImportType{DescriptorImport},
+			Extends:  []Extend{{Typing: "google.protobuf.FieldOptions", Fields: []Field{f}}},
+			Messages: mapped,
+		}
+	}
+	tests := []struct {
+		name     string
+		spec     Spec
+		wantCode ErrorCode
+	}{
+		{
+			name: "Resolved custom option",
+			spec: Spec{Package: "mux", Imports: []ImportType{DescriptorImport}, Extends: []Extend{fieldOwner}, Messages: owned("(mux.field_owner)", StringValue("data-team"))},
+		},
+		{
+			name: "Relative custom option",
+			spec: Spec{Package: "mux.data", Imports: []ImportType{DescriptorImport}, Extends: []Extend{fieldOwner}, Messages: owned("(field_owner)", StringValue("data-team"))},
+		},
+		{
+			name: "Custom option from another import",
+			spec: Spec{Imports: []ImportType{"mux/options.proto"}, Messages: owned("(mux.field_owner)", StringValue("data-team"))},
+		},
+		{
+			name:     "Unresolved custom option",
+			spec:     Spec{Package: "mux", Imports: []ImportType{DescriptorImport}, Extends: []Extend{fieldOwner}, Messages: owned("(mux.field_ownr)", StringValue("data-team"))},
+			wantCode: CodeUnresolvedOption,
+		},
+		{
+			name:     "Custom option with wrong value type",
+			spec:     Spec{Package: "mux", Imports: []ImportType{DescriptorImport}, Extends: []Extend{fieldOwner}, Messages: owned("(mux.field_owner)", BoolValue(true))},
+			wantCode: CodeInvalidOption,
+		},
+		{
+			name: "Custom option on wrong element",
+			spec: Spec{
+				Package:  "mux",
+				Imports:  []ImportType{DescriptorImport},
+				Extends:  []Extend{fieldOwner},
+				Messages: []Message{{Name: "Beacon", Options: []Option{{Name: "(mux.field_owner)", Value: StringValue("data-team")}}}},
+			},
+			wantCode: CodeInvalidOption,
+		},
+		{
+			name:     "Missing descriptor import",
+			spec:     Spec{Package: "mux", Extends: []Extend{fieldOwner}, Messages: owned("(mux.field_owner)", StringValue("data-team"))},
+			wantCode: CodeInvalidExtension,
+		},
+		{
+			name: "Extension tag out of range",
+			spec: Spec{
+				Imports:  []ImportType{DescriptorImport},
+				Extends:  []Extend{{Typing: "google.protobuf.FieldOptions", Fields: []Field{ScalarField{Name: "field_owner", Typing: StringType, Tag: 999}}}},
+				Messages: owned("deprecated", BoolValue(true)),
+			},
+			wantCode: CodeInvalidTag,
+		},
+		{
+			name: "Extension of a regular message",
+			spec: Spec{
+				Imports:  []ImportType{DescriptorImport},
+				Extends:  []Extend{{Typing: "Beacon", Fields: []Field{ScalarField{Name: "field_owner", Typing: StringType, Tag: 50000}}}},
+				Messages: owned("deprecated", BoolValue(true)),
+			},
+			wantCode: CodeInvalidExtension,
+		},
+		{
+			name:     "Map extension field",
+			spec:     mapExtension(MapField{Name: "owners", Tag: 50000, KeyTyping: StringType, ValueTyping: StringType}),
+			wantCode: CodeInvalidExtension,
+		},
+		{
+			name:     "Message map extension field",
+			spec:     mapExtension(MessageMapField{Name: "owners", Tag: 50000, KeyTyping: StringType, ValueTyping: &mapped[0]}),
+			wantCode: CodeInvalidExtension,
+		},
+		{
+			name:     "Enum map extension field",
+			spec:     mapExtension(EnumMapField{Name: "owners", Tag: 50000, KeyTyping: StringType, ValueTyping: &mapped[0].Enums[0]}),
+			wantCode: CodeInvalidExtension,
+		},
+		{
+			name: "Duplicate extension tags",
+			spec: Spec{
+				Package: "mux",
+				Imports: []ImportType{DescriptorImport},
+				Extends: []Extend{fieldOwner},
+				Messages: []Message{{
+					Name:    "Beacon",
+					Extends: []Extend{{Typing: ".google.protobuf.FieldOptions", Fields: []Field{ScalarField{Name: "owner", Typing: StringType, Tag: 50000}}}},
+				}},
+			},
+			wantCode: CodeDuplicateTag,
+		},
+	}
+	for _, tt := range tests {
+		err := tt.spec.Validate()
+		if tt.wantCode == "" {
+			if err != nil {
+				t.Errorf("%q. Spec.Validate() error = %v", tt.name, err)
+			}
+			continue
+		}
+		errs, _ := err.(ValidationErrors)
+		found := false
+		for _, e := range errs {
+			found = found || e.Code == tt.wantCode
+		}
+		if !found {
+			t.Errorf("%q. Spec.Validate() error = %v, want code %s", tt.name, err, tt.wantCode)
+		}
+	}
+}
+
+func TestParse_Extend(t *testing.T) {
+	src := `syntax = "proto3";
+package mux;
+import "google/protobuf/descriptor.proto";
+
+message Beacon {
+  option (mux.message_owner) = "video-team";
+
+  string view_id = 1 [(mux.field_owner) = "data-team", (mux.rule).min_len = 1];
+
+}
+
+// Ownership of beacon fields
+extend google.protobuf.FieldOptions {
+  string field_owner = 50000;
+  Rule rule = 50001;
+}
+
+extend google.protobuf.MessageOptions {
+  string message_owner = 50000;
+}
+`
+	spec, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(spec.Extends) != 2 || spec.Extends[0].Comment != "Ownership of beacon fields" {
+		t.Errorf("Parse() extends = %+v", spec.Extends)
+	}
+	got, err := spec.Write()
+	if err != nil {
+		t.Fatalf("Spec.Write() error = %v", err)
+	}
+	if got != src {
+		t.Errorf("Spec.Write() of parsed spec differs from source:\n%s", got)
+	}
+}
+
+func ExampleExtend() {
+	spec := &Spec{
+		Package: "mux",
+		Imports: []ImportType{DescriptorImport},
+		Extends: []Extend{
+			{
+				Typing: "google.protobuf.FieldOptions",
+				Fields: []Field{ScalarField{Name: "field_owner", Typing: StringType, Tag: 50000}},
+			},
+		},
+		Messages: []Message{
+			{
+				Name: "Beacon",
+				Fields: []Field{
+					ScalarField{Name: "view_id", Typing: StringType, Tag: 1, Options: []Option{{Name: "(mux.field_owner)", Value: StringValue("data-team")}}},
+				},
+			},
+		},
+	}
+
+	s, err := spec.Write()
+	if err != nil {
+		fmt.Println(err)
+	} else {
+		fmt.Println(s)
+	}
+
+	// Output:
+	// syntax = "proto3";
+	// package mux;
+	// import "google/protobuf/descriptor.proto";
+	//
+	// message Beacon {
+	//   string view_id = 1 [(mux.field_owner) = "data-team"];
+	//
+	// }
+	//
+	// extend google.protobuf.FieldOptions {
+	//   string field_owner = 50000;
+	// }
+}
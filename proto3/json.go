@@ -0,0 +1,482 @@
+package proto3
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Specs are encoded as JSON in the format read by LoadDefinition, so that an encoded spec is itself a valid
+// definition. Fields, reserved values and option values are written with a kind that identifies their Go
+// type, so that a spec survives a round trip through encoding/json without loss.
+
+// Kinds that discriminate between the implementations of Field
+const (
+	scalarFieldKind    = "scalar"
+	customFieldKind    = "custom"
+	mapFieldKind       = "map"
+	customMapFieldKind = "custom-map"
+)
+
+// Kinds that discriminate between the implementations of Reserved
+const (
+	reservedNameKind  = "name"
+	reservedTagKind   = "tag"
+	reservedRangeKind = "range"
+)
+
+// Kinds that discriminate between the implementations of OptionValue
+const (
+	stringValueKind    = "string"
+	intValueKind       = "int"
+	floatValueKind     = "float"
+	boolValueKind      = "bool"
+	identValueKind     = "ident"
+	aggregateValueKind = "aggregate"
+)
+
+// MarshalJSON encodes a spec. Fields that refer to a message or enum of the spec are written as custom
+// fields naming the type, so they are read back as custom fields.
+func (s Spec) MarshalJSON() ([]byte, error) {
+	type spec Spec
+	return json.Marshal(spec(*s.withTypeNames()))
+}
+
+// MarshalJSON encodes a message, writing the kind of each field and reserved value.
+func (m Message) MarshalJSON() ([]byte, error) {
+	type message Message
+	return json.Marshal(struct {
+		message
+		Fields         []fieldJSON    `json:"fields,omitempty"`
+		ReservedValues []reservedJSON `json:"reserved,omitempty"`
+	}{message(m), wrapFields(m.Fields), wrapReserved(m.ReservedValues)})
+}
+
+// UnmarshalJSON decodes a message, using the kind of each field and reserved value to select its type.
+func (m *Message) UnmarshalJSON(data []byte) error {
+	type message Message
+	var v struct {
+		message
+		Fields         []fieldJSON    `json:"fields"`
+		ReservedValues []reservedJSON `json:"reserved"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*m = Message(v.message)
+	m.Fields = unwrapFields(v.Fields)
+	m.ReservedValues = nil
+	for _, r := range v.ReservedValues {
+		m.ReservedValues = append(m.ReservedValues, r.Reserved)
+	}
+	return nil
+}
+
+// MarshalJSON encodes an enum, writing the kind of each reserved value.
+func (e Enum) MarshalJSON() ([]byte, error) {
+	type enum Enum
+	return json.Marshal(struct {
+		enum
+		ReservedValues []reservedJSON `json:"reserved,omitempty"`
+	}{enum(e), wrapReserved(e.ReservedValues)})
+}
+
+// UnmarshalJSON decodes an enum, using the kind of each reserved value to select its type.
+func (e *Enum) UnmarshalJSON(data []byte) error {
+	type enum Enum
+	var v struct {
+		enum
+		ReservedValues []reservedJSON `json:"reserved"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*e = Enum(v.enum)
+	e.ReservedValues = nil
+	for _, r := range v.ReservedValues {
+		e.ReservedValues = append(e.ReservedValues, r.Reserved)
+	}
+	return nil
+}
+
+// MarshalJSON encodes a oneof, writing the kind of each field.
+func (o OneOf) MarshalJSON() ([]byte, error) {
+	type oneof OneOf
+	return json.Marshal(struct {
+		oneof
+		Fields []fieldJSON `json:"fields,omitempty"`
+	}{oneof(o), wrapFields(o.Fields)})
+}
+
+// UnmarshalJSON decodes a oneof, using the kind of each field to select its type.
+func (o *OneOf) UnmarshalJSON(data []byte) error {
+	type oneof OneOf
+	var v struct {
+		oneof
+		Fields []fieldJSON `json:"fields"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*o = OneOf(v.oneof)
+	o.Fields = unwrapFields(v.Fields)
+	return nil
+}
+
+// MarshalJSON encodes an extend block, writing the kind of each field.
+func (e Extend) MarshalJSON() ([]byte, error) {
+	type extend Extend
+	return json.Marshal(struct {
+		extend
+		Fields []fieldJSON `json:"fields,omitempty"`
+	}{extend(e), wrapFields(e.Fields)})
+}
+
+// UnmarshalJSON decodes an extend block, using the kind of each field to select its type.
+func (e *Extend) UnmarshalJSON(data []byte) error {
+	type extend Extend
+	var v struct {
+		extend
+		Fields []fieldJSON `json:"fields"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*e = Extend(v.extend)
+	e.Fields = unwrapFields(v.Fields)
+	return nil
+}
+
+// fieldJSON encodes any of the field types defined in this package along with its kind.
+type fieldJSON struct {
+	Field
+}
+
+func wrapFields(fields []Field) []fieldJSON {
+	var wrapped []fieldJSON
+	for _, f := range fields {
+		wrapped = append(wrapped, fieldJSON{f})
+	}
+	return wrapped
+}
+
+func unwrapFields(wrapped []fieldJSON) []Field {
+	var fields []Field
+	for _, f := range wrapped {
+		fields = append(fields, f.Field)
+	}
+	return fields
+}
+
+// MarshalJSON encodes the field with its kind.
+func (f fieldJSON) MarshalJSON() ([]byte, error) {
+	type kind struct {
+		Kind string `json:"kind"`
+	}
+	switch v := f.Field.(type) {
+	case ScalarField:
+		return json.Marshal(struct {
+			kind
+			ScalarField
+		}{kind{scalarFieldKind}, v})
+	case CustomField:
+		return json.Marshal(struct {
+			kind
+			CustomField
+		}{kind{customFieldKind}, v})
+	case MapField:
+		return json.Marshal(struct {
+			kind
+			MapField
+		}{kind{mapFieldKind}, v})
+	case CustomMapField:
+		return json.Marshal(struct {
+			kind
+			CustomMapField
+		}{kind{customMapFieldKind}, v})
+	case MessageField:
+		return fieldJSON{v.custom(messageName(v.Typing))}.MarshalJSON()
+	case EnumField:
+		return fieldJSON{v.custom(enumName(v.Typing))}.MarshalJSON()
+	case MessageMapField:
+		return fieldJSON{v.custom(messageName(v.ValueTyping))}.MarshalJSON()
+	case EnumMapField:
+		return fieldJSON{v.custom(enumName(v.ValueTyping))}.MarshalJSON()
+	default:
+		return nil, fmt.Errorf("Field of type %T cannot be encoded as JSON", f.Field)
+	}
+}
+
+// UnmarshalJSON decodes a field of the type given by its kind.
+func (f *fieldJSON) UnmarshalJSON(data []byte) error {
+	var kind struct {
+		Kind string `json:"kind"`
+	}
+	if err := json.Unmarshal(data, &kind); err != nil {
+		return err
+	}
+	var err error
+	switch kind.Kind {
+	case scalarFieldKind:
+		var v ScalarField
+		err = json.Unmarshal(data, &v)
+		f.Field = v
+	case customFieldKind:
+		var v CustomField
+		err = json.Unmarshal(data, &v)
+		f.Field = v
+	case mapFieldKind:
+		var v MapField
+		err = json.Unmarshal(data, &v)
+		f.Field = v
+	case customMapFieldKind:
+		var v CustomMapField
+		err = json.Unmarshal(data, &v)
+		f.Field = v
+	default:
+		return fmt.Errorf("Field has unknown kind %q", kind.Kind)
+	}
+	return err
+}
+
+// reservedJSON encodes any of the reserved types defined in this package along with its kind.
+type reservedJSON struct {
+	Reserved
+}
+
+func wrapReserved(reserved []Reserved) []reservedJSON {
+	var wrapped []reservedJSON
+	for _, r := range reserved {
+		wrapped = append(wrapped, reservedJSON{r})
+	}
+	return wrapped
+}
+
+// MarshalJSON encodes the reserved value with its kind.
+func (r reservedJSON) MarshalJSON() ([]byte, error) {
+	type kind struct {
+		Kind string `json:"kind"`
+	}
+	switch v := r.Reserved.(type) {
+	case ReservedName:
+		return json.Marshal(struct {
+			kind
+			ReservedName
+		}{kind{reservedNameKind}, v})
+	case ReservedTagValue:
+		return json.Marshal(struct {
+			kind
+			ReservedTagValue
+		}{kind{reservedTagKind}, v})
+	case ReservedTagRange:
+		return json.Marshal(struct {
+			kind
+			ReservedTagRange
+		}{kind{reservedRangeKind}, v})
+	default:
+		return nil, fmt.Errorf("Reserved value of type %T cannot be encoded as JSON", r.Reserved)
+	}
+}
+
+// UnmarshalJSON decodes a reserved value of the type given by its kind.
+func (r *reservedJSON) UnmarshalJSON(data []byte) error {
+	var kind struct {
+		Kind string `json:"kind"`
+	}
+	if err := json.Unmarshal(data, &kind); err != nil {
+		return err
+	}
+	var err error
+	switch kind.Kind {
+	case reservedNameKind:
+		var v ReservedName
+		err = json.Unmarshal(data, &v)
+		r.Reserved = v
+	case reservedTagKind:
+		var v ReservedTagValue
+		err = json.Unmarshal(data, &v)
+		r.Reserved = v
+	case reservedRangeKind:
+		var v ReservedTagRange
+		err = json.Unmarshal(data, &v)
+		r.Reserved = v
+	default:
+		return fmt.Errorf("Reserved value has unknown kind %q", kind.Kind)
+	}
+	return err
+}
+
+// optionJSON is the encoding of an option. Non-finite floats are written as the strings inf, -inf and nan.
+type optionJSON struct {
+	Name    string          `json:"name"`
+	Kind    string          `json:"kind,omitempty"`
+	Value   json.RawMessage `json:"value,omitempty"`
+	Comment string          `json:"comment,omitempty"`
+}
+
+// MarshalJSON encodes an option with the kind of its value.
+func (o Option) MarshalJSON() ([]byte, error) {
+	v := optionJSON{Name: o.Name, Comment: o.Comment}
+	var value interface{}
+	switch ov := o.Value.(type) {
+	case nil:
+		return json.Marshal(v)
+	case StringValue:
+		v.Kind, value = stringValueKind, string(ov)
+	case IntValue:
+		v.Kind, value = intValueKind, int64(ov)
+	case UintValue:
+		v.Kind, value = intValueKind, uint64(ov)
+	case FloatValue:
+		v.Kind, value = floatValueKind, float64(ov)
+		if math.IsInf(float64(ov), 0) || math.IsNaN(float64(ov)) {
+			value = ov.Write()
+		}
+	case BoolValue:
+		v.Kind, value = boolValueKind, bool(ov)
+	case IdentValue:
+		v.Kind, value = identValueKind, string(ov)
+	case AggregateValue:
+		v.Kind, value = aggregateValueKind, []Option(ov)
+	default:
+		return nil, fmt.Errorf("Option %s has a value of type %T that cannot be encoded as JSON", o.Name, o.Value)
+	}
+	var err error
+	if v.Value, err = json.Marshal(value); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON decodes an option with a value of the type given by its kind.
+func (o *Option) UnmarshalJSON(data []byte) error {
+	var v optionJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	o.Name, o.Value, o.Comment = v.Name, nil, v.Comment
+	if v.Kind == "" && len(v.Value) == 0 {
+		return nil
+	}
+	value, err := decodeOptionValue(v.Kind, v.Value)
+	if err != nil {
+		return fmt.Errorf("Option %s: %s", v.Name, err)
+	}
+	o.Value = value
+	return nil
+}
+
+// decodeOptionValue decodes the JSON value of an option of the given kind.
+func decodeOptionValue(kind string, data []byte) (OptionValue, error) {
+	var err error
+	switch kind {
+	case stringValueKind:
+		var v string
+		err = json.Unmarshal(data, &v)
+		return StringValue(v), err
+	case intValueKind:
+		var v int64
+		if err = json.Unmarshal(data, &v); err != nil {
+			var u uint64
+			if json.Unmarshal(data, &u) == nil {
+				return UintValue(u), nil
+			}
+		}
+		return IntValue(v), err
+	case floatValueKind:
+		var s string
+		if json.Unmarshal(data, &s) == nil {
+			v, err := strconv.ParseFloat(s, 64)
+			return FloatValue(v), err
+		}
+		var v float64
+		err = json.Unmarshal(data, &v)
+		return FloatValue(v), err
+	case boolValueKind:
+		var v bool
+		err = json.Unmarshal(data, &v)
+		return BoolValue(v), err
+	case identValueKind:
+		var v string
+		err = json.Unmarshal(data, &v)
+		return IdentValue(v), err
+	case aggregateValueKind:
+		var v []Option
+		err = json.Unmarshal(data, &v)
+		return AggregateValue(v), err
+	default:
+		return nil, fmt.Errorf("unknown value kind %q", kind)
+	}
+}
+
+// MarshalText encodes a FieldType by its name in a specification.
+func (f FieldType) MarshalText() ([]byte, error) {
+	if f > BytesType {
+		return nil, fmt.Errorf("Unknown field type %d", uint8(f))
+	}
+	return []byte(f.Write()), nil
+}
+
+// UnmarshalText decodes a FieldType from its name in a specification.
+func (f *FieldType) UnmarshalText(text []byte) error {
+	t, ok := parseFieldType(string(text))
+	if !ok {
+		return fmt.Errorf("Unknown field type %q", text)
+	}
+	*f = t
+	return nil
+}
+
+// MarshalText encodes a FieldRule as repeated, optional, or an empty string for no rule.
+func (f FieldRule) MarshalText() ([]byte, error) {
+	switch f {
+	case None:
+		return []byte{}, nil
+	case Repeated:
+		return []byte("repeated"), nil
+	case Optional:
+		return []byte("optional"), nil
+	default:
+		return nil, fmt.Errorf("Unknown field rule %d", uint8(f))
+	}
+}
+
+// UnmarshalText decodes a FieldRule from repeated, optional, or an empty string for no rule.
+func (f *FieldRule) UnmarshalText(text []byte) error {
+	for _, rule := range []FieldRule{None, Repeated, Optional} {
+		if v, _ := rule.MarshalText(); string(v) == string(text) {
+			*f = rule
+			return nil
+		}
+	}
+	return fmt.Errorf("Unknown field rule %q", text)
+}
+
+// streamingNames are the names of the streaming modes, as written in definitions.
+var streamingNames = map[StreamingType]string{
+	Unary:           "unary",
+	ClientStreaming: "client",
+	ServerStreaming: "server",
+	BidiStreaming:   "bidi",
+}
+
+// MarshalText encodes a StreamingType as unary, client, server or bidi.
+func (s StreamingType) MarshalText() ([]byte, error) {
+	name, ok := streamingNames[s]
+	if !ok {
+		return nil, fmt.Errorf("Unknown streaming type %d", uint8(s))
+	}
+	return []byte(name), nil
+}
+
+// UnmarshalText decodes a StreamingType from unary, client, server or bidi.
+func (s *StreamingType) UnmarshalText(text []byte) error {
+	for streaming, name := range streamingNames {
+		if name == string(text) {
+			*s = streaming
+			return nil
+		}
+	}
+	return fmt.Errorf("Unknown streaming type %q", text)
+}
@@ -0,0 +1,121 @@
+package proto3_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	. "github.com/muxinc/protogen/proto3"
+)
+
+// jsonSpec uses every kind of field, reserved value and option value.
+var jsonSpec = &Spec{
+	FileComment: "Beacon messages",
+	Package:     "mux",
+	GoPackage:   "github.com/muxinc/beacon",
+	Imports:     []ImportType{"google/protobuf/descriptor.proto"},
+	Options: []Option{
+		{Name: "java_multiple_files", Value: BoolValue(true)},
+		{Name: "java_outer_classname", Value: StringValue("BeaconProto")},
+		{Name: "optimize_for", Value: IdentValue("SPEED")},
+	},
+	Messages: []Message{{
+		Name:           "Beacon",
+		Comment:        "A single beacon",
+		ReservedValues: []Reserved{ReservedName{Name: "player_id"}, ReservedTagValue{Tag: 4}, ReservedTagRange{LowerTag: 9, UpperTag: MaxTag}},
+		Fields: []Field{
+			ScalarField{Name: "view_id", Typing: StringType, Tag: 1, Comment: "Unique view identifier"},
+			CustomField{Name: "events", Typing: "Event", Tag: 2, Rule: Repeated},
+			MapField{Name: "tags", KeyTyping: StringType, ValueTyping: StringType, Tag: 3, Options: []Option{{Name: "deprecated", Value: BoolValue(true)}}},
+			CustomMapField{Name: "sessions", KeyTyping: Int64Type, ValueTyping: "Session", Tag: 5},
+			ScalarField{Name: "score", Typing: DoubleType, Tag: 8, Rule: Optional, Options: []Option{
+				{Name: "(limits)", Value: AggregateValue{{Name: "min", Value: IntValue(-1)}, {Name: "max", Value: FloatValue(math.Inf(1))}}},
+			}},
+		},
+		OneOfs: []OneOf{{
+			Name: "source",
+			Fields: []Field{
+				ScalarField{Name: "page_url", Typing: StringType, Tag: 6, Options: []Option{{Name: "json_name", Value: StringValue("pageURL")}}},
+				ScalarField{Name: "app_id", Typing: Int32Type, Tag: 7},
+			},
+		}},
+		Order: []Element{{Kind: ElementField, Index: 0}, {Kind: ElementOneOf, Index: 0}, {Kind: ElementField, Index: 1}},
+		Enums: []Enum{{
+			Name:           "Kind",
+			ReservedValues: []Reserved{ReservedTagRange{LowerTag: 2, UpperTag: MaxTag}, ReservedName{Name: "AD"}},
+			Values:         []EnumValue{{Name: "UNKNOWN", Tag: 0}, {Name: "VIEW", Tag: 1, Options: []Option{{Name: "deprecated", Value: BoolValue(true)}}}},
+		}},
+	}},
+	Extends: []Extend{{
+		Typing: "google.protobuf.FieldOptions",
+		Fields: []Field{CustomField{Name: "limits", Typing: "mux.Limits", Tag: 50000}},
+	}},
+	Services: []Service{{
+		Name:    "Tracker",
+		Methods: []Method{{Name: "Track", RequestType: "Beacon", ResponseType: "Beacon", Streaming: BidiStreaming}},
+	}},
+}
+
+func TestSpec_JSON(t *testing.T) {
+	data, err := json.Marshal(jsonSpec)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+
+	var got Spec
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	if !reflect.DeepEqual(&got, jsonSpec) {
+		t.Errorf("json.Unmarshal() = %+v, want %+v", got, jsonSpec)
+	}
+
+	loaded, err := LoadDefinition(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("LoadDefinition() error = %v", err)
+	}
+	if !reflect.DeepEqual(loaded, jsonSpec) {
+		t.Errorf("LoadDefinition() = %+v, want %+v", loaded, jsonSpec)
+	}
+}
+
+func TestSpec_JSONErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		src       string
+		wantError string
+	}{
+		{"Unknown field kind", `{"messages": [{"name": "Beacon", "fields": [{"kind": "list", "name": "view_id"}]}]}`, `unknown kind "list"`},
+		{"Missing field kind", `{"messages": [{"name": "Beacon", "fields": [{"name": "view_id"}]}]}`, `unknown kind ""`},
+		{"Unknown reserved kind", `{"messages": [{"name": "Beacon", "reserved": [{"kind": "names"}]}]}`, `unknown kind "names"`},
+		{"Unknown option kind", `{"options": [{"name": "deprecated", "kind": "flag", "value": true}]}`, `unknown value kind "flag"`},
+		{"Mismatched option kind", `{"options": [{"name": "deprecated", "kind": "int", "value": true}]}`, "deprecated"},
+		{"Unknown field type", `{"messages": [{"name": "Beacon", "fields": [{"kind": "scalar", "name": "view_id", "type": "text"}]}]}`, `Unknown field type "text"`},
+	}
+	for _, tt := range tests {
+		var spec Spec
+		err := json.Unmarshal([]byte(tt.src), &spec)
+		if err == nil || !strings.Contains(err.Error(), tt.wantError) {
+			t.Errorf("%q. json.Unmarshal() error = %v, want %q", tt.name, err, tt.wantError)
+		}
+	}
+}
+
+func ExampleMessage_MarshalJSON() {
+	m := Message{
+		Name:           "Beacon",
+		ReservedValues: []Reserved{ReservedTagValue{Tag: 2}},
+		Fields: []Field{
+			ScalarField{Name: "view_id", Typing: StringType, Tag: 1},
+			CustomMapField{Name: "sessions", KeyTyping: Int64Type, ValueTyping: "Session", Tag: 3},
+		},
+	}
+	data, _ := json.Marshal(m)
+	fmt.Println(string(data))
+	// Output:
+	// {"name":"Beacon","fields":[{"kind":"scalar","name":"view_id","tag":1,"type":"string"},{"kind":"custom-map","name":"sessions","tag":3,"key":"int64","value":"Session"}],"reserved":[{"kind":"tag","tag":2}]}
+}
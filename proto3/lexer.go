@@ -0,0 +1,329 @@
+package proto3
+
+import (
+	"fmt"
+	"strings"
+)
+
+// tokenKind classifies the lexical tokens of a .proto file.
+type tokenKind uint8
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenInt
+	tokenFloat
+	tokenString
+	tokenSymbol
+)
+
+// token is a single lexical element of a .proto file. Comments are not tokens themselves; they are attached
+// to the token that follows them (leading) or to the token they share a line with (trailing).
+type token struct {
+	kind     tokenKind
+	text     string // raw text for identifiers, numbers and symbols; unquoted value for strings
+	line     int
+	col      int
+	index    int // position of the token within the file's tokens
+	leading  []comment
+	trailing []comment
+}
+
+// comment is the cleaned text of a line or block comment, the position at which it starts and the line it
+// ends on.
+type comment struct {
+	text string
+	line int
+	col  int
+	end  int
+}
+
+// commentText joins the text of consecutive comments line by line, leaving out the blank lines before the
+// first line of text and after the last.
+func commentText(comments []comment) string {
+	lines := make([]string, len(comments))
+	for i, c := range comments {
+		lines[i] = c.text
+	}
+	return strings.Trim(strings.Join(lines, "\n"), "\n")
+}
+
+// ParseError describes a syntax error at a given position within a .proto file.
+type ParseError struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+// Error reports the error message prefixed with its line and column.
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Message)
+}
+
+// lexer splits the source of a .proto file into tokens.
+type lexer struct {
+	src     []byte
+	pos     int
+	line    int
+	col     int
+	tokens  []token
+	pending []comment // comments waiting to be attached to the next token
+}
+
+func lex(src []byte) ([]token, error) {
+	l := &lexer{src: src, line: 1, col: 1}
+	for {
+		if err := l.skipSpaceAndComments(); err != nil {
+			return nil, err
+		}
+		if l.pos >= len(l.src) {
+			l.attach(token{kind: tokenEOF, line: l.line, col: l.col})
+			return l.tokens, nil
+		}
+		tok, err := l.scan()
+		if err != nil {
+			return nil, err
+		}
+		l.attach(tok)
+	}
+}
+
+// attach adds a token, attaching the comments before it either to the previous token or to it. Comments
+// that start on the line the previous token ends on, or on the line the comment before them ends on, trail
+// the previous token, unless they end on the line of the token. The other comments lead the token.
+func (l *lexer) attach(tok token) {
+	n, line := 0, 0
+	if len(l.tokens) > 0 {
+		prev := &l.tokens[len(l.tokens)-1]
+		line = prev.line
+		for n < len(l.pending) && l.pending[n].line == line && (l.pending[n].end < tok.line || tok.kind == tokenEOF) {
+			line = l.pending[n].end
+			n++
+		}
+		prev.trailing = append(prev.trailing, l.pending[:n]...)
+	}
+	tok.index = len(l.tokens)
+	tok.leading = l.pending[n:]
+	l.pending = nil
+	l.tokens = append(l.tokens, tok)
+}
+
+func (l *lexer) errorf(line, col int, format string, args ...interface{}) error {
+	return &ParseError{Line: line, Column: col, Message: fmt.Sprintf(format, args...)}
+}
+
+func (l *lexer) peekByte(offset int) byte {
+	if l.pos+offset < len(l.src) {
+		return l.src[l.pos+offset]
+	}
+	return 0
+}
+
+func (l *lexer) advance() byte {
+	c := l.src[l.pos]
+	l.pos++
+	if c == '\n' {
+		l.line++
+		l.col = 1
+	} else {
+		l.col++
+	}
+	return c
+}
+
+// skipSpaceAndComments consumes whitespace and comments, keeping the comments until the next token is
+// attached. Comments separated from the next token by a blank line are still attached to it, so that no
+// comment is lost.
+func (l *lexer) skipSpaceAndComments() error {
+	for l.pos < len(l.src) {
+		c := l.peekByte(0)
+		switch {
+		case c == '\n':
+			l.advance()
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f' || c == '\v':
+			l.advance()
+		case c == '/' && l.peekByte(1) == '/':
+			line, col := l.line, l.col
+			start := l.pos + 2
+			for l.pos < len(l.src) && l.peekByte(0) != '\n' {
+				l.advance()
+			}
+			l.pending = append(l.pending, comment{text: strings.TrimSpace(string(l.src[start:l.pos])), line: line, col: col, end: line})
+		case c == '/' && l.peekByte(1) == '*':
+			line, col := l.line, l.col
+			l.advance()
+			l.advance()
+			start := l.pos
+			for l.pos < len(l.src) && !(l.peekByte(0) == '*' && l.peekByte(1) == '/') {
+				l.advance()
+			}
+			if l.pos >= len(l.src) {
+				return l.errorf(line, col, "unterminated block comment")
+			}
+			text := string(l.src[start:l.pos])
+			l.advance()
+			l.advance()
+			l.pending = append(l.pending, comment{text: cleanBlockComment(text), line: line, col: col, end: l.line})
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// cleanBlockComment returns the lines of a block comment without their indentation and leading asterisk,
+// leaving out the blank lines before the first line of text and after the last.
+func cleanBlockComment(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "*"))
+	}
+	return strings.Trim(strings.Join(lines, "\n"), "\n")
+}
+
+// joinComments joins two comments, such as the leading and trailing comments of an element, keeping each
+// on lines of its own.
+func joinComments(a, b string) string {
+	switch {
+	case a == "":
+		return b
+	case b == "":
+		return a
+	default:
+		return a + "\n" + b
+	}
+}
+
+func isLetter(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func (l *lexer) scan() (token, error) {
+	line, col := l.line, l.col
+	c := l.peekByte(0)
+	switch {
+	case isLetter(c):
+		start := l.pos
+		for l.pos < len(l.src) && (isLetter(l.peekByte(0)) || isDigit(l.peekByte(0))) {
+			l.advance()
+		}
+		return token{kind: tokenIdent, text: string(l.src[start:l.pos]), line: line, col: col}, nil
+	case isDigit(c) || (c == '.' && isDigit(l.peekByte(1))):
+		return l.scanNumber()
+	case c == '"' || c == '\'':
+		return l.scanString()
+	case strings.IndexByte("{}[]()<>;=,.:-+/", c) >= 0:
+		l.advance()
+		return token{kind: tokenSymbol, text: string(c), line: line, col: col}, nil
+	default:
+		return token{}, l.errorf(line, col, "unexpected character %q", c)
+	}
+}
+
+func (l *lexer) scanNumber() (token, error) {
+	line, col := l.line, l.col
+	start := l.pos
+	hex := l.peekByte(0) == '0' && (l.peekByte(1) == 'x' || l.peekByte(1) == 'X')
+	kind := tokenInt
+	for l.pos < len(l.src) {
+		c := l.peekByte(0)
+		switch {
+		case isDigit(c) || isLetter(c):
+			if !hex && (c == 'e' || c == 'E') {
+				kind = tokenFloat
+				l.advance()
+				if s := l.peekByte(0); s == '+' || s == '-' {
+					l.advance()
+				}
+				continue
+			}
+			l.advance()
+		case c == '.' && !hex:
+			kind = tokenFloat
+			l.advance()
+		default:
+			return token{kind: kind, text: string(l.src[start:l.pos]), line: line, col: col}, nil
+		}
+	}
+	return token{kind: kind, text: string(l.src[start:l.pos]), line: line, col: col}, nil
+}
+
+func (l *lexer) scanString() (token, error) {
+	line, col := l.line, l.col
+	quote := l.advance()
+	var buffer []byte
+	for {
+		if l.pos >= len(l.src) || l.peekByte(0) == '\n' {
+			return token{}, l.errorf(line, col, "unterminated string literal")
+		}
+		c := l.advance()
+		if c == quote {
+			return token{kind: tokenString, text: string(buffer), line: line, col: col}, nil
+		}
+		if c != '\\' {
+			buffer = append(buffer, c)
+			continue
+		}
+		if l.pos >= len(l.src) {
+			return token{}, l.errorf(line, col, "unterminated string literal")
+		}
+		e := l.advance()
+		switch e {
+		case 'a':
+			buffer = append(buffer, '\a')
+		case 'b':
+			buffer = append(buffer, '\b')
+		case 'f':
+			buffer = append(buffer, '\f')
+		case 'n':
+			buffer = append(buffer, '\n')
+		case 'r':
+			buffer = append(buffer, '\r')
+		case 't':
+			buffer = append(buffer, '\t')
+		case 'v':
+			buffer = append(buffer, '\v')
+		case '\\', '\'', '"', '?':
+			buffer = append(buffer, e)
+		case 'x', 'X':
+			v, n := 0, 0
+			for ; n < 2 && isHexDigit(l.peekByte(0)); n++ {
+				v = v*16 + hexValue(l.advance())
+			}
+			if n == 0 {
+				return token{}, l.errorf(l.line, l.col, "invalid hex escape in string literal")
+			}
+			buffer = append(buffer, byte(v))
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			v := int(e - '0')
+			for n := 1; n < 3 && l.peekByte(0) >= '0' && l.peekByte(0) <= '7'; n++ {
+				v = v*8 + int(l.advance()-'0')
+			}
+			if v > 0xff {
+				return token{}, l.errorf(l.line, l.col, "octal escape out of range in string literal")
+			}
+			buffer = append(buffer, byte(v))
+		default:
+			return token{}, l.errorf(l.line, l.col-1, "invalid escape sequence \\%c in string literal", e)
+		}
+	}
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func hexValue(c byte) int {
+	switch {
+	case isDigit(c):
+		return int(c - '0')
+	case c >= 'a' && c <= 'f':
+		return int(c-'a') + 10
+	default:
+		return int(c-'A') + 10
+	}
+}
@@ -0,0 +1,255 @@
+// Package lint checks Protobuf specifications against style rules, such as naming conventions and
+// documentation of every message and field, that go beyond what proto3 requires.
+//
+// Each rule can be turned off or given a different severity by a Config. A rule is suppressed for an
+// element, and every element nested within it, by a line of its comment of the form
+//
+//	protogen:lint:ignore <rule> [<rule>...]
+//
+// or by listing the path of the element under the rule in Config.Ignore. Problems are reported as
+// proto3.ValidationErrors, with the name of the rule as their code.
+package lint
+
+import (
+	"path"
+	"strings"
+
+	"github.com/muxinc/protogen/proto3"
+)
+
+// Setting turns a rule off or sets the severity of the problems it reports.
+type Setting uint8
+
+// Settings of a rule
+const (
+	Default Setting = iota // report with the default severity of the rule
+	Off
+	Warning
+	Error
+)
+
+// Config sets up the rules a Linter checks.
+type Config struct {
+	// Rules sets each rule by name. Rules that are not listed are checked with their default severity.
+	Rules map[string]Setting
+	// Ignore lists, by rule name, the paths of elements that the rule is not checked for (e.g.
+	// mux.Beacon.viewID). Elements nested within them are not checked either.
+	Ignore map[string][]string
+}
+
+// ignoreDirective starts a line of a comment that suppresses rules for an element.
+const ignoreDirective = "protogen:lint:ignore"
+
+// Linter checks specs against the style rules.
+type Linter struct {
+	config Config
+}
+
+// New creates a linter that checks the rules as the configuration sets them up.
+func New(config Config) *Linter {
+	return &Linter{config: config}
+}
+
+// Lint checks a spec against every rule that is not turned off. The path of the .proto file of the spec is
+// used by rules about the layout of files, which are skipped when it is empty. It returns
+// proto3.ValidationErrors, or nil when no problems are found.
+func (l *Linter) Lint(file string, s *proto3.Spec) error {
+	var errs proto3.ValidationErrors
+	for _, e := range elements(file, s) {
+		for _, r := range rules {
+			if r.kind != e.kind {
+				continue
+			}
+			severity, enabled := l.severity(r)
+			if !enabled || l.ignored(r.Name, e) {
+				continue
+			}
+			if message := r.check(e); message != "" {
+				errs = append(errs, &proto3.ValidationError{
+					Path:     e.path,
+					Code:     proto3.ErrorCode(r.Name),
+					Severity: severity,
+					Message:  message,
+				})
+			}
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// severity returns the severity a rule reports with, and whether it is checked at all.
+func (l *Linter) severity(r Rule) (proto3.Severity, bool) {
+	switch l.config.Rules[r.Name] {
+	case Off:
+		return 0, false
+	case Warning:
+		return proto3.SeverityWarning, true
+	case Error:
+		return proto3.SeverityError, true
+	default:
+		return r.Severity, true
+	}
+}
+
+// ignored reports whether a rule is suppressed for an element, by a comment of the element or one it is
+// nested within, or by the configuration.
+func (l *Linter) ignored(rule string, e element) bool {
+	for _, suppressed := range e.ignored {
+		if suppressed == rule {
+			return true
+		}
+	}
+	for _, p := range l.config.Ignore[rule] {
+		if e.path == p || strings.HasPrefix(e.path, p+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// kind identifies the kind of element a rule checks.
+type kind uint8
+
+const (
+	fileElement kind = iota
+	messageElement
+	enumElement
+	enumValueElement
+	fieldElement
+)
+
+// element is a declaration of a spec that the rules check.
+type element struct {
+	kind    kind
+	path    string // package-qualified path, or empty for the file
+	name    string // simple name, or the path of the .proto file for the file
+	comment string
+	ignored []string // rules suppressed by the comments of the element and those it is nested within
+	pkg     string   // package of the spec
+	enum    string   // name of the enum an enum value belongs to
+	tag     proto3.TagType
+	first   bool // whether an enum value is the first value of its enum
+}
+
+// elements lists the file, messages, enums, enum values and fields of a spec, including nested ones,
+// oneof fields and extension fields.
+func elements(file string, s *proto3.Spec) []element {
+	var list []element
+	add := func(e element, parent []string) []string {
+		e.pkg = s.Package
+		e.ignored = append(append([]string(nil), parent...), ignoredRules(e.comment)...)
+		list = append(list, e)
+		return e.ignored
+	}
+	fields := func(prefix string, values []proto3.Field, parent []string) {
+		for _, f := range values {
+			if name, comment, ok := fieldNameComment(f); ok {
+				add(element{kind: fieldElement, path: joinPath(prefix, name), name: name, comment: comment}, parent)
+			}
+		}
+	}
+	extends := func(prefix string, values []proto3.Extend, parent []string) {
+		for _, x := range values {
+			fields(prefix, x.Fields, append(append([]string(nil), parent...), ignoredRules(x.Comment)...))
+		}
+	}
+	enums := func(prefix string, values []proto3.Enum, parent []string) {
+		for _, e := range values {
+			path := joinPath(prefix, string(e.Name))
+			ignored := add(element{kind: enumElement, path: path, name: string(e.Name), comment: e.Comment}, parent)
+			for i, v := range e.Values {
+				add(element{
+					kind:    enumValueElement,
+					path:    joinPath(path, string(v.Name)),
+					name:    string(v.Name),
+					comment: v.Comment,
+					enum:    string(e.Name),
+					tag:     v.Tag,
+					first:   i == 0,
+				}, ignored)
+			}
+		}
+	}
+	var messages func(prefix string, values []proto3.Message, parent []string)
+	messages = func(prefix string, values []proto3.Message, parent []string) {
+		for _, m := range values {
+			path := joinPath(prefix, m.Name)
+			ignored := add(element{kind: messageElement, path: path, name: m.Name, comment: m.Comment}, parent)
+			fields(path, m.Fields, ignored)
+			for _, o := range m.OneOfs {
+				fields(path, o.Fields, append(append([]string(nil), ignored...), ignoredRules(o.Comment)...))
+			}
+			enums(path, m.Enums, ignored)
+			extends(path, m.Extends, ignored)
+			messages(path, m.Messages, ignored)
+		}
+	}
+
+	ignored := add(element{kind: fileElement, name: file, comment: s.FileComment}, nil)
+	enums(s.Package, s.Enums, ignored)
+	messages(s.Package, s.Messages, ignored)
+	extends(s.Package, s.Extends, ignored)
+	return list
+}
+
+// fieldNameComment returns the name and comment of any of the field types defined by the proto3 package.
+func fieldNameComment(f proto3.Field) (string, string, bool) {
+	switch f := f.(type) {
+	case proto3.ScalarField:
+		return string(f.Name), f.Comment, true
+	case proto3.CustomField:
+		return string(f.Name), f.Comment, true
+	case proto3.MapField:
+		return string(f.Name), f.Comment, true
+	case proto3.CustomMapField:
+		return string(f.Name), f.Comment, true
+	case proto3.MessageField:
+		return string(f.Name), f.Comment, true
+	case proto3.EnumField:
+		return string(f.Name), f.Comment, true
+	case proto3.MessageMapField:
+		return string(f.Name), f.Comment, true
+	case proto3.EnumMapField:
+		return string(f.Name), f.Comment, true
+	default:
+		return "", "", false
+	}
+}
+
+// ignoredRules returns the rules suppressed by the ignore directives of a comment.
+func ignoredRules(comment string) []string {
+	var rules []string
+	for _, line := range strings.Split(comment, "\n") {
+		if words := strings.Fields(line); len(words) > 0 && words[0] == ignoreDirective {
+			rules = append(rules, words[1:]...)
+		}
+	}
+	return rules
+}
+
+// documentation returns the text of a comment without its ignore directives.
+func documentation(comment string) string {
+	var lines []string
+	for _, line := range strings.Split(comment, "\n") {
+		if words := strings.Fields(line); len(words) == 0 || words[0] != ignoreDirective {
+			lines = append(lines, line)
+		}
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// joinPath joins two dotted element paths, either of which may be empty.
+func joinPath(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+// packageDirectory returns the directory that files of a package are expected to be in.
+func packageDirectory(pkg string) string {
+	return path.Join(strings.Split(pkg, ".")...)
+}
@@ -0,0 +1,132 @@
+package lint_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/muxinc/protogen/proto3"
+	. "github.com/muxinc/protogen/proto3/lint"
+)
+
+// styledSpec declares a documented spec that follows every rule.
+func styledSpec() *proto3.Spec {
+	return &proto3.Spec{
+		Package: "mux.events",
+		Enums: []proto3.Enum{{
+			Name:    "HTTPStatus",
+			Comment: "Status of a response",
+			Values: []proto3.EnumValue{
+				{Name: "HTTP_STATUS_UNSPECIFIED", Tag: 0},
+				{Name: "HTTP_STATUS_OK", Tag: 200},
+			},
+		}},
+		Messages: []proto3.Message{{
+			Name:    "Beacon",
+			Comment: "Beacon reports what a player did",
+			Fields: []proto3.Field{
+				proto3.ScalarField{Name: "view_id", Typing: proto3.StringType, Tag: 1, Comment: "Unique to the view"},
+				proto3.CustomField{Name: "status", Typing: "HTTPStatus", Tag: 2, Comment: "Status of the player's last request"},
+			},
+			OneOfs: []proto3.OneOf{{Name: "source", Fields: []proto3.Field{
+				proto3.ScalarField{Name: "page_url", Typing: proto3.StringType, Tag: 3, Comment: "Page the player is on"},
+			}}},
+		}},
+	}
+}
+
+func TestLinter_Lint(t *testing.T) {
+	if err := New(Config{}).Lint("mux/events/events.proto", styledSpec()); err != nil {
+		t.Fatalf("Linter.Lint() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		file     string
+		change   func(s *proto3.Spec)
+		wantCode proto3.ErrorCode
+		wantPath string
+	}{
+		{"Package outside its directory", "protos/events.proto", func(s *proto3.Spec) {}, "package-directory-match", ""},
+		{"Snake case message", "", func(s *proto3.Spec) { s.Messages[0].Name = "beacon_event" }, "message-pascal-case", "mux.events.beacon_event"},
+		{"Undocumented message", "", func(s *proto3.Spec) { s.Messages[0].Comment = "" }, "message-comment", "mux.events.Beacon"},
+		{"Directive is not documentation", "", func(s *proto3.Spec) { s.Messages[0].Comment = "protogen:lint:ignore field-comment" }, "message-comment", "mux.events.Beacon"},
+		{"Upper case enum", "", func(s *proto3.Spec) { s.Enums[0].Name = "HTTP_STATUS" }, "enum-pascal-case", "mux.events.HTTP_STATUS"},
+		{"Camel case enum value", "", func(s *proto3.Spec) { s.Enums[0].Values[1].Name = "HTTP_STATUS_Ok" }, "enum-value-upper-snake-case", "mux.events.HTTPStatus.HTTP_STATUS_Ok"},
+		{"Enum value without prefix", "", func(s *proto3.Spec) { s.Enums[0].Values[1].Name = "OK" }, "enum-value-prefix", "mux.events.HTTPStatus.OK"},
+		{"Zero value without suffix", "", func(s *proto3.Spec) { s.Enums[0].Values[0].Name = "HTTP_STATUS_NONE" }, "enum-zero-value-suffix", "mux.events.HTTPStatus.HTTP_STATUS_NONE"},
+		{"Camel case field", "", func(s *proto3.Spec) {
+			s.Messages[0].Fields[0] = proto3.ScalarField{Name: "viewId", Typing: proto3.StringType, Tag: 1, Comment: "Unique"}
+		}, "field-lower-snake-case", "mux.events.Beacon.viewId"},
+		{"Undocumented oneof field", "", func(s *proto3.Spec) {
+			s.Messages[0].OneOfs[0].Fields[0] = proto3.ScalarField{Name: "page_url", Typing: proto3.StringType, Tag: 3}
+		}, "field-comment", "mux.events.Beacon.page_url"},
+	}
+	for _, tt := range tests {
+		spec := styledSpec()
+		tt.change(spec)
+		errs, _ := New(Config{}).Lint(tt.file, spec).(proto3.ValidationErrors)
+		if len(errs) != 1 || errs[0].Code != tt.wantCode || errs[0].Path != tt.wantPath {
+			t.Errorf("%q. Linter.Lint() error = %v, want code %s at %q", tt.name, errs, tt.wantCode, tt.wantPath)
+		}
+	}
+}
+
+func TestLinter_Lint_config(t *testing.T) {
+	spec := styledSpec()
+	spec.Messages[0].Fields[0] = proto3.ScalarField{Name: "viewId", Typing: proto3.StringType, Tag: 1}
+	tests := []struct {
+		name   string
+		config Config
+		want   []string
+	}{
+		{"Defaults", Config{}, []string{"error field-lower-snake-case", "warning field-comment"}},
+		{"Rule turned off", Config{Rules: map[string]Setting{"field-comment": Off}}, []string{"error field-lower-snake-case"}},
+		{"Severity changed", Config{Rules: map[string]Setting{"field-lower-snake-case": Warning, "field-comment": Error}}, []string{"warning field-lower-snake-case", "error field-comment"}},
+		{"Element ignored", Config{Ignore: map[string][]string{"field-comment": {"mux.events.Beacon.viewId"}}}, []string{"error field-lower-snake-case"}},
+		{"Parent ignored", Config{Ignore: map[string][]string{"field-lower-snake-case": {"mux.events.Beacon"}}}, []string{"warning field-comment"}},
+	}
+	for _, tt := range tests {
+		errs, _ := New(tt.config).Lint("", spec).(proto3.ValidationErrors)
+		var got []string
+		for _, e := range errs {
+			got = append(got, fmt.Sprintf("%s %s", e.Severity, e.Code))
+		}
+		if strings.Join(got, ", ") != strings.Join(tt.want, ", ") {
+			t.Errorf("%q. Linter.Lint() error = %v, want %q", tt.name, errs, tt.want)
+		}
+	}
+}
+
+func TestLinter_Lint_ignoreComment(t *testing.T) {
+	spec := styledSpec()
+	spec.Messages[0].Comment = "Beacon reports what a player did\nprotogen:lint:ignore field-lower-snake-case field-comment"
+	spec.Messages[0].Fields[0] = proto3.ScalarField{Name: "viewId", Typing: proto3.StringType, Tag: 1}
+	spec.Enums[0].Values[1] = proto3.EnumValue{Name: "OK", Tag: 200, Comment: "protogen:lint:ignore enum-value-prefix"}
+
+	if err := New(Config{}).Lint("", spec); err != nil {
+		t.Errorf("Linter.Lint() error = %v", err)
+	}
+}
+
+func ExampleLinter_Lint() {
+	spec := &proto3.Spec{
+		Package: "mux",
+		Enums: []proto3.Enum{{
+			Name:    "Kind",
+			Comment: "Kind of beacon",
+			Values:  []proto3.EnumValue{{Name: "UNKNOWN", Tag: 0}, {Name: "KIND_VIEW", Tag: 1}},
+		}},
+		Messages: []proto3.Message{{
+			Name:    "Beacon",
+			Comment: "Beacon reports what a player did",
+			Fields:  []proto3.Field{proto3.ScalarField{Name: "viewID", Typing: proto3.StringType, Tag: 1}},
+		}},
+	}
+	fmt.Println(New(Config{}).Lint("mux/beacon.proto", spec))
+	// Output:
+	// mux.Kind.UNKNOWN: Enum value name UNKNOWN should start with KIND_
+	// mux.Kind.UNKNOWN: Enum zero value UNKNOWN should end in _UNSPECIFIED
+	// mux.Beacon.viewID: Field name viewID should be lower_snake_case
+	// mux.Beacon.viewID: Field should have a comment
+}
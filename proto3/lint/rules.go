@@ -0,0 +1,171 @@
+package lint
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/muxinc/protogen/proto3"
+)
+
+// Rule is a style check of one kind of element.
+type Rule struct {
+	Name        string
+	Description string
+	Severity    proto3.Severity // severity of the problems it reports unless configured otherwise
+	kind        kind
+	check       func(e element) string // describes the problem with an element, or is empty
+}
+
+// Rules returns every rule, in the order they are checked for each element.
+func Rules() []Rule {
+	return append([]Rule(nil), rules...)
+}
+
+var rules = []Rule{
+	{"package-directory-match", "files are in the directory of their package, such as mux/events for mux.events", proto3.SeverityError, fileElement, checkPackageDirectory},
+	{"message-pascal-case", "message names are PascalCase", proto3.SeverityError, messageElement, checkMessageName},
+	{"message-comment", "every message has a comment", proto3.SeverityWarning, messageElement, checkMessageComment},
+	{"enum-pascal-case", "enum names are PascalCase", proto3.SeverityError, enumElement, checkEnumName},
+	{"enum-value-upper-snake-case", "enum value names are UPPER_SNAKE_CASE", proto3.SeverityError, enumValueElement, checkEnumValueName},
+	{"enum-value-prefix", "enum value names start with the name of their enum in UPPER_SNAKE_CASE", proto3.SeverityError, enumValueElement, checkEnumValuePrefix},
+	{"enum-zero-value-suffix", "the zero value of an enum ends in _UNSPECIFIED", proto3.SeverityError, enumValueElement, checkEnumZeroValue},
+	{"field-lower-snake-case", "field names are lower_snake_case", proto3.SeverityError, fieldElement, checkFieldName},
+	{"field-comment", "every field has a comment", proto3.SeverityWarning, fieldElement, checkFieldComment},
+}
+
+func checkPackageDirectory(e element) string {
+	if e.name == "" || e.pkg == "" {
+		return ""
+	}
+	dir, want := path.Dir(filepath.ToSlash(e.name)), packageDirectory(e.pkg)
+	if dir == want || strings.HasSuffix(dir, "/"+want) {
+		return ""
+	}
+	return fmt.Sprintf("File %s of package %s should be in a directory %s", e.name, e.pkg, want)
+}
+
+func checkMessageName(e element) string {
+	if isPascalCase(e.name) {
+		return ""
+	}
+	return fmt.Sprintf("Message name %s should be PascalCase", e.name)
+}
+
+func checkMessageComment(e element) string {
+	if documentation(e.comment) != "" {
+		return ""
+	}
+	return "Message should have a comment"
+}
+
+func checkEnumName(e element) string {
+	if isPascalCase(e.name) {
+		return ""
+	}
+	return fmt.Sprintf("Enum name %s should be PascalCase", e.name)
+}
+
+func checkEnumValueName(e element) string {
+	if isUpperSnakeCase(e.name) {
+		return ""
+	}
+	return fmt.Sprintf("Enum value name %s should be UPPER_SNAKE_CASE", e.name)
+}
+
+func checkEnumValuePrefix(e element) string {
+	prefix := upperSnakeCase(e.enum) + "_"
+	if strings.HasPrefix(e.name, prefix) {
+		return ""
+	}
+	return fmt.Sprintf("Enum value name %s should start with %s", e.name, prefix)
+}
+
+func checkEnumZeroValue(e element) string {
+	if !e.first || e.tag != 0 || strings.HasSuffix(e.name, "_UNSPECIFIED") {
+		return ""
+	}
+	return fmt.Sprintf("Enum zero value %s should end in _UNSPECIFIED", e.name)
+}
+
+func checkFieldName(e element) string {
+	if isLowerSnakeCase(e.name) {
+		return ""
+	}
+	return fmt.Sprintf("Field name %s should be lower_snake_case", e.name)
+}
+
+func checkFieldComment(e element) string {
+	if documentation(e.comment) != "" {
+		return ""
+	}
+	return "Field should have a comment"
+}
+
+// isPascalCase reports whether a name is made of letters and digits, starting with an upper-case letter.
+func isPascalCase(name string) bool {
+	if name == "" || !isUpper(name[0]) {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if !isUpper(name[i]) && !isLower(name[i]) && !isDigit(name[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// isLowerSnakeCase reports whether a name is made of words of lower-case letters and digits joined by
+// single underscores, starting with a letter.
+func isLowerSnakeCase(name string) bool {
+	return isSnakeCase(name, isLower)
+}
+
+// isUpperSnakeCase reports whether a name is made of words of upper-case letters and digits joined by
+// single underscores, starting with a letter.
+func isUpperSnakeCase(name string) bool {
+	return isSnakeCase(name, isUpper)
+}
+
+func isSnakeCase(name string, isLetter func(c byte) bool) bool {
+	if name == "" || !isLetter(name[0]) {
+		return false
+	}
+	for _, word := range strings.Split(name, "_") {
+		if word == "" {
+			return false
+		}
+		for i := 0; i < len(word); i++ {
+			if !isLetter(word[i]) && !isDigit(word[i]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// upperSnakeCase converts a PascalCase name to UPPER_SNAKE_CASE, keeping acronyms together (e.g.
+// HTTPStatus becomes HTTP_STATUS).
+func upperSnakeCase(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if i > 0 && isUpper(c) {
+			previous := name[i-1]
+			nextLower := i+1 < len(name) && isLower(name[i+1])
+			if isLower(previous) || isDigit(previous) || (isUpper(previous) && nextLower) {
+				b.WriteByte('_')
+			}
+		}
+		if isLower(c) {
+			c -= 'a' - 'A'
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+func isUpper(c byte) bool { return c >= 'A' && c <= 'Z' }
+func isLower(c byte) bool { return c >= 'a' && c <= 'z' }
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
@@ -0,0 +1,364 @@
+package proto3
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Option sets a named option on a file, message, field, oneof, enum, enum value, service or method.
+// https://developers.google.com/protocol-buffers/docs/proto3#options
+type Option struct {
+	Name    string
+	Value   OptionValue
+	Comment string // written after an option statement, or before it over several lines; options in brackets and aggregates have none
+}
+
+// OptionValue describes the typed value assigned to an option.
+type OptionValue interface {
+	Validate() error
+	Write() string
+}
+
+// StringValue is an option value written as a quoted, escaped string literal.
+type StringValue string
+
+// IntValue is an option value written as an integer literal.
+type IntValue int64
+
+// UintValue is an option value written as an integer literal too large for an IntValue, which only
+// uint64, fixed64 and floating-point options can hold.
+type UintValue uint64
+
+// FloatValue is an option value written as a floating-point literal.
+type FloatValue float64
+
+// BoolValue is an option value written as true or false.
+type BoolValue bool
+
+// IdentValue is an option value written as an unquoted identifier, such as an enum value name.
+type IdentValue string
+
+// AggregateValue is an option value written as a protobuf text-format message, with one entry per field.
+// Repeated fields are expressed by repeating the entry.
+type AggregateValue []Option
+
+// optionScope identifies the kind of element an option is attached to.
+type optionScope uint8
+
+const (
+	fileScope optionScope = iota
+	messageScope
+	fieldScope
+	oneofScope
+	enumScope
+	enumValueScope
+	serviceScope
+	methodScope
+)
+
+// builtinOption describes the type of value expected by an option defined in google/protobuf/descriptor.proto.
+type builtinOption struct {
+	value  OptionValue // zero value of the expected type
+	idents []string    // permitted values when the option is an enum
+}
+
+// builtinOptions lists the options defined by google/protobuf/descriptor.proto that can be set in a proto3
+// file, keyed by the element they apply to.
+var builtinOptions = map[optionScope]map[string]builtinOption{
+	fileScope: {
+		"java_package":           {value: StringValue("")},
+		"java_outer_classname":   {value: StringValue("")},
+		"java_multiple_files":    {value: BoolValue(false)},
+		"java_string_check_utf8": {value: BoolValue(false)},
+		"optimize_for":           {value: IdentValue(""), idents: []string{"SPEED", "CODE_SIZE", "LITE_RUNTIME"}},
+		"go_package":             {value: StringValue("")},
+		"cc_generic_services":    {value: BoolValue(false)},
+		"java_generic_services":  {value: BoolValue(false)},
+		"py_generic_services":    {value: BoolValue(false)},
+		"deprecated":             {value: BoolValue(false)},
+		"cc_enable_arenas":       {value: BoolValue(false)},
+		"objc_class_prefix":      {value: StringValue("")},
+		"csharp_namespace":       {value: StringValue("")},
+		"swift_prefix":           {value: StringValue("")},
+		"php_class_prefix":       {value: StringValue("")},
+		"php_namespace":          {value: StringValue("")},
+		"php_metadata_namespace": {value: StringValue("")},
+		"ruby_package":           {value: StringValue("")},
+	},
+	messageScope: {
+		"message_set_wire_format":         {value: BoolValue(false)},
+		"no_standard_descriptor_accessor": {value: BoolValue(false)},
+		"deprecated":                      {value: BoolValue(false)},
+	},
+	fieldScope: {
+		"ctype":      {value: IdentValue(""), idents: []string{"STRING", "CORD", "STRING_PIECE"}},
+		"packed":     {value: BoolValue(false)},
+		"jstype":     {value: IdentValue(""), idents: []string{"JS_NORMAL", "JS_STRING", "JS_NUMBER"}},
+		"lazy":       {value: BoolValue(false)},
+		"deprecated": {value: BoolValue(false)},
+		"json_name":  {value: StringValue("")},
+	},
+	oneofScope: {},
+	enumScope: {
+		"allow_alias": {value: BoolValue(false)},
+		"deprecated":  {value: BoolValue(false)},
+	},
+	enumValueScope: {
+		"deprecated": {value: BoolValue(false)},
+	},
+	serviceScope: {
+		"deprecated": {value: BoolValue(false)},
+	},
+	methodScope: {
+		"deprecated":        {value: BoolValue(false)},
+		"idempotency_level": {value: IdentValue(""), idents: []string{"IDEMPOTENCY_UNKNOWN", "NO_SIDE_EFFECTS", "IDEMPOTENT"}},
+	},
+}
+
+// WRITERS
+
+// Write an Option as a string of the form name = value
+func (o Option) Write() (string, error) {
+	if o.Value == nil {
+		return "", fmt.Errorf("Option %s must have a value", o.Name)
+	}
+	return fmt.Sprintf("%s = %s", o.Name, o.Value.Write()), nil
+}
+
+// Write a StringValue as a quoted string literal
+func (v StringValue) Write() string {
+	return quoteString(string(v))
+}
+
+// Write an IntValue as a string
+func (v IntValue) Write() string {
+	return strconv.FormatInt(int64(v), 10)
+}
+
+// Write a UintValue as a string
+func (v UintValue) Write() string {
+	return strconv.FormatUint(uint64(v), 10)
+}
+
+// Write a FloatValue as a string
+func (v FloatValue) Write() string {
+	f := float64(v)
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	case math.IsNaN(f):
+		return "nan"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// Write a BoolValue as a string
+func (v BoolValue) Write() string {
+	return strconv.FormatBool(bool(v))
+}
+
+// Write an IdentValue as a string
+func (v IdentValue) Write() string {
+	return string(v)
+}
+
+// Write an AggregateValue as a text-format message
+func (v AggregateValue) Write() string {
+	var buffer bytes.Buffer
+	buffer.WriteString("{")
+	for _, o := range v {
+		buffer.WriteString(" ")
+		buffer.WriteString(o.Name)
+		buffer.WriteString(": ")
+		if o.Value != nil {
+			buffer.WriteString(o.Value.Write())
+		}
+	}
+	buffer.WriteString(" }")
+	return buffer.String()
+}
+
+// quoteString writes s as a double-quoted string literal, escaping quotes, backslashes and control
+// characters.
+func quoteString(s string) string {
+	var buffer bytes.Buffer
+	buffer.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case '"':
+			buffer.WriteString(`\"`)
+		case '\\':
+			buffer.WriteString(`\\`)
+		case '\n':
+			buffer.WriteString(`\n`)
+		case '\r':
+			buffer.WriteString(`\r`)
+		case '\t':
+			buffer.WriteString(`\t`)
+		default:
+			if c < 0x20 || c == 0x7f {
+				buffer.WriteString(fmt.Sprintf(`\%03o`, c))
+			} else {
+				buffer.WriteByte(c)
+			}
+		}
+	}
+	buffer.WriteByte('"')
+	return buffer.String()
+}
+
+// VALIDATORS
+
+// Validate option attributes
+func (o Option) Validate() error {
+	var errs ValidationErrors
+	if o.Name == "" {
+		errs.add("", CodeEmptyName, "Option must have a non-empty name")
+	} else if !isOptionName(o.Name) {
+		errs.add("", CodeInvalidOption, "Option name %s is not a valid identifier", o.Name)
+	}
+	if o.Value == nil {
+		errs.add(o.Name, CodeInvalidOption, "Option must have a value")
+	} else {
+		errs.merge(o.Name, o.Value.Validate())
+	}
+	return errs.err()
+}
+
+// Validate a string value
+func (v StringValue) Validate() error {
+	return nil
+}
+
+// Validate an integer value
+func (v IntValue) Validate() error {
+	return nil
+}
+
+// Validate an unsigned integer value
+func (v UintValue) Validate() error {
+	return nil
+}
+
+// intValue returns an integer option value, which is a UintValue when it is too large for an IntValue.
+func intValue(v uint64) OptionValue {
+	if v > math.MaxInt64 {
+		return UintValue(v)
+	}
+	return IntValue(v)
+}
+
+// Validate a floating-point value
+func (v FloatValue) Validate() error {
+	return nil
+}
+
+// Validate a boolean value
+func (v BoolValue) Validate() error {
+	return nil
+}
+
+// Validate an identifier value
+func (v IdentValue) Validate() error {
+	var errs ValidationErrors
+	if !isFullIdent(string(v)) {
+		errs.add("", CodeInvalidOption, "Option value %q is not a valid identifier", string(v))
+	}
+	return errs.err()
+}
+
+// Validate each entry of an aggregate value. Entry names may be bracketed extension names.
+func (v AggregateValue) Validate() error {
+	var errs ValidationErrors
+	for _, o := range v {
+		name := o.Name
+		if strings.HasPrefix(name, "[") && strings.HasSuffix(name, "]") {
+			name = name[1 : len(name)-1]
+		}
+		if !isFullIdent(name) {
+			errs.add("", CodeInvalidOption, "Aggregate entry name %s is not a valid identifier", o.Name)
+		}
+		if o.Value == nil {
+			errs.add(o.Name, CodeInvalidOption, "Aggregate entry must have a value")
+		} else {
+			errs.merge(o.Name, o.Value.Validate())
+		}
+	}
+	return errs.err()
+}
+
+// validateOptions checks each option in a set, that no option is set more than once, and that options
+// defined by google/protobuf/descriptor.proto apply to the scope and have a value of the expected type.
+// Custom options are resolved against the spec's extensions by Spec.Validate.
+func validateOptions(scope optionScope, options []Option) error {
+	var errs ValidationErrors
+	names := make(map[string]bool)
+	for _, o := range options {
+		if err := o.Validate(); err != nil {
+			errs.merge("", err)
+			continue
+		}
+		if names[o.Name] {
+			errs.add(o.Name, CodeDuplicateOption, "Option %s is set more than once", o.Name)
+		}
+		names[o.Name] = true
+		if _, custom := customOptionName(o.Name); custom {
+			continue
+		}
+
+		builtin, ok := builtinOptions[scope][o.Name]
+		if !ok {
+			errs.add(o.Name, CodeUnknownOption, "Option %s is not a known option", o.Name)
+			continue
+		}
+		if reflect.TypeOf(builtin.value) != reflect.TypeOf(o.Value) {
+			errs.add(o.Name, CodeInvalidOption, "Option %s has a value of the wrong type", o.Name)
+			continue
+		}
+		if ident, isIdent := o.Value.(IdentValue); isIdent && !containsString(builtin.idents, string(ident)) {
+			errs.add(o.Name, CodeInvalidOption, "Option %s must be one of %s", o.Name, strings.Join(builtin.idents, ", "))
+		}
+	}
+	return errs.err()
+}
+
+// hasOption reports whether an option with the given name is present in a set.
+func hasOption(options []Option, name string) bool {
+	for _, o := range options {
+		if o.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// isFullIdent reports whether s is a dot-separated sequence of identifiers.
+func isFullIdent(s string) bool {
+	for _, part := range strings.Split(s, ".") {
+		if part == "" || !isLetter(part[0]) {
+			return false
+		}
+		for i := 1; i < len(part); i++ {
+			if !isLetter(part[i]) && !isDigit(part[i]) {
+				return false
+			}
+		}
+	}
+	return true
+}
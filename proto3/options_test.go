@@ -0,0 +1,189 @@
+package proto3_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	. "github.com/muxinc/protogen/proto3"
+)
+
+func TestOption_Write(t *testing.T) {
+	tests := []struct {
+		name   string
+		option Option
+		want   string
+	}{
+		{"String", Option{Name: "csharp_namespace", Value: StringValue("Mux.Data")}, `csharp_namespace = "Mux.Data"`},
+		{"Escaped string", Option{Name: "json_name", Value: StringValue("say \"hi\"\\\n\x01")}, `json_name = "say \"hi\"\\\n\001"`},
+		{"Integer", Option{Name: "number", Value: IntValue(-42)}, `number = -42`},
+		{"Float", Option{Name: "ratio", Value: FloatValue(0.5)}, `ratio = 0.5`},
+		{"Boolean", Option{Name: "deprecated", Value: BoolValue(true)}, `deprecated = true`},
+		{"Identifier", Option{Name: "optimize_for", Value: IdentValue("SPEED")}, `optimize_for = SPEED`},
+		{
+			"Aggregate",
+			Option{Name: "rule", Value: AggregateValue{{Name: "min", Value: IntValue(1)}, {Name: "nested", Value: AggregateValue{{Name: "name", Value: StringValue("x")}}}}},
+			`rule = { min: 1 nested: { name: "x" } }`,
+		},
+	}
+	for _, tt := range tests {
+		got, err := tt.option.Write()
+		if err != nil {
+			t.Errorf("%q. Option.Write() error = %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q. Option.Write() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSpec_ValidateOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    Spec
+		wantErr bool
+	}{
+		{
+			name: "Known options",
+			spec: Spec{
+				Options:  []Option{{Name: "java_multiple_files", Value: BoolValue(true)}, {Name: "optimize_for", Value: IdentValue("CODE_SIZE")}},
+				Messages: []Message{{Name: "Beacon", Fields: []Field{ScalarField{Name: "ids", Tag: 1, Rule: Repeated, Typing: Int64Type, Options: []Option{{Name: "packed", Value: BoolValue(false)}}}}}},
+			},
+		},
+		{
+			name: "Unknown option",
+			spec: Spec{
+				Messages: []Message{{Name: "Beacon", Options: []Option{{Name: "packed", Value: BoolValue(true)}}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Wrong value type",
+			spec: Spec{
+				Options:  []Option{{Name: "java_multiple_files", Value: StringValue("true")}},
+				Messages: []Message{{Name: "Beacon"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Unknown enum option value",
+			spec: Spec{
+				Options:  []Option{{Name: "optimize_for", Value: IdentValue("FAST")}},
+				Messages: []Message{{Name: "Beacon"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Option set twice",
+			spec: Spec{
+				GoPackage: "example.com/foo",
+				Options:   []Option{{Name: "go_package", Value: StringValue("example.com/bar")}},
+				Messages:  []Message{{Name: "Beacon"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Packed string field",
+			spec: Spec{
+				Messages: []Message{{Name: "Beacon", Fields: []Field{ScalarField{Name: "names", Tag: 1, Rule: Repeated, Typing: StringType, Options: []Option{{Name: "packed", Value: BoolValue(true)}}}}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Missing value",
+			spec: Spec{
+				Messages: []Message{{Name: "Beacon", Enums: []Enum{{Name: "Kind", Values: []EnumValue{{Name: "NONE", Options: []Option{{Name: "deprecated"}}}}}}}},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		if err := tt.spec.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%q. Spec.Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestParse_Options(t *testing.T) {
+	src := `syntax = "proto3";
+package foo;
+option go_package = "example.com/foo";
+option java_multiple_files = true;
+option optimize_for = CODE_SIZE;
+option csharp_namespace = "Mux.\"Data\"";
+
+message Beacon {
+  option deprecated = true;
+
+  enum Kind {
+    option allow_alias = true;
+    option deprecated = false;
+    NONE = 0;
+    VIEW = 1 [deprecated = true];
+    PAGE = 1;
+  }
+
+  repeated int64 ids = 1 [packed = false, json_name = "IDs"];   // Identifiers
+
+  oneof choice {
+    string name = 2 [deprecated = true];
+  }
+}
+
+service Collector {
+  option deprecated = true;
+  rpc Send(Beacon) returns (Beacon) { option idempotency_level = IDEMPOTENT; }
+}
+`
+	spec, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	got, err := spec.Write()
+	if err != nil {
+		t.Fatalf("Spec.Write() error = %v", err)
+	}
+	if got != src {
+		t.Errorf("Spec.Write() of parsed spec differs from source:\n%s", got)
+	}
+}
+
+func ExampleOption() {
+	spec := &Spec{
+		Package: "foo",
+		Options: []Option{
+			{Name: "java_multiple_files", Value: BoolValue(true)},
+			{Name: "optimize_for", Value: IdentValue("SPEED")},
+		},
+		Messages: []Message{
+			{
+				Name:    "Beacon",
+				Options: []Option{{Name: "deprecated", Value: BoolValue(true)}},
+				Fields: []Field{
+					ScalarField{Name: "view_id", Typing: StringType, Tag: 1, Options: []Option{{Name: "json_name", Value: StringValue("viewID")}}},
+				},
+			},
+		},
+	}
+
+	s, err := spec.Write()
+	if err != nil {
+		fmt.Println(err)
+	} else {
+		fmt.Println(s)
+	}
+
+	// Output:
+	// syntax = "proto3";
+	// package foo;
+	// option java_multiple_files = true;
+	// option optimize_for = SPEED;
+	//
+	// message Beacon {
+	//   option deprecated = true;
+	//
+	//   string view_id = 1 [json_name = "viewID"];
+	//
+	// }
+}
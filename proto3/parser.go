@@ -0,0 +1,1138 @@
+package proto3
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Parse reads a proto3 specification from r and converts it into a Spec. Constructs that cannot be
+// represented by a Spec are reported as errors rather than silently dropped. This includes comments,
+// which are kept when they precede or follow a statement or definition, and are otherwise reported at
+// their position. The comments of the package, imports and go_package and java_package options, which a
+// Spec keeps no comment for, are kept by the comment of the file, and those of the allow_alias option by
+// the comment of its enum. The Order of the spec and of each body records the declaration order, along
+// with the blank lines, reserved statements listing several values and trailing comments of the source.
+func Parse(r io.Reader) (*Spec, error) {
+	src, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, leadingKept: make([]bool, len(tokens)), trailingKept: make([]bool, len(tokens))}
+	spec, err := p.parseSpec()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.checkComments(); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// parser builds a Spec from the tokens of a .proto file using recursive descent.
+type parser struct {
+	tokens       []token
+	pos          int
+	leadingKept  []bool // whether the leading comments of each token are kept by the Spec
+	trailingKept []bool // whether the trailing comments of each token are kept by the Spec
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) errorf(tok token, format string, args ...interface{}) error {
+	return &ParseError{Line: tok.line, Column: tok.col, Message: fmt.Sprintf(format, args...)}
+}
+
+// leading returns the comments before a token, recording that they are kept.
+func (p *parser) leading(tok token) string {
+	p.leadingKept[tok.index] = true
+	return commentText(tok.leading)
+}
+
+// trailing returns the comments that follow a token on its line, recording that they are kept.
+func (p *parser) trailing(tok token) string {
+	p.trailingKept[tok.index] = true
+	return commentText(tok.trailing)
+}
+
+// checkComments reports the first comment that is not kept by the Spec, such as a comment within a
+// statement, before a closing brace or at the end of the file. Empty comments hold nothing to lose.
+func (p *parser) checkComments() error {
+	for _, tok := range p.tokens {
+		var dropped []comment
+		if !p.leadingKept[tok.index] {
+			dropped = append(dropped, tok.leading...)
+		}
+		if !p.trailingKept[tok.index] {
+			dropped = append(dropped, tok.trailing...)
+		}
+		for _, c := range dropped {
+			if c.text != "" {
+				return commentError(c)
+			}
+		}
+	}
+	return nil
+}
+
+func commentError(c comment) error {
+	return &ParseError{Line: c.line, Column: c.col, Message: "comment cannot be kept here; " +
+		"comments must precede or follow a definition, field, enum value, option or reserved statement"}
+}
+
+func (p *parser) unexpected(tok token, expected string) error {
+	if tok.kind == tokenEOF {
+		return p.errorf(tok, "unexpected end of file, expected %s", expected)
+	}
+	return p.errorf(tok, "unexpected %q, expected %s", tok.text, expected)
+}
+
+func (p *parser) isSymbol(s string) bool {
+	tok := p.peek()
+	return tok.kind == tokenSymbol && tok.text == s
+}
+
+func (p *parser) isKeyword(s string) bool {
+	tok := p.peek()
+	return tok.kind == tokenIdent && tok.text == s
+}
+
+func (p *parser) expectSymbol(s string) (token, error) {
+	tok := p.next()
+	if tok.kind != tokenSymbol || tok.text != s {
+		return tok, p.unexpected(tok, fmt.Sprintf("%q", s))
+	}
+	return tok, nil
+}
+
+func (p *parser) expectKeyword(s string) (token, error) {
+	tok := p.next()
+	if tok.kind != tokenIdent || tok.text != s {
+		return tok, p.unexpected(tok, fmt.Sprintf("%q", s))
+	}
+	return tok, nil
+}
+
+func (p *parser) expectIdent() (token, error) {
+	tok := p.next()
+	if tok.kind != tokenIdent {
+		return tok, p.unexpected(tok, "identifier")
+	}
+	return tok, nil
+}
+
+// expectString reads one or more adjacent string literals and concatenates them.
+func (p *parser) expectString() (string, error) {
+	tok := p.next()
+	if tok.kind != tokenString {
+		return "", p.unexpected(tok, "string literal")
+	}
+	value := tok.text
+	for p.peek().kind == tokenString {
+		value += p.next().text
+	}
+	return value, nil
+}
+
+// parseFullIdent reads a dotted identifier such as foo.bar.Baz, optionally with a leading dot.
+func (p *parser) parseFullIdent() (string, error) {
+	var buffer bytes.Buffer
+	if p.isSymbol(".") {
+		buffer.WriteString(p.next().text)
+	}
+	tok, err := p.expectIdent()
+	if err != nil {
+		return "", err
+	}
+	buffer.WriteString(tok.text)
+	for p.isSymbol(".") {
+		buffer.WriteString(p.next().text)
+		tok, err := p.expectIdent()
+		if err != nil {
+			return "", err
+		}
+		buffer.WriteString(tok.text)
+	}
+	return buffer.String(), nil
+}
+
+// parseTag reads a field or enum value number. Negative numbers are only permitted when allowNegative is
+// set, which is the case for enum values.
+func (p *parser) parseTag(allowNegative bool) (TagType, error) {
+	negative := false
+	if allowNegative && p.isSymbol("-") {
+		p.next()
+		negative = true
+	}
+	tok := p.next()
+	if tok.kind != tokenInt {
+		return 0, p.unexpected(tok, "integer")
+	}
+	v, err := parseIntLiteral(tok.text)
+	if err != nil {
+		return 0, p.errorf(tok, "invalid integer %q", tok.text)
+	}
+	limit := uint64(math.MaxInt32)
+	if negative {
+		limit++
+	}
+	if v > limit {
+		return 0, p.errorf(tok, "tag %s is out of range", tok.text)
+	}
+	if negative {
+		return TagType(-int64(v)), nil
+	}
+	return TagType(v), nil
+}
+
+// parseIntLiteral converts the text of an integer literal as protoc reads it: decimal, hexadecimal after
+// 0x, or octal after a leading 0.
+func parseIntLiteral(text string) (uint64, error) {
+	base, digits := 10, text
+	switch {
+	case len(text) > 2 && (text[:2] == "0x" || text[:2] == "0X"):
+		base, digits = 16, text[2:]
+	case len(text) > 1 && text[0] == '0':
+		base, digits = 8, text[1:]
+	}
+	return strconv.ParseUint(digits, base, 64)
+}
+
+// parseEnd reads the terminating semicolon of a statement and returns its trailing comment, if any.
+func (p *parser) parseEnd() (string, error) {
+	tok, err := p.expectSymbol(";")
+	if err != nil {
+		return "", err
+	}
+	return p.trailing(tok), nil
+}
+
+func (p *parser) parseSpec() (*Spec, error) {
+	spec := &Spec{}
+
+	tok := p.peek()
+	if tok.kind != tokenIdent || tok.text != "syntax" {
+		return nil, p.errorf(tok, "missing syntax statement, only proto3 files are supported")
+	}
+	spec.FileComment = p.leading(tok)
+	if n := len(tok.leading); n > 0 && tok.line > tok.leading[n-1].end+1 {
+		spec.Order = append(spec.Order, Element{Kind: ElementBlank})
+	}
+	spec.Order = append(spec.Order, Element{Kind: ElementSyntax})
+	p.next()
+	if _, err := p.expectSymbol("="); err != nil {
+		return nil, err
+	}
+	syntaxTok := p.peek()
+	syntax, err := p.expectString()
+	if err != nil {
+		return nil, err
+	}
+	if syntax != "proto3" {
+		return nil, p.errorf(syntaxTok, "unsupported syntax %q, only proto3 files are supported", syntax)
+	}
+	trailing, err := p.parseEnd()
+	if err != nil {
+		return nil, err
+	}
+	spec.FileComment = joinComments(spec.FileComment, trailing)
+
+	for {
+		tok := p.peek()
+		switch {
+		case tok.kind == tokenEOF:
+			return spec, nil
+		case tok.kind == tokenSymbol && tok.text == ";":
+			p.next()
+		case tok.kind != tokenIdent:
+			return nil, p.unexpected(tok, "top-level definition")
+		case tok.text == "package":
+			p.next()
+			if spec.Package != "" {
+				return nil, p.errorf(tok, "multiple package statements")
+			}
+			name, err := p.parseFullIdent()
+			if err != nil {
+				return nil, err
+			}
+			spec.Package = name
+			trailing, err := p.parseEnd()
+			if err != nil {
+				return nil, err
+			}
+			spec.Order = p.declare(spec.Order, tok, "", Element{Kind: ElementPackage})
+			spec.FileComment = joinComments(spec.FileComment, joinComments(p.leading(tok), trailing))
+		case tok.text == "import":
+			p.next()
+			if p.isKeyword("public") || p.isKeyword("weak") {
+				return nil, p.errorf(p.peek(), "%s imports are not supported", p.peek().text)
+			}
+			path, err := p.expectString()
+			if err != nil {
+				return nil, err
+			}
+			spec.Imports = append(spec.Imports, ImportType(path))
+			trailing, err := p.parseEnd()
+			if err != nil {
+				return nil, err
+			}
+			spec.FileComment = joinComments(spec.FileComment, joinComments(p.leading(tok), trailing))
+		case tok.text == "option":
+			if err := p.parseFileOption(spec); err != nil {
+				return nil, err
+			}
+		case tok.text == "message":
+			msg, err := p.parseMessage()
+			if err != nil {
+				return nil, err
+			}
+			spec.Order = p.declare(spec.Order, tok, msg.Comment, Element{Kind: ElementMessage, Index: len(spec.Messages)})
+			spec.Messages = append(spec.Messages, msg)
+		case tok.text == "enum":
+			e, err := p.parseEnum()
+			if err != nil {
+				return nil, err
+			}
+			spec.Order = p.declare(spec.Order, tok, e.Comment, Element{Kind: ElementEnum, Index: len(spec.Enums)})
+			spec.Enums = append(spec.Enums, e)
+		case tok.text == "extend":
+			e, err := p.parseExtend()
+			if err != nil {
+				return nil, err
+			}
+			spec.Order = p.declare(spec.Order, tok, e.Comment, Element{Kind: ElementExtend, Index: len(spec.Extends)})
+			spec.Extends = append(spec.Extends, e)
+		case tok.text == "service":
+			svc, err := p.parseService()
+			if err != nil {
+				return nil, err
+			}
+			spec.Order = p.declare(spec.Order, tok, svc.Comment, Element{Kind: ElementService, Index: len(spec.Services)})
+			spec.Services = append(spec.Services, svc)
+		default:
+			return nil, p.errorf(tok, "unsupported top-level definition %q", tok.text)
+		}
+	}
+}
+
+func (p *parser) parseFileOption(spec *Spec) error {
+	nameTok := p.tokens[p.pos+1]
+	o, err := p.parseOptionStatement()
+	if err != nil {
+		return err
+	}
+	switch v := o.Value.(type) {
+	case StringValue:
+		if o.Name == "go_package" && spec.GoPackage == "" {
+			spec.GoPackage = string(v)
+			spec.FileComment = joinComments(spec.FileComment, o.Comment)
+			return nil
+		}
+		if o.Name == "java_package" && spec.JavaPackage == "" {
+			spec.JavaPackage = string(v)
+			spec.FileComment = joinComments(spec.FileComment, o.Comment)
+			return nil
+		}
+	default:
+		if o.Name == "go_package" || o.Name == "java_package" {
+			return p.errorf(nameTok, "option %s must be a string", o.Name)
+		}
+	}
+	spec.Options = append(spec.Options, o)
+	return nil
+}
+
+func (p *parser) parseMessage() (Message, error) {
+	keyword := p.next()
+	msg := Message{Comment: p.leading(keyword)}
+	name, err := p.expectIdent()
+	if err != nil {
+		return msg, err
+	}
+	msg.Name = name.text
+	open, err := p.expectSymbol("{")
+	if err != nil {
+		return msg, err
+	}
+	msg.Comment = joinComments(msg.Comment, p.trailing(open))
+
+	for {
+		tok := p.peek()
+		switch {
+		case tok.kind == tokenSymbol && tok.text == "}":
+			msg.Comment = joinComments(msg.Comment, p.trailing(p.next()))
+			return msg, nil
+		case tok.kind == tokenSymbol && tok.text == ";":
+			p.next()
+		case tok.kind == tokenEOF:
+			return msg, p.unexpected(tok, "\"}\"")
+		case tok.kind == tokenIdent && tok.text == "message":
+			nested, err := p.parseMessage()
+			if err != nil {
+				return msg, err
+			}
+			msg.Order = p.declare(msg.Order, tok, nested.Comment, Element{Kind: ElementMessage, Index: len(msg.Messages)})
+			msg.Messages = append(msg.Messages, nested)
+		case tok.kind == tokenIdent && tok.text == "enum":
+			e, err := p.parseEnum()
+			if err != nil {
+				return msg, err
+			}
+			msg.Order = p.declare(msg.Order, tok, e.Comment, Element{Kind: ElementEnum, Index: len(msg.Enums)})
+			msg.Enums = append(msg.Enums, e)
+		case tok.kind == tokenIdent && tok.text == "oneof":
+			o, err := p.parseOneOf()
+			if err != nil {
+				return msg, err
+			}
+			msg.Order = p.declare(msg.Order, tok, o.Comment, Element{Kind: ElementOneOf, Index: len(msg.OneOfs)})
+			msg.OneOfs = append(msg.OneOfs, o)
+		case tok.kind == tokenIdent && tok.text == "reserved":
+			reserved, err := p.parseReserved(false)
+			if err != nil {
+				return msg, err
+			}
+			msg.Order = p.declare(msg.Order, tok, reservedComment(reserved[0]), reservedElements(len(msg.ReservedValues), len(reserved))...)
+			msg.ReservedValues = append(msg.ReservedValues, reserved...)
+		case tok.kind == tokenIdent && tok.text == "option":
+			o, err := p.parseOptionStatement()
+			if err != nil {
+				return msg, err
+			}
+			msg.Order = p.declare(msg.Order, tok, o.Comment, Element{Kind: ElementOption, Index: len(msg.Options)})
+			msg.Options = append(msg.Options, o)
+		case tok.kind == tokenIdent && tok.text == "extend":
+			e, err := p.parseExtend()
+			if err != nil {
+				return msg, err
+			}
+			msg.Order = p.declare(msg.Order, tok, e.Comment, Element{Kind: ElementExtend, Index: len(msg.Extends)})
+			msg.Extends = append(msg.Extends, e)
+		case tok.kind == tokenIdent && (tok.text == "extensions" ||
+			tok.text == "service" || tok.text == "group" || tok.text == "required"):
+			return msg, p.errorf(tok, "unsupported message element %q", tok.text)
+		default:
+			f, err := p.parseField(true)
+			if err != nil {
+				return msg, err
+			}
+			msg.Order = p.declare(msg.Order, tok, fieldComment(f), Element{Kind: ElementField, Index: len(msg.Fields)})
+			msg.Fields = append(msg.Fields, f)
+		}
+	}
+}
+
+// parseField reads a normal or map field. Field rules are only permitted when allowRule is set, which is
+// not the case for fields within a oneof.
+func (p *parser) parseField(allowRule bool) (Field, error) {
+	first := p.peek()
+	rule := None
+	if p.isKeyword("repeated") || p.isKeyword("optional") {
+		if !allowRule {
+			return nil, p.errorf(first, "oneof fields cannot be %s", first.text)
+		}
+		p.next()
+		rule = Repeated
+		if first.text == "optional" {
+			rule = Optional
+		}
+	}
+
+	if p.isKeyword("map") && p.tokens[p.pos+1].kind == tokenSymbol && p.tokens[p.pos+1].text == "<" {
+		return p.parseMapField(first, rule)
+	}
+
+	typeTok := p.peek()
+	typing, err := p.parseFullIdent()
+	if err != nil {
+		return nil, p.unexpected(typeTok, "field type")
+	}
+	name, tag, options, comment, err := p.parseFieldTail(first)
+	if err != nil {
+		return nil, err
+	}
+	if scalar, ok := parseFieldType(typing); ok {
+		return ScalarField{Name: name, Tag: tag, Rule: rule, Comment: comment, Typing: scalar, Options: options}, nil
+	}
+	return CustomField{Name: name, Tag: tag, Rule: rule, Comment: comment, Typing: typing, Options: options}, nil
+}
+
+func (p *parser) parseMapField(first token, rule FieldRule) (Field, error) {
+	p.next()
+	p.next()
+	keyTok := p.peek()
+	key, err := p.parseFullIdent()
+	if err != nil {
+		return nil, err
+	}
+	keyTyping, ok := parseFieldType(key)
+	if !ok {
+		return nil, p.errorf(keyTok, "map key type %q must be a scalar type", key)
+	}
+	if _, err := p.expectSymbol(","); err != nil {
+		return nil, err
+	}
+	value, err := p.parseFullIdent()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expectSymbol(">"); err != nil {
+		return nil, err
+	}
+	name, tag, options, comment, err := p.parseFieldTail(first)
+	if err != nil {
+		return nil, err
+	}
+	if valueTyping, ok := parseFieldType(value); ok {
+		return MapField{Name: name, Tag: tag, Rule: rule, Comment: comment, KeyTyping: keyTyping, ValueTyping: valueTyping, Options: options}, nil
+	}
+	return CustomMapField{Name: name, Tag: tag, Rule: rule, Comment: comment, KeyTyping: keyTyping, ValueTyping: value, Options: options}, nil
+}
+
+// parseFieldTail reads the "name = tag [options];" portion of a field definition along with the field's
+// comment. A trailing comment is preferred, with any leading comment on the first token of the field
+// prepended.
+func (p *parser) parseFieldTail(first token) (NameType, TagType, []Option, string, error) {
+	name, err := p.expectIdent()
+	if err != nil {
+		return "", 0, nil, "", err
+	}
+	if _, err := p.expectSymbol("="); err != nil {
+		return "", 0, nil, "", err
+	}
+	tag, err := p.parseTag(false)
+	if err != nil {
+		return "", 0, nil, "", err
+	}
+	options, err := p.parseCompactOptions()
+	if err != nil {
+		return "", 0, nil, "", err
+	}
+	trailing, err := p.parseEnd()
+	if err != nil {
+		return "", 0, nil, "", err
+	}
+	return NameType(name.text), tag, options, joinComments(p.leading(first), trailing), nil
+}
+
+func (p *parser) parseExtend() (Extend, error) {
+	keyword := p.next()
+	e := Extend{Comment: p.leading(keyword)}
+	typing, err := p.parseFullIdent()
+	if err != nil {
+		return e, err
+	}
+	e.Typing = typing
+	open, err := p.expectSymbol("{")
+	if err != nil {
+		return e, err
+	}
+	e.Comment = joinComments(e.Comment, p.trailing(open))
+	for {
+		tok := p.peek()
+		switch {
+		case tok.kind == tokenSymbol && tok.text == "}":
+			e.Comment = joinComments(e.Comment, p.trailing(p.next()))
+			return e, nil
+		case tok.kind == tokenSymbol && tok.text == ";":
+			p.next()
+		case tok.kind == tokenEOF:
+			return e, p.unexpected(tok, "\"}\"")
+		default:
+			f, err := p.parseField(true)
+			if err != nil {
+				return e, err
+			}
+			e.Fields = append(e.Fields, f)
+		}
+	}
+}
+
+func (p *parser) parseOneOf() (OneOf, error) {
+	keyword := p.next()
+	o := OneOf{Comment: p.leading(keyword)}
+	name, err := p.expectIdent()
+	if err != nil {
+		return o, err
+	}
+	o.Name = NameType(name.text)
+	open, err := p.expectSymbol("{")
+	if err != nil {
+		return o, err
+	}
+	o.Comment = joinComments(o.Comment, p.trailing(open))
+	for {
+		tok := p.peek()
+		switch {
+		case tok.kind == tokenSymbol && tok.text == "}":
+			o.Comment = joinComments(o.Comment, p.trailing(p.next()))
+			return o, nil
+		case tok.kind == tokenSymbol && tok.text == ";":
+			p.next()
+		case tok.kind == tokenEOF:
+			return o, p.unexpected(tok, "\"}\"")
+		case tok.kind == tokenIdent && tok.text == "option":
+			option, err := p.parseOptionStatement()
+			if err != nil {
+				return o, err
+			}
+			o.Order = p.declare(o.Order, tok, option.Comment, Element{Kind: ElementOption, Index: len(o.Options)})
+			o.Options = append(o.Options, option)
+		default:
+			if p.isKeyword("map") {
+				return o, p.errorf(tok, "oneof fields cannot be maps")
+			}
+			f, err := p.parseField(false)
+			if err != nil {
+				return o, err
+			}
+			o.Order = p.declare(o.Order, tok, fieldComment(f), Element{Kind: ElementField, Index: len(o.Fields)})
+			o.Fields = append(o.Fields, f)
+		}
+	}
+}
+
+// declare adds the elements of a definition or statement to a declaration order, given its first token and
+// its comment. They are preceded by a blank line marker when a blank line separates them from the element
+// before them, though not from the opening brace of their body, joined by commas when a reserved statement
+// lists several values, and followed by a trailing marker when the comment only follows them.
+func (p *parser) declare(order []Element, first token, comment string, elements ...Element) []Element {
+	if prev := p.tokens[first.index-1]; !(prev.kind == tokenSymbol && prev.text == "{") && p.blankBefore(first) {
+		order = append(order, Element{Kind: ElementBlank})
+	}
+	for i, e := range elements {
+		if i > 0 {
+			order = append(order, Element{Kind: ElementComma})
+		}
+		order = append(order, e)
+	}
+	if comment != "" && commentText(first.leading) == "" {
+		order = append(order, Element{Kind: ElementTrailing})
+	}
+	return order
+}
+
+// blankBefore reports whether a blank line separates a token, with the comments that lead it, from the token
+// before it and the comments that trail that token.
+func (p *parser) blankBefore(tok token) bool {
+	prev := p.tokens[tok.index-1]
+	end := prev.line
+	if n := len(prev.trailing); n > 0 {
+		end = prev.trailing[n-1].end
+	}
+	start := tok.line
+	if len(tok.leading) > 0 {
+		start = tok.leading[0].line
+	}
+	return start > end+1
+}
+
+// reservedElements returns the elements of a reserved statement, which follow the reserved values declared
+// before it.
+func reservedElements(declared, n int) []Element {
+	elements := make([]Element, n)
+	for i := range elements {
+		elements[i] = Element{Kind: ElementReserved, Index: declared + i}
+	}
+	return elements
+}
+
+// parseReserved reads a reserved statement, which is either a list of tags and tag ranges or a list of
+// names. Negative tags are only allowed for enums. The comment of the statement is kept by its first entry.
+func (p *parser) parseReserved(allowNegative bool) ([]Reserved, error) {
+	keyword := p.next()
+	var reserved []Reserved
+	if p.peek().kind == tokenString {
+		for {
+			name, err := p.expectString()
+			if err != nil {
+				return nil, err
+			}
+			reserved = append(reserved, ReservedName{Name: NameType(name)})
+			if !p.isSymbol(",") {
+				break
+			}
+			p.next()
+		}
+	} else {
+		for {
+			lower, err := p.parseTag(allowNegative)
+			if err != nil {
+				return nil, err
+			}
+			if p.isKeyword("to") {
+				p.next()
+				upper := MaxTag
+				if p.isKeyword("max") {
+					p.next()
+				} else if upper, err = p.parseTag(allowNegative); err != nil {
+					return nil, err
+				}
+				reserved = append(reserved, ReservedTagRange{LowerTag: lower, UpperTag: upper})
+			} else {
+				reserved = append(reserved, ReservedTagValue{Tag: lower})
+			}
+			if !p.isSymbol(",") {
+				break
+			}
+			p.next()
+		}
+	}
+	trailing, err := p.parseEnd()
+	if err != nil {
+		return nil, err
+	}
+	reserved[0] = withReservedComment(reserved[0], joinComments(p.leading(keyword), trailing))
+	return reserved, nil
+}
+
+func (p *parser) parseEnum() (Enum, error) {
+	keyword := p.next()
+	e := Enum{Comment: p.leading(keyword)}
+	name, err := p.expectIdent()
+	if err != nil {
+		return e, err
+	}
+	e.Name = NameType(name.text)
+	open, err := p.expectSymbol("{")
+	if err != nil {
+		return e, err
+	}
+	e.Comment = joinComments(e.Comment, p.trailing(open))
+	for {
+		tok := p.peek()
+		switch {
+		case tok.kind == tokenSymbol && tok.text == "}":
+			e.Comment = joinComments(e.Comment, p.trailing(p.next()))
+			return e, nil
+		case tok.kind == tokenSymbol && tok.text == ";":
+			p.next()
+		case tok.kind == tokenEOF:
+			return e, p.unexpected(tok, "\"}\"")
+		case tok.kind == tokenIdent && tok.text == "option":
+			if err := p.parseEnumOption(&e); err != nil {
+				return e, err
+			}
+		case tok.kind == tokenIdent && tok.text == "reserved":
+			reserved, err := p.parseReserved(true)
+			if err != nil {
+				return e, err
+			}
+			e.Order = p.declare(e.Order, tok, reservedComment(reserved[0]), reservedElements(len(e.ReservedValues), len(reserved))...)
+			e.ReservedValues = append(e.ReservedValues, reserved...)
+		default:
+			v, err := p.parseEnumValue()
+			if err != nil {
+				return e, err
+			}
+			e.Order = p.declare(e.Order, tok, v.Comment, Element{Kind: ElementValue, Index: len(e.Values)})
+			e.Values = append(e.Values, v)
+		}
+	}
+}
+
+func (p *parser) parseEnumOption(e *Enum) error {
+	first := p.peek()
+	nameTok := p.tokens[p.pos+1]
+	o, err := p.parseOptionStatement()
+	if err != nil {
+		return err
+	}
+	if o.Name != "allow_alias" {
+		e.Order = p.declare(e.Order, first, o.Comment, Element{Kind: ElementOption, Index: len(e.Options)})
+		e.Options = append(e.Options, o)
+		return nil
+	}
+	v, ok := o.Value.(BoolValue)
+	if !ok {
+		return p.errorf(nameTok, "option allow_alias must be a boolean")
+	}
+	e.AllowAlias = bool(v)
+	e.Comment = joinComments(e.Comment, o.Comment)
+	return nil
+}
+
+func (p *parser) parseEnumValue() (EnumValue, error) {
+	name, err := p.expectIdent()
+	if err != nil {
+		return EnumValue{}, err
+	}
+	if _, err := p.expectSymbol("="); err != nil {
+		return EnumValue{}, err
+	}
+	tag, err := p.parseTag(true)
+	if err != nil {
+		return EnumValue{}, err
+	}
+	options, err := p.parseCompactOptions()
+	if err != nil {
+		return EnumValue{}, err
+	}
+	trailing, err := p.parseEnd()
+	if err != nil {
+		return EnumValue{}, err
+	}
+	return EnumValue{
+		Name:    NameType(name.text),
+		Tag:     tag,
+		Comment: joinComments(p.leading(name), trailing),
+		Options: options,
+	}, nil
+}
+
+func (p *parser) parseService() (Service, error) {
+	keyword := p.next()
+	svc := Service{Comment: p.leading(keyword)}
+	name, err := p.expectIdent()
+	if err != nil {
+		return svc, err
+	}
+	svc.Name = NameType(name.text)
+	open, err := p.expectSymbol("{")
+	if err != nil {
+		return svc, err
+	}
+	svc.Comment = joinComments(svc.Comment, p.trailing(open))
+	for {
+		tok := p.peek()
+		switch {
+		case tok.kind == tokenSymbol && tok.text == "}":
+			svc.Comment = joinComments(svc.Comment, p.trailing(p.next()))
+			return svc, nil
+		case tok.kind == tokenSymbol && tok.text == ";":
+			p.next()
+		case tok.kind == tokenEOF:
+			return svc, p.unexpected(tok, "\"}\"")
+		case tok.kind == tokenIdent && tok.text == "rpc":
+			m, err := p.parseMethod()
+			if err != nil {
+				return svc, err
+			}
+			svc.Order = p.declare(svc.Order, tok, m.Comment, Element{Kind: ElementMethod, Index: len(svc.Methods)})
+			if len(m.Options) == 0 && p.tokens[p.pos-1].text == "}" {
+				svc.Order = append(svc.Order, Element{Kind: ElementBraces})
+			}
+			svc.Methods = append(svc.Methods, m)
+		case tok.kind == tokenIdent && tok.text == "option":
+			o, err := p.parseOptionStatement()
+			if err != nil {
+				return svc, err
+			}
+			svc.Order = p.declare(svc.Order, tok, o.Comment, Element{Kind: ElementOption, Index: len(svc.Options)})
+			svc.Options = append(svc.Options, o)
+		default:
+			return svc, p.unexpected(tok, "\"rpc\"")
+		}
+	}
+}
+
+func (p *parser) parseMethod() (Method, error) {
+	keyword := p.next()
+	name, err := p.expectIdent()
+	if err != nil {
+		return Method{}, err
+	}
+	m := Method{Name: NameType(name.text)}
+	var clientStreaming, serverStreaming bool
+	if m.RequestType, clientStreaming, err = p.parseMethodType(); err != nil {
+		return m, err
+	}
+	if _, err := p.expectKeyword("returns"); err != nil {
+		return m, err
+	}
+	if m.ResponseType, serverStreaming, err = p.parseMethodType(); err != nil {
+		return m, err
+	}
+	switch {
+	case clientStreaming && serverStreaming:
+		m.Streaming = BidiStreaming
+	case clientStreaming:
+		m.Streaming = ClientStreaming
+	case serverStreaming:
+		m.Streaming = ServerStreaming
+	}
+
+	var trailing string
+	if p.isSymbol("{") {
+		trailing = p.trailing(p.next())
+		for !p.isSymbol("}") {
+			tok := p.peek()
+			if tok.kind == tokenSymbol && tok.text == ";" {
+				p.next()
+				continue
+			}
+			if tok.kind == tokenIdent && tok.text == "option" {
+				o, err := p.parseOptionStatement()
+				if err != nil {
+					return m, err
+				}
+				m.Options = append(m.Options, o)
+				continue
+			}
+			return m, p.unexpected(tok, "\"}\"")
+		}
+		trailing = joinComments(trailing, p.trailing(p.next()))
+	} else if trailing, err = p.parseEnd(); err != nil {
+		return m, err
+	}
+	m.Comment = joinComments(p.leading(keyword), trailing)
+	return m, nil
+}
+
+// parseMethodType reads a parenthesised RPC request or response type and whether it is streamed.
+func (p *parser) parseMethodType() (string, bool, error) {
+	if _, err := p.expectSymbol("("); err != nil {
+		return "", false, err
+	}
+	streaming := false
+	if p.isKeyword("stream") {
+		next := p.tokens[p.pos+1]
+		if next.kind == tokenIdent || (next.kind == tokenSymbol && next.text == ".") {
+			p.next()
+			streaming = true
+		}
+	}
+	typing, err := p.parseFullIdent()
+	if err != nil {
+		return "", false, err
+	}
+	if _, err := p.expectSymbol(")"); err != nil {
+		return "", false, err
+	}
+	return typing, streaming, nil
+}
+
+// parseOptionStatement reads an option statement of the form: option name = value; along with its
+// comment, which joins any leading comment with the trailing one.
+func (p *parser) parseOptionStatement() (Option, error) {
+	keyword := p.next()
+	o, err := p.parseOption()
+	if err != nil {
+		return o, err
+	}
+	trailing, err := p.parseEnd()
+	o.Comment = joinComments(p.leading(keyword), trailing)
+	return o, err
+}
+
+// parseCompactOptions reads the optional bracketed list of options that follows a field or enum value.
+func (p *parser) parseCompactOptions() ([]Option, error) {
+	if !p.isSymbol("[") {
+		return nil, nil
+	}
+	p.next()
+	var options []Option
+	for {
+		o, err := p.parseOption()
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, o)
+		if !p.isSymbol(",") {
+			break
+		}
+		p.next()
+	}
+	if _, err := p.expectSymbol("]"); err != nil {
+		return nil, err
+	}
+	return options, nil
+}
+
+// parseOption reads an option assignment of the form: name = value
+func (p *parser) parseOption() (Option, error) {
+	name, err := p.parseOptionName()
+	if err != nil {
+		return Option{}, err
+	}
+	if _, err := p.expectSymbol("="); err != nil {
+		return Option{}, err
+	}
+	value, err := p.parseOptionValue()
+	if err != nil {
+		return Option{}, err
+	}
+	return Option{Name: name, Value: value}, nil
+}
+
+// parseOptionName reads a plain option name, or a custom option name of the form (full.ident) optionally
+// followed by .sub.field selectors.
+func (p *parser) parseOptionName() (string, error) {
+	if !p.isSymbol("(") {
+		return p.parseFullIdent()
+	}
+	p.next()
+	ref, err := p.parseFullIdent()
+	if err != nil {
+		return "", err
+	}
+	if _, err := p.expectSymbol(")"); err != nil {
+		return "", err
+	}
+	name := "(" + ref + ")"
+	for p.isSymbol(".") {
+		p.next()
+		tok, err := p.expectIdent()
+		if err != nil {
+			return "", err
+		}
+		name += "." + tok.text
+	}
+	return name, nil
+}
+
+// parseOptionValue reads a constant: a string, number, boolean, identifier or text-format aggregate.
+func (p *parser) parseOptionValue() (OptionValue, error) {
+	tok := p.peek()
+	switch {
+	case tok.kind == tokenString:
+		v, err := p.expectString()
+		return StringValue(v), err
+	case tok.kind == tokenSymbol && tok.text == "{":
+		return p.parseAggregate()
+	case tok.kind == tokenSymbol && (tok.text == "-" || tok.text == "+"):
+		p.next()
+		number := p.peek()
+		v, err := p.parseNumber()
+		if err != nil || tok.text == "+" {
+			return v, err
+		}
+		switch v := v.(type) {
+		case IntValue:
+			return -v, nil
+		case UintValue:
+			if v != 1<<63 {
+				return nil, p.errorf(number, "integer -%s is out of range", number.text)
+			}
+			return IntValue(math.MinInt64), nil
+		case FloatValue:
+			return -v, nil
+		}
+		return v, nil
+	case tok.kind == tokenInt || tok.kind == tokenFloat:
+		return p.parseNumber()
+	case tok.kind == tokenIdent && (tok.text == "true" || tok.text == "false"):
+		p.next()
+		return BoolValue(tok.text == "true"), nil
+	case tok.kind == tokenIdent && (tok.text == "inf" || tok.text == "nan"):
+		return p.parseNumber()
+	case tok.kind == tokenIdent || (tok.kind == tokenSymbol && tok.text == "."):
+		v, err := p.parseFullIdent()
+		return IdentValue(v), err
+	default:
+		return nil, p.unexpected(tok, "option value")
+	}
+}
+
+// parseNumber reads an unsigned integer or floating-point literal, including inf and nan. Integers too
+// large for an IntValue, which uint64 and fixed64 options can hold, are read as a UintValue.
+func (p *parser) parseNumber() (OptionValue, error) {
+	tok := p.next()
+	switch {
+	case tok.kind == tokenInt:
+		v, err := parseIntLiteral(tok.text)
+		if err != nil {
+			return nil, p.errorf(tok, "invalid integer %q", tok.text)
+		}
+		return intValue(v), nil
+	case tok.kind == tokenFloat:
+		v, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil || strings.ContainsRune(tok.text, '_') {
+			return nil, p.errorf(tok, "invalid number %q", tok.text)
+		}
+		return FloatValue(v), nil
+	case tok.kind == tokenIdent && tok.text == "inf":
+		return FloatValue(math.Inf(1)), nil
+	case tok.kind == tokenIdent && tok.text == "nan":
+		return FloatValue(math.NaN()), nil
+	default:
+		return nil, p.unexpected(tok, "number")
+	}
+}
+
+// parseAggregate reads a text-format message value. List values are expanded into repeated entries.
+func (p *parser) parseAggregate() (OptionValue, error) {
+	p.next()
+	var value AggregateValue
+	for !p.isSymbol("}") {
+		var name string
+		if p.isSymbol("[") {
+			p.next()
+			ext, err := p.parseFullIdent()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.expectSymbol("]"); err != nil {
+				return nil, err
+			}
+			name = "[" + ext + "]"
+		} else {
+			tok, err := p.expectIdent()
+			if err != nil {
+				return nil, err
+			}
+			name = tok.text
+		}
+		if p.isSymbol(":") {
+			p.next()
+		} else if !p.isSymbol("{") {
+			return nil, p.unexpected(p.peek(), "\":\"")
+		}
+		if p.isSymbol("[") {
+			p.next()
+			for !p.isSymbol("]") {
+				v, err := p.parseOptionValue()
+				if err != nil {
+					return nil, err
+				}
+				value = append(value, Option{Name: name, Value: v})
+				if !p.isSymbol(",") {
+					break
+				}
+				p.next()
+			}
+			if _, err := p.expectSymbol("]"); err != nil {
+				return nil, err
+			}
+		} else {
+			v, err := p.parseOptionValue()
+			if err != nil {
+				return nil, err
+			}
+			value = append(value, Option{Name: name, Value: v})
+		}
+		if p.isSymbol(",") || p.isSymbol(";") {
+			p.next()
+		}
+	}
+	p.next()
+	return value, nil
+}
+
+// parseFieldType looks up the built-in field type with the given protobuf name.
+func parseFieldType(name string) (FieldType, bool) {
+	for t := DoubleType; t <= BytesType; t++ {
+		if t.Write() == name {
+			return t, true
+		}
+	}
+	return 0, false
+}
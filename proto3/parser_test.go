@@ -0,0 +1,558 @@
+package proto3_test
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	. "github.com/muxinc/protogen/proto3"
+)
+
+const beaconSpec = `// DO NOT EDIT - File generated using protogen
+syntax = "proto3";
+package foo;
+option go_package = "example.com/foo";
+option java_package = "com.foo";
+
+// Beacon Message containing event information
+message Beacon {
+  message Event {
+    reserved 1;
+    reserved 2;
+    reserved 3;
+    reserved 6 to 9;
+
+    repeated string Habitat = 10;   // What am I?
+    string Continent = 11;   // Where am I?
+    map<string, string> LanguageMap = 12;   // Super essential
+
+  }
+
+  // Country code
+  enum Country {
+    US = 0;
+    CA = 1;   // Canada
+    GB = 2;   // Great Britain
+    MX = 3;   // Mexico
+  }
+
+  enum PlaybackState {
+    option allow_alias = true;
+    Waiting = 0;
+    Playing = 1;
+    Started = 1;
+    Stopped = 2;
+  }
+
+  reserved 1;
+  reserved 2;
+  reserved 3;
+  reserved 6 to 9;
+
+  string Habitat = 20;   // What am I?
+  repeated string Continent = 21;   // Where am I?
+  map<string, string> LanguageMap = 22;   // Super essential
+  map<string, Event> CustomMap = 23;
+
+  // Can have a name or sub-message, but not both
+  oneof test_oneof {
+    string name = 24;   // Name
+    Event sub_message = 25;   // Sub-Message
+  }
+}
+`
+
+func TestParse_RoundTrip(t *testing.T) {
+	spec, err := Parse(strings.NewReader(beaconSpec))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	got, err := spec.Write()
+	if err != nil {
+		t.Fatalf("Spec.Write() error = %v", err)
+	}
+	if got != beaconSpec {
+		t.Errorf("Spec.Write() of parsed spec differs from source:\n%s", got)
+	}
+}
+
+func TestParse(t *testing.T) {
+	src := `
+		// Header comment
+		syntax = "proto3";
+		package foo.bar;
+		import "other.proto";
+
+		/* Documents
+		 * the message */
+		message Thing {
+			// Leading comment
+			.foo.bar.Other other = 1;
+			map<int32, Other> others = 2 ;
+			reserved "old_name", 'older' "_name";
+			reserved 3, 0x10 to 020;
+		}
+	`
+	spec, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if spec.FileComment != "Header comment" || spec.Package != "foo.bar" || len(spec.Imports) != 1 || spec.Imports[0] != "other.proto" {
+		t.Errorf("Parse() returned unexpected file-level attributes: %+v", spec)
+	}
+	if len(spec.Messages) != 1 {
+		t.Fatalf("Parse() returned %d messages, want 1", len(spec.Messages))
+	}
+	msg := spec.Messages[0]
+	if msg.Name != "Thing" || msg.Comment != "Documents\nthe message" {
+		t.Errorf("Parse() returned message %q with comment %q", msg.Name, msg.Comment)
+	}
+	wantFields := []Field{
+		CustomField{Name: "other", Tag: 1, Typing: ".foo.bar.Other", Comment: "Leading comment"},
+		CustomMapField{Name: "others", Tag: 2, KeyTyping: Int32Type, ValueTyping: "Other"},
+	}
+	if len(msg.Fields) != len(wantFields) {
+		t.Fatalf("Parse() returned %d fields, want %d", len(msg.Fields), len(wantFields))
+	}
+	for i, want := range wantFields {
+		if !reflect.DeepEqual(msg.Fields[i], want) {
+			t.Errorf("Parse() field %d = %+v, want %+v", i, msg.Fields[i], want)
+		}
+	}
+	wantReserved := []Reserved{
+		ReservedName{Name: "old_name"},
+		ReservedName{Name: "older_name"},
+		ReservedTagValue{Tag: 3},
+		ReservedTagRange{LowerTag: 16, UpperTag: 16},
+	}
+	if len(msg.ReservedValues) != len(wantReserved) {
+		t.Fatalf("Parse() returned %d reserved values, want %d", len(msg.ReservedValues), len(wantReserved))
+	}
+	for i, want := range wantReserved {
+		if msg.ReservedValues[i] != want {
+			t.Errorf("Parse() reserved value %d = %+v, want %+v", i, msg.ReservedValues[i], want)
+		}
+	}
+}
+
+func TestParse_Comments(t *testing.T) {
+	src := `// c01
+syntax = "proto3"; // c02
+package foo;
+import "google/protobuf/descriptor.proto";
+option go_package = "example.com/foo";
+
+// c03
+
+// c04
+option java_multiple_files = true; // c05
+
+// c06
+message Thing { // c07
+  // c08
+  option deprecated = true; // c09
+
+  /* c10 */
+  reserved 1, 2 to 5; // c11
+  reserved "old"; // c12
+  string name = 6; // c13
+  // c14
+  map<string, int32> counts = 7;
+  // c15
+  oneof choice { // c16
+    option (owner) = "me"; // c17
+    string a = 8; // c18
+  } // c19
+  // c20
+  enum Kind {
+    // c21
+    reserved -1; // c22
+    UNKNOWN = 0; // c23
+    // c24
+    KNOWN = 1;
+  } // c25
+} // c26
+
+// c27
+extend google.protobuf.OneofOptions {
+  string owner = 50000; // c28
+}
+
+// c29
+service Tracker { // c30
+  option deprecated = true; // c31
+  // c32
+  rpc Track(Thing) returns (Thing); // c33
+  // c34
+  rpc Watch(Thing) returns (stream Thing) { // c35
+    option deprecated = true; // c36
+  } // c37
+}
+`
+	spec, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	got, err := spec.Write()
+	if err != nil {
+		t.Fatalf("Spec.Write() error = %v", err)
+	}
+	for i := 1; i <= 37; i++ {
+		if c := fmt.Sprintf("c%02d", i); !strings.Contains(got, c) {
+			t.Errorf("Spec.Write() of parsed spec lost comment %s:\n%s", c, got)
+		}
+	}
+	reparsed, err := Parse(strings.NewReader(got))
+	if err != nil {
+		t.Fatalf("Parse() of written spec error = %v", err)
+	}
+	if again, _ := reparsed.Write(); again != got {
+		t.Errorf("Spec.Write() of reparsed spec differs:\n%s", again)
+	}
+}
+
+func TestParse_CommentLines(t *testing.T) {
+	src := `syntax = "proto3";
+
+/*
+ * Documents
+ *
+ * the message
+ */
+message Thing {
+  string a = 1; /* leads b */ string b = 2;
+  // leads c
+  // over two lines
+  string c = 3; // trails c
+  string d = 4; /* trails d
+                   over two lines */
+  string e = 5;
+}
+`
+	spec, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if c := spec.Messages[0].Comment; c != "Documents\n\nthe message" {
+		t.Errorf("Parse() message comment = %q", c)
+	}
+	want := []string{"", "leads b", "leads c\nover two lines\ntrails c", "trails d\nover two lines", ""}
+	for i, f := range spec.Messages[0].Fields {
+		if c := f.(ScalarField).Comment; c != want[i] {
+			t.Errorf("Parse() field %d comment = %q, want %q", i, c, want[i])
+		}
+	}
+	got, err := spec.Write()
+	if err != nil {
+		t.Fatalf("Spec.Write() error = %v", err)
+	}
+	reparsed, err := Parse(strings.NewReader(got))
+	if err != nil {
+		t.Fatalf("Parse() of written spec error = %v", err)
+	}
+	// Writing moves comments between the lines before and after their fields, which the declaration order
+	// marks, so only the elements it lists are compared.
+	for _, s := range []*Spec{spec, reparsed} {
+		s.Messages[0].Order = withoutMarkers(s.Messages[0].Order)
+	}
+	if !reflect.DeepEqual(reparsed, spec) {
+		t.Errorf("Parse() of written spec = %+v, want %+v", reparsed, spec)
+	}
+}
+
+// withoutMarkers returns the entries of a declaration order that refer to elements.
+func withoutMarkers(order []Element) []Element {
+	var elements []Element
+	for _, e := range order {
+		switch e.Kind {
+		case ElementBlank, ElementComma, ElementTrailing, ElementBraces:
+		default:
+			elements = append(elements, e)
+		}
+	}
+	return elements
+}
+
+func TestParse_Layout(t *testing.T) {
+	src := `syntax = "proto3";
+package mux;
+
+message Beacon {
+  reserved 2, 4 to 6;
+
+  string view_id = 1; // Unique to the view
+}
+
+service Collector {
+  rpc Send(Beacon) returns (Beacon) {}
+}
+`
+	spec, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	tests := []struct {
+		name  string
+		order []Element
+		want  []Element
+	}{
+		{"Spec", spec.Order, []Element{{Kind: ElementSyntax}, {Kind: ElementPackage}, {Kind: ElementBlank}, {Kind: ElementMessage}, {Kind: ElementBlank}, {Kind: ElementService}}},
+		{"Message", spec.Messages[0].Order, []Element{{Kind: ElementReserved}, {Kind: ElementComma}, {Kind: ElementReserved, Index: 1}, {Kind: ElementBlank}, {Kind: ElementField}, {Kind: ElementTrailing}}},
+		{"Service", spec.Services[0].Order, []Element{{Kind: ElementMethod}, {Kind: ElementBraces}}},
+	}
+	for _, tt := range tests {
+		if !reflect.DeepEqual(tt.order, tt.want) {
+			t.Errorf("%q. Parse() order = %+v, want %+v", tt.name, tt.order, tt.want)
+		}
+	}
+}
+
+func TestParse_HeaderComments(t *testing.T) {
+	src := `// Beacons
+syntax = "proto3";
+
+// The package
+package mux; // of beacons
+import "other.proto"; // for Other
+option go_package = "example.com/mux"; // Go
+option java_package = "com.mux"; // Java
+
+enum Kind {
+  option allow_alias = true; // VIEW and SEEN are the same
+  UNKNOWN = 0;
+  VIEW = 1;
+  SEEN = 1;
+}
+`
+	spec, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if want := "Beacons\nThe package\nof beacons\nfor Other\nGo\nJava"; spec.FileComment != want {
+		t.Errorf("Parse() file comment = %q, want %q", spec.FileComment, want)
+	}
+	if want := "VIEW and SEEN are the same"; spec.Enums[0].Comment != want {
+		t.Errorf("Parse() enum comment = %q, want %q", spec.Enums[0].Comment, want)
+	}
+}
+
+func TestParse_Integers(t *testing.T) {
+	src := `syntax = "proto3";
+
+message Thing {
+  option (big) = 18446744073709551615;
+  option (small) = -9223372036854775808;
+  option (octal) = 017;
+  string a = 0x1F;
+}
+`
+	spec, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	want := []Option{
+		{Name: "(big)", Value: UintValue(math.MaxUint64)},
+		{Name: "(small)", Value: IntValue(math.MinInt64)},
+		{Name: "(octal)", Value: IntValue(15)},
+	}
+	if got := spec.Messages[0].Options; !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() options = %+v, want %+v", got, want)
+	}
+	if f := spec.Messages[0].Fields[0].(ScalarField); f.Tag != 31 {
+		t.Errorf("Parse() field tag = %d, want 31", f.Tag)
+	}
+}
+
+func TestParse_TagRanges(t *testing.T) {
+	src := `syntax = "proto3";
+
+message Beacon {
+  reserved 1000 to max;
+
+  string view_id = 1;
+}
+
+message Other {
+  string view_id = 536870911;
+}
+
+enum Direction {
+  NONE = 0;
+  BACKWARD = -1;
+  reserved -10 to -2, 2 to max;
+  reserved "UP", 'DOWN';
+  FORWARD = 1;
+}
+`
+	spec, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if r := spec.Messages[0].ReservedValues[0]; r != (ReservedTagRange{LowerTag: 1000, UpperTag: MaxTag}) {
+		t.Errorf("Parse() reserved range = %+v", r)
+	}
+	if v := spec.Enums[0].Values[1]; v.Tag != -1 {
+		t.Errorf("Parse() enum value %s = %d, want -1", v.Name, v.Tag)
+	}
+	wantReserved := []Reserved{
+		ReservedTagRange{LowerTag: -10, UpperTag: -2},
+		ReservedTagRange{LowerTag: 2, UpperTag: MaxTag},
+		ReservedName{Name: "UP"},
+		ReservedName{Name: "DOWN"},
+	}
+	if got := spec.Enums[0].ReservedValues; !reflect.DeepEqual(got, wantReserved) {
+		t.Errorf("Parse() enum reserved values = %+v, want %+v", got, wantReserved)
+	}
+	got, err := spec.Write()
+	if err != nil {
+		t.Fatalf("Spec.Write() error = %v", err)
+	}
+	want := `syntax = "proto3";
+
+enum Direction {
+  reserved -10 to -2;
+  reserved 2 to max;
+  reserved "UP";
+  reserved "DOWN";
+  NONE = 0;
+  BACKWARD = -1;
+  FORWARD = 1;
+}
+
+message Beacon {
+  reserved 1000 to max;
+
+  string view_id = 1;
+
+}
+
+message Other {
+  string view_id = 536870911;
+
+}
+`
+	if got != want {
+		t.Errorf("Spec.Write() of parsed spec = \n%s", got)
+	}
+}
+
+func TestParse_Optional(t *testing.T) {
+	src := `syntax = "proto3";
+
+message Beacon {
+  optional int32 rebuffer_count = 1;   // Unset when the player does not report rebuffering
+  optional Event last_event = 2;
+
+}
+`
+	spec, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if f, ok := spec.Messages[0].Fields[0].(ScalarField); !ok || f.Rule != Optional {
+		t.Errorf("Parse() field = %+v, want optional scalar field", spec.Messages[0].Fields[0])
+	}
+	got, err := spec.Write()
+	if err != nil {
+		t.Fatalf("Spec.Write() error = %v", err)
+	}
+	if got != src {
+		t.Errorf("Spec.Write() of parsed spec differs from source:\n%s", got)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "Missing syntax",
+			src:  `package foo;`,
+			want: "1:1: missing syntax statement",
+		},
+		{
+			name: "Proto2 syntax",
+			src:  `syntax = "proto2";`,
+			want: "1:10: unsupported syntax",
+		},
+		{
+			name: "Missing semicolon",
+			src:  "syntax = \"proto3\";\nmessage Foo {\n  string a = 1\n}",
+			want: "4:1: unexpected \"}\"",
+		},
+		{
+			name: "Unterminated message",
+			src:  "syntax = \"proto3\";\nmessage Foo {",
+			want: "unexpected end of file",
+		},
+		{
+			name: "Repeated oneof field",
+			src:  "syntax = \"proto3\";\nmessage Foo {\n  oneof bar {\n    repeated string a = 1;\n  }\n}",
+			want: "4:5: oneof fields cannot be repeated",
+		},
+		{
+			name: "Optional oneof field",
+			src:  "syntax = \"proto3\";\nmessage Foo {\n  oneof bar {\n    optional string a = 1;\n  }\n}",
+			want: "4:5: oneof fields cannot be optional",
+		},
+		{
+			name: "Non-scalar map key",
+			src:  "syntax = \"proto3\";\nmessage Foo {\n  map<Bar, string> a = 1;\n}",
+			want: "3:7: map key type \"Bar\" must be a scalar type",
+		},
+		{
+			name: "Comment before closing brace",
+			src:  "syntax = \"proto3\";\nmessage Foo {\n  string a = 1;\n  // b\n}",
+			want: "4:3: comment cannot be kept here",
+		},
+		{
+			name: "Comment at end of file",
+			src:  "syntax = \"proto3\";\nmessage Foo {}\n\n/* end */\n",
+			want: "4:1: comment cannot be kept here",
+		},
+		{
+			name: "Comment within field",
+			src:  "syntax = \"proto3\";\nmessage Foo {\n  string a /* b */ = 1;\n}",
+			want: "3:12: comment cannot be kept here",
+		},
+		{
+			name: "Underscore in tag",
+			src:  "syntax = \"proto3\";\nmessage Foo {\n  string a = 1_0;\n}",
+			want: "3:14: invalid integer \"1_0\"",
+		},
+		{
+			name: "Binary tag",
+			src:  "syntax = \"proto3\";\nmessage Foo {\n  string a = 0b11;\n}",
+			want: "3:14: invalid integer \"0b11\"",
+		},
+		{
+			name: "Octal tag with a decimal digit",
+			src:  "syntax = \"proto3\";\nmessage Foo {\n  string a = 018;\n}",
+			want: "3:14: invalid integer \"018\"",
+		},
+		{
+			name: "Negative option out of range",
+			src:  "syntax = \"proto3\";\noption (big) = -9223372036854775809;\n",
+			want: "2:17: integer -9223372036854775809 is out of range",
+		},
+		{
+			name: "Unterminated string",
+			src:  "syntax = \"proto3;\n",
+			want: "1:10: unterminated string literal",
+		},
+	}
+	for _, tt := range tests {
+		_, err := Parse(strings.NewReader(tt.src))
+		if err == nil {
+			t.Errorf("%q. Parse() expected error containing %q", tt.name, tt.want)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%q. Parse() error = %v, want error containing %q", tt.name, err, tt.want)
+		}
+	}
+}
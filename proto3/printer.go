@@ -0,0 +1,1134 @@
+package proto3
+
+import (
+	"bufio"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// FormatOptions controls the layout of a written specification. Layouts are usually made by adjusting
+// DefaultFormat or BufFormat, since every option is used as given.
+type FormatOptions struct {
+	// Indent is written once for each level of nesting, for example four spaces or a tab.
+	Indent string
+	// CommentGap is the number of spaces between a declaration and its trailing comment.
+	CommentGap int
+	// AlignValues aligns the = of the fields or enum values of a body into a column.
+	AlignValues bool
+	// AlignComments aligns the trailing comments of the declarations of a body into a column.
+	AlignComments bool
+	// MaxLineWidth wraps comments at word boundaries so that lines fit within it, and writes trailing
+	// comments that do not fit on the lines before their declaration. Tabs count as eight columns, and zero
+	// leaves comments as they are.
+	MaxLineWidth int
+	// Spacing places the blank lines between the sections of a body.
+	Spacing Spacing
+	// Order arranges the top-level definitions and the elements of each body.
+	Order Ordering
+	// GroupHeader separates the syntax, package, imports and file options with blank lines, writing the
+	// imports in order of path before the file options in order of name, with custom options last.
+	GroupHeader bool
+	// ExpandOptions writes aggregate option values, the options of a method, and the options of a field or
+	// enum value when there is more than one, over several lines. Aggregates holding a single entry that is
+	// not itself an aggregate are kept on one line.
+	ExpandOptions bool
+	// CollapseEmpty writes the braces of an empty body on the same line, as {}.
+	CollapseEmpty bool
+	// DeclaredComments writes each comment on the lines before its element, or after it on its line when
+	// elements are written as declared and the Order of their body marks the comment as trailing. The comment
+	// of a definition then follows the opening brace of its body. Otherwise the single-line comments of
+	// fields, enum values, options, reserved values and methods are written after them.
+	DeclaredComments bool
+}
+
+// Spacing places blank lines within the body of a message, enum, oneof or service. A body is made of
+// sections: each nested message, enum, extend or oneof, and each run of consecutive options, reserved
+// values, fields, enum values or methods. Top-level definitions are separated by a blank line, except as
+// SpacingDeclared places them.
+type Spacing uint8
+
+// Spacings
+const (
+	SpacingAfterSections   Spacing = iota // blank line after each section of a message but its oneofs, and the last in declaration order
+	SpacingBetweenSections                // blank line between sections, and none after the last
+	SpacingNone                           // no blank lines
+	SpacingDeclared                       // blank lines where the Order lists them, when elements are written as declared
+)
+
+// Ordering arranges the top-level definitions of a file and the elements of each body. Grouped, enums come
+// before messages, extends and services at the top level, and options come before the other elements of a
+// body. Unless they are ordered as declared, the values of an enum are written by tag with the zero value
+// first, since proto3 uses the first value as the default.
+type Ordering uint8
+
+// Orderings
+const (
+	OrderGrouped  Ordering = iota // options, nested messages, enums and extends, reserved values, fields, oneofs, and enum values by tag
+	OrderDeclared                 // as listed by the Order of the spec or body, with elements it leaves out grouped after it
+	OrderByTag                    // grouped, but with the fields and oneofs of a message interleaved in order of tag
+)
+
+// DefaultFormat is the layout written by Spec.Write and Spec.WriteTo.
+var DefaultFormat = FormatOptions{Indent: "  ", CommentGap: 3}
+
+// BufFormat is the layout of buf format. A parsed file is written as buf format writes it, keeping the
+// declaration order, blank lines, reserved statements and comment placement its Orders record, except
+// where a Spec does not keep the source: comments are written as // lines with a single space after the
+// slashes, without the blank lines between a comment and its element, and a comment after a closing brace
+// or on the package, imports or file options moves as Parse describes. Strings are written with double
+// quotes, integers in decimal, and the entries of aggregate values with colons and no separators.
+var BufFormat = FormatOptions{
+	Indent:           "  ",
+	CommentGap:       1,
+	Spacing:          SpacingDeclared,
+	Order:            OrderDeclared,
+	GroupHeader:      true,
+	ExpandOptions:    true,
+	CollapseEmpty:    true,
+	DeclaredComments: true,
+}
+
+// tabWidth is the number of columns a tab counts as when measuring lines.
+const tabWidth = 8
+
+// printer streams the text of a specification to a writer. The first error, whether from the writer or
+// from an element that cannot be written, stops any further output and is kept to be reported at the end,
+// so that elements can be printed without checking errors after every write.
+type printer struct {
+	w      io.Writer
+	format FormatOptions
+	n      int64
+	err    error
+}
+
+// printString prints elements into a string in the default format, which is empty when printing fails.
+func printString(print func(p *printer)) (string, error) {
+	var buffer strings.Builder
+	p := &printer{w: &buffer, format: DefaultFormat}
+	print(p)
+	if p.err != nil {
+		return "", p.err
+	}
+	return buffer.String(), nil
+}
+
+// WriteTo streams the specification to w in the default format, validating it first. It returns the
+// number of bytes written.
+func (s *Spec) WriteTo(w io.Writer) (int64, error) {
+	return s.WriteFormatted(w, DefaultFormat)
+}
+
+// WriteFormatted streams the specification to w laid out as format describes, validating it first. It
+// returns the number of bytes written.
+func (s *Spec) WriteFormatted(w io.Writer, format FormatOptions) (int64, error) {
+	if err := s.Validate(); err != nil {
+		return 0, err
+	}
+	buffered := bufio.NewWriter(w)
+	p := &printer{w: buffered, format: format}
+	p.spec(s.withTypeNames())
+	if p.err == nil {
+		p.err = buffered.Flush()
+	}
+	return p.n, p.err
+}
+
+// fail records an error, unless one was already recorded.
+func (p *printer) fail(err error) {
+	if p.err == nil {
+		p.err = err
+	}
+}
+
+// print writes each string in turn.
+func (p *printer) print(values ...string) {
+	for _, v := range values {
+		if p.err != nil {
+			return
+		}
+		n, err := io.WriteString(p.w, v)
+		p.n += int64(n)
+		p.err = err
+	}
+}
+
+// sprint returns what print writes in the same format as a string, recording any error it fails with.
+func (p *printer) sprint(print func(q *printer)) string {
+	var buffer strings.Builder
+	q := &printer{w: &buffer, format: p.format}
+	print(q)
+	if q.err != nil {
+		p.fail(q.err)
+	}
+	return buffer.String()
+}
+
+// indent writes the indentation for a nesting level.
+func (p *printer) indent(level int) {
+	for i := 0; i < level; i++ {
+		p.print(p.format.Indent)
+	}
+}
+
+// columns returns the width of text on a line, counting each tab as tabWidth columns.
+func columns(s string) int {
+	return utf8.RuneCountInString(s) + strings.Count(s, "\t")*(tabWidth-1)
+}
+
+// lastLine returns the text after the last newline of s.
+func lastLine(s string) string {
+	return s[strings.LastIndex(s, "\n")+1:]
+}
+
+// comment writes a comment on lines of its own at a nesting level, or nothing for an empty comment.
+func (p *printer) comment(level int, comment string) {
+	if comment == "" {
+		return
+	}
+	for _, line := range p.wrap(level, comment) {
+		p.indent(level)
+		if line == "" {
+			p.print("//\n")
+		} else {
+			p.print("// ", line, "\n")
+		}
+	}
+}
+
+// wrap splits a comment written at a nesting level into its lines, and those into lines that fit the
+// maximum line width. Words longer than the width are kept whole.
+func (p *printer) wrap(level int, comment string) []string {
+	if p.format.MaxLineWidth <= 0 {
+		return strings.Split(comment, "\n")
+	}
+	width := p.format.MaxLineWidth - level*columns(p.format.Indent) - len("// ")
+	var lines []string
+	for _, paragraph := range strings.Split(comment, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			if line != "" && columns(line)+1+columns(word) > width {
+				lines = append(lines, line)
+				line = ""
+			}
+			if line != "" {
+				line += " "
+			}
+			line += word
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// multiline reports whether a comment spans several lines, which cannot follow an element on its line.
+func multiline(comment string) bool {
+	return strings.Contains(comment, "\n")
+}
+
+// leads reports whether the comment of a statement is written on the lines before it rather than after it on
+// its line, given whether the Order of its body marks the comment as trailing. Comments that span several
+// lines always lead.
+func (p *printer) leads(comment string, trailing bool) bool {
+	return multiline(comment) || p.format.DeclaredComments && !trailing
+}
+
+// leadingComment writes the comment of a statement at a nesting level on the lines before it when it leads
+// the statement, which trailingComment leaves out.
+func (p *printer) leadingComment(level int, comment string, trailing bool) {
+	if p.leads(comment, trailing) {
+		p.comment(level, comment)
+	}
+}
+
+// trailingComment writes the comment of a statement following it on the same line, or nothing for an empty
+// comment or one that leads the statement.
+func (p *printer) trailingComment(comment string, trailing bool) {
+	if comment != "" && !p.leads(comment, trailing) {
+		p.print(strings.Repeat(" ", p.format.CommentGap), "// ", comment)
+	}
+}
+
+// definitionComment writes the comment of a definition at a nesting level on the lines before it, unless the
+// format places comments as declared and the Order of its body marks the comment as trailing. That comment
+// is returned instead, to follow the opening brace.
+func (p *printer) definitionComment(level int, comment string, trailing bool) string {
+	if p.follows(comment, trailing) {
+		return comment
+	}
+	p.comment(level, comment)
+	return ""
+}
+
+// follows reports whether the comment of a definition follows the opening brace of its body, which only
+// single-line comments the Order marks as trailing do, when the format places comments as declared.
+func (p *printer) follows(comment string, trailing bool) bool {
+	return p.format.DeclaredComments && trailing && comment != "" && !multiline(comment)
+}
+
+// open writes the opening brace of a body followed by a comment, or both braces of an empty body when the
+// format collapses them. It reports whether the contents and closing brace of the body remain to be
+// written.
+func (p *printer) open(empty bool, comment string) bool {
+	if empty && p.format.CollapseEmpty {
+		p.print(" {}")
+		p.trailingComment(comment, true)
+		return false
+	}
+	p.print(" {")
+	p.trailingComment(comment, true)
+	p.print("\n")
+	return true
+}
+
+// section writes a section of a body, separated from the sections before it as the format's spacing
+// requires. started records whether a section was written before, and trailing whether the section is
+// followed by a blank line when spacing after sections.
+func (p *printer) section(started *bool, trailing bool, write func()) {
+	if *started && p.format.Spacing == SpacingBetweenSections {
+		p.print("\n")
+	}
+	*started = true
+	write()
+	if trailing && p.format.Spacing == SpacingAfterSections {
+		p.print("\n")
+	}
+}
+
+func (p *printer) spec(s *Spec) {
+	p.comment(0, s.FileComment)
+	if blank, _ := p.headerBlank(s, ElementSyntax); blank && s.FileComment != "" {
+		p.print("\n")
+	}
+	p.print("syntax = \"proto3\";\n")
+	options := s.Options
+	if s.JavaPackage != "" {
+		options = append([]Option{{Name: "java_package", Value: StringValue(s.JavaPackage)}}, options...)
+	}
+	if s.GoPackage != "" {
+		options = append([]Option{{Name: "go_package", Value: StringValue(s.GoPackage)}}, options...)
+	}
+	if p.format.GroupHeader {
+		blank, listed := p.headerBlank(s, ElementPackage)
+		p.groupedHeader(s.Package, blank || !listed, s.Imports, options)
+	} else {
+		if s.Package != "" {
+			p.print("package ", s.Package, ";\n")
+		}
+		p.optionStatements(0, options, nil)
+		for _, i := range s.Imports {
+			p.print("import ", quoteString(string(i)), ";\n")
+		}
+	}
+
+	p.definitions(s)
+}
+
+// headerBlank reports whether the Order of a spec lists a blank line before its syntax or package statement,
+// and whether it lists the statement at all. Neither is listed unless the format writes blank lines and
+// elements as declared.
+func (p *printer) headerBlank(s *Spec, kind ElementKind) (blank, listed bool) {
+	if p.format.Spacing != SpacingDeclared || p.format.Order != OrderDeclared {
+		return false, false
+	}
+	for i, e := range s.Order {
+		if e.Kind == kind {
+			return i > 0 && s.Order[i-1].Kind == ElementBlank, true
+		}
+	}
+	return false, false
+}
+
+// definitions writes the top-level definitions of a spec, each after a blank line. With declared spacing,
+// definitions listed by the Order of the spec are only preceded by a blank line where the Order lists one,
+// where their comment is written before them, or when the first follows the syntax directly.
+func (p *printer) definitions(s *Spec) {
+	first := s.Package == "" && len(s.Imports) == 0 && len(s.Options) == 0 && s.GoPackage == "" && s.JavaPackage == ""
+	elements := groupElements(topLevelKinds, s.elementCounts())
+	listed := make(map[Element]bool)
+	if p.format.Order == OrderDeclared {
+		elements = declaredElements(withoutHeader(s.Order), elements)
+		for _, e := range s.Order {
+			listed[e] = true
+		}
+	}
+	blank := false
+	for i, e := range elements {
+		trailing := i+1 < len(elements) && elements[i+1].Kind == ElementTrailing
+		var comment string
+		var write func()
+		switch e.Kind {
+		case ElementBlank:
+			blank = true
+			continue
+		case ElementEnum:
+			comment, write = s.Enums[e.Index].Comment, func() { p.enum(0, s.Enums[e.Index], trailing) }
+		case ElementMessage:
+			comment, write = s.Messages[e.Index].Comment, func() { p.message(0, &s.Messages[e.Index], trailing) }
+		case ElementExtend:
+			comment, write = s.Extends[e.Index].Comment, func() { p.extend(0, s.Extends[e.Index], trailing) }
+		case ElementService:
+			comment, write = s.Services[e.Index].Comment, func() { p.service(0, s.Services[e.Index], trailing) }
+		default:
+			continue
+		}
+		if p.format.Spacing != SpacingDeclared || blank || first || !listed[e] || comment != "" && !p.follows(comment, trailing) {
+			p.print("\n")
+		}
+		blank, first = false, false
+		write()
+		p.print("\n")
+	}
+}
+
+// groupedHeader writes the package, imports and file options as groups separated by blank lines, with the
+// imports in order of path and the options in order of name, custom options last. The blank line before the
+// package is left out unless blank is set.
+func (p *printer) groupedHeader(pkg string, blank bool, imports []ImportType, options []Option) {
+	if pkg != "" && blank {
+		p.print("\n")
+	}
+	if pkg != "" {
+		p.print("package ", pkg, ";\n")
+	}
+	if len(imports) > 0 {
+		sorted := append([]ImportType(nil), imports...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		p.print("\n")
+		for _, i := range sorted {
+			p.print("import ", quoteString(string(i)), ";\n")
+		}
+	}
+	if len(options) > 0 {
+		sorted := append([]Option(nil), options...)
+		sort.SliceStable(sorted, func(i, j int) bool {
+			customI, customJ := strings.HasPrefix(sorted[i].Name, "("), strings.HasPrefix(sorted[j].Name, "(")
+			if customI != customJ {
+				return customJ
+			}
+			return sorted[i].Name < sorted[j].Name
+		})
+		p.print("\n")
+		p.optionStatements(0, sorted, nil)
+	}
+}
+
+func (p *printer) message(level int, m *Message, trailing bool) {
+	comment := p.definitionComment(level, m.Comment, trailing)
+	p.indent(level)
+	p.print("message ", m.Name)
+	elements := groupElements(elementKinds, m.elementCounts())
+	if !p.open(len(elements) == 0, comment) {
+		return
+	}
+
+	switch p.format.Order {
+	case OrderDeclared:
+		elements = declaredElements(m.Order, elements)
+	case OrderByTag:
+		elements = sortElements(elements, func(e Element) (TagType, bool) {
+			switch e.Kind {
+			case ElementField:
+				_, tag, ok := fieldNameTag(m.Fields[e.Index])
+				return tag, ok
+			case ElementOneOf:
+				return oneofTag(m.OneOfs[e.Index])
+			}
+			return 0, false
+		})
+	}
+	// Oneofs are only followed by a blank line when an element other than a oneof follows them, and in
+	// declaration order the last section is not followed by one.
+	trailingBlank := func(kind, next ElementKind) bool {
+		if next == "" && p.format.Order == OrderDeclared {
+			return false
+		}
+		return kind != ElementOneOf || next != "" && next != ElementOneOf
+	}
+	p.body(elements, trailingBlank, func(r run) {
+		first := r.elements[0]
+		switch first.Kind {
+		case ElementOption:
+			options := make([]Option, len(r.elements))
+			for i, e := range r.elements {
+				options[i] = m.Options[e.Index]
+			}
+			p.optionStatements(level+1, options, r.trailing)
+		case ElementMessage:
+			p.message(level+1, &m.Messages[first.Index], r.trailing[0])
+			p.print("\n")
+		case ElementEnum:
+			p.enum(level+1, m.Enums[first.Index], r.trailing[0])
+			p.print("\n")
+		case ElementExtend:
+			p.extend(level+1, m.Extends[first.Index], r.trailing[0])
+			p.print("\n")
+		case ElementReserved:
+			reserved := make([]Reserved, len(r.elements))
+			for i, e := range r.elements {
+				reserved[i] = m.ReservedValues[e.Index]
+			}
+			p.reserved(level+1, reserved, r)
+		case ElementField:
+			fields := make([]Field, len(r.elements))
+			for i, e := range r.elements {
+				fields[i] = m.Fields[e.Index]
+			}
+			p.fields(level+1, fields, r.trailing)
+		case ElementOneOf:
+			p.oneof(level+1, m.OneOfs[first.Index], r.trailing[0])
+			p.print("\n")
+		}
+	})
+
+	p.indent(level)
+	p.print("}")
+}
+
+// enum writes an enum with its zero value first and the other values in order of tag. When the format
+// orders elements as declared, the values listed by the Order of the enum come first instead.
+func (p *printer) enum(level int, e Enum, trailing bool) {
+	comment := p.definitionComment(level, e.Comment, trailing)
+	p.indent(level)
+	p.print("enum ", string(e.Name))
+	elements := groupElements(elementKinds, e.elementCounts())
+	if !p.open(!e.AllowAlias && len(elements) == 0, comment) {
+		return
+	}
+
+	values := elements[len(elements)-len(e.Values):] // values are grouped last
+	sort.SliceStable(values, func(i, j int) bool { return e.Less(values[i].Index, values[j].Index) })
+	if p.format.Order == OrderDeclared {
+		elements = declaredElements(e.Order, elements)
+	}
+	if e.AllowAlias {
+		elements = append([]Element{{Kind: ElementOption, Index: allowAliasIndex}}, elements...)
+	}
+	p.body(elements, nil, func(r run) {
+		switch r.elements[0].Kind {
+		case ElementOption:
+			var options []Option
+			var marks []bool
+			for i, el := range r.elements {
+				if el.Index == allowAliasIndex {
+					p.indent(level + 1)
+					p.print("option allow_alias = true;\n")
+					continue
+				}
+				options = append(options, e.Options[el.Index])
+				marks = append(marks, r.trailing[i])
+			}
+			p.optionStatements(level+1, options, marks)
+		case ElementReserved:
+			reserved := make([]Reserved, len(r.elements))
+			for i, el := range r.elements {
+				reserved[i] = e.ReservedValues[el.Index]
+			}
+			p.reserved(level+1, reserved, r)
+		case ElementValue:
+			values := make([]declaration, len(r.elements))
+			for i, el := range r.elements {
+				v := e.Values[el.Index]
+				values[i] = declaration{
+					head:     string(v.Name),
+					tail:     strconv.Itoa(int(v.Tag)) + p.compactOptions(level+1, v.Options) + ";",
+					comment:  v.Comment,
+					trailing: r.trailing[i],
+				}
+			}
+			p.declarations(level+1, values)
+		}
+	})
+	p.indent(level)
+	p.print("}")
+}
+
+// elementKinds lists the kinds of element of a body in the order they are grouped in.
+var elementKinds = []ElementKind{ElementOption, ElementMessage, ElementEnum, ElementExtend, ElementReserved, ElementField, ElementOneOf, ElementValue, ElementMethod}
+
+// topLevelKinds lists the kinds of top-level definition in the order they are grouped in.
+var topLevelKinds = []ElementKind{ElementEnum, ElementMessage, ElementExtend, ElementService}
+
+// allowAliasIndex stands for the allow_alias option of an enum among the elements written for it, as the
+// option is kept apart from the options of the enum.
+const allowAliasIndex = -1
+
+// groupElements lists the elements of a body or of the top level grouped by kind, given the kinds in the
+// order they are grouped in and the number of elements of each kind.
+func groupElements(kinds []ElementKind, counts map[ElementKind]int) []Element {
+	var elements []Element
+	for _, kind := range kinds {
+		for i := 0; i < counts[kind]; i++ {
+			elements = append(elements, Element{Kind: kind, Index: i})
+		}
+	}
+	return elements
+}
+
+// declaredElements arranges the elements of a body as listed by order, followed by the elements that it
+// leaves out in the order they are given in. The layout markers of order are kept in place, while entries
+// that refer to no element, or to an element listed before, are skipped.
+func declaredElements(order, elements []Element) []Element {
+	remaining := make(map[Element]bool, len(elements))
+	for _, e := range elements {
+		remaining[e] = true
+	}
+	arranged := make([]Element, 0, len(order)+len(elements))
+	for _, e := range order {
+		switch {
+		case isMarker(e.Kind):
+			arranged = append(arranged, e)
+		case remaining[e]:
+			arranged = append(arranged, e)
+			delete(remaining, e)
+		}
+	}
+	for _, e := range elements {
+		if remaining[e] {
+			arranged = append(arranged, e)
+		}
+	}
+	return arranged
+}
+
+// withoutHeader returns the Order of a spec without its syntax and package statements, or the blank lines
+// before them.
+func withoutHeader(order []Element) []Element {
+	var kept []Element
+	for i, e := range order {
+		header := e.Kind == ElementSyntax || e.Kind == ElementPackage
+		if e.Kind == ElementBlank && i+1 < len(order) {
+			header = order[i+1].Kind == ElementSyntax || order[i+1].Kind == ElementPackage
+		}
+		if !header {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
+
+// isMarker reports whether a kind of element is a layout marker rather than an element.
+func isMarker(kind ElementKind) bool {
+	return kind == ElementBlank || kind == ElementComma || kind == ElementTrailing || kind == ElementBraces
+}
+
+// sortElements moves the elements of a body that have a tag after those that do not, in order of tag.
+func sortElements(elements []Element, tag func(Element) (TagType, bool)) []Element {
+	var untagged, tagged []Element
+	tags := make(map[Element]TagType)
+	for _, e := range elements {
+		if t, ok := tag(e); ok {
+			tags[e] = t
+			tagged = append(tagged, e)
+		} else {
+			untagged = append(untagged, e)
+		}
+	}
+	sort.SliceStable(tagged, func(i, j int) bool { return tags[tagged[i]] < tags[tagged[j]] })
+	return append(untagged, tagged...)
+}
+
+// oneofTag returns the lowest tag of the fields of a oneof, which places it among the fields of its message
+// when they are written in order of tag.
+func oneofTag(o OneOf) (TagType, bool) {
+	var lowest TagType
+	found := false
+	for _, f := range o.Fields {
+		if _, tag, ok := fieldNameTag(f); ok && (!found || tag < lowest) {
+			lowest, found = tag, true
+		}
+	}
+	return lowest, found
+}
+
+// run is a section of a body: a single nested message, enum, extend or oneof, or a run of consecutive
+// options, reserved values, fields, enum values or methods. The layout markers among its elements are
+// recorded for each element instead.
+type run struct {
+	elements []Element
+	trailing []bool // whether the comment of each element is marked as trailing
+	joined   []bool // whether each reserved value shares a statement with the one before it
+	braces   []bool // whether each method is marked as having an empty body
+}
+
+// newRun returns the run of a section, given its elements and layout markers.
+func newRun(elements []Element) run {
+	var r run
+	joined := false
+	for _, e := range elements {
+		switch e.Kind {
+		case ElementBlank:
+		case ElementComma:
+			joined = true
+		case ElementTrailing:
+			if n := len(r.trailing); n > 0 {
+				r.trailing[n-1] = true
+			}
+		case ElementBraces:
+			if n := len(r.braces); n > 0 {
+				r.braces[n-1] = true
+			}
+		default:
+			r.elements = append(r.elements, e)
+			r.trailing = append(r.trailing, false)
+			r.joined = append(r.joined, joined)
+			r.braces = append(r.braces, false)
+			joined = false
+		}
+	}
+	return r
+}
+
+// body writes the elements of a body as sections, each holding a single nested message, enum, extend or
+// oneof, or a run of consecutive options, reserved values, fields, enum values or methods. trailing reports
+// whether a section of a kind is followed by a blank line when spacing after sections, given the kind of the
+// element after it, which is empty for the last section. With declared spacing, a blank line listed between
+// elements also starts a new section, and is written before it.
+func (p *printer) body(elements []Element, trailing func(kind, next ElementKind) bool, write func(r run)) {
+	if p.format.Spacing != SpacingDeclared {
+		var kept []Element
+		for _, e := range elements {
+			if e.Kind != ElementBlank {
+				kept = append(kept, e)
+			}
+		}
+		elements = kept
+	}
+	started, blank := false, false
+	for len(elements) > 0 {
+		kind := elements[0].Kind
+		if isMarker(kind) {
+			blank = blank || kind == ElementBlank
+			elements = elements[1:]
+			continue
+		}
+		n := 1
+		for n < len(elements) && (elements[n].Kind == ElementTrailing || elements[n].Kind == ElementBraces ||
+			isRun(kind) && (elements[n].Kind == kind || elements[n].Kind == ElementComma)) {
+			n++
+		}
+		var next ElementKind
+		for _, e := range elements[n:] {
+			if !isMarker(e.Kind) {
+				next = e.Kind
+				break
+			}
+		}
+		if blank && started {
+			p.print("\n")
+		}
+		blank = false
+		r := newRun(elements[:n])
+		p.section(&started, trailing != nil && trailing(kind, next), func() { write(r) })
+		elements = elements[n:]
+	}
+}
+
+// isRun reports whether consecutive elements of a kind are written as a single section.
+func isRun(kind ElementKind) bool {
+	switch kind {
+	case ElementOption, ElementReserved, ElementField, ElementValue, ElementMethod:
+		return true
+	}
+	return false
+}
+
+// reserved writes reserved statements at a nesting level, each listing a reserved value along with those
+// that the run joins to it.
+func (p *printer) reserved(level int, values []Reserved, r run) {
+	for i := 0; i < len(values); {
+		n := 1
+		trailing := r.trailing[i]
+		for i+n < len(values) && r.joined[i+n] {
+			trailing = trailing || r.trailing[i+n]
+			n++
+		}
+		listed := make([]string, n)
+		for j := range listed {
+			v, err := values[i+j].Write()
+			if err != nil {
+				p.fail(err)
+				return
+			}
+			listed[j] = v
+		}
+		comment := reservedComment(values[i])
+		p.leadingComment(level, comment, trailing)
+		p.indent(level)
+		p.print("reserved ", strings.Join(listed, ", "), ";")
+		p.trailingComment(comment, trailing)
+		p.print("\n")
+		i += n
+	}
+}
+
+func (p *printer) oneof(level int, o OneOf, trailing bool) {
+	comment := p.definitionComment(level, o.Comment, trailing)
+	p.indent(level)
+	p.print("oneof ", string(o.Name))
+	elements := groupElements(elementKinds, o.elementCounts())
+	if !p.open(len(elements) == 0, comment) {
+		return
+	}
+	if p.format.Order == OrderDeclared {
+		elements = declaredElements(o.Order, elements)
+	}
+	p.body(elements, nil, func(r run) {
+		switch r.elements[0].Kind {
+		case ElementOption:
+			options := make([]Option, len(r.elements))
+			for i, e := range r.elements {
+				options[i] = o.Options[e.Index]
+			}
+			p.optionStatements(level+1, options, r.trailing)
+		case ElementField:
+			fields := make([]Field, len(r.elements))
+			for i, e := range r.elements {
+				fields[i] = o.Fields[e.Index]
+			}
+			p.fields(level+1, fields, r.trailing)
+		}
+	})
+	p.indent(level)
+	p.print("}")
+}
+
+func (p *printer) extend(level int, e Extend, trailing bool) {
+	comment := p.definitionComment(level, e.Comment, trailing)
+	p.indent(level)
+	p.print("extend ", e.Typing)
+	if !p.open(len(e.Fields) == 0, comment) {
+		return
+	}
+	p.fields(level+1, e.Fields, nil)
+	p.indent(level)
+	p.print("}")
+}
+
+func (p *printer) service(level int, s Service, trailing bool) {
+	comment := p.definitionComment(level, s.Comment, trailing)
+	p.indent(level)
+	p.print("service ", string(s.Name))
+	elements := groupElements(elementKinds, s.elementCounts())
+	if !p.open(len(elements) == 0, comment) {
+		return
+	}
+	if p.format.Order == OrderDeclared {
+		elements = declaredElements(s.Order, elements)
+	}
+	p.body(elements, nil, func(r run) {
+		switch r.elements[0].Kind {
+		case ElementOption:
+			options := make([]Option, len(r.elements))
+			for i, e := range r.elements {
+				options[i] = s.Options[e.Index]
+			}
+			p.optionStatements(level+1, options, r.trailing)
+		case ElementMethod:
+			methods := make([]declaration, len(r.elements))
+			for i, e := range r.elements {
+				methods[i] = p.methodDeclaration(level+1, s.Methods[e.Index], r.braces[i])
+				methods[i].trailing = r.trailing[i]
+			}
+			p.declarations(level+1, methods)
+		}
+	})
+	p.indent(level)
+	p.print("}")
+}
+
+// declaration is a field, enum value or method to be written on a line of its own. The head is followed
+// by = and the tail, unless the tail is empty.
+type declaration struct {
+	head     string // e.g. repeated string name
+	tail     string // e.g. 1 [packed = true];
+	comment  string
+	trailing bool // whether the Order of the body marks the comment as trailing
+}
+
+// statement returns the declaration without its comment, with its = in the column after width.
+func (d declaration) statement(width int) string {
+	if d.tail == "" {
+		return d.head
+	}
+	if pad := width - columns(d.head); pad > 0 {
+		return d.head + strings.Repeat(" ", pad) + " = " + d.tail
+	}
+	return d.head + " = " + d.tail
+}
+
+// declaration writes a declaration on the current line, followed by its comment, or on the line after a
+// comment of several lines.
+func (p *printer) declaration(d declaration) {
+	p.leadingComment(0, d.comment, d.trailing)
+	p.print(d.statement(0))
+	p.trailingComment(d.comment, d.trailing)
+}
+
+// declarations writes each declaration on a line of its own at a nesting level, aligning the = and the
+// trailing comments of single-line declarations into columns as the format requires. Comments of several
+// lines, and trailing comments that would run past the maximum line width, are written on the lines before
+// their declaration instead.
+func (p *printer) declarations(level int, decls []declaration) {
+	width := 0
+	if p.format.AlignValues {
+		for _, d := range decls {
+			if d.tail != "" && columns(d.head) > width {
+				width = columns(d.head)
+			}
+		}
+	}
+	statements := make([]string, len(decls))
+	for i, d := range decls {
+		statements[i] = d.statement(width)
+	}
+
+	// Moving a comment can narrow the column the others are aligned to, so the column is found again until
+	// no more comments move.
+	moved := make([]bool, len(decls))
+	for i, d := range decls {
+		moved[i] = p.leads(d.comment, d.trailing)
+	}
+	column := 0
+	for changed := true; changed; {
+		changed = false
+		column = 0
+		for i, d := range decls {
+			single := !strings.Contains(statements[i], "\n")
+			if p.format.AlignComments && d.comment != "" && !moved[i] && single && columns(statements[i]) > column {
+				column = columns(statements[i])
+			}
+		}
+		if p.format.MaxLineWidth <= 0 {
+			break
+		}
+		for i, d := range decls {
+			if d.comment == "" || moved[i] {
+				continue
+			}
+			end := columns(lastLine(statements[i]))
+			if !strings.Contains(statements[i], "\n") {
+				if end < column {
+					end = column
+				}
+				end += level * columns(p.format.Indent)
+			}
+			if end+p.format.CommentGap+len("// ")+columns(d.comment) > p.format.MaxLineWidth {
+				moved[i], changed = true, true
+			}
+		}
+	}
+
+	for i, d := range decls {
+		if moved[i] {
+			p.comment(level, d.comment)
+		}
+		p.indent(level)
+		p.print(statements[i])
+		if d.comment != "" && !moved[i] {
+			if pad := column - columns(statements[i]); pad > 0 && !strings.Contains(statements[i], "\n") {
+				p.print(strings.Repeat(" ", pad))
+			}
+			p.trailingComment(d.comment, d.trailing)
+		}
+		p.print("\n")
+	}
+}
+
+// methodDeclaration returns an rpc declaration at a nesting level. Its options are written in a body on
+// the same line, or over several lines when the format expands options or an option has a comment. A method
+// without options ends with a semicolon, unless braces are given for its empty body.
+func (p *printer) methodDeclaration(level int, m Method, braces bool) declaration {
+	var request, response string
+	if m.Streaming == ClientStreaming || m.Streaming == BidiStreaming {
+		request = "stream "
+	}
+	if m.Streaming == ServerStreaming || m.Streaming == BidiStreaming {
+		response = "stream "
+	}
+	head := "rpc " + string(m.Name) + "(" + request + m.RequestType + ") returns (" + response + m.ResponseType + ")"
+	switch {
+	case len(m.Options) == 0 && braces:
+		head += " {}"
+	case len(m.Options) == 0:
+		head += ";"
+	case p.format.ExpandOptions || hasComments(m.Options):
+		head += " {\n" + p.sprint(func(q *printer) {
+			q.optionStatements(level+1, m.Options, nil)
+			q.indent(level)
+		}) + "}"
+	default:
+		head += " {" + p.sprint(func(q *printer) {
+			for _, o := range m.Options {
+				q.print(" option ")
+				q.option(level, o)
+				q.print(";")
+			}
+		}) + " }"
+	}
+	return declaration{head: head, comment: m.Comment}
+}
+
+// hasComments reports whether any of the options has a comment.
+func hasComments(options []Option) bool {
+	for _, o := range options {
+		if o.Comment != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// method writes an rpc declaration.
+func (p *printer) method(m Method) {
+	p.declaration(p.methodDeclaration(0, m, false))
+}
+
+// fields writes each field on a line of its own at a nesting level, given whether the comment of each is
+// marked as trailing, or nil when none is.
+func (p *printer) fields(level int, fields []Field, trailing []bool) {
+	decls := make([]declaration, len(fields))
+	for i, f := range fields {
+		decls[i] = p.fieldDeclaration(level, f)
+		decls[i].trailing = trailing != nil && trailing[i]
+	}
+	p.declarations(level, decls)
+}
+
+// field writes a field declaration.
+func (p *printer) field(f Field) {
+	p.declaration(p.fieldDeclaration(0, f))
+}
+
+// fieldDeclaration returns the declaration of a field at a nesting level. Fields of types defined outside
+// this package write themselves, and are not split for alignment.
+func (p *printer) fieldDeclaration(level int, f Field) declaration {
+	switch f := f.(type) {
+	case ScalarField:
+		return p.fieldParts(level, f.Rule.Write()+f.Typing.Write(), f.Name, f.Tag, f.Options, f.Comment)
+	case CustomField:
+		return p.fieldParts(level, f.Rule.Write()+f.Typing, f.Name, f.Tag, f.Options, f.Comment)
+	case MapField:
+		return p.fieldParts(level, f.Rule.Write()+"map<"+f.KeyTyping.Write()+", "+f.ValueTyping.Write()+">", f.Name, f.Tag, f.Options, f.Comment)
+	case CustomMapField:
+		return p.fieldParts(level, f.Rule.Write()+"map<"+f.KeyTyping.Write()+", "+f.ValueTyping+">", f.Name, f.Tag, f.Options, f.Comment)
+	case MessageField:
+		return p.fieldDeclaration(level, f.custom(messageName(f.Typing)))
+	case EnumField:
+		return p.fieldDeclaration(level, f.custom(enumName(f.Typing)))
+	case MessageMapField:
+		return p.fieldDeclaration(level, f.custom(messageName(f.ValueTyping)))
+	case EnumMapField:
+		return p.fieldDeclaration(level, f.custom(enumName(f.ValueTyping)))
+	default:
+		v, err := f.Write()
+		if err != nil {
+			p.fail(err)
+		}
+		return declaration{head: v}
+	}
+}
+
+// fieldParts returns the declaration of a field of the given type.
+func (p *printer) fieldParts(level int, typing string, name NameType, tag TagType, options []Option, comment string) declaration {
+	return declaration{
+		head:    typing + " " + string(name),
+		tail:    strconv.Itoa(int(tag)) + p.compactOptions(level, options) + ";",
+		comment: comment,
+	}
+}
+
+// option writes an option of the form name = value. Aggregate values that the format expands are indented
+// from the nesting level.
+func (p *printer) option(level int, o Option) {
+	if v, ok := o.Value.(AggregateValue); ok && p.format.ExpandOptions {
+		p.print(o.Name, " = ")
+		p.aggregate(level, v)
+		return
+	}
+	v, err := o.Write()
+	if err != nil {
+		p.fail(err)
+		return
+	}
+	p.print(v)
+}
+
+// aggregate writes an aggregate value with each entry on a line of its own, or on one line when it holds a
+// single entry that is not an aggregate.
+func (p *printer) aggregate(level int, v AggregateValue) {
+	if len(v) == 0 {
+		p.print("{}")
+		return
+	}
+	if _, nested := v[0].Value.(AggregateValue); len(v) == 1 && v[0].Value != nil && !nested {
+		p.print("{", v[0].Name, ": ", v[0].Value.Write(), "}")
+		return
+	}
+	p.print("{\n")
+	for _, o := range v {
+		p.indent(level + 1)
+		p.print(o.Name, ":")
+		switch value := o.Value.(type) {
+		case nil:
+		case AggregateValue:
+			p.print(" ")
+			p.aggregate(level+1, value)
+		default:
+			p.print(" ", value.Write())
+		}
+		p.print("\n")
+	}
+	p.indent(level)
+	p.print("}")
+}
+
+// optionStatements writes each option as its own option statement at a nesting level, given whether the
+// comment of each is marked as trailing, or nil when none is.
+func (p *printer) optionStatements(level int, options []Option, trailing []bool) {
+	for i, o := range options {
+		marked := trailing != nil && trailing[i]
+		p.leadingComment(level, o.Comment, marked)
+		p.indent(level)
+		p.print("option ")
+		p.option(level, o)
+		p.print(";")
+		p.trailingComment(o.Comment, marked)
+		p.print("\n")
+	}
+}
+
+// compactOptions returns the bracketed option list that follows a field or enum value declared at a
+// nesting level, including a leading space, or nothing when there are no options. Several options are
+// written one per line when the format expands options.
+func (p *printer) compactOptions(level int, options []Option) string {
+	if len(options) == 0 {
+		return ""
+	}
+	return p.sprint(func(q *printer) {
+		if len(options) > 1 && q.format.ExpandOptions {
+			q.print(" [\n")
+			for i, o := range options {
+				q.indent(level + 1)
+				q.option(level+1, o)
+				if i < len(options)-1 {
+					q.print(",")
+				}
+				q.print("\n")
+			}
+			q.indent(level)
+			q.print("]")
+			return
+		}
+		q.print(" [")
+		for i, o := range options {
+			if i > 0 {
+				q.print(", ")
+			}
+			q.option(level, o)
+		}
+		q.print("]")
+	})
+}
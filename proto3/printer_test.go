@@ -0,0 +1,530 @@
+package proto3_test
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	. "github.com/muxinc/protogen/proto3"
+)
+
+// largeSpec declares a message with n fields and an enum with n values.
+func largeSpec(n int) *Spec {
+	fields := make([]Field, n)
+	values := make([]EnumValue, n)
+	for i := range fields {
+		fields[i] = ScalarField{Name: NameType(fmt.Sprintf("field_%d", i)), Typing: StringType, Tag: TagType(i + 1), Comment: "Generated field"}
+		values[i] = EnumValue{Name: NameType(fmt.Sprintf("VALUE_%d", i)), Tag: TagType(i)}
+	}
+	return &Spec{
+		Package:  "mux",
+		Messages: []Message{{Name: "Beacon", Fields: fields}},
+		Enums:    []Enum{{Name: "Kind", Values: values}},
+	}
+}
+
+// failingWriter fails every write.
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestSpec_WriteTo(t *testing.T) {
+	spec := largeSpec(100)
+	want, err := spec.Write()
+	if err != nil {
+		t.Fatalf("Spec.Write() error = %v", err)
+	}
+
+	var got strings.Builder
+	n, err := spec.WriteTo(&got)
+	if err != nil {
+		t.Fatalf("Spec.WriteTo() error = %v", err)
+	}
+	if got.String() != want || n != int64(len(want)) {
+		t.Errorf("Spec.WriteTo() = %d, %q, want %d, %q", n, got.String(), len(want), want)
+	}
+
+	if _, err := spec.WriteTo(failingWriter{}); err == nil || err.Error() != "disk full" {
+		t.Errorf("Spec.WriteTo() error = %v, want the writer's error", err)
+	}
+	if _, err := (&Spec{}).WriteTo(&got); err == nil {
+		t.Errorf("Spec.WriteTo() of an invalid spec succeeded")
+	}
+}
+
+// formattedSpec declares a small spec with comments, options and a service.
+func formattedSpec() *Spec {
+	return &Spec{
+		Package:   "mux",
+		GoPackage: "github.com/muxinc/beacon",
+		Imports:   []ImportType{"google/protobuf/timestamp.proto", "google/protobuf/empty.proto"},
+		Messages: []Message{{
+			Name:    "Beacon",
+			Comment: "Beacon reports what a player did during a view",
+			Fields: []Field{
+				ScalarField{Name: "view_id", Typing: StringType, Tag: 1, Comment: "Unique to the view"},
+				CustomField{Name: "sent_at", Typing: "google.protobuf.Timestamp", Tag: 2, Comment: "When the player sent the beacon"},
+				ScalarField{Name: "ids", Typing: Int64Type, Tag: 3, Rule: Repeated, Options: []Option{{Name: "packed", Value: BoolValue(true)}, {Name: "deprecated", Value: BoolValue(true)}}},
+			},
+			OneOfs: []OneOf{{Name: "source", Fields: []Field{ScalarField{Name: "url", Typing: StringType, Tag: 4}}}},
+		}},
+		Services: []Service{{Name: "Collector", Methods: []Method{
+			{Name: "Send", RequestType: "Beacon", ResponseType: "google.protobuf.Empty", Options: []Option{{Name: "deprecated", Value: BoolValue(true)}}},
+		}}},
+	}
+}
+
+func TestSpec_WriteFormatted(t *testing.T) {
+	tests := []struct {
+		name   string
+		format FormatOptions
+		want   string
+	}{
+		{"Default", DefaultFormat, `syntax = "proto3";
+package mux;
+option go_package = "github.com/muxinc/beacon";
+import "google/protobuf/timestamp.proto";
+import "google/protobuf/empty.proto";
+
+// Beacon reports what a player did during a view
+message Beacon {
+  string view_id = 1;   // Unique to the view
+  google.protobuf.Timestamp sent_at = 2;   // When the player sent the beacon
+  repeated int64 ids = 3 [packed = true, deprecated = true];
+
+  oneof source {
+    string url = 4;
+  }
+}
+
+service Collector {
+  rpc Send(Beacon) returns (google.protobuf.Empty) { option deprecated = true; }
+}
+`},
+		{"Aligned with tabs", FormatOptions{Indent: "\t", CommentGap: 2, AlignValues: true, AlignComments: true, Spacing: SpacingBetweenSections}, `syntax = "proto3";
+package mux;
+option go_package = "github.com/muxinc/beacon";
+import "google/protobuf/timestamp.proto";
+import "google/protobuf/empty.proto";
+
+// Beacon reports what a player did during a view
+message Beacon {
+	string view_id                    = 1;  // Unique to the view
+	google.protobuf.Timestamp sent_at = 2;  // When the player sent the beacon
+	repeated int64 ids                = 3 [packed = true, deprecated = true];
+
+	oneof source {
+		string url = 4;
+	}
+}
+
+service Collector {
+	rpc Send(Beacon) returns (google.protobuf.Empty) { option deprecated = true; }
+}
+`},
+		{"Wrapped comments", FormatOptions{Indent: "  ", CommentGap: 1, MaxLineWidth: 40, Spacing: SpacingNone}, `syntax = "proto3";
+package mux;
+option go_package = "github.com/muxinc/beacon";
+import "google/protobuf/timestamp.proto";
+import "google/protobuf/empty.proto";
+
+// Beacon reports what a player did
+// during a view
+message Beacon {
+  // Unique to the view
+  string view_id = 1;
+  // When the player sent the beacon
+  google.protobuf.Timestamp sent_at = 2;
+  repeated int64 ids = 3 [packed = true, deprecated = true];
+  oneof source {
+    string url = 4;
+  }
+}
+
+service Collector {
+  rpc Send(Beacon) returns (google.protobuf.Empty) { option deprecated = true; }
+}
+`},
+		{"Buf", BufFormat, `syntax = "proto3";
+
+package mux;
+
+import "google/protobuf/empty.proto";
+import "google/protobuf/timestamp.proto";
+
+option go_package = "github.com/muxinc/beacon";
+
+// Beacon reports what a player did during a view
+message Beacon {
+  // Unique to the view
+  string view_id = 1;
+  // When the player sent the beacon
+  google.protobuf.Timestamp sent_at = 2;
+  repeated int64 ids = 3 [
+    packed = true,
+    deprecated = true
+  ];
+  oneof source {
+    string url = 4;
+  }
+}
+
+service Collector {
+  rpc Send(Beacon) returns (google.protobuf.Empty) {
+    option deprecated = true;
+  }
+}
+`},
+	}
+	for _, tt := range tests {
+		var got strings.Builder
+		if _, err := formattedSpec().WriteFormatted(&got, tt.format); err != nil {
+			t.Errorf("%q. Spec.WriteFormatted() error = %v", tt.name, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("%q. Spec.WriteFormatted() = %s, want %s", tt.name, got.String(), tt.want)
+		}
+	}
+}
+
+// bufInput is an unformatted spec, and bufGolden is what buf format v1.28.1 writes for it.
+const bufInput = `// Beacons sent by players.
+syntax = "proto3";
+package mux.beacon;
+import "google/protobuf/timestamp.proto";
+option java_multiple_files = true;
+option go_package = "github.com/muxinc/beacon";
+
+// A single beacon.
+message Beacon {
+  option deprecated = true;
+  reserved 4, 8 to 10;
+  reserved "player_id", "session_id";
+
+
+  // Unique view identifier
+  string view_id = 1;
+  int64 seq = 2; // Sequence number
+  google.protobuf.Timestamp at = 3 [deprecated = true];
+
+  map<string, string> tags = 5 [(mux.owner) = "video", deprecated = true];
+  oneof source {
+    string page_url = 6;
+
+    int32 app_id = 7;
+  }
+
+  message Event {}
+  enum Kind {
+    KIND_UNSPECIFIED = 0;
+
+    KIND_VIEW = 1;
+    reserved 2, 3;
+  }
+}
+
+enum Level {
+  LEVEL_UNSPECIFIED = 0;
+  LEVEL_LOW = 1;
+
+  LEVEL_HIGH = 2;
+}
+
+service Tracker {
+  option deprecated = true;
+
+  rpc Track(Beacon) returns (Beacon);
+  // Lists beacons.
+  rpc List(Beacon) returns (stream Beacon) {
+    option idempotency_level = NO_SIDE_EFFECTS;
+  }
+
+  rpc Get(Beacon) returns (Beacon) {}
+}
+`
+
+const bufGolden = `// Beacons sent by players.
+syntax = "proto3";
+package mux.beacon;
+
+import "google/protobuf/timestamp.proto";
+
+option go_package = "github.com/muxinc/beacon";
+option java_multiple_files = true;
+
+// A single beacon.
+message Beacon {
+  option deprecated = true;
+  reserved 4, 8 to 10;
+  reserved "player_id", "session_id";
+
+  // Unique view identifier
+  string view_id = 1;
+  int64 seq = 2; // Sequence number
+  google.protobuf.Timestamp at = 3 [deprecated = true];
+
+  map<string, string> tags = 5 [
+    (mux.owner) = "video",
+    deprecated = true
+  ];
+  oneof source {
+    string page_url = 6;
+
+    int32 app_id = 7;
+  }
+
+  message Event {}
+  enum Kind {
+    KIND_UNSPECIFIED = 0;
+
+    KIND_VIEW = 1;
+    reserved 2, 3;
+  }
+}
+
+enum Level {
+  LEVEL_UNSPECIFIED = 0;
+  LEVEL_LOW = 1;
+
+  LEVEL_HIGH = 2;
+}
+
+service Tracker {
+  option deprecated = true;
+
+  rpc Track(Beacon) returns (Beacon);
+  // Lists beacons.
+  rpc List(Beacon) returns (stream Beacon) {
+    option idempotency_level = NO_SIDE_EFFECTS;
+  }
+
+  rpc Get(Beacon) returns (Beacon) {}
+}
+`
+
+func TestSpec_WriteFormatted_bufGolden(t *testing.T) {
+	for _, src := range []string{bufInput, bufGolden} {
+		spec, err := Parse(strings.NewReader(src))
+		if err != nil {
+			t.Fatalf("Parse() error = %v", err)
+		}
+		var got strings.Builder
+		if _, err := spec.WriteFormatted(&got, BufFormat); err != nil {
+			t.Fatalf("Spec.WriteFormatted() error = %v", err)
+		}
+		if got.String() != bufGolden {
+			t.Errorf("Spec.WriteFormatted() = %s, want %s", got.String(), bufGolden)
+		}
+	}
+}
+
+func TestSpec_Write_multilineComments(t *testing.T) {
+	spec := &Spec{
+		Options: []Option{{Name: "java_multiple_files", Value: BoolValue(true), Comment: "One class\nper message"}},
+		Messages: []Message{{
+			Name:           "Beacon",
+			Comment:        "line1\n\nline2",
+			ReservedValues: []Reserved{ReservedTagValue{Tag: 1, Comment: "Retired\nfields"}},
+			Fields: []Field{
+				ScalarField{Name: "view_id", Typing: StringType, Tag: 2, Comment: "line1\nline2"},
+				ScalarField{Name: "at", Typing: Int64Type, Tag: 3, Comment: "When"},
+			},
+		}},
+	}
+	want := `syntax = "proto3";
+// One class
+// per message
+option java_multiple_files = true;
+
+// line1
+//
+// line2
+message Beacon {
+  // Retired
+  // fields
+  reserved 1;
+
+  // line1
+  // line2
+  string view_id = 2;
+  int64 at = 3;   // When
+
+}
+`
+	got, err := spec.Write()
+	if err != nil {
+		t.Fatalf("Spec.Write() error = %v", err)
+	}
+	if got != want {
+		t.Errorf("Spec.Write() = %s, want %s", got, want)
+	}
+	if _, err := Parse(strings.NewReader(got)); err != nil {
+		t.Errorf("Parse() of written spec error = %v", err)
+	}
+}
+
+// orderedSpec declares the elements of a message and an enum out of the order they are grouped in, and
+// the values of the enum out of the order of their tags.
+const orderedSpec = `syntax = "proto3";
+
+enum Level {
+  reserved 3;
+  LOW = 0;
+  HIGH = 2;
+  NONE = -1;
+  MEDIUM = 1;
+}
+
+message Beacon {
+  string view_id = 1;
+  oneof source {
+    string page_url = 4;
+    int32 app_id = 5;
+  }
+  repeated Event events = 3;
+  message Event {
+    string name = 1;
+  }
+  reserved 2;
+  int64 at = 6;
+}
+`
+
+func TestSpec_WriteFormatted_order(t *testing.T) {
+	tests := []struct {
+		order Ordering
+		want  string
+	}{
+		{OrderGrouped, `syntax = "proto3";
+
+enum Level {
+  reserved 3;
+  LOW = 0;
+  NONE = -1;
+  MEDIUM = 1;
+  HIGH = 2;
+}
+
+message Beacon {
+  message Event {
+    string name = 1;
+  }
+  reserved 2;
+  string view_id = 1;
+  repeated Event events = 3;
+  int64 at = 6;
+  oneof source {
+    string page_url = 4;
+    int32 app_id = 5;
+  }
+}
+`},
+		{OrderDeclared, orderedSpec},
+		{OrderByTag, `syntax = "proto3";
+
+enum Level {
+  reserved 3;
+  LOW = 0;
+  NONE = -1;
+  MEDIUM = 1;
+  HIGH = 2;
+}
+
+message Beacon {
+  message Event {
+    string name = 1;
+  }
+  reserved 2;
+  string view_id = 1;
+  repeated Event events = 3;
+  oneof source {
+    string page_url = 4;
+    int32 app_id = 5;
+  }
+  int64 at = 6;
+}
+`},
+	}
+	spec, err := Parse(strings.NewReader(orderedSpec))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	for _, tt := range tests {
+		var got strings.Builder
+		format := FormatOptions{Indent: "  ", CommentGap: 1, Spacing: SpacingNone, Order: tt.order}
+		if _, err := spec.WriteFormatted(&got, format); err != nil {
+			t.Errorf("%d. Spec.WriteFormatted() error = %v", tt.order, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("%d. Spec.WriteFormatted() = %s, want %s", tt.order, got.String(), tt.want)
+		}
+	}
+
+	// Elements left out of the order follow it, grouped.
+	spec.Messages[0].Order = []Element{{Kind: ElementField, Index: 2}, {Kind: ElementOneOf, Index: 0}}
+	var got strings.Builder
+	if _, err := spec.WriteFormatted(&got, FormatOptions{Indent: "  ", Spacing: SpacingNone, Order: OrderDeclared}); err != nil {
+		t.Fatalf("Spec.WriteFormatted() error = %v", err)
+	}
+	want := "message Beacon {\n  int64 at = 6;\n  oneof source {\n    string page_url = 4;\n    int32 app_id = 5;\n  }\n  message Event {\n    string name = 1;\n  }\n  reserved 2;\n  string view_id = 1;\n  repeated Event events = 3;\n}\n"
+	if !strings.Contains(got.String(), want) {
+		t.Errorf("Spec.WriteFormatted() = %s, want it to contain %s", got.String(), want)
+	}
+}
+
+func ExampleSpec_WriteFormatted() {
+	spec := &Spec{
+		Package: "mux",
+		Options: []Option{{Name: "(owner)", Value: AggregateValue{{Name: "team", Value: StringValue("video")}}}, {Name: "java_multiple_files", Value: BoolValue(true)}},
+		Imports: []ImportType{"owner.proto"},
+		Messages: []Message{
+			{Name: "Empty"},
+			{Name: "Beacon", Fields: []Field{ScalarField{Name: "view_id", Typing: StringType, Tag: 1}}},
+		},
+	}
+	spec.WriteFormatted(os.Stdout, BufFormat)
+	// Output:
+	// syntax = "proto3";
+	//
+	// package mux;
+	//
+	// import "owner.proto";
+	//
+	// option java_multiple_files = true;
+	// option (owner) = {team: "video"};
+	//
+	// message Empty {}
+	//
+	// message Beacon {
+	//   string view_id = 1;
+	// }
+}
+
+// BenchmarkSpec_Write measures writing a large spec into a string, which holds the whole output in memory.
+func BenchmarkSpec_Write(b *testing.B) {
+	spec := largeSpec(1000)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := spec.Write(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkSpec_WriteTo measures streaming the same spec to a writer, which only buffers a little of it.
+func BenchmarkSpec_WriteTo(b *testing.B) {
+	spec := largeSpec(1000)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := spec.WriteTo(ioutil.Discard); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
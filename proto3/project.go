@@ -0,0 +1,196 @@
+package proto3
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Project is a set of specs that are generated together, such as a package split over several files. Each
+// file is laid out in a directory named after its package, and imports the files of the project and the
+// well-known types that declare the types it refers to, so the Imports of a spec only need to list files
+// from outside the project.
+type Project struct {
+	Files []ProjectFile
+}
+
+// ProjectFile is a spec along with the name of the .proto file it is written to (e.g. events.proto).
+type ProjectFile struct {
+	Name string
+	Spec *Spec
+}
+
+// Path returns the path the file is imported by: its name within a directory for each component of its
+// package (e.g. mux/events/events.proto for package mux.events).
+func (f ProjectFile) Path() string {
+	if f.Spec.Package == "" {
+		return f.Name
+	}
+	return strings.Replace(f.Spec.Package, ".", "/", -1) + "/" + f.Name
+}
+
+// Imports computes the imports of a file of the project from the types and extensions it refers to: the
+// other files of the project and the well-known types that declare them, in order of path. Imports of files
+// from outside the project are kept, as the types they declare are not known.
+func (p *Project) Imports(f ProjectFile) []ImportType {
+	paths := make(map[string]bool)
+	for _, other := range p.Files {
+		paths[other.Path()] = true
+	}
+	spec := *f.Spec
+	spec.Imports = nil
+	for _, i := range f.Spec.Imports {
+		if _, wellKnown := wellKnownTypes[i]; !wellKnown && !paths[string(i)] {
+			spec.Imports = append(spec.Imports, i)
+		}
+	}
+
+	imports := spec.Imports
+	for path := range p.resolver(f).check(&spec).missing {
+		imports = append(imports, ImportType(path))
+	}
+	sort.Slice(imports, func(i, j int) bool { return imports[i] < imports[j] })
+	return imports
+}
+
+// Validate checks every file of the project with its computed imports, including that the type references
+// of each file resolve. Problems with a file are reported prefixed with its path. Problems with the project
+// as a whole, which are types declared by more than one file and files that import each other in a cycle,
+// are reported as ValidationErrors.
+func (p *Project) Validate() error {
+	var errs ValidationErrors
+	paths := make(map[string]bool)
+	symbols := newSymbolTable()
+	for _, f := range p.Files {
+		path := f.Path()
+		if paths[path] {
+			return fmt.Errorf("Project has more than one file at %s", path)
+		}
+		paths[path] = true
+		symbols.addFile(path, f.Spec)
+	}
+	names := make([]string, 0, len(symbols.types))
+	for name := range symbols.types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if files := symbols.types[name].files; len(files) > 1 {
+			errs.add(name, CodeDuplicateName, "Type is declared by more than one file: %s", strings.Join(files, ", "))
+		}
+	}
+
+	order := make([]string, 0, len(p.Files))
+	imports := make(map[string][]ImportType)
+	for _, f := range p.Files {
+		path := f.Path()
+		spec := f.withImports(p.Imports(f))
+		if err := spec.Validate(); err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+		var problems ValidationErrors
+		for _, e := range p.resolver(f).check(spec).errs {
+			if e.Severity == SeverityError {
+				problems = append(problems, e)
+			}
+		}
+		if err := problems.err(); err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+		order = append(order, path)
+		imports[path] = spec.Imports
+	}
+	if cycle := importCycle(order, imports); cycle != nil {
+		errs.add("", CodeImportCycle, "Files import each other in a cycle: %s", strings.Join(cycle, " -> "))
+	}
+	return errs.err()
+}
+
+// WriteAll validates the project and writes each file with its computed imports to its path within dir,
+// creating the directories of its package as needed.
+func (p *Project) WriteAll(dir string) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	for _, f := range p.Files {
+		v, err := f.withImports(p.Imports(f)).Write()
+		if err != nil {
+			return fmt.Errorf("%s: %s", f.Path(), err)
+		}
+		path := filepath.Join(dir, filepath.FromSlash(f.Path()))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(path, []byte(v), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolver returns a resolver that knows every file of the project but f, by path.
+func (p *Project) resolver(f ProjectFile) *Resolver {
+	var others []DescriptorFile
+	for _, other := range p.Files {
+		if other.Spec != f.Spec {
+			others = append(others, DescriptorFile{Name: other.Path(), Spec: other.Spec})
+		}
+	}
+	return NewResolver(others...)
+}
+
+// withImports returns a copy of the spec of the file that has the given imports.
+func (f ProjectFile) withImports(imports []ImportType) *Spec {
+	spec := *f.Spec
+	spec.Imports = imports
+	return &spec
+}
+
+// importCycle returns the paths of files that import each other in a cycle, starting and ending with the
+// same file, or nil when there is no cycle. Files are visited in order, and imports of paths that are not
+// among the files are ignored.
+func importCycle(order []string, imports map[string][]ImportType) []string {
+	const (
+		visiting = iota + 1
+		visited
+	)
+	state := make(map[string]int)
+	var stack []string
+	var visit func(path string) []string
+	visit = func(path string) []string {
+		state[path] = visiting
+		stack = append(stack, path)
+		for _, i := range imports[path] {
+			next := string(i)
+			if _, ok := imports[next]; !ok {
+				continue
+			}
+			switch state[next] {
+			case visiting:
+				for j, p := range stack {
+					if p == next {
+						return append(append([]string{}, stack[j:]...), next)
+					}
+				}
+			case 0:
+				if cycle := visit(next); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[path] = visited
+		return nil
+	}
+	for _, path := range order {
+		if state[path] == 0 {
+			if cycle := visit(path); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
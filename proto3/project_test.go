@@ -0,0 +1,117 @@
+package proto3_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	. "github.com/muxinc/protogen/proto3"
+)
+
+// beaconProject splits the mux package over files that refer to each other's types.
+func beaconProject() *Project {
+	common := &Spec{
+		Package:  "mux.common",
+		Messages: []Message{{Name: "Limits", Fields: []Field{ScalarField{Name: "max", Typing: Int32Type, Tag: 1}}}},
+		Extends:  []Extend{{Typing: "google.protobuf.FieldOptions", Fields: []Field{ScalarField{Name: "owner", Typing: StringType, Tag: 50000}}}},
+	}
+	events := &Spec{
+		Package:  "mux",
+		Messages: []Message{{Name: "Event", Fields: []Field{CustomField{Name: "limits", Typing: "common.Limits", Tag: 1}}}},
+	}
+	beacon := &Spec{
+		Package: "mux",
+		Imports: []ImportType{"vendor/sessions.proto", "mux/unused.proto"},
+		Messages: []Message{{
+			Name: "Beacon",
+			Fields: []Field{
+				CustomField{Name: "events", Typing: "Event", Tag: 1, Rule: Repeated, Options: []Option{{Name: "(common.owner)", Value: StringValue("video")}}},
+				CustomField{Name: "at", Typing: "google.protobuf.Timestamp", Tag: 2},
+			},
+		}},
+	}
+	return &Project{Files: []ProjectFile{
+		{Name: "common.proto", Spec: common},
+		{Name: "events.proto", Spec: events},
+		{Name: "beacon.proto", Spec: beacon},
+	}}
+}
+
+func TestProject_Imports(t *testing.T) {
+	project := beaconProject()
+	tests := []struct {
+		file ProjectFile
+		want []ImportType
+	}{
+		{project.Files[0], []ImportType{DescriptorImport}},
+		{project.Files[1], []ImportType{"mux/common/common.proto"}},
+		{project.Files[2], []ImportType{"google/protobuf/timestamp.proto", "mux/common/common.proto", "mux/events.proto", "mux/unused.proto", "vendor/sessions.proto"}},
+	}
+	for _, tt := range tests {
+		if got := project.Imports(tt.file); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q. Project.Imports() = %q, want %q", tt.file.Path(), got, tt.want)
+		}
+	}
+}
+
+func TestProject_Validate(t *testing.T) {
+	if err := beaconProject().Validate(); err != nil {
+		t.Fatalf("Project.Validate() error = %v", err)
+	}
+
+	cyclic := beaconProject()
+	cyclic.Files[0].Spec.Messages[0].Fields = append(cyclic.Files[0].Spec.Messages[0].Fields, CustomField{Name: "event", Typing: "mux.Event", Tag: 2})
+	unresolved := beaconProject()
+	unresolved.Files[1].Spec.Messages[0].Fields[0] = CustomField{Name: "limits", Typing: "common.Limts", Tag: 1}
+	duplicate := beaconProject()
+	duplicate.Files[2].Spec.Messages = append(duplicate.Files[2].Spec.Messages, Message{Name: "Event"})
+	tests := []struct {
+		name      string
+		project   *Project
+		wantError string
+	}{
+		{"Import cycle", cyclic, "mux/common/common.proto -> mux/events.proto -> mux/common/common.proto"},
+		{"Unresolved type", unresolved, "mux/events.proto: mux.Event.limits: Type common.Limts"},
+		{"Type declared twice", duplicate, "mux.Event: Type is declared by more than one file: mux/events.proto, mux/beacon.proto"},
+		{"File path used twice", &Project{Files: []ProjectFile{beaconProject().Files[1], beaconProject().Files[1]}}, "more than one file at mux/events.proto"},
+	}
+	for _, tt := range tests {
+		err := tt.project.Validate()
+		if err == nil || !strings.Contains(err.Error(), tt.wantError) {
+			t.Errorf("%q. Project.Validate() error = %v, want %q", tt.name, err, tt.wantError)
+		}
+	}
+}
+
+func TestProject_WriteAll(t *testing.T) {
+	dir, err := ioutil.TempDir("", "protogen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := beaconProject().WriteAll(dir); err != nil {
+		t.Fatalf("Project.WriteAll() error = %v", err)
+	}
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"mux/common/common.proto", "package mux.common;\nimport \"google/protobuf/descriptor.proto\";\n"},
+		{"mux/events.proto", "package mux;\nimport \"mux/common/common.proto\";\n"},
+		{"mux/beacon.proto", "import \"mux/events.proto\";\nimport \"mux/unused.proto\";\nimport \"vendor/sessions.proto\";\n"},
+	}
+	for _, tt := range tests {
+		got, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(tt.path)))
+		if err != nil {
+			t.Errorf("Project.WriteAll() did not write %s: %v", tt.path, err)
+			continue
+		}
+		if !strings.Contains(string(got), tt.want) {
+			t.Errorf("Project.WriteAll() wrote %s = %s, want it to contain %q", tt.path, got, tt.want)
+		}
+	}
+}
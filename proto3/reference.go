@@ -0,0 +1,200 @@
+package proto3
+
+import "strings"
+
+// Fields of the types MessageField, EnumField, MessageMapField and EnumMapField refer to a message or enum
+// of the model rather than naming it. The reference is to the element within the spec, for example
+// &spec.Messages[0], so that it is found when the spec is written, validated or encoded. Before then, each
+// such field is replaced by the equivalent custom field naming the type.
+
+// custom returns the field as a custom field with the given type.
+func (m MessageField) custom(typing string) CustomField {
+	return CustomField{Name: m.Name, Tag: m.Tag, Rule: m.Rule, Comment: m.Comment, Typing: typing, Options: m.Options}
+}
+
+// custom returns the field as a custom field with the given type.
+func (e EnumField) custom(typing string) CustomField {
+	return CustomField{Name: e.Name, Tag: e.Tag, Rule: e.Rule, Comment: e.Comment, Typing: typing, Options: e.Options}
+}
+
+// custom returns the field as a custom map field with the given value type.
+func (m MessageMapField) custom(typing string) CustomMapField {
+	return CustomMapField{Name: m.Name, Tag: m.Tag, Rule: m.Rule, Comment: m.Comment, KeyTyping: m.KeyTyping, ValueTyping: typing, Options: m.Options}
+}
+
+// custom returns the field as a custom map field with the given value type.
+func (e EnumMapField) custom(typing string) CustomMapField {
+	return CustomMapField{Name: e.Name, Tag: e.Tag, Rule: e.Rule, Comment: e.Comment, KeyTyping: e.KeyTyping, ValueTyping: typing, Options: e.Options}
+}
+
+// messageName returns the simple name of a referenced message, which is empty for a nil reference.
+func messageName(m *Message) string {
+	if m == nil {
+		return ""
+	}
+	return m.Name
+}
+
+// enumName returns the simple name of a referenced enum, which is empty for a nil reference.
+func enumName(e *Enum) string {
+	if e == nil {
+		return ""
+	}
+	return string(e.Name)
+}
+
+// fieldReference returns the message or enum that any of the field types defined in this package refers
+// to, if it is one of the types that refer to an element of the model.
+func fieldReference(f Field) (interface{}, string, bool) {
+	switch f := f.(type) {
+	case MessageField:
+		return f.Typing, "message " + messageName(f.Typing), f.Typing != nil
+	case EnumField:
+		return f.Typing, "enum " + enumName(f.Typing), f.Typing != nil
+	case MessageMapField:
+		return f.ValueTyping, "message " + messageName(f.ValueTyping), f.ValueTyping != nil
+	case EnumMapField:
+		return f.ValueTyping, "enum " + enumName(f.ValueTyping), f.ValueTyping != nil
+	default:
+		return nil, "", false
+	}
+}
+
+// typeNames returns the fully-qualified name of every message and enum of the spec, keyed by its address.
+// Fields refer to messages and enums by the address of their element within the spec's slices, so a
+// reference taken before an append that reallocates a slice goes stale: it no longer matches a key and is
+// reported as unresolved.
+func (s *Spec) typeNames() map[interface{}]string {
+	names := make(map[interface{}]string)
+	var add func(prefix string, messages []Message, enums []Enum)
+	add = func(prefix string, messages []Message, enums []Enum) {
+		for i := range enums {
+			names[&enums[i]] = joinPath(prefix, string(enums[i].Name))
+		}
+		for i := range messages {
+			name := joinPath(prefix, messages[i].Name)
+			names[&messages[i]] = name
+			add(name, messages[i].Messages, messages[i].Enums)
+		}
+	}
+	add(s.Package, s.Messages, s.Enums)
+	return names
+}
+
+// validateReferences checks that every field referring to a message or enum, including extension fields,
+// refers to one declared within the spec.
+func (s *Spec) validateReferences() error {
+	var errs ValidationErrors
+	names := s.typeNames()
+	check := func(path string, f Field) {
+		ref, kind, ok := fieldReference(f)
+		if _, declared := names[ref]; ok && !declared {
+			errs.add(path, CodeUnresolvedType, "Field refers to %s, which is not declared in the spec", kind)
+		}
+	}
+	s.walkFields(check)
+	var extensions func(prefix string, extends []Extend, messages []Message)
+	extensions = func(prefix string, extends []Extend, messages []Message) {
+		for _, e := range extends {
+			for _, f := range e.Fields {
+				name, _, _ := fieldNameTag(f)
+				check(joinPath(prefix, string(name)), f)
+			}
+		}
+		for _, m := range messages {
+			extensions(joinPath(prefix, m.Name), m.Extends, m.Messages)
+		}
+	}
+	extensions(s.Package, s.Extends, s.Messages)
+	return errs.err()
+}
+
+// withTypeNames returns a copy of the spec in which every field referring to a message or enum is replaced
+// by a custom field naming its type by the shortest name that resolves to it from the scope of the field.
+// References to types that are not declared in the spec, including stale references (see typeNames), name
+// the type by its simple name.
+func (s *Spec) withTypeNames() *Spec {
+	names := s.typeNames()
+	symbols := newSymbolTable()
+	symbols.addFile("", s)
+	typeName := func(scope string, ref interface{}, name string) string {
+		if fullName, ok := names[ref]; ok {
+			return symbols.shortestName(scope, fullName)
+		}
+		return name
+	}
+
+	fields := func(scope string, values []Field) []Field {
+		if values == nil {
+			return nil
+		}
+		copied := make([]Field, len(values))
+		for i, f := range values {
+			switch f := f.(type) {
+			case MessageField:
+				copied[i] = f.custom(typeName(scope, f.Typing, messageName(f.Typing)))
+			case EnumField:
+				copied[i] = f.custom(typeName(scope, f.Typing, enumName(f.Typing)))
+			case MessageMapField:
+				copied[i] = f.custom(typeName(scope, f.ValueTyping, messageName(f.ValueTyping)))
+			case EnumMapField:
+				copied[i] = f.custom(typeName(scope, f.ValueTyping, enumName(f.ValueTyping)))
+			default:
+				copied[i] = f
+			}
+		}
+		return copied
+	}
+	extends := func(scope string, values []Extend) []Extend {
+		if values == nil {
+			return nil
+		}
+		copied := make([]Extend, len(values))
+		for i, e := range values {
+			e.Fields = fields(scope, e.Fields)
+			copied[i] = e
+		}
+		return copied
+	}
+	var messages func(scope string, values []Message) []Message
+	messages = func(scope string, values []Message) []Message {
+		if values == nil {
+			return nil
+		}
+		copied := make([]Message, len(values))
+		for i, m := range values {
+			path := joinPath(scope, m.Name)
+			m.Fields = fields(path, m.Fields)
+			if m.OneOfs != nil {
+				oneofs := make([]OneOf, len(m.OneOfs))
+				for j, o := range m.OneOfs {
+					o.Fields = fields(path, o.Fields)
+					oneofs[j] = o
+				}
+				m.OneOfs = oneofs
+			}
+			m.Extends = extends(path, m.Extends)
+			m.Messages = messages(path, m.Messages)
+			copied[i] = m
+		}
+		return copied
+	}
+
+	copied := *s
+	copied.Extends = extends(s.Package, s.Extends)
+	copied.Messages = messages(s.Package, s.Messages)
+	return &copied
+}
+
+// shortestName returns the shortest reference to a fully-qualified type name that resolves to it from
+// within scope, falling back to the fully-qualified reference.
+func (t *symbolTable) shortestName(scope, name string) string {
+	parts := strings.Split(name, ".")
+	for i := len(parts) - 1; i >= 0; i-- {
+		ref := strings.Join(parts[i:], ".")
+		if found, ok := t.lookup(scope, ref); ok && found == name {
+			return ref
+		}
+	}
+	return "." + name
+}
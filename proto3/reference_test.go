@@ -0,0 +1,150 @@
+package proto3_test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	. "github.com/muxinc/protogen/proto3"
+)
+
+// referenceSpec declares a Beacon message whose fields refer to messages and enums of the spec.
+func referenceSpec() *Spec {
+	spec := &Spec{
+		Package: "mux",
+		Enums:   []Enum{{Name: "Level", Values: []EnumValue{{Name: "LOW", Tag: 0}, {Name: "HIGH", Tag: 1}}}},
+		Messages: []Message{
+			{Name: "Event", Fields: []Field{ScalarField{Name: "name", Typing: StringType, Tag: 1}}},
+			{
+				Name:     "Beacon",
+				Messages: []Message{{Name: "Player", Fields: []Field{ScalarField{Name: "name", Typing: StringType, Tag: 1}}}},
+				Enums:    []Enum{{Name: "Kind", Values: []EnumValue{{Name: "UNKNOWN", Tag: 0}}}},
+			},
+		},
+	}
+	beacon := &spec.Messages[1]
+	beacon.Fields = []Field{
+		MessageField{Name: "events", Typing: &spec.Messages[0], Tag: 1, Rule: Repeated},
+		MessageField{Name: "player", Typing: &beacon.Messages[0], Tag: 2},
+		EnumField{Name: "kinds", Typing: &beacon.Enums[0], Tag: 3, Rule: Repeated, Options: []Option{{Name: "packed", Value: BoolValue(true)}}},
+		MessageMapField{Name: "players", KeyTyping: StringType, ValueTyping: &beacon.Messages[0], Tag: 4},
+		EnumMapField{Name: "levels", KeyTyping: Int32Type, ValueTyping: &spec.Enums[0], Tag: 5},
+	}
+	return spec
+}
+
+func TestMessageField_Write(t *testing.T) {
+	spec := referenceSpec()
+	spec.Messages[0].Name = "Activity"
+	spec.Messages[1].Messages[0].Name = "Viewer"
+
+	got, err := spec.Write()
+	if err != nil {
+		t.Fatalf("Spec.Write() error = %v", err)
+	}
+	for _, want := range []string{
+		"repeated Activity events = 1;",
+		"Viewer player = 2;",
+		"repeated Kind kinds = 3 [packed = true];",
+		"map<string, Viewer> players = 4;",
+		"map<int32, Level> levels = 5;",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Spec.Write() = %s, want it to contain %q", got, want)
+		}
+	}
+
+	v, _ := spec.Messages[1].Fields[1].Write()
+	if v != "Viewer player = 2;" {
+		t.Errorf("MessageField.Write() = %q, want %q", v, "Viewer player = 2;")
+	}
+}
+
+func TestMessageField_ShortestName(t *testing.T) {
+	spec := &Spec{
+		Package: "mux",
+		Messages: []Message{
+			{Name: "Beacon", Messages: []Message{{Name: "Event"}}},
+			{Name: "Session", Messages: []Message{{Name: "Beacon"}}},
+		},
+	}
+	spec.Messages[1].Fields = []Field{MessageField{Name: "event", Typing: &spec.Messages[0].Messages[0], Tag: 1}}
+
+	got, err := spec.Write()
+	if err != nil {
+		t.Fatalf("Spec.Write() error = %v", err)
+	}
+	if want := "mux.Beacon.Event event = 1;"; !strings.Contains(got, want) {
+		t.Errorf("Spec.Write() = %s, want it to contain %q", got, want)
+	}
+}
+
+func TestMessageField_Validate(t *testing.T) {
+	outside := Message{Name: "Event"}
+	tests := []struct {
+		name     string
+		field    Field
+		wantCode ErrorCode
+	}{
+		{"Message field", MessageField{Name: "event", Tag: 1}, CodeInvalidType},
+		{"Enum field", EnumField{Name: "level", Tag: 1}, CodeInvalidType},
+		{"Message outside the spec", MessageField{Name: "event", Typing: &outside, Tag: 1}, CodeUnresolvedType},
+		{"Enum outside the spec", EnumField{Name: "level", Typing: &Enum{Name: "Level"}, Tag: 1}, CodeUnresolvedType},
+		{"Packed message field", MessageField{Name: "event", Typing: &outside, Tag: 1, Rule: Repeated, Options: []Option{{Name: "packed", Value: BoolValue(true)}}}, CodeInvalidOption},
+		{"Repeated map of messages", MessageMapField{Name: "events", KeyTyping: StringType, ValueTyping: &outside, Tag: 1, Rule: Repeated}, CodeInvalidRule},
+		{"Enum map with bytes key", EnumMapField{Name: "levels", KeyTyping: BytesType, ValueTyping: &Enum{Name: "Level"}, Tag: 1}, CodeInvalidMapKey},
+	}
+	for _, tt := range tests {
+		spec := &Spec{Package: "mux", Messages: []Message{{Name: "Beacon", Fields: []Field{tt.field}}}}
+		errs, _ := spec.Validate().(ValidationErrors)
+		found := false
+		for _, e := range errs {
+			found = found || e.Code == tt.wantCode
+		}
+		if !found {
+			t.Errorf("%q. Spec.Validate() error = %v, want code %s", tt.name, errs, tt.wantCode)
+		}
+	}
+}
+
+func TestMessageField_Descriptor(t *testing.T) {
+	got, err := referenceSpec().Descriptor("beacon.proto")
+	if err != nil {
+		t.Fatalf("Spec.Descriptor() error = %v", err)
+	}
+	for _, want := range []string{
+		"\x28\x0b\x32\x0a.mux.Event",           // events
+		"\x28\x0e\x32\x10.mux.Beacon.Kind",     // kinds
+		"\x28\x0e\x32\x0a.mux.Level",           // levels entry value
+		"\x28\x0b\x32\x12.mux.Beacon.Player",   // player
+		"\x0a\x0cPlayersEntry\x12\x10\x0a\x03", // players entry
+	} {
+		if !bytes.Contains(got, []byte(want)) {
+			t.Errorf("Spec.Descriptor() = %q, want it to contain %q", got, want)
+		}
+	}
+}
+
+func ExampleMessageField() {
+	spec := &Spec{
+		Package:  "mux",
+		Messages: []Message{{Name: "Event"}, {Name: "Beacon"}},
+	}
+	spec.Messages[1].Fields = []Field{MessageField{Name: "events", Typing: &spec.Messages[0], Tag: 1, Rule: Repeated}}
+	spec.Messages[0].Name = "Activity"
+
+	v, _ := spec.Write()
+	fmt.Print(v)
+	// Output:
+	// syntax = "proto3";
+	// package mux;
+	//
+	// message Activity {
+	// }
+	//
+	// message Beacon {
+	//   repeated Activity events = 1;
+	//
+	// }
+}
@@ -0,0 +1,158 @@
+package proto3
+
+import (
+	"fmt"
+	"sort"
+)
+
+// FieldRegistry is a central repository of field definitions. Each field is defined once, with its name,
+// type, rule, comment and options, and messages reference it by name while assigning their own tag. This
+// keeps fields that appear in many messages consistent with one another.
+type FieldRegistry struct {
+	fields map[NameType]Field
+}
+
+// FieldRef references a registered field by name and assigns the tag it uses within a message.
+type FieldRef struct {
+	Name NameType
+	Tag  TagType
+}
+
+// NewFieldRegistry creates an empty registry.
+func NewFieldRegistry() *FieldRegistry {
+	return &FieldRegistry{fields: make(map[NameType]Field)}
+}
+
+// Register adds field definitions to the registry. The tag of each definition is ignored. A definition
+// is rejected if it is invalid or if a field with the same name is already registered.
+func (r *FieldRegistry) Register(fields ...Field) error {
+	for _, f := range fields {
+		name, _, ok := fieldNameTag(f)
+		if !ok {
+			return fmt.Errorf("Field registry cannot register fields of type %T", f)
+		}
+		if err := withTag(f, MinFieldTag).Validate(); err != nil {
+			return err
+		}
+		if _, exists := r.fields[name]; exists {
+			return fmt.Errorf("Field %s is already registered", name)
+		}
+		r.fields[name] = f
+	}
+	return nil
+}
+
+// Names lists the names of every registered field in alphabetical order.
+func (r *FieldRegistry) Names() []NameType {
+	names := make([]NameType, 0, len(r.fields))
+	for name := range r.fields {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
+
+// Field returns the registered field with the given name, using the given tag.
+func (r *FieldRegistry) Field(name NameType, tag TagType) (Field, error) {
+	f, ok := r.fields[name]
+	if !ok {
+		return nil, fmt.Errorf("Field %s is not registered", name)
+	}
+	return withTag(f, tag), nil
+}
+
+// Fields resolves a list of field references into the fields of a message.
+func (r *FieldRegistry) Fields(refs ...FieldRef) ([]Field, error) {
+	fields := make([]Field, 0, len(refs))
+	for _, ref := range refs {
+		f, err := r.Field(ref.Name, ref.Tag)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// Validate checks that every field sharing a name across the messages of the given specs has the same
+// type, and that fields whose names are registered match their registered definition.
+func (r *FieldRegistry) Validate(specs ...*Spec) error {
+	type use struct {
+		path   string
+		typing string
+	}
+	var errs ValidationErrors
+	seen := make(map[NameType]use)
+	for _, s := range specs {
+		s.walkFields(func(path string, f Field) {
+			name, _, ok := fieldNameTag(f)
+			if !ok {
+				return
+			}
+			typing := fieldTypeName(f)
+			if def, registered := r.fields[name]; registered {
+				if want := fieldTypeName(def); want != typing {
+					errs.add(path, CodeInconsistentField, "Field has type %s but is registered with type %s", typing, want)
+				} else if rule, want := fieldRule(f), fieldRule(def); rule != want {
+					errs.add(path, CodeInconsistentField, "Field has rule %q but is registered with rule %q", rule.Write(), want.Write())
+				}
+				return
+			}
+			if first, exists := seen[name]; exists && first.typing != typing {
+				errs.add(path, CodeInconsistentField, "Field has type %s but %s has type %s", typing, first.path, first.typing)
+			} else if !exists {
+				seen[name] = use{path: path, typing: typing}
+			}
+		})
+	}
+	return errs.err()
+}
+
+// walkFields calls fn for every message field in the spec, including fields within oneofs, along with the
+// path of the field.
+func (s *Spec) walkFields(fn func(path string, f Field)) {
+	var messages func(prefix string, values []Message)
+	messages = func(prefix string, values []Message) {
+		for _, m := range values {
+			path := joinPath(prefix, m.Name)
+			for _, f := range messageFields(m) {
+				name, _, _ := fieldNameTag(f)
+				fn(joinPath(path, string(name)), f)
+			}
+			messages(path, m.Messages)
+		}
+	}
+	messages(s.Package, s.Messages)
+}
+
+// withTag returns a copy of any of the field types defined in this package using the given tag.
+func withTag(f Field, tag TagType) Field {
+	switch f := f.(type) {
+	case ScalarField:
+		f.Tag = tag
+		return f
+	case CustomField:
+		f.Tag = tag
+		return f
+	case MapField:
+		f.Tag = tag
+		return f
+	case CustomMapField:
+		f.Tag = tag
+		return f
+	case MessageField:
+		f.Tag = tag
+		return f
+	case EnumField:
+		f.Tag = tag
+		return f
+	case MessageMapField:
+		f.Tag = tag
+		return f
+	case EnumMapField:
+		f.Tag = tag
+		return f
+	default:
+		return f
+	}
+}
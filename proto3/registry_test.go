@@ -0,0 +1,101 @@
+package proto3_test
+
+import (
+	"reflect"
+	"testing"
+
+	. "github.com/muxinc/protogen/proto3"
+)
+
+func TestFieldRegistry_Fields(t *testing.T) {
+	registry := NewFieldRegistry()
+	err := registry.Register(
+		ScalarField{Name: "view_id", Typing: StringType, Comment: "Unique view identifier"},
+		ScalarField{Name: "timestamps", Typing: Int64Type, Rule: Repeated},
+	)
+	if err != nil {
+		t.Fatalf("FieldRegistry.Register() error = %v", err)
+	}
+
+	got, err := registry.Fields(FieldRef{Name: "view_id", Tag: 1}, FieldRef{Name: "timestamps", Tag: 7})
+	if err != nil {
+		t.Fatalf("FieldRegistry.Fields() error = %v", err)
+	}
+	want := []Field{
+		ScalarField{Name: "view_id", Typing: StringType, Comment: "Unique view identifier", Tag: 1},
+		ScalarField{Name: "timestamps", Typing: Int64Type, Rule: Repeated, Tag: 7},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FieldRegistry.Fields() = %+v, want %+v", got, want)
+	}
+	if names := registry.Names(); !reflect.DeepEqual(names, []NameType{"timestamps", "view_id"}) {
+		t.Errorf("FieldRegistry.Names() = %v", names)
+	}
+
+	if _, err := registry.Fields(FieldRef{Name: "view_idd", Tag: 1}); err == nil {
+		t.Errorf("FieldRegistry.Fields() of an unregistered field succeeded")
+	}
+	if err := registry.Register(ScalarField{Name: "view_id", Typing: BytesType}); err == nil {
+		t.Errorf("FieldRegistry.Register() of a duplicate field succeeded")
+	}
+	if err := registry.Register(ScalarField{Typing: BytesType}); err == nil {
+		t.Errorf("FieldRegistry.Register() of an invalid field succeeded")
+	}
+}
+
+func TestFieldRegistry_Validate(t *testing.T) {
+	registry := NewFieldRegistry()
+	if err := registry.Register(ScalarField{Name: "view_id", Typing: StringType}); err != nil {
+		t.Fatalf("FieldRegistry.Register() error = %v", err)
+	}
+	tests := []struct {
+		name     string
+		spec     Spec
+		wantPath string
+	}{
+		{
+			name: "Consistent fields",
+			spec: Spec{Package: "mux", Messages: []Message{
+				{Name: "Beacon", Fields: []Field{ScalarField{Name: "view_id", Typing: StringType, Tag: 1}, ScalarField{Name: "seq", Typing: Int32Type, Tag: 2}}},
+				{Name: "Event", Fields: []Field{ScalarField{Name: "view_id", Typing: StringType, Tag: 4}, ScalarField{Name: "seq", Typing: Int32Type, Tag: 5}}},
+			}},
+		},
+		{
+			name: "Field differs from registered definition",
+			spec: Spec{Package: "mux", Messages: []Message{
+				{Name: "Beacon", Fields: []Field{ScalarField{Name: "view_id", Typing: BytesType, Tag: 1}}},
+			}},
+			wantPath: "mux.Beacon.view_id",
+		},
+		{
+			name: "Field rule differs from registered definition",
+			spec: Spec{Package: "mux", Messages: []Message{
+				{Name: "Beacon", Fields: []Field{ScalarField{Name: "view_id", Typing: StringType, Rule: Repeated, Tag: 1}}},
+			}},
+			wantPath: "mux.Beacon.view_id",
+		},
+		{
+			name: "Unregistered fields differ across messages",
+			spec: Spec{Package: "mux", Messages: []Message{
+				{Name: "Beacon", Fields: []Field{ScalarField{Name: "seq", Typing: Int32Type, Tag: 2}}},
+				{Name: "Event", Messages: []Message{
+					{Name: "Habitat", OneOfs: []OneOf{{Name: "kind", Fields: []Field{ScalarField{Name: "seq", Typing: Int64Type, Tag: 3}}}}},
+				}},
+			}},
+			wantPath: "mux.Event.Habitat.seq",
+		},
+	}
+	for _, tt := range tests {
+		err := registry.Validate(&tt.spec)
+		if tt.wantPath == "" {
+			if err != nil {
+				t.Errorf("%q. FieldRegistry.Validate() error = %v", tt.name, err)
+			}
+			continue
+		}
+		errs, _ := err.(ValidationErrors)
+		if len(errs) != 1 || errs[0].Path != tt.wantPath || errs[0].Code != CodeInconsistentField {
+			t.Errorf("%q. FieldRegistry.Validate() error = %v, want %s at %s", tt.name, err, CodeInconsistentField, tt.wantPath)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package proto3
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Resolver resolves the type references of specs using protobuf scoping rules: a reference is looked up in
+// the message it is made from and each enclosing message and package in turn, and then in the files the
+// spec imports. The resolver knows the files it is given, by the path they are imported by, and the
+// well-known types distributed with protoc; references into any other import cannot be checked.
+type Resolver struct {
+	files map[string]*Spec
+}
+
+// NewResolver creates a resolver that knows the given files. The specs being resolved may be among them.
+func NewResolver(files ...DescriptorFile) *Resolver {
+	r := &Resolver{files: make(map[string]*Spec)}
+	for _, f := range files {
+		r.files[f.Name] = f.Spec
+	}
+	return r
+}
+
+// Resolve returns the fully-qualified name, without a leading dot, of the message or enum that a reference
+// made from within scope of a spec resolves to. Scope is the package-qualified name of the message the
+// reference is made from, or the package for references made outside of any message. A type declared in a
+// known file that the spec does not import still resolves; Validate warns about the missing import.
+func (r *Resolver) Resolve(s *Spec, scope, ref string) (string, error) {
+	res := r.resolution(s)
+	name, ok := res.typeRef(scope, scope, ref, false)
+	if !ok {
+		if err := res.errs.err(); err != nil {
+			return "", res.errs[0]
+		}
+		return "", &ValidationError{Path: scope, Code: CodeUnresolvedType, Severity: SeverityError, Message: fmt.Sprintf("Type %s may be declared by an import that is not known to the resolver", ref)}
+	}
+	return name, nil
+}
+
+// Validate resolves every type reference in a spec: the types of fields and map values, the request and
+// response types of methods and the messages extended. References that do not resolve or that resolve
+// ambiguously are reported as errors. Imports of known files that nothing is referenced from, and types
+// that resolve to a known file the spec does not import, are reported as warnings. References that do
+// not resolve are assumed to come from an import that is not known to the resolver, if there is one.
+func (r *Resolver) Validate(s *Spec) error {
+	res := r.check(s)
+	for _, i := range s.Imports {
+		if res.known[string(i)] && !res.used[string(i)] {
+			res.errs.warn(s.Package, CodeUnusedImport, "Import %s is not used", i)
+		}
+	}
+	return res.errs.err()
+}
+
+// check resolves every type reference and custom option in a spec.
+func (r *Resolver) check(s *Spec) *resolution {
+	res := r.resolution(s)
+	s = s.withTypeNames()
+	var fields func(scope string, values []Field)
+	fields = func(scope string, values []Field) {
+		for _, f := range values {
+			switch f := f.(type) {
+			case CustomField:
+				res.typeRef(joinPath(scope, string(f.Name)), scope, f.Typing, false)
+			case CustomMapField:
+				res.typeRef(joinPath(scope, string(f.Name)), scope, f.ValueTyping, false)
+			}
+		}
+	}
+	extends := func(scope string, values []Extend) {
+		for _, e := range values {
+			res.typeRef(scope, scope, e.Typing, true)
+			fields(scope, e.Fields)
+		}
+	}
+	var messages func(scope string, values []Message)
+	messages = func(scope string, values []Message) {
+		for _, m := range values {
+			path := joinPath(scope, m.Name)
+			fields(path, m.Fields)
+			for _, o := range m.OneOfs {
+				fields(path, o.Fields)
+			}
+			extends(path, m.Extends)
+			messages(path, m.Messages)
+		}
+	}
+	extends(s.Package, s.Extends)
+	messages(s.Package, s.Messages)
+	for _, svc := range s.Services {
+		for _, m := range svc.Methods {
+			path := joinPath(s.Package, joinPath(string(svc.Name), string(m.Name)))
+			res.typeRef(path, s.Package, m.RequestType, true)
+			res.typeRef(path, s.Package, m.ResponseType, true)
+		}
+	}
+	s.walkOptions(func(path string, scope optionScope, o Option) {
+		if ref, ok := customOptionName(o.Name); ok {
+			res.optionRef(joinPath(path, o.Name), s.Package, ref)
+		}
+	})
+	return res
+}
+
+// resolution tracks the references made from a single spec.
+type resolution struct {
+	visible *symbolTable    // declared by the spec and the known files it imports
+	hidden  *symbolTable    // declared by the known files the spec does not import
+	opaque  bool            // whether the spec imports a file that is not known
+	known   map[string]bool // the imports whose contents are known
+	used    map[string]bool // the imports something was referenced from
+	missing map[string]bool // the known files something was referenced from without importing them
+	errs    ValidationErrors
+}
+
+// resolution gathers the symbols a spec can reference, and those it could reference by importing another
+// known file.
+func (r *Resolver) resolution(s *Spec) *resolution {
+	res := &resolution{
+		visible: newSymbolTable(),
+		hidden:  newSymbolTable(),
+		known:   make(map[string]bool),
+		used:    make(map[string]bool),
+		missing: make(map[string]bool),
+	}
+	res.visible.addFile("", s)
+	for _, i := range s.Imports {
+		if dep, ok := r.files[string(i)]; ok {
+			res.visible.addFile(string(i), dep)
+		} else if _, ok := wellKnownTypes[i]; ok {
+			res.visible.addWellKnown(i)
+		} else {
+			res.opaque = true
+			continue
+		}
+		res.known[string(i)] = true
+	}
+
+	names := make([]string, 0, len(r.files))
+	for name := range r.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !res.known[name] && r.files[name] != s {
+			res.hidden.addFile(name, r.files[name])
+		}
+	}
+	for i := range wellKnownTypes {
+		if !res.known[string(i)] {
+			res.hidden.addWellKnown(i)
+		}
+	}
+	return res
+}
+
+// typeRef resolves a reference made at path from within scope, recording the problems with it. It
+// reports whether the reference resolved.
+func (res *resolution) typeRef(path, scope, ref string, message bool) (string, bool) {
+	name, ok := res.visible.lookup(scope, ref)
+	if ok {
+		sym := res.visible.types[name]
+		for _, file := range sym.files {
+			res.used[file] = true
+		}
+		if len(sym.files) > 1 {
+			res.errs.add(path, CodeAmbiguousType, "Type %s is declared by more than one file: %s", name, strings.Join(fileNames(sym.files), ", "))
+			return name, false
+		}
+		if message && sym.kind != messageSymbol {
+			res.errs.add(path, CodeInvalidType, "Type %s is an enum, not a message", name)
+		}
+		return name, true
+	}
+	if hidden, ok := res.hidden.lookup(scope, ref); ok && (name == "" || name == hidden) {
+		file := res.hidden.types[hidden].files[0]
+		res.missing[file] = true
+		res.errs.warn(path, CodeMissingImport, "Type %s is declared by %s, which is not imported", hidden, file)
+		return hidden, true
+	}
+	if res.opaque {
+		return "", false
+	}
+	if name == "" || strings.HasPrefix(ref, ".") {
+		res.errs.add(path, CodeUnresolvedType, "Type %s does not resolve to a message or enum in the spec or its imports", ref)
+		return "", false
+	}
+
+	// The first component of the reference named a message or package that does not declare the rest of
+	// it, which hides any type of the same name in an outer scope.
+	for outer := strings.TrimSuffix(strings.TrimSuffix(name, ref), "."); outer != ""; {
+		outer = parentScope(outer)
+		if sym, ok := res.visible.types[joinPath(outer, ref)]; ok {
+			for _, file := range sym.files {
+				res.used[file] = true
+			}
+			res.errs.add(path, CodeAmbiguousType, "Type %s resolves to %s, which is not declared; use .%s to refer to the type in an outer scope", ref, name, joinPath(outer, ref))
+			return "", false
+		}
+	}
+	res.errs.add(path, CodeUnresolvedType, "Type %s resolves to %s, which is not declared", ref, name)
+	return "", false
+}
+
+// optionRef resolves the extension a custom option refers to, recording the import it is declared by.
+// Options that do not resolve are reported by Spec.Validate.
+func (res *resolution) optionRef(path, pkg, ref string) {
+	if name, _, ok := resolveExtension(res.visible.extensions, pkg, ref); ok {
+		res.used[res.visible.extensionFiles[name]] = true
+	} else if name, _, ok := resolveExtension(res.hidden.extensions, pkg, ref); ok {
+		file := res.hidden.extensionFiles[name]
+		res.missing[file] = true
+		res.errs.warn(path, CodeMissingImport, "Extension %s is declared by %s, which is not imported", name, file)
+	}
+}
+
+// fileNames describes the files declaring a type, where the spec being resolved is the empty name.
+func fileNames(files []string) []string {
+	names := make([]string, len(files))
+	for i, file := range files {
+		if file == "" {
+			file = "the spec"
+		}
+		names[i] = file
+	}
+	return names
+}
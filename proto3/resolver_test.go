@@ -0,0 +1,180 @@
+package proto3_test
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/muxinc/protogen/proto3"
+)
+
+var resolverEvents = &Spec{
+	Package:  "mux.events",
+	Messages: []Message{{Name: "Event", Fields: []Field{ScalarField{Name: "name", Typing: StringType, Tag: 1}}}},
+	Enums:    []Enum{{Name: "Level", Values: []EnumValue{{Name: "LOW", Tag: 0}}}},
+}
+
+func TestResolver_Validate(t *testing.T) {
+	resolver := NewResolver(
+		DescriptorFile{Name: "events.proto", Spec: resolverEvents},
+		DescriptorFile{Name: "copy.proto", Spec: &Spec{Package: "mux.events", Messages: []Message{{Name: "Event"}}}},
+	)
+	beacon := func(imports []ImportType, fields ...Field) *Spec {
+		return &Spec{
+			Package: "mux",
+			Imports: imports,
+			Messages: []Message{{
+				Name:     "Beacon",
+				Messages: []Message{{Name: "Player", Messages: []Message{{Name: "Event"}}}},
+				Fields:   fields,
+			}},
+		}
+	}
+	tests := []struct {
+		name         string
+		spec         *Spec
+		wantCode     ErrorCode
+		wantSeverity Severity
+	}{
+		{
+			name: "Nested type",
+			spec: beacon(nil, CustomField{Name: "player", Typing: "Player", Tag: 1}),
+		},
+		{
+			name: "Imported types",
+			spec: beacon([]ImportType{"events.proto"}, CustomField{Name: "event", Typing: "events.Event", Tag: 1}, CustomMapField{Name: "levels", KeyTyping: StringType, ValueTyping: "mux.events.Level", Tag: 2}),
+		},
+		{
+			name: "Well-known type",
+			spec: beacon([]ImportType{"google/protobuf/timestamp.proto"}, CustomField{Name: "at", Typing: ".google.protobuf.Timestamp", Tag: 1}),
+		},
+		{
+			name: "Type from an unknown import",
+			spec: beacon([]ImportType{"mux/sessions.proto"}, CustomField{Name: "session", Typing: "Session", Tag: 1}),
+		},
+		{
+			name:     "Unresolved type",
+			spec:     beacon(nil, CustomField{Name: "player", Typing: "Playr", Tag: 1}),
+			wantCode: CodeUnresolvedType,
+		},
+		{
+			name:     "Unresolved map value type",
+			spec:     beacon(nil, CustomMapField{Name: "events", KeyTyping: StringType, ValueTyping: "Evnet", Tag: 1}),
+			wantCode: CodeUnresolvedType,
+		},
+		{
+			name:     "Type captured by a nested message",
+			spec:     beacon(nil, CustomField{Name: "level", Typing: "Player.Level", Tag: 1}),
+			wantCode: CodeUnresolvedType,
+		},
+		{
+			name: "Type hidden by an enclosing scope",
+			spec: &Spec{
+				Package: "mux",
+				Imports: []ImportType{"events.proto"},
+				Messages: []Message{{
+					Name:     "Beacon",
+					Messages: []Message{{Name: "events"}},
+					Fields:   []Field{CustomField{Name: "event", Typing: "events.Event", Tag: 1}},
+				}},
+			},
+			wantCode: CodeAmbiguousType,
+		},
+		{
+			name:     "Type declared by two imports",
+			spec:     beacon([]ImportType{"events.proto", "copy.proto"}, CustomField{Name: "event", Typing: "mux.events.Event", Tag: 1}),
+			wantCode: CodeAmbiguousType,
+		},
+		{
+			name:         "Missing import",
+			spec:         beacon(nil, CustomField{Name: "event", Typing: "events.Event", Tag: 1}),
+			wantCode:     CodeMissingImport,
+			wantSeverity: SeverityWarning,
+		},
+		{
+			name:         "Missing well-known import",
+			spec:         beacon(nil, CustomField{Name: "at", Typing: "google.protobuf.Timestamp", Tag: 1}),
+			wantCode:     CodeMissingImport,
+			wantSeverity: SeverityWarning,
+		},
+		{
+			name:         "Unused import",
+			spec:         beacon([]ImportType{"events.proto"}, CustomField{Name: "player", Typing: "Player", Tag: 1}),
+			wantCode:     CodeUnusedImport,
+			wantSeverity: SeverityWarning,
+		},
+		{
+			name: "Enum request type",
+			spec: &Spec{
+				Package:  "mux",
+				Imports:  []ImportType{"events.proto"},
+				Services: []Service{{Name: "Tracker", Methods: []Method{{Name: "Track", RequestType: "events.Level", ResponseType: "events.Event"}}}},
+			},
+			wantCode: CodeInvalidType,
+		},
+	}
+	for _, tt := range tests {
+		err := resolver.Validate(tt.spec)
+		if tt.wantCode == "" {
+			if err != nil {
+				t.Errorf("%q. Resolver.Validate() error = %v", tt.name, err)
+			}
+			continue
+		}
+		errs, _ := err.(ValidationErrors)
+		if len(errs) != 1 || errs[0].Code != tt.wantCode || errs[0].Severity != tt.wantSeverity {
+			t.Errorf("%q. Resolver.Validate() error = %v, want a single %s with code %s", tt.name, err, tt.wantSeverity, tt.wantCode)
+		}
+	}
+}
+
+func TestResolver_Resolve(t *testing.T) {
+	spec := &Spec{
+		Package: "mux",
+		Imports: []ImportType{"events.proto"},
+		Messages: []Message{{
+			Name:     "Beacon",
+			Messages: []Message{{Name: "Player"}},
+		}},
+	}
+	resolver := NewResolver(DescriptorFile{Name: "events.proto", Spec: resolverEvents})
+	tests := []struct {
+		scope string
+		ref   string
+		want  string
+	}{
+		{"mux.Beacon", "Player", "mux.Beacon.Player"},
+		{"mux.Beacon", "Beacon.Player", "mux.Beacon.Player"},
+		{"mux", ".mux.Beacon", "mux.Beacon"},
+		{"mux", "events.Event", "mux.events.Event"},
+		{"mux.Beacon", "mux.events.Level", "mux.events.Level"},
+		{"mux", "Player", ""},
+	}
+	for _, tt := range tests {
+		got, err := resolver.Resolve(spec, tt.scope, tt.ref)
+		if got != tt.want || (err == nil) != (tt.want != "") {
+			t.Errorf("Resolver.Resolve(%q, %q) = %q, %v, want %q", tt.scope, tt.ref, got, err, tt.want)
+		}
+	}
+}
+
+func ExampleResolver_Validate() {
+	beacon := &Spec{
+		Package: "mux",
+		Imports: []ImportType{"google/protobuf/duration.proto"},
+		Messages: []Message{{
+			Name: "Beacon",
+			Fields: []Field{
+				CustomField{Name: "event", Typing: "Evnet", Tag: 1},
+				CustomField{Name: "at", Typing: "google.protobuf.Timestamp", Tag: 2},
+			},
+		}},
+	}
+	err := NewResolver().Validate(beacon)
+	for _, e := range err.(ValidationErrors) {
+		fmt.Printf("%s: %s\n", e.Severity, e)
+	}
+	// Output:
+	// error: mux.Beacon.event: Type Evnet does not resolve to a message or enum in the spec or its imports
+	// warning: mux.Beacon.at: Type google.protobuf.Timestamp is declared by google/protobuf/timestamp.proto, which is not imported
+	// warning: mux: Import google/protobuf/duration.proto is not used
+}
@@ -0,0 +1,168 @@
+package proto3
+
+import "strings"
+
+// StreamingType specifies whether the request and/or response of an RPC method are streamed.
+type StreamingType uint8
+
+// Streaming modes that can be applied to RPC methods
+// https://grpc.io/docs/what-is-grpc/core-concepts/#rpc-life-cycle
+const (
+	Unary StreamingType = iota
+	ClientStreaming
+	ServerStreaming
+	BidiStreaming
+)
+
+// Service defines a set of RPC methods.
+// https://developers.google.com/protocol-buffers/docs/proto3#services
+type Service struct {
+	Name    NameType  `json:"name"`
+	Comment string    `json:"comment,omitempty"`
+	Methods []Method  `json:"methods,omitempty"`
+	Options []Option  `json:"options,omitempty"`
+	Order   []Element `json:"order,omitempty"` // declaration order, written with OrderDeclared
+}
+
+// Method is a single RPC method within a service. RequestType and ResponseType name messages defined in
+// the spec or in one of its imports.
+type Method struct {
+	Name         NameType      `json:"name"`
+	Comment      string        `json:"comment,omitempty"`
+	RequestType  string        `json:"request"`
+	ResponseType string        `json:"response"`
+	Streaming    StreamingType `json:"streaming,omitempty"`
+	Options      []Option      `json:"options,omitempty"`
+}
+
+// WRITERS
+
+// Write the service specification as a string at a given indentation level.
+func (s Service) Write(level int) (string, error) {
+	if err := s.Validate(); err != nil {
+		return "", err
+	}
+	return printString(func(p *printer) { p.service(level, s, false) })
+}
+
+// Write a Method as a string
+func (m Method) Write() (string, error) {
+	return printString(func(p *printer) { p.method(m) })
+}
+
+// VALIDATORS
+
+// Validate service attributes
+func (s Service) Validate() error {
+	var errs ValidationErrors
+	if s.Name == "" {
+		errs.add("", CodeEmptyName, "Service must have a non-empty name")
+	}
+	errs.merge(string(s.Name), validateOptions(serviceScope, s.Options))
+	names := make(map[NameType]bool)
+	for _, m := range s.Methods {
+		errs.merge(string(s.Name), m.Validate())
+		if names[m.Name] && m.Name != "" {
+			errs.add(joinPath(string(s.Name), string(m.Name)), CodeDuplicateName, "Service has more than one method named %s", m.Name)
+		}
+		names[m.Name] = true
+	}
+	errs.merge(string(s.Name), validateOrder(s.Order, s.elementCounts(), nil))
+	return errs.err()
+}
+
+// elementCounts returns the number of elements of each kind that can be declared within a service.
+func (s Service) elementCounts() map[ElementKind]int {
+	return map[ElementKind]int{
+		ElementOption: len(s.Options),
+		ElementMethod: len(s.Methods),
+	}
+}
+
+// Validate method attributes
+func (m Method) Validate() error {
+	var errs ValidationErrors
+	if m.Name == "" {
+		errs.add("", CodeEmptyName, "Method must have a non-empty name")
+	}
+	if m.RequestType == "" {
+		errs.add(string(m.Name), CodeInvalidType, "Method must have a request type")
+	}
+	if m.ResponseType == "" {
+		errs.add(string(m.Name), CodeInvalidType, "Method must have a response type")
+	}
+	if m.Streaming > BidiStreaming {
+		errs.add(string(m.Name), CodeUnknownStreaming, "Method has an unknown streaming type")
+	}
+	errs.merge(string(m.Name), validateOptions(methodScope, m.Options))
+	return errs.err()
+}
+
+// resolvesMessage reports whether typing names a message defined in the spec, either relative to the
+// package or fully-qualified. A package-qualified name that is not defined locally is assumed to come
+// from an import when the spec has any, unless it starts with the name of a local message or enum. Names
+// of local enums never resolve.
+func (s *Spec) resolvesMessage(typing string) bool {
+	name := typing
+	if strings.HasPrefix(name, ".") {
+		name = strings.TrimPrefix(name, ".")
+		if s.Package != "" {
+			if !strings.HasPrefix(name, s.Package+".") {
+				return len(s.Imports) > 0
+			}
+			name = strings.TrimPrefix(name, s.Package+".")
+		}
+	} else if s.Package != "" && strings.HasPrefix(name, s.Package+".") {
+		local := strings.TrimPrefix(name, s.Package+".")
+		if findMessage(s.Messages, local) {
+			return true
+		}
+		if findEnum(s.Messages, s.Enums, local) {
+			return false
+		}
+	}
+	if findMessage(s.Messages, name) {
+		return true
+	}
+	if findEnum(s.Messages, s.Enums, name) || !strings.Contains(name, ".") {
+		return false
+	}
+	first := strings.SplitN(name, ".", 2)[0]
+	_, local := s.scopeNames()[NameType(first)]
+	return !local && len(s.Imports) > 0
+}
+
+// findMessage walks a dotted path of message names (e.g. Beacon.Event) through nested messages.
+func findMessage(messages []Message, path string) bool {
+	parts := strings.SplitN(path, ".", 2)
+	for _, msg := range messages {
+		if msg.Name != parts[0] {
+			continue
+		}
+		if len(parts) == 1 {
+			return true
+		}
+		return findMessage(msg.Messages, parts[1])
+	}
+	return false
+}
+
+// findEnum walks a dotted path of message names ending in an enum name (e.g. Beacon.Country) through
+// nested messages and their enums.
+func findEnum(messages []Message, enums []Enum, path string) bool {
+	parts := strings.SplitN(path, ".", 2)
+	if len(parts) == 1 {
+		for _, e := range enums {
+			if string(e.Name) == path {
+				return true
+			}
+		}
+		return false
+	}
+	for _, msg := range messages {
+		if msg.Name == parts[0] {
+			return findEnum(msg.Messages, msg.Enums, parts[1])
+		}
+	}
+	return false
+}
@@ -0,0 +1,177 @@
+package proto3_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	. "github.com/muxinc/protogen/proto3"
+)
+
+func TestSpec_ValidateServices(t *testing.T) {
+	messages := []Message{
+		{
+			Name:     "Beacon",
+			Messages: []Message{{Name: "Event"}},
+			Enums:    []Enum{{Name: "Country", Values: []EnumValue{{Name: "US", Tag: 0}}}},
+		},
+	}
+	tests := []struct {
+		name    string
+		spec    Spec
+		wantErr bool
+	}{
+		{
+			name: "Local message types",
+			spec: Spec{
+				Package:  "foo",
+				Messages: messages,
+				Services: []Service{{Name: "Collector", Methods: []Method{
+					{Name: "Send", RequestType: "Beacon", ResponseType: "Beacon.Event"},
+					{Name: "SendAll", RequestType: "foo.Beacon", ResponseType: ".foo.Beacon", Streaming: BidiStreaming},
+				}}},
+			},
+		},
+		{
+			name: "Imported message type",
+			spec: Spec{
+				Imports:  []ImportType{"google/protobuf/empty.proto"},
+				Services: []Service{{Name: "Collector", Methods: []Method{{Name: "Ping", RequestType: "google.protobuf.Empty", ResponseType: "google.protobuf.Empty"}}}},
+			},
+		},
+		{
+			name: "Unresolved message type",
+			spec: Spec{
+				Messages: messages,
+				Services: []Service{{Name: "Collector", Methods: []Method{{Name: "Send", RequestType: "Beacon", ResponseType: "Evnet"}}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Misspelled message type with an import",
+			spec: Spec{
+				Imports:  []ImportType{"google/protobuf/empty.proto"},
+				Messages: messages,
+				Services: []Service{{Name: "Collector", Methods: []Method{{Name: "Send", RequestType: "Beacon", ResponseType: "Evnet"}}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Misspelled nested message type with an import",
+			spec: Spec{
+				Imports:  []ImportType{"google/protobuf/empty.proto"},
+				Messages: messages,
+				Services: []Service{{Name: "Collector", Methods: []Method{{Name: "Send", RequestType: "Beacon.Evnet", ResponseType: "Beacon"}}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Enum used as message type with an import",
+			spec: Spec{
+				Package:  "foo",
+				Imports:  []ImportType{"google/protobuf/empty.proto"},
+				Messages: messages,
+				Services: []Service{{Name: "Collector", Methods: []Method{{Name: "Send", RequestType: "foo.Beacon.Country", ResponseType: "Beacon"}}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Enum used as message type",
+			spec: Spec{
+				Messages: messages,
+				Services: []Service{{Name: "Collector", Methods: []Method{{Name: "Send", RequestType: "Beacon.Country", ResponseType: "Beacon"}}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Wrong package",
+			spec: Spec{
+				Package:  "foo",
+				Messages: messages,
+				Services: []Service{{Name: "Collector", Methods: []Method{{Name: "Send", RequestType: ".bar.Beacon", ResponseType: "Beacon"}}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Duplicate method name",
+			spec: Spec{
+				Messages: messages,
+				Services: []Service{{Name: "Collector", Methods: []Method{
+					{Name: "Send", RequestType: "Beacon", ResponseType: "Beacon"},
+					{Name: "Send", RequestType: "Beacon", ResponseType: "Beacon"},
+				}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Missing service name",
+			spec: Spec{
+				Messages: messages,
+				Services: []Service{{Methods: []Method{{Name: "Send", RequestType: "Beacon", ResponseType: "Beacon"}}}},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		if err := tt.spec.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%q. Spec.Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestParse_Service(t *testing.T) {
+	src := `syntax = "proto3";
+package foo;
+
+message Beacon {
+}
+
+// Receives beacons
+service Collector {
+  rpc Send(Beacon) returns (Beacon);
+  rpc Upload(stream Beacon) returns (Beacon);   // Batch upload
+  rpc Watch(Beacon) returns (stream Beacon);
+  rpc Sync(stream Beacon) returns (stream Beacon);
+}
+`
+	spec, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	got, err := spec.Write()
+	if err != nil {
+		t.Fatalf("Spec.Write() error = %v", err)
+	}
+	if got != src {
+		t.Errorf("Spec.Write() of parsed spec differs from source:\n%s", got)
+	}
+}
+
+func ExampleService_Write() {
+	svc := Service{
+		Name:    "Collector",
+		Comment: "Receives beacons",
+		Methods: []Method{
+			{Name: "Send", RequestType: "Beacon", ResponseType: "google.protobuf.Empty"},
+			{Name: "Upload", RequestType: "Beacon", ResponseType: "google.protobuf.Empty", Streaming: ClientStreaming, Comment: "Batch upload"},
+			{Name: "Watch", RequestType: "google.protobuf.Empty", ResponseType: "Beacon", Streaming: ServerStreaming},
+			{Name: "Sync", RequestType: "Beacon", ResponseType: "Beacon", Streaming: BidiStreaming},
+		},
+	}
+
+	s, err := svc.Write(0)
+	if err != nil {
+		fmt.Println(err)
+	} else {
+		fmt.Println(s)
+	}
+
+	// Output:
+	// // Receives beacons
+	// service Collector {
+	//   rpc Send(Beacon) returns (google.protobuf.Empty);
+	//   rpc Upload(stream Beacon) returns (google.protobuf.Empty);   // Batch upload
+	//   rpc Watch(google.protobuf.Empty) returns (stream Beacon);
+	//   rpc Sync(stream Beacon) returns (stream Beacon);
+	// }
+}
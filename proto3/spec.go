@@ -1,10 +1,10 @@
 package proto3
 
 import (
-	"bytes"
-	"errors"
 	"fmt"
+	"math"
 	"sort"
+	"strings"
 )
 
 // ImportType applies to a package import statement
@@ -13,8 +13,8 @@ type ImportType string
 // NameType applies to a field name
 type NameType string
 
-// TagType applies to a field tag value
-type TagType uint8
+// TagType applies to a field tag value or an enum value number
+type TagType int32
 
 // FieldType applies to the data-type of a field value
 type FieldType uint8
@@ -22,11 +22,24 @@ type FieldType uint8
 // FieldRule specifies additional rules (e.g. repeated) that can be set on a field
 type FieldRule uint8
 
-// Rules that can be applied to message fields
+// Rules that can be applied to message fields. Optional gives a singular field explicit presence, so that
+// an unset field can be distinguished from one set to its default value.
 // https://developers.google.com/protocol-buffers/docs/proto3#specifying-field-rules
 const (
 	None FieldRule = iota
 	Repeated
+	Optional
+)
+
+// Limits on the tag values that can be assigned to message fields. Enum values may use the full range of
+// TagType, including negative numbers.
+// https://developers.google.com/protocol-buffers/docs/proto3#assigning-field-numbers
+const (
+	MinFieldTag          TagType = 1
+	MaxFieldTag          TagType = 1<<29 - 1
+	FirstImplReservedTag TagType = 19000         // first tag reserved for the Protocol Buffers implementation
+	LastImplReservedTag  TagType = 19999         // last tag reserved for the Protocol Buffers implementation
+	MaxTag               TagType = math.MaxInt32 // upper bound of a ReservedTagRange written as "max"
 )
 
 // Built-in field types
@@ -63,153 +76,232 @@ type Field interface {
 
 // Spec represents a top-level Protobuf specification.
 type Spec struct {
-	FileComment string
-	Package     string       // https://developers.google.com/protocol-buffers/docs/proto3#packages
-	GoPackage   string       // https://developers.google.com/protocol-buffers/docs/reference/go-generated#package
-	JavaPackage string       // https://developers.google.com/protocol-buffers/docs/reference/java-generated#package
-	Imports     []ImportType // https://developers.google.com/protocol-buffers/docs/proto3#importing-definitions
-	Messages    []Message
-	Enums       []Enum
+	FileComment string       `json:"comment,omitempty"`
+	Package     string       `json:"package,omitempty"`      // https://developers.google.com/protocol-buffers/docs/proto3#packages
+	GoPackage   string       `json:"go_package,omitempty"`   // https://developers.google.com/protocol-buffers/docs/reference/go-generated#package
+	JavaPackage string       `json:"java_package,omitempty"` // https://developers.google.com/protocol-buffers/docs/reference/java-generated#package
+	Imports     []ImportType `json:"imports,omitempty"`      // https://developers.google.com/protocol-buffers/docs/proto3#importing-definitions
+	Options     []Option     `json:"options,omitempty"`      // https://developers.google.com/protocol-buffers/docs/proto3#options
+	Messages    []Message    `json:"messages,omitempty"`
+	Enums       []Enum       `json:"enums,omitempty"`
+	Extends     []Extend     `json:"extends,omitempty"`  // https://developers.google.com/protocol-buffers/docs/proto3#custom_options
+	Services    []Service    `json:"services,omitempty"` // https://developers.google.com/protocol-buffers/docs/proto3#services
+	Order       []Element    `json:"order,omitempty"`    // declaration order of the top-level definitions, written with OrderDeclared
 }
 
 // Message is a single Protobuf message definition.
 type Message struct {
-	Name           string
-	Comment        string
-	Messages       []Message
-	ReservedValues []Reserved
-	Fields         []Field
-	OneOfs         []OneOf
-	Enums          []Enum
+	Name           string     `json:"name"`
+	Comment        string     `json:"comment,omitempty"`
+	Messages       []Message  `json:"messages,omitempty"`
+	ReservedValues []Reserved `json:"reserved,omitempty"`
+	Fields         []Field    `json:"fields,omitempty"`
+	OneOfs         []OneOf    `json:"oneofs,omitempty"`
+	Enums          []Enum     `json:"enums,omitempty"`
+	Options        []Option   `json:"options,omitempty"`
+	Extends        []Extend   `json:"extends,omitempty"`
+	Order          []Element  `json:"order,omitempty"` // declaration order, written with OrderDeclared
 }
 
-// ReservedName is a field name that is reserved within a message type and cannot be reused.
+// ReservedName is a field or enum value name that is reserved within a message or enum and cannot be reused.
 // https://developers.google.com/protocol-buffers/docs/proto3#reserved
 type ReservedName struct {
-	Name NameType
+	Name    NameType `json:"name"`
+	Comment string   `json:"comment,omitempty"`
 }
 
-// ReservedTagValue is a single field tag value that is reserved within a message type and cannot be reused.
+// ReservedTagValue is a single field tag or enum value number that is reserved within a message or enum and
+// cannot be reused.
 // https://developers.google.com/protocol-buffers/docs/proto3#reserved
 type ReservedTagValue struct {
-	Tag TagType
+	Tag     TagType `json:"tag"`
+	Comment string  `json:"comment,omitempty"`
 }
 
-// ReservedTagRange is a range of numeric tag values that are reserved within a message type and cannot be reused.
+// ReservedTagRange is a range of numeric tag values that are reserved within a message or enum and cannot be
+// reused. An UpperTag of MaxTag reserves every tag from LowerTag up to the maximum (reserved N to max).
 // https://developers.google.com/protocol-buffers/docs/proto3#reserved
 type ReservedTagRange struct {
-	LowerTag TagType
-	UpperTag TagType
+	LowerTag TagType `json:"from"`
+	UpperTag TagType `json:"to"`
+	Comment  string  `json:"comment,omitempty"`
 }
 
 // CustomField is a message field with an unchecked, custom type. This can be used to define fields that
 // use imported types.
 type CustomField struct {
-	Name    NameType
-	Tag     TagType
-	Rule    FieldRule
-	Comment string
-	Typing  string
+	Name    NameType  `json:"name"`
+	Tag     TagType   `json:"tag,omitempty"`
+	Rule    FieldRule `json:"rule,omitempty"`
+	Comment string    `json:"comment,omitempty"`
+	Typing  string    `json:"type"`
+	Options []Option  `json:"options,omitempty"`
 }
 
 // ScalarField is a message field that uses a built-in protobuf type.
 type ScalarField struct {
-	Name    NameType
-	Tag     TagType
-	Rule    FieldRule
-	Comment string
-	Typing  FieldType
+	Name    NameType  `json:"name"`
+	Tag     TagType   `json:"tag,omitempty"`
+	Rule    FieldRule `json:"rule,omitempty"`
+	Comment string    `json:"comment,omitempty"`
+	Typing  FieldType `json:"type"`
+	Options []Option  `json:"options,omitempty"`
 }
 
 // MapField is a message field that maps built-in protobuf type as key-value pairs
 // https://developers.google.com/protocol-buffers/docs/proto3#maps
 type MapField struct {
-	Name        NameType
-	Tag         TagType
-	Rule        FieldRule
-	Comment     string
-	KeyTyping   FieldType
-	ValueTyping FieldType
+	Name        NameType  `json:"name"`
+	Tag         TagType   `json:"tag,omitempty"`
+	Rule        FieldRule `json:"rule,omitempty"`
+	Comment     string    `json:"comment,omitempty"`
+	KeyTyping   FieldType `json:"key"`
+	ValueTyping FieldType `json:"value"`
+	Options     []Option  `json:"options,omitempty"`
 }
 
 // CustomMapField is a message field that maps between a built-in protobuf type as
 // the key and a custom type as the value.
 // https://developers.google.com/protocol-buffers/docs/proto3#maps
 type CustomMapField struct {
-	Name        NameType
-	Tag         TagType
-	Rule        FieldRule
-	Comment     string
-	KeyTyping   FieldType
-	ValueTyping string
+	Name        NameType  `json:"name"`
+	Tag         TagType   `json:"tag,omitempty"`
+	Rule        FieldRule `json:"rule,omitempty"`
+	Comment     string    `json:"comment,omitempty"`
+	KeyTyping   FieldType `json:"key"`
+	ValueTyping string    `json:"value"`
+	Options     []Option  `json:"options,omitempty"`
+}
+
+// MessageField is a message field whose type is a message of the spec. The field refers to the message
+// itself, so the type written for the field follows the message when it is renamed. The reference is the
+// address of the message within the spec, which goes stale when appending to the slice holding the message
+// reallocates it.
+type MessageField struct {
+	Name    NameType  `json:"name"`
+	Tag     TagType   `json:"tag,omitempty"`
+	Rule    FieldRule `json:"rule,omitempty"`
+	Comment string    `json:"comment,omitempty"`
+	Typing  *Message  `json:"-"`
+	Options []Option  `json:"options,omitempty"`
+}
+
+// EnumField is a message field whose type is an enum of the spec. The field refers to the enum itself, so
+// the type written for the field follows the enum when it is renamed. As for MessageField, the reference
+// goes stale when appending to the slice holding the enum reallocates it.
+type EnumField struct {
+	Name    NameType  `json:"name"`
+	Tag     TagType   `json:"tag,omitempty"`
+	Rule    FieldRule `json:"rule,omitempty"`
+	Comment string    `json:"comment,omitempty"`
+	Typing  *Enum     `json:"-"`
+	Options []Option  `json:"options,omitempty"`
+}
+
+// MessageMapField is a message field that maps between a built-in protobuf type as the key and a message
+// of the spec as the value.
+// https://developers.google.com/protocol-buffers/docs/proto3#maps
+type MessageMapField struct {
+	Name        NameType  `json:"name"`
+	Tag         TagType   `json:"tag,omitempty"`
+	Rule        FieldRule `json:"rule,omitempty"`
+	Comment     string    `json:"comment,omitempty"`
+	KeyTyping   FieldType `json:"key"`
+	ValueTyping *Message  `json:"-"`
+	Options     []Option  `json:"options,omitempty"`
+}
+
+// EnumMapField is a message field that maps between a built-in protobuf type as the key and an enum of
+// the spec as the value. Enums can only be used as map values, never as keys.
+// https://developers.google.com/protocol-buffers/docs/proto3#maps
+type EnumMapField struct {
+	Name        NameType  `json:"name"`
+	Tag         TagType   `json:"tag,omitempty"`
+	Rule        FieldRule `json:"rule,omitempty"`
+	Comment     string    `json:"comment,omitempty"`
+	KeyTyping   FieldType `json:"key"`
+	ValueTyping *Enum     `json:"-"`
+	Options     []Option  `json:"options,omitempty"`
 }
 
 // OneOf defines a set of fields for which only the most-recently-set field will be used.
 // https://developers.google.com/protocol-buffers/docs/proto3#oneof
 type OneOf struct {
-	Name    NameType
-	Fields  []Field
-	Comment string
+	Name    NameType  `json:"name"`
+	Fields  []Field   `json:"fields,omitempty"`
+	Comment string    `json:"comment,omitempty"`
+	Options []Option  `json:"options,omitempty"`
+	Order   []Element `json:"order,omitempty"` // declaration order, written with OrderDeclared
 }
 
 // Enum defines an enumeration type of a set of values.
 // https://developers.google.com/protocol-buffers/docs/proto3#enum
 type Enum struct {
-	Name       NameType
-	Values     []EnumValue
-	AllowAlias bool
-	Comment    string
+	Name           NameType    `json:"name"`
+	Values         []EnumValue `json:"values,omitempty"`
+	ReservedValues []Reserved  `json:"reserved,omitempty"`
+	AllowAlias     bool        `json:"allow_alias,omitempty"`
+	Comment        string      `json:"comment,omitempty"`
+	Options        []Option    `json:"options,omitempty"`
+	Order          []Element   `json:"order,omitempty"` // declaration order, written with OrderDeclared
 }
 
 // EnumValue describes a single enumerated value within an enumeration.
 // https://developers.google.com/protocol-buffers/docs/proto3#enum
 type EnumValue struct {
-	Name    NameType
-	Tag     TagType
-	Comment string
+	Name    NameType `json:"name"`
+	Tag     TagType  `json:"tag"`
+	Comment string   `json:"comment,omitempty"`
+	Options []Option `json:"options,omitempty"`
+}
+
+// ElementKind identifies the kind of an element declared at the top level of a file or within the body of a
+// message, enum, oneof or service.
+type ElementKind string
+
+// Kinds of element. Each refers to the slice of a Spec, Message, Enum, OneOf or Service holding elements of
+// its kind.
+const (
+	ElementOption   ElementKind = "option"   // Options
+	ElementMessage  ElementKind = "message"  // Messages
+	ElementEnum     ElementKind = "enum"     // Enums
+	ElementExtend   ElementKind = "extend"   // Extends
+	ElementService  ElementKind = "service"  // Spec.Services
+	ElementSyntax   ElementKind = "syntax"   // the syntax statement, listed only for the blank line before it
+	ElementPackage  ElementKind = "package"  // Spec.Package, listed only for the blank line before it
+	ElementReserved ElementKind = "reserved" // ReservedValues
+	ElementField    ElementKind = "field"    // Fields
+	ElementOneOf    ElementKind = "oneof"    // Message.OneOfs
+	ElementValue    ElementKind = "value"    // Enum.Values
+	ElementMethod   ElementKind = "method"   // Service.Methods
+)
+
+// Layout markers, which an Order lists between its elements to keep the layout of a parsed file. They refer
+// to no element, so their Index is zero.
+const (
+	ElementBlank    ElementKind = "blank"    // a blank line separates the elements on either side
+	ElementComma    ElementKind = "comma"    // the reserved values on either side share a reserved statement
+	ElementTrailing ElementKind = "trailing" // the comment of the element before follows it on its line
+	ElementBraces   ElementKind = "braces"   // the method before has an empty body, {}, rather than a semicolon
+)
+
+// Element refers to an element declared at the top level or within a body by its kind and its index within
+// the slice holding elements of that kind, for example {ElementField, 0} for Message.Fields[0]. The Order of
+// a spec, message, enum, oneof or service lists its elements in the order they were declared, so that they
+// can be written in that order rather than grouped by kind.
+type Element struct {
+	Kind  ElementKind `json:"kind"`
+	Index int         `json:"index"`
 }
 
 // WRITERS
 
 // Write turns the specification into a string.
 func (s *Spec) Write() (string, error) {
-	if err := s.Validate(); err != nil {
+	var buffer strings.Builder
+	if _, err := s.WriteTo(&buffer); err != nil {
 		return "", err
 	}
-
-	var buffer bytes.Buffer
-	if len(s.FileComment) > 0 {
-		buffer.WriteString(fmt.Sprintf("// %s\n", s.FileComment))
-	}
-	buffer.WriteString("syntax = \"proto3\";\n")
-	if len(s.Package) > 0 {
-		buffer.WriteString(fmt.Sprintf("package %s;\n", s.Package))
-	}
-	if len(s.GoPackage) > 0 {
-		buffer.WriteString(fmt.Sprintf("option go_package = \"%s\";\n", s.GoPackage))
-	}
-	if len(s.JavaPackage) > 0 {
-		buffer.WriteString(fmt.Sprintf("option java_package = \"%s\";\n", s.JavaPackage))
-	}
-	for _, importPackage := range s.Imports {
-		buffer.WriteString(fmt.Sprintf("import \"%s\";\n", importPackage))
-	}
-
-	for _, v := range s.Enums {
-		v, err := v.Write(0)
-		if err != nil {
-			return "", err
-		}
-		buffer.WriteString(fmt.Sprintf("\n%s\n", v))
-	}
-
-	for _, msg := range s.Messages {
-		msgSpec, err := msg.Write(0) // write message at level zero (0)
-		if err != nil {
-			return "", err
-		}
-		buffer.WriteString(fmt.Sprintf("\n%s\n", msgSpec))
-	}
 	return buffer.String(), nil
 }
 
@@ -218,71 +310,12 @@ func (m *Message) Write(level int) (string, error) {
 	if err := m.Validate(); err != nil {
 		return "", err
 	}
-
-	var buffer bytes.Buffer
-	if m.Comment != "" {
-		buffer.WriteString(fmt.Sprintf("%s// %s\n", indentLevel(level), m.Comment))
-	}
-	buffer.WriteString(fmt.Sprintf("%smessage %s {\n", indentLevel(level), m.Name))
-
-	// NESTED MESSAGE TYPES
-	for _, msg := range m.Messages {
-		msgSpec, err := msg.Write(level + 1)
-		if err != nil {
-			return "", err
-		}
-		buffer.WriteString(fmt.Sprintf("%s\n\n", msgSpec))
-	}
-
-	// ENUMS
-	for _, v := range m.Enums {
-		v, err := v.Write(level + 1)
-		if err != nil {
-			return "", err
-		}
-		buffer.WriteString(fmt.Sprintf("%s\n\n", v))
-	}
-
-	// RESERVED TAGS
-	if len(m.ReservedValues) > 0 {
-		for _, reservedValue := range m.ReservedValues {
-			v, err := reservedValue.Write()
-			if err != nil {
-				return "", err
-			}
-			buffer.WriteString(fmt.Sprintf("%sreserved %s;\n", indentLevel(level+1), v))
-		}
-		buffer.WriteString("\n")
-	}
-
-	// FIELDS
-	if len(m.Fields) > 0 {
-		for _, v := range m.Fields {
-			v, err := v.Write()
-			if err != nil {
-				return "", err
-			}
-			buffer.WriteString(fmt.Sprintf("%s%s\n", indentLevel(level+1), v))
-		}
-		buffer.WriteString("\n")
-	}
-
-	// ONE-OF FIELDS
-	for _, v := range m.OneOfs {
-		v, err := v.Write(level + 1)
-		if err != nil {
-			return "", err
-		}
-		buffer.WriteString(fmt.Sprintf("%s\n", v))
-	}
-
-	buffer.WriteString(fmt.Sprintf("%s}", indentLevel(level)))
-	return buffer.String(), nil
+	return printString(func(p *printer) { p.message(level, m, false) })
 }
 
 // Write a ReservedName as a string
 func (r ReservedName) Write() (string, error) {
-	return fmt.Sprintf("\"%s\"", r.Name), nil
+	return quoteString(string(r.Name)), nil
 }
 
 // Write a ReservedTagValue as a string
@@ -292,85 +325,96 @@ func (r ReservedTagValue) Write() (string, error) {
 
 // Write a ReservedTagRange as a string
 func (r ReservedTagRange) Write() (string, error) {
+	if r.UpperTag == MaxTag {
+		return fmt.Sprintf("%d to max", r.LowerTag), nil
+	}
 	return fmt.Sprintf("%d to %d", r.LowerTag, r.UpperTag), nil
 }
 
+// reservedComment returns the comment of a reserved value of one of the types defined in this package.
+func reservedComment(r Reserved) string {
+	switch r := r.(type) {
+	case ReservedName:
+		return r.Comment
+	case ReservedTagValue:
+		return r.Comment
+	case ReservedTagRange:
+		return r.Comment
+	default:
+		return ""
+	}
+}
+
+// withReservedComment returns a reserved value of one of the types defined in this package with the given
+// comment.
+func withReservedComment(r Reserved, comment string) Reserved {
+	switch v := r.(type) {
+	case ReservedName:
+		v.Comment = comment
+		return v
+	case ReservedTagValue:
+		v.Comment = comment
+		return v
+	case ReservedTagRange:
+		v.Comment = comment
+		return v
+	default:
+		return r
+	}
+}
+
 // Write a CustomField as a string
 func (c CustomField) Write() (string, error) {
-	v := fmt.Sprintf("%s%s %s = %d;", c.Rule.Write(), c.Typing, c.Name, c.Tag)
-	if c.Comment != "" {
-		v = fmt.Sprintf("%s   // %s", v, c.Comment)
-	}
-	return v, nil
+	return printString(func(p *printer) { p.field(c) })
 }
 
 // Write a ScalarField as a string
 func (s ScalarField) Write() (string, error) {
-	v := fmt.Sprintf("%s%s %s = %d;", s.Rule.Write(), s.Typing.Write(), s.Name, s.Tag)
-	if s.Comment != "" {
-		v = fmt.Sprintf("%s   // %s", v, s.Comment)
-	}
-	return v, nil
+	return printString(func(p *printer) { p.field(s) })
 }
 
 // Write a MapField as a string
 func (m MapField) Write() (string, error) {
-	v := fmt.Sprintf("%smap<%s, %s> %s = %d;", m.Rule.Write(), m.KeyTyping.Write(), m.ValueTyping.Write(), m.Name, m.Tag)
-	if m.Comment != "" {
-		v = fmt.Sprintf("%s   // %s", v, m.Comment)
-	}
-	return v, nil
+	return printString(func(p *printer) { p.field(m) })
 }
 
 // Write a CustomMapField as a string
 func (c CustomMapField) Write() (string, error) {
-	v := fmt.Sprintf("%smap<%s, %s> %s = %d;", c.Rule.Write(), c.KeyTyping.Write(), c.ValueTyping, c.Name, c.Tag)
-	if c.Comment != "" {
-		v = fmt.Sprintf("%s   // %s", v, c.Comment)
-	}
-	return v, nil
+	return printString(func(p *printer) { p.field(c) })
 }
 
-// Write a CustomMapField as a string
-func (e Enum) Write(level int) (string, error) {
-	sort.Sort(e)
+// Write a MessageField as a string, naming the message by its simple name. Within a spec, the message is
+// named by the shortest name that resolves to it.
+func (m MessageField) Write() (string, error) {
+	return m.custom(messageName(m.Typing)).Write()
+}
 
-	var v string
-	if e.Comment != "" {
-		v = fmt.Sprintf("%s// %s\n", indentLevel(level), e.Comment)
-	}
-	v = fmt.Sprintf("%s%senum %s {\n", v, indentLevel(level), e.Name)
-	if e.AllowAlias {
-		v = fmt.Sprintf("%s%soption allow_alias = true;\n", v, indentLevel(level+1))
-	}
-	for _, enumValue := range e.Values {
-		v = fmt.Sprintf("%s%s%s = %d;", v, indentLevel(level+1), enumValue.Name, enumValue.Tag)
-		if enumValue.Comment != "" {
-			v = fmt.Sprintf("%s   // %s", v, enumValue.Comment)
-		}
-		v = fmt.Sprintf("%s\n", v)
-	}
-	v = fmt.Sprintf("%s%s}", v, indentLevel(level))
-	return v, nil
+// Write an EnumField as a string, naming the enum by its simple name. Within a spec, the enum is named by
+// the shortest name that resolves to it.
+func (e EnumField) Write() (string, error) {
+	return e.custom(enumName(e.Typing)).Write()
 }
 
-func (o OneOf) Write(level int) (string, error) {
-	var v string
-	if o.Comment != "" {
-		v = fmt.Sprintf("%s// %s\n", indentLevel(level), o.Comment)
-	}
-	v = fmt.Sprintf("%s%soneof %s {\n", v, indentLevel(level), o.Name)
+// Write a MessageMapField as a string, naming the message by its simple name. Within a spec, the message
+// is named by the shortest name that resolves to it.
+func (m MessageMapField) Write() (string, error) {
+	return m.custom(messageName(m.ValueTyping)).Write()
+}
 
-	for _, f := range o.Fields {
-		s, err := f.Write()
-		if err != nil {
-			return "", err
-		}
-		v = fmt.Sprintf("%s%s%s\n", v, indentLevel(level+1), s)
-	}
+// Write an EnumMapField as a string, naming the enum by its simple name. Within a spec, the enum is named
+// by the shortest name that resolves to it.
+func (e EnumMapField) Write() (string, error) {
+	return e.custom(enumName(e.ValueTyping)).Write()
+}
 
-	v = fmt.Sprintf("%s%s}", v, indentLevel(level))
-	return v, nil
+// Write an Enum as a string, sorting its values with the zero value first and the others by tag.
+func (e Enum) Write(level int) (string, error) {
+	return printString(func(p *printer) { p.enum(level, e, false) })
+}
+
+// Write a OneOf as a string at a given indentation level.
+func (o OneOf) Write(level int) (string, error) {
+	return printString(func(p *printer) { p.oneof(level, o, false) })
 }
 
 // Write a FieldRule as a string
@@ -380,6 +424,8 @@ func (f *FieldRule) Write() string {
 		return ""
 	case Repeated:
 		return "repeated "
+	case Optional:
+		return "optional "
 	default:
 		return ""
 	}
@@ -425,172 +471,783 @@ func (f *FieldType) Write() string {
 
 // VALIDATORS
 
-// Validate spec
+// Validate spec, collecting every problem found into ValidationErrors rather than stopping at the first.
 func (s *Spec) Validate() error {
-	if len(s.Messages) == 0 {
-		return errors.New("Spec must contain at least one message")
+	var errs ValidationErrors
+	if len(s.Messages) == 0 && len(s.Services) == 0 {
+		errs.add(s.Package, CodeEmptySpec, "Spec must contain at least one message or service")
+	}
+	errs.merge(s.Package, validateOptions(fileScope, s.Options))
+	if s.GoPackage != "" && hasOption(s.Options, "go_package") {
+		errs.add(joinPath(s.Package, "go_package"), CodeDuplicateOption, "Option go_package is set by both GoPackage and Options")
+	}
+	if s.JavaPackage != "" && hasOption(s.Options, "java_package") {
+		errs.add(joinPath(s.Package, "java_package"), CodeDuplicateOption, "Option java_package is set by both JavaPackage and Options")
 	}
 	for _, msg := range s.Messages {
-		if err := msg.Validate(); err != nil {
-			return err
-		}
+		errs.merge(s.Package, msg.Validate())
 	}
 	for _, v := range s.Enums {
-		if err := v.Validate(); err != nil {
-			return err
+		errs.merge(s.Package, v.Validate())
+	}
+	errs.merge(s.Package, validateEnumValueScope(s.Enums, s.scopeNames()))
+	for _, v := range s.Extends {
+		errs.merge(s.Package, v.Validate())
+	}
+	errs.merge("", s.validateExtensions())
+	errs.merge("", s.validateReferences())
+	errs.merge(s.Package, validateOrder(s.Order, s.elementCounts(), nil))
+	for _, v := range s.Services {
+		errs.merge(s.Package, v.Validate())
+		for _, method := range v.Methods {
+			path := joinPath(s.Package, joinPath(string(v.Name), string(method.Name)))
+			if method.RequestType != "" && !s.resolvesMessage(method.RequestType) {
+				errs.add(path, CodeUnresolvedType, "Request type %s does not resolve to a message in the spec or its imports", method.RequestType)
+			}
+			if method.ResponseType != "" && !s.resolvesMessage(method.ResponseType) {
+				errs.add(path, CodeUnresolvedType, "Response type %s does not resolve to a message in the spec or its imports", method.ResponseType)
+			}
 		}
 	}
-	return nil
+	return errs.err()
+}
+
+// scopeNames describes the top-level messages and enums of the spec, by name.
+func (s *Spec) scopeNames() map[NameType]string {
+	names := make(map[NameType]string)
+	for _, v := range s.Messages {
+		names[NameType(v.Name)] = "message " + v.Name
+	}
+	for _, v := range s.Enums {
+		names[v.Name] = "enum " + string(v.Name)
+	}
+	return names
 }
 
 // Validate the attributes of a message, including all children that can be validated individually.
 func (m Message) Validate() error {
+	var errs ValidationErrors
 	if m.Name == "" {
-		return errors.New("Message name cannot be empty")
+		errs.add("", CodeEmptyName, "Message name cannot be empty")
 	}
+	errs.merge(m.Name, validateOptions(messageScope, m.Options))
 	for _, v := range m.Fields {
-		if err := v.Validate(); err != nil {
-			return err
-		}
+		errs.merge(m.Name, v.Validate())
 	}
 	for _, v := range m.Messages {
-		if err := v.Validate(); err != nil {
-			return err
-		}
+		errs.merge(m.Name, v.Validate())
 	}
 	for _, v := range m.ReservedValues {
-		if err := v.Validate(); err != nil {
-			return err
-		}
+		errs.merge(m.Name, v.Validate())
 	}
 	for _, v := range m.Enums {
-		if err := v.Validate(); err != nil {
-			return err
+		errs.merge(m.Name, v.Validate())
+	}
+	for _, v := range m.Extends {
+		errs.merge(m.Name, v.Validate())
+	}
+	for _, v := range m.OneOfs {
+		errs.merge(m.Name, v.Validate())
+	}
+	errs.merge(m.Name, validateReservedOverlap(m.ReservedValues))
+	errs.merge(m.Name, m.validateOneOfNames())
+	errs.merge(m.Name, validateOrder(m.Order, m.elementCounts(), m.ReservedValues))
+	errs.merge(m.Name, m.validateFieldUniqueness())
+	errs.merge(m.Name, validateEnumValueScope(m.Enums, m.scopeNames()))
+	return errs.err()
+}
+
+// scopeNames describes the elements declared within the message other than enum values, by name.
+func (m Message) scopeNames() map[NameType]string {
+	names := make(map[NameType]string)
+	for _, v := range m.Messages {
+		names[NameType(v.Name)] = "message " + v.Name
+	}
+	for _, v := range m.Enums {
+		names[v.Name] = "enum " + string(v.Name)
+	}
+	for _, v := range m.OneOfs {
+		names[v.Name] = "oneof " + string(v.Name)
+	}
+	for _, f := range messageFields(m) {
+		if name, _, ok := fieldNameTag(f); ok {
+			names[name] = "field " + string(name)
+		}
+	}
+	return names
+}
+
+// validateOneOfNames checks that each oneof of the message has a name of its own, which is shared with
+// neither a field nor another oneof.
+func (m Message) validateOneOfNames() error {
+	var errs ValidationErrors
+	names := make(map[NameType]string)
+	for _, f := range messageFields(m) {
+		if name, _, ok := fieldNameTag(f); ok {
+			names[name] = "field"
+		}
+	}
+	for _, o := range m.OneOfs {
+		if o.Name == "" {
+			continue
+		}
+		if kind, exists := names[o.Name]; exists {
+			errs.add(string(o.Name), CodeDuplicateName, "OneOf has the same name as a %s of the message", kind)
+			continue
+		}
+		names[o.Name] = "oneof"
+	}
+	return errs.err()
+}
+
+// validateFieldUniqueness checks that no two fields of the message, including those declared within its
+// oneofs, share a name or tag, and that no field uses a reserved name or tag.
+func (m Message) validateFieldUniqueness() error {
+	var errs ValidationErrors
+	names := make(map[NameType]bool)
+	tags := make(map[TagType]NameType)
+	for _, f := range messageFields(m) {
+		name, tag, ok := fieldNameTag(f)
+		if !ok {
+			continue
+		}
+		if names[name] && name != "" {
+			errs.add(string(name), CodeDuplicateName, "Message has more than one field named %s", name)
+		}
+		names[name] = true
+		if other, exists := tags[tag]; exists {
+			errs.add(string(name), CodeDuplicateTag, "Field uses tag %d, which is already used by field %s", tag, other)
+		} else {
+			tags[tag] = name
+		}
+
+		errs.merge(string(name), validateReservedUse(m.ReservedValues, "Field", name, tag))
+	}
+	return errs.err()
+}
+
+// validateReservedUse checks that an element, described by what (e.g. Field), does not use a reserved name
+// or tag.
+func validateReservedUse(reserved []Reserved, what string, name NameType, tag TagType) error {
+	var errs ValidationErrors
+	for _, r := range reserved {
+		switch r := r.(type) {
+		case ReservedName:
+			if r.Name == name {
+				errs.add("", CodeReservedName, "%s uses reserved name %s", what, name)
+			}
+		case ReservedTagValue:
+			if r.Tag == tag {
+				errs.add("", CodeReservedTag, "%s uses reserved tag %d", what, tag)
+			}
+		case ReservedTagRange:
+			if tag >= r.LowerTag && tag <= r.UpperTag {
+				errs.add("", CodeReservedTag, "%s uses tag %d from reserved range %d to %d", what, tag, r.LowerTag, r.UpperTag)
+			}
 		}
 	}
-	return nil
+	return errs.err()
+}
+
+// validateReservedOverlap checks that no tag or name is reserved more than once within a message or enum.
+func validateReservedOverlap(reserved []Reserved) error {
+	type tagRange struct {
+		lower, upper TagType
+	}
+	var errs ValidationErrors
+	var ranges []tagRange
+	names := make(map[NameType]bool)
+	for _, r := range reserved {
+		switch r := r.(type) {
+		case ReservedName:
+			if names[r.Name] {
+				errs.add("", CodeReservedOverlap, "Name %s is reserved more than once", r.Name)
+			}
+			names[r.Name] = true
+		case ReservedTagValue:
+			ranges = append(ranges, tagRange{r.Tag, r.Tag})
+		case ReservedTagRange:
+			ranges = append(ranges, tagRange{r.LowerTag, r.UpperTag})
+		}
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].lower < ranges[j].lower })
+	for i := 1; i < len(ranges); i++ {
+		if ranges[i].lower <= ranges[i-1].upper {
+			errs.add("", CodeReservedOverlap, "Reserved tags overlap at %d", ranges[i].lower)
+		}
+	}
+	return errs.err()
+}
+
+// fieldNameTag returns the name and tag of any of the field types defined in this package.
+func fieldNameTag(f Field) (NameType, TagType, bool) {
+	switch f := f.(type) {
+	case ScalarField:
+		return f.Name, f.Tag, true
+	case CustomField:
+		return f.Name, f.Tag, true
+	case MapField:
+		return f.Name, f.Tag, true
+	case CustomMapField:
+		return f.Name, f.Tag, true
+	case MessageField:
+		return f.Name, f.Tag, true
+	case EnumField:
+		return f.Name, f.Tag, true
+	case MessageMapField:
+		return f.Name, f.Tag, true
+	case EnumMapField:
+		return f.Name, f.Tag, true
+	default:
+		return "", 0, false
+	}
+}
+
+// fieldComment returns the comment of any of the field types defined in this package.
+func fieldComment(f Field) string {
+	switch f := f.(type) {
+	case ScalarField:
+		return f.Comment
+	case CustomField:
+		return f.Comment
+	case MapField:
+		return f.Comment
+	case CustomMapField:
+		return f.Comment
+	case MessageField:
+		return f.Comment
+	case EnumField:
+		return f.Comment
+	case MessageMapField:
+		return f.Comment
+	case EnumMapField:
+		return f.Comment
+	default:
+		return ""
+	}
+}
+
+// fieldRule returns the rule of any of the field types defined in this package.
+func fieldRule(f Field) FieldRule {
+	switch f := f.(type) {
+	case ScalarField:
+		return f.Rule
+	case CustomField:
+		return f.Rule
+	case MapField:
+		return f.Rule
+	case CustomMapField:
+		return f.Rule
+	case MessageField:
+		return f.Rule
+	case EnumField:
+		return f.Rule
+	case MessageMapField:
+		return f.Rule
+	case EnumMapField:
+		return f.Rule
+	default:
+		return None
+	}
+}
+
+// fieldTypeName returns the declared type of any of the field types defined in this package, as it is
+// written in a specification (e.g. string, Event or map<string, Event>).
+func fieldTypeName(f Field) string {
+	switch f := f.(type) {
+	case ScalarField:
+		return f.Typing.Write()
+	case CustomField:
+		return f.Typing
+	case MapField:
+		return fmt.Sprintf("map<%s, %s>", f.KeyTyping.Write(), f.ValueTyping.Write())
+	case CustomMapField:
+		return fmt.Sprintf("map<%s, %s>", f.KeyTyping.Write(), f.ValueTyping)
+	case MessageField:
+		return messageName(f.Typing)
+	case EnumField:
+		return enumName(f.Typing)
+	case MessageMapField:
+		return fmt.Sprintf("map<%s, %s>", f.KeyTyping.Write(), messageName(f.ValueTyping))
+	case EnumMapField:
+		return fmt.Sprintf("map<%s, %s>", f.KeyTyping.Write(), enumName(f.ValueTyping))
+	default:
+		return ""
+	}
 }
 
 // Validate field attributes
 func (s ScalarField) Validate() error {
+	var errs ValidationErrors
 	if s.Name == "" {
-		return errors.New("Scalar field must have a non-empty name")
+		errs.add("", CodeEmptyName, "Scalar field must have a non-empty name")
+	}
+	if s.Typing > BytesType {
+		errs.add(string(s.Name), CodeInvalidType, "Scalar field has an unknown type")
 	}
-	return nil
+	if s.Rule > Optional {
+		errs.add(string(s.Name), CodeInvalidRule, "Scalar field has an unknown rule")
+	}
+	errs.merge(string(s.Name), validateFieldTag(s.Tag))
+	errs.merge(string(s.Name), validateFieldOptions(s.Options, s.Rule == Repeated && s.Typing != StringType && s.Typing != BytesType))
+	return errs.err()
 }
 
 // Validate field attributes
 func (r ReservedName) Validate() error {
+	var errs ValidationErrors
 	if r.Name == "" {
-		return errors.New("ReservedName field must have a non-empty name")
+		errs.add("", CodeEmptyName, "ReservedName field must have a non-empty name")
 	}
-	return nil
+	return errs.err()
 }
 
 // Validate field attributes
 func (r ReservedTagValue) Validate() error {
-	return nil
+	var errs ValidationErrors
+	if r.Tag < MinFieldTag || r.Tag > MaxFieldTag {
+		errs.add("", CodeInvalidTag, "ReservedTagValue tag %d must be between %d and %d", r.Tag, MinFieldTag, MaxFieldTag)
+	}
+	return errs.err()
 }
 
 // Validate field attributes
 func (r ReservedTagRange) Validate() error {
-	if r.LowerTag < 0 {
-		return errors.New("ReservedTagRange lower-tag must be greater-than-or-equal to zero")
+	var errs ValidationErrors
+	if r.LowerTag < MinFieldTag {
+		errs.add("", CodeInvalidRange, "ReservedTagRange lower-tag must be greater-than-or-equal to %d", MinFieldTag)
 	}
-	if r.LowerTag >= r.UpperTag {
-		return errors.New("ReservedTagRange upper-tag must be greater-than lower-tag")
+	if r.LowerTag > r.UpperTag {
+		errs.add("", CodeInvalidRange, "ReservedTagRange upper-tag must be greater-than-or-equal to lower-tag")
 	}
-	return nil
+	if r.UpperTag > MaxFieldTag && r.UpperTag != MaxTag {
+		errs.add("", CodeInvalidRange, "ReservedTagRange upper-tag must be less-than-or-equal to %d", MaxFieldTag)
+	}
+	return errs.err()
 }
 
 // Validate field attributes
 func (c CustomField) Validate() error {
+	var errs ValidationErrors
 	if c.Name == "" {
-		return errors.New("CustomField name must have non-empty name")
+		errs.add("", CodeEmptyName, "CustomField name must have non-empty name")
+	}
+	if c.Typing == "" {
+		errs.add(string(c.Name), CodeInvalidType, "CustomField must have a type")
 	}
-	if c.Tag < 0 {
-		return errors.New("CustomField must have positive integer for tag")
+	if c.Rule > Optional {
+		errs.add(string(c.Name), CodeInvalidRule, "CustomField has an unknown rule")
 	}
-	return nil
+	errs.merge(string(c.Name), validateFieldTag(c.Tag))
+	errs.merge(string(c.Name), validateFieldOptions(c.Options, c.Rule == Repeated))
+	return errs.err()
 }
 
 // Validate field attributes
 func (c CustomMapField) Validate() error {
+	var errs ValidationErrors
 	if c.Name == "" {
-		return errors.New("CustomMapField name must have non-empty name")
+		errs.add("", CodeEmptyName, "CustomMapField name must have non-empty name")
+	}
+	if !validMapKey(c.KeyTyping) {
+		errs.add(string(c.Name), CodeInvalidMapKey, "Map field must use a scalar integral or string type for the map key")
+	}
+	if c.ValueTyping == "" {
+		errs.add(string(c.Name), CodeInvalidType, "Map field must have a type specified for the map value")
+	}
+	if c.Rule != None {
+		errs.add(string(c.Name), CodeInvalidRule, "CustomMapField cannot use %srule", c.Rule.Write())
+	}
+	errs.merge(string(c.Name), validateFieldTag(c.Tag))
+	errs.merge(string(c.Name), validateFieldOptions(c.Options, false))
+	return errs.err()
+}
+
+// Validate field attributes
+func (m MessageField) Validate() error {
+	var errs ValidationErrors
+	if m.Name == "" {
+		errs.add("", CodeEmptyName, "MessageField must have a non-empty name")
+	}
+	if m.Typing == nil {
+		errs.add(string(m.Name), CodeInvalidType, "MessageField must refer to a message")
+	}
+	if m.Rule > Optional {
+		errs.add(string(m.Name), CodeInvalidRule, "MessageField has an unknown rule")
+	}
+	errs.merge(string(m.Name), validateFieldTag(m.Tag))
+	errs.merge(string(m.Name), validateFieldOptions(m.Options, false))
+	return errs.err()
+}
+
+// Validate field attributes
+func (e EnumField) Validate() error {
+	var errs ValidationErrors
+	if e.Name == "" {
+		errs.add("", CodeEmptyName, "EnumField must have a non-empty name")
+	}
+	if e.Typing == nil {
+		errs.add(string(e.Name), CodeInvalidType, "EnumField must refer to an enum")
+	}
+	if e.Rule > Optional {
+		errs.add(string(e.Name), CodeInvalidRule, "EnumField has an unknown rule")
+	}
+	errs.merge(string(e.Name), validateFieldTag(e.Tag))
+	errs.merge(string(e.Name), validateFieldOptions(e.Options, e.Rule == Repeated))
+	return errs.err()
+}
+
+// Validate map attributes
+func (m MessageMapField) Validate() error {
+	var errs ValidationErrors
+	if m.Name == "" {
+		errs.add("", CodeEmptyName, "MessageMapField must have a non-empty name")
+	}
+	if !validMapKey(m.KeyTyping) {
+		errs.add(string(m.Name), CodeInvalidMapKey, "Map field must use a scalar integral or string type for the map key")
+	}
+	if m.ValueTyping == nil {
+		errs.add(string(m.Name), CodeInvalidType, "MessageMapField must refer to a message for the map value")
+	}
+	if m.Rule != None {
+		errs.add(string(m.Name), CodeInvalidRule, "MessageMapField cannot use %srule", m.Rule.Write())
+	}
+	errs.merge(string(m.Name), validateFieldTag(m.Tag))
+	errs.merge(string(m.Name), validateFieldOptions(m.Options, false))
+	return errs.err()
+}
+
+// Validate map attributes
+func (e EnumMapField) Validate() error {
+	var errs ValidationErrors
+	if e.Name == "" {
+		errs.add("", CodeEmptyName, "EnumMapField must have a non-empty name")
+	}
+	if !validMapKey(e.KeyTyping) {
+		errs.add(string(e.Name), CodeInvalidMapKey, "Map field must use a scalar integral or string type for the map key")
 	}
-	if c.KeyTyping < 0 || c.KeyTyping == DoubleType || c.KeyTyping == FloatType || c.KeyTyping == BytesType {
-		return fmt.Errorf("Map field %s must use a scalar integral or string type for the map key", c.Name)
+	if e.ValueTyping == nil {
+		errs.add(string(e.Name), CodeInvalidType, "EnumMapField must refer to an enum for the map value")
 	}
-	if c.Rule == Repeated {
-		return errors.New("CustomMapField cannot use repeated rule")
+	if e.Rule != None {
+		errs.add(string(e.Name), CodeInvalidRule, "EnumMapField cannot use %srule", e.Rule.Write())
 	}
-	return nil
+	errs.merge(string(e.Name), validateFieldTag(e.Tag))
+	errs.merge(string(e.Name), validateFieldOptions(e.Options, false))
+	return errs.err()
 }
 
 // Validate map attributes
 func (m MapField) Validate() error {
+	var errs ValidationErrors
 	if m.Name == "" {
-		return errors.New("MapField must have a non-empty name")
+		errs.add("", CodeEmptyName, "MapField must have a non-empty name")
 	}
-	if m.KeyTyping < 0 || m.KeyTyping == DoubleType || m.KeyTyping == FloatType || m.KeyTyping == BytesType {
-		return fmt.Errorf("Map field %s must use a scalar integral or string type for the map key", m.Name)
+	if !validMapKey(m.KeyTyping) {
+		errs.add(string(m.Name), CodeInvalidMapKey, "Map field must use a scalar integral or string type for the map key")
 	}
-	if m.ValueTyping < 0 {
-		return fmt.Errorf("Map field %s must have a type specified for the map value", m.Name)
+	if m.ValueTyping > BytesType {
+		errs.add(string(m.Name), CodeInvalidType, "Map field must have a type specified for the map value")
 	}
-	if m.Rule == Repeated {
-		return errors.New("MapField cannot use repeated rule")
+	if m.Rule != None {
+		errs.add(string(m.Name), CodeInvalidRule, "MapField cannot use %srule", m.Rule.Write())
 	}
-	return nil
+	errs.merge(string(m.Name), validateFieldTag(m.Tag))
+	errs.merge(string(m.Name), validateFieldOptions(m.Options, false))
+	return errs.err()
 }
 
 // Validate enum attributes
 func (e *Enum) Validate() error {
+	var errs ValidationErrors
 	if e.Name == "" {
-		return errors.New("Enum must have a non-empty name")
+		errs.add("", CodeEmptyName, "Enum must have a non-empty name")
 	}
 	if len(e.Values) == 0 {
-		return errors.New("Enum must have non-empty set of values")
+		errs.add(string(e.Name), CodeEmptyValues, "Enum must have non-empty set of values")
 	}
-	if e.AllowAlias == false {
-		tags := make(map[TagType]NameType)
-		for _, v := range e.Values {
-			if _, exists := tags[v.Tag]; exists {
-				return fmt.Errorf("Enum value has tag that is already in use while aliasing is not allowed: %s", v.Name)
+	errs.merge(string(e.Name), validateOptions(enumScope, e.Options))
+	if e.AllowAlias && hasOption(e.Options, "allow_alias") {
+		errs.add(joinPath(string(e.Name), "allow_alias"), CodeDuplicateOption, "Option allow_alias is set by both AllowAlias and Options")
+	}
+	for _, v := range e.Values {
+		errs.merge(joinPath(string(e.Name), string(v.Name)), validateOptions(enumValueScope, v.Options))
+	}
+	for _, r := range e.ReservedValues {
+		errs.merge(string(e.Name), validateEnumReserved(r))
+	}
+	errs.merge(string(e.Name), validateReservedOverlap(e.ReservedValues))
+	names := make(map[NameType]bool)
+	tags := make(map[TagType]NameType)
+	aliased := false
+	for _, v := range e.Values {
+		path := joinPath(string(e.Name), string(v.Name))
+		if v.Name == "" {
+			errs.add(string(e.Name), CodeEmptyName, "Enum value must have a non-empty name")
+		}
+		if names[v.Name] && v.Name != "" {
+			errs.add(path, CodeDuplicateName, "Enum has more than one value named %s", v.Name)
+		}
+		names[v.Name] = true
+		errs.merge(path, validateReservedUse(e.ReservedValues, "Enum value", v.Name, v.Tag))
+		if _, exists := tags[v.Tag]; exists {
+			aliased = true
+			if !e.allowsAlias() {
+				errs.add(path, CodeDuplicateTag, "Enum value has tag that is already in use while aliasing is not allowed")
 			}
+		} else {
 			tags[v.Tag] = v.Name
 		}
 	}
-	return nil
+	if e.allowsAlias() && !aliased && len(e.Values) > 0 {
+		errs.add(string(e.Name), CodeInvalidOption, "Enum allows aliases but no two values share a tag")
+	}
+	errs.merge(string(e.Name), e.validateZeroValue())
+	errs.merge(string(e.Name), validateOrder(e.Order, e.elementCounts(), e.ReservedValues))
+	return errs.err()
+}
+
+// elementCounts returns the number of elements of each kind that can be declared at the top level.
+func (s *Spec) elementCounts() map[ElementKind]int {
+	packages := 0
+	if s.Package != "" {
+		packages = 1
+	}
+	return map[ElementKind]int{
+		ElementSyntax:  1,
+		ElementPackage: packages,
+		ElementMessage: len(s.Messages),
+		ElementEnum:    len(s.Enums),
+		ElementExtend:  len(s.Extends),
+		ElementService: len(s.Services),
+	}
+}
+
+// elementCounts returns the number of elements of each kind that can be declared within a message.
+func (m Message) elementCounts() map[ElementKind]int {
+	return map[ElementKind]int{
+		ElementOption:   len(m.Options),
+		ElementMessage:  len(m.Messages),
+		ElementEnum:     len(m.Enums),
+		ElementExtend:   len(m.Extends),
+		ElementReserved: len(m.ReservedValues),
+		ElementField:    len(m.Fields),
+		ElementOneOf:    len(m.OneOfs),
+	}
+}
+
+// elementCounts returns the number of elements of each kind that can be declared within an enum.
+func (e Enum) elementCounts() map[ElementKind]int {
+	return map[ElementKind]int{
+		ElementOption:   len(e.Options),
+		ElementReserved: len(e.ReservedValues),
+		ElementValue:    len(e.Values),
+	}
+}
+
+// elementCounts returns the number of elements of each kind that can be declared within a oneof.
+func (o OneOf) elementCounts() map[ElementKind]int {
+	return map[ElementKind]int{
+		ElementOption: len(o.Options),
+		ElementField:  len(o.Fields),
+	}
+}
+
+// validateOrder checks that each entry of a declaration order refers to one of the elements declared where
+// it belongs, given the number of elements of each kind, and that no element is listed twice. Layout markers
+// that do not apply where they appear are ignored, but a comma must join two reserved values, both names or
+// both tags, as a reserved statement cannot mix them.
+func validateOrder(order []Element, counts map[ElementKind]int, reserved []Reserved) error {
+	var errs ValidationErrors
+	listed := make(map[Element]bool)
+	for i, e := range order {
+		n, ok := counts[e.Kind]
+		switch {
+		case e.Kind == ElementBlank || e.Kind == ElementTrailing || e.Kind == ElementBraces:
+			continue
+		case e.Kind == ElementComma:
+			if i == 0 || i == len(order)-1 || !joinsReserved(order[i-1], order[i+1], reserved) {
+				errs.add("", CodeInvalidOrder, "Order lists a comma that does not join two reserved names or two reserved tags")
+			}
+			continue
+		case !ok:
+			errs.add("", CodeInvalidOrder, "Order lists an element of kind %q, which cannot be declared here", e.Kind)
+		case e.Index < 0 || e.Index >= n:
+			errs.add("", CodeInvalidOrder, "Order lists %s %d, but there are only %d", e.Kind, e.Index, n)
+		case listed[e]:
+			errs.add("", CodeInvalidOrder, "Order lists %s %d more than once", e.Kind, e.Index)
+		}
+		listed[e] = true
+	}
+	return errs.err()
+}
+
+// joinsReserved reports whether two elements are reserved values that can share a reserved statement.
+func joinsReserved(a, b Element, reserved []Reserved) bool {
+	for _, e := range []Element{a, b} {
+		if e.Kind != ElementReserved || e.Index < 0 || e.Index >= len(reserved) {
+			return false
+		}
+	}
+	_, nameA := reserved[a.Index].(ReservedName)
+	_, nameB := reserved[b.Index].(ReservedName)
+	return nameA == nameB
+}
+
+// validateEnumReserved checks a reserved value of an enum. Unlike field tags, enum values can use any
+// 32-bit integer, including zero and negative numbers.
+func validateEnumReserved(r Reserved) error {
+	var errs ValidationErrors
+	switch r := r.(type) {
+	case ReservedTagValue:
+	case ReservedTagRange:
+		if r.LowerTag > r.UpperTag {
+			errs.add("", CodeInvalidRange, "ReservedTagRange upper-tag must be greater-than-or-equal to lower-tag")
+		}
+	default:
+		return r.Validate()
+	}
+	return errs.err()
 }
 
-// Validate oneof attributes
+// validateZeroValue checks that the value of the enum written first has tag zero, as proto3 uses the first
+// value of an enum as its default. Values are written with the zero value first, unless the enum is ordered
+// as declared and its Order lists a value, which is then written first.
+func (e *Enum) validateZeroValue() error {
+	var errs ValidationErrors
+	for _, el := range e.Order {
+		if el.Kind != ElementValue || el.Index < 0 || el.Index >= len(e.Values) {
+			continue
+		}
+		if v := e.Values[el.Index]; v.Tag != 0 {
+			errs.add(string(v.Name), CodeInvalidTag, "Order lists %s as the first value of the enum, but the first value must have tag 0, which is its default", v.Name)
+		}
+		return errs.err()
+	}
+	for _, v := range e.Values {
+		if v.Tag == 0 {
+			return nil
+		}
+	}
+	if len(e.Values) > 0 {
+		errs.add("", CodeInvalidTag, "Enum must have a value with tag 0, which is its default")
+	}
+	return errs.err()
+}
+
+// validateEnumValueScope checks that the values of enums declared side by side in a message or package do
+// not share a name with each other or with the other elements of that scope. Enum values belong to the
+// scope enclosing their enum rather than to the enum itself, so protoc rejects such names. names lists the
+// other elements of the scope, described by kind (e.g. message Beacon), by name.
+func validateEnumValueScope(enums []Enum, names map[NameType]string) error {
+	type value struct {
+		enum NameType
+		kind string
+	}
+	var errs ValidationErrors
+	declared := make(map[NameType]value, len(names))
+	for name, kind := range names {
+		declared[name] = value{kind: kind}
+	}
+	for _, e := range enums {
+		for _, v := range e.Values {
+			other, exists := declared[v.Name]
+			switch {
+			case !exists:
+				declared[v.Name] = value{enum: e.Name, kind: fmt.Sprintf("value %s of enum %s", v.Name, e.Name)}
+			case other.enum != e.Name:
+				errs.add(joinPath(string(e.Name), string(v.Name)), CodeDuplicateName, "Enum value %s has the same name as %s, and enum values share the scope enclosing their enum", v.Name, other.kind)
+			}
+		}
+	}
+	return errs.err()
+}
+
+// Validate oneof attributes, including its options and each of its fields, which cannot be repeated,
+// optional or maps.
 func (o OneOf) Validate() error {
+	var errs ValidationErrors
 	if o.Name == "" {
-		return errors.New("OneOf must have a non-empty name")
+		errs.add("", CodeEmptyName, "OneOf must have a non-empty name")
 	}
 	if len(o.Fields) == 0 {
-		return errors.New("OneOf must have non-empty set of values")
+		errs.add(string(o.Name), CodeEmptyValues, "OneOf must have non-empty set of values")
 	}
-	return nil
+	errs.merge(string(o.Name), validateOptions(oneofScope, o.Options))
+	for _, f := range o.Fields {
+		errs.merge("", f.Validate())
+		name, _, _ := fieldNameTag(f)
+		switch f.(type) {
+		case MapField, CustomMapField, MessageMapField, EnumMapField:
+			errs.add(string(name), CodeInvalidType, "Fields within a oneof cannot be maps")
+			continue
+		}
+		switch fieldRule(f) {
+		case Repeated:
+			errs.add(string(name), CodeInvalidRule, "Fields within a oneof cannot be repeated")
+		case Optional:
+			errs.add(string(name), CodeInvalidRule, "Fields within a oneof cannot be optional")
+		}
+	}
+	errs.merge(string(o.Name), validateOrder(o.Order, o.elementCounts(), nil))
+	return errs.err()
 }
 
-// FORMATTING
+// allowsAlias reports whether the enum permits several values to share a tag, either through AllowAlias
+// or an explicit allow_alias option.
+func (e *Enum) allowsAlias() bool {
+	for _, o := range e.Options {
+		if v, ok := o.Value.(BoolValue); ok && o.Name == "allow_alias" {
+			return bool(v)
+		}
+	}
+	return e.AllowAlias
+}
+
+// validateFieldOptions checks the options of a field. The packed option is only permitted when packable
+// is set, which is the case for repeated fields of numeric types.
+func validateFieldOptions(options []Option, packable bool) error {
+	var errs ValidationErrors
+	errs.merge("", validateOptions(fieldScope, options))
+	if hasOption(options, "packed") && !packable {
+		errs.add("packed", CodeInvalidOption, "Option packed can only be set on repeated fields of numeric types")
+	}
+	return errs.err()
+}
 
-func indentLevel(level int) string {
-	var buffer bytes.Buffer
-	for i := 0; i < level; i++ {
-		buffer.WriteString("  ")
+// validateFieldTag checks that a field tag lies within the legal range and outside of the block reserved
+// for the Protocol Buffers implementation.
+func validateFieldTag(tag TagType) error {
+	var errs ValidationErrors
+	if tag < MinFieldTag || tag > MaxFieldTag {
+		errs.add("", CodeInvalidTag, "Field must have a tag between %d and %d", MinFieldTag, MaxFieldTag)
+	} else if tag >= FirstImplReservedTag && tag <= LastImplReservedTag {
+		errs.add("", CodeImplReservedTag, "Field cannot use tag %d, tags %d through %d are reserved for the Protocol Buffers implementation",
+			tag, FirstImplReservedTag, LastImplReservedTag)
 	}
-	return buffer.String()
+	return errs.err()
+}
+
+// validMapKey reports whether a built-in type can be used as the key of a map field.
+func validMapKey(t FieldType) bool {
+	return t <= BytesType && t != DoubleType && t != FloatType && t != BytesType
 }
 
+// FORMATTING
+
 // Len reports the number of enum values.
 func (e Enum) Len() int { return len(e.Values) }
 
 // Swap entries in the enum at the given positions.
 func (e Enum) Swap(i, j int) { e.Values[i], e.Values[j] = e.Values[j], e.Values[i] }
 
-// Less returns true iff the tag at the first position is less than the tag at the second position.
-func (e Enum) Less(i, j int) bool { return e.Values[i].Tag < e.Values[j].Tag }
+// Less returns true iff the value at the first position is written before the value at the second
+// position: values with tag zero come first, since proto3 uses the first value as the default, and the
+// others follow in order of tag, negative tags included.
+func (e Enum) Less(i, j int) bool {
+	a, b := e.Values[i].Tag, e.Values[j].Tag
+	if (a == 0) != (b == 0) {
+		return a == 0
+	}
+	return a < b
+}
@@ -25,6 +25,36 @@ func TestScalarField_Validate(t *testing.T) {
 			fields:  fields{Name: "MyMap", Tag: 1, Typing: StringType},
 			wantErr: false,
 		},
+		{
+			name:    "Tag above 255",
+			fields:  fields{Name: "MyField", Tag: 300, Typing: StringType},
+			wantErr: false,
+		},
+		{
+			name:    "Maximum tag",
+			fields:  fields{Name: "MyField", Tag: MaxFieldTag, Typing: StringType},
+			wantErr: false,
+		},
+		{
+			name:    "Optional field",
+			fields:  fields{Name: "MyField", Tag: 1, Rule: Optional, Typing: Int32Type},
+			wantErr: false,
+		},
+		{
+			name:    "Zero tag",
+			fields:  fields{Name: "MyField", Tag: 0, Typing: StringType},
+			wantErr: true,
+		},
+		{
+			name:    "Tag above maximum",
+			fields:  fields{Name: "MyField", Tag: MaxFieldTag + 1, Typing: StringType},
+			wantErr: true,
+		},
+		{
+			name:    "Tag reserved for implementation",
+			fields:  fields{Name: "MyField", Tag: 19500, Typing: StringType},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		s := &ScalarField{
@@ -40,6 +70,344 @@ func TestScalarField_Validate(t *testing.T) {
 	}
 }
 
+func TestReservedTagRange_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		r       ReservedTagRange
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "Valid range",
+			r:    ReservedTagRange{LowerTag: 6, UpperTag: 9},
+			want: "6 to 9",
+		},
+		{
+			name: "Range of a single tag",
+			r:    ReservedTagRange{LowerTag: 5, UpperTag: 5},
+			want: "5 to 5",
+		},
+		{
+			name:    "Inverted range",
+			r:       ReservedTagRange{LowerTag: 6, UpperTag: 5},
+			wantErr: true,
+		},
+		{
+			name: "Range up to max",
+			r:    ReservedTagRange{LowerTag: 1000, UpperTag: MaxTag},
+			want: "1000 to max",
+		},
+		{
+			name: "Range up to maximum field tag",
+			r:    ReservedTagRange{LowerTag: 1000, UpperTag: MaxFieldTag},
+			want: "1000 to 536870911",
+		},
+		{
+			name:    "Range above maximum field tag",
+			r:       ReservedTagRange{LowerTag: 1000, UpperTag: MaxFieldTag + 1},
+			wantErr: true,
+		},
+		{
+			name:    "Range starting at zero",
+			r:       ReservedTagRange{LowerTag: 0, UpperTag: 5},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		if err := tt.r.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%q. ReservedTagRange.Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if got, _ := tt.r.Write(); got != tt.want {
+			t.Errorf("%q. ReservedTagRange.Write() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMessage_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		msg     Message
+		wantErr bool
+	}{
+		{
+			name: "Unique fields",
+			msg: Message{
+				Name:           "Beacon",
+				ReservedValues: []Reserved{ReservedTagValue{Tag: 1}, ReservedTagRange{LowerTag: 2, UpperTag: 4}, ReservedName{Name: "old"}},
+				Fields:         []Field{ScalarField{Name: "a", Tag: 5}, CustomField{Name: "b", Tag: 6, Typing: "Event"}},
+				OneOfs:         []OneOf{{Name: "choice", Fields: []Field{ScalarField{Name: "c", Tag: 7}}}},
+			},
+		},
+		{
+			name: "Duplicate tag",
+			msg: Message{
+				Name:   "Beacon",
+				Fields: []Field{ScalarField{Name: "a", Tag: 5}, MapField{Name: "b", Tag: 5, KeyTyping: StringType}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Duplicate name",
+			msg: Message{
+				Name:   "Beacon",
+				Fields: []Field{ScalarField{Name: "a", Tag: 5}, ScalarField{Name: "a", Tag: 6}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Duplicate tag within oneof",
+			msg: Message{
+				Name:   "Beacon",
+				Fields: []Field{ScalarField{Name: "a", Tag: 5}},
+				OneOfs: []OneOf{{Name: "choice", Fields: []Field{ScalarField{Name: "c", Tag: 5}}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Duplicate name across oneofs",
+			msg: Message{
+				Name: "Beacon",
+				OneOfs: []OneOf{
+					{Name: "first", Fields: []Field{ScalarField{Name: "c", Tag: 5}}},
+					{Name: "second", Fields: []Field{ScalarField{Name: "c", Tag: 6}}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Reserved tag",
+			msg: Message{
+				Name:           "Beacon",
+				ReservedValues: []Reserved{ReservedTagValue{Tag: 1}},
+				Fields:         []Field{ScalarField{Name: "a", Tag: 1}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Tag within reserved range",
+			msg: Message{
+				Name:           "Beacon",
+				ReservedValues: []Reserved{ReservedTagRange{LowerTag: 100, UpperTag: MaxTag}},
+				OneOfs:         []OneOf{{Name: "choice", Fields: []Field{ScalarField{Name: "c", Tag: 200}}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Reserved name",
+			msg: Message{
+				Name:           "Beacon",
+				ReservedValues: []Reserved{ReservedName{Name: "old"}},
+				Fields:         []Field{CustomMapField{Name: "old", Tag: 1, KeyTyping: StringType, ValueTyping: "Event"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Overlapping reserved ranges",
+			msg: Message{
+				Name:           "Beacon",
+				ReservedValues: []Reserved{ReservedTagRange{LowerTag: 6, UpperTag: 9}, ReservedTagRange{LowerTag: 9, UpperTag: 12}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Reserved value within reserved range",
+			msg: Message{
+				Name:           "Beacon",
+				ReservedValues: []Reserved{ReservedTagRange{LowerTag: 6, UpperTag: 9}, ReservedTagValue{Tag: 7}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Optional field within oneof",
+			msg: Message{
+				Name:   "Beacon",
+				OneOfs: []OneOf{{Name: "choice", Fields: []Field{ScalarField{Name: "c", Tag: 5, Rule: Optional}}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Repeated field within oneof",
+			msg: Message{
+				Name:   "Beacon",
+				OneOfs: []OneOf{{Name: "choice", Fields: []Field{CustomField{Name: "c", Typing: "Event", Tag: 5, Rule: Repeated}}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Map field within oneof",
+			msg: Message{
+				Name:   "Beacon",
+				OneOfs: []OneOf{{Name: "choice", Fields: []Field{MapField{Name: "c", Tag: 5, KeyTyping: StringType, ValueTyping: StringType}}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Invalid field within oneof",
+			msg: Message{
+				Name:   "Beacon",
+				OneOfs: []OneOf{{Name: "choice", Fields: []Field{CustomField{Name: "c", Tag: 5}}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Empty oneof",
+			msg: Message{
+				Name:   "Beacon",
+				OneOfs: []OneOf{{Name: "choice"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Oneof named like a field",
+			msg: Message{
+				Name:   "Beacon",
+				Fields: []Field{ScalarField{Name: "choice", Tag: 1}},
+				OneOfs: []OneOf{{Name: "choice", Fields: []Field{ScalarField{Name: "c", Tag: 5}}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Oneof with custom option",
+			msg: Message{
+				Name:   "Beacon",
+				OneOfs: []OneOf{{Name: "choice", Options: []Option{{Name: "(required)", Value: BoolValue(true)}}, Fields: []Field{ScalarField{Name: "c", Tag: 5}}}},
+			},
+		},
+		{
+			name: "Oneof with unknown option",
+			msg: Message{
+				Name:   "Beacon",
+				OneOfs: []OneOf{{Name: "choice", Options: []Option{{Name: "packed", Value: BoolValue(true)}}, Fields: []Field{ScalarField{Name: "c", Tag: 5}}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Declaration order",
+			msg: Message{
+				Name:   "Beacon",
+				Fields: []Field{ScalarField{Name: "a", Tag: 1}, ScalarField{Name: "b", Tag: 3}},
+				OneOfs: []OneOf{{Name: "choice", Fields: []Field{ScalarField{Name: "c", Tag: 2}}}},
+				Order:  []Element{{Kind: ElementField, Index: 0}, {Kind: ElementOneOf, Index: 0}, {Kind: ElementField, Index: 1}},
+			},
+		},
+		{
+			name: "Order listing a field twice",
+			msg: Message{
+				Name:   "Beacon",
+				Fields: []Field{ScalarField{Name: "a", Tag: 1}},
+				Order:  []Element{{Kind: ElementField, Index: 0}, {Kind: ElementField, Index: 0}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Order listing an enum value",
+			msg: Message{
+				Name:   "Beacon",
+				Fields: []Field{ScalarField{Name: "a", Tag: 1}},
+				Order:  []Element{{Kind: ElementValue, Index: 0}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Optional map field",
+			msg: Message{
+				Name:   "Beacon",
+				Fields: []Field{MapField{Name: "m", Tag: 5, Rule: Optional, KeyTyping: StringType}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Name reserved twice",
+			msg: Message{
+				Name:           "Beacon",
+				ReservedValues: []Reserved{ReservedName{Name: "old"}, ReservedName{Name: "old"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Sibling enums sharing a value name",
+			msg: Message{
+				Name: "Beacon",
+				Enums: []Enum{
+					{Name: "Kind", Values: []EnumValue{{Name: "UNKNOWN", Tag: 0}}},
+					{Name: "Level", Values: []EnumValue{{Name: "UNKNOWN", Tag: 0}}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Enum value named like a field",
+			msg: Message{
+				Name:   "Beacon",
+				Enums:  []Enum{{Name: "Kind", Values: []EnumValue{{Name: "view", Tag: 0}}}},
+				Fields: []Field{ScalarField{Name: "view", Tag: 1}},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		if err := tt.msg.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%q. Message.Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestEnum_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		enum     Enum
+		wantCode ErrorCode
+	}{
+		{"Valid", Enum{Name: "Kind", Values: []EnumValue{{Name: "NEGATIVE", Tag: -1}, {Name: "UNKNOWN", Tag: 0}, {Name: "VIEW", Tag: 1}}}, ""},
+		{"Aliases", Enum{Name: "Kind", AllowAlias: true, Values: []EnumValue{{Name: "UNKNOWN", Tag: 0}, {Name: "VIEW", Tag: 1}, {Name: "SEEN", Tag: 1}}}, ""},
+		{"Without zero value", Enum{Name: "Kind", Values: []EnumValue{{Name: "VIEW", Tag: 1}}}, CodeInvalidTag},
+		{"Empty value name", Enum{Name: "Kind", Values: []EnumValue{{Name: "UNKNOWN", Tag: 0}, {Name: "", Tag: 1}}}, CodeEmptyName},
+		{"Duplicate tag", Enum{Name: "Kind", Values: []EnumValue{{Name: "UNKNOWN", Tag: 0}, {Name: "VIEW", Tag: 0}}}, CodeDuplicateTag},
+		{"Duplicate name", Enum{Name: "Kind", AllowAlias: true, Values: []EnumValue{{Name: "UNKNOWN", Tag: 0}, {Name: "UNKNOWN", Tag: 0}}}, CodeDuplicateName},
+		{"Aliases allowed but unused", Enum{Name: "Kind", AllowAlias: true, Values: []EnumValue{{Name: "UNKNOWN", Tag: 0}, {Name: "VIEW", Tag: 1}}}, CodeInvalidOption},
+		{"Alias option unused", Enum{Name: "Kind", Options: []Option{{Name: "allow_alias", Value: BoolValue(true)}}, Values: []EnumValue{{Name: "UNKNOWN", Tag: 0}}}, CodeInvalidOption},
+		{"Reserved negative and zero tags", Enum{Name: "Kind", ReservedValues: []Reserved{ReservedTagRange{LowerTag: -5, UpperTag: -1}, ReservedTagValue{Tag: 2}}, Values: []EnumValue{{Name: "UNKNOWN", Tag: 0}}}, ""},
+		{"Reserved tag used", Enum{Name: "Kind", ReservedValues: []Reserved{ReservedTagValue{Tag: 1}}, Values: []EnumValue{{Name: "UNKNOWN", Tag: 0}, {Name: "VIEW", Tag: 1}}}, CodeReservedTag},
+		{"Reserved range used", Enum{Name: "Kind", ReservedValues: []Reserved{ReservedTagRange{LowerTag: 1, UpperTag: MaxTag}}, Values: []EnumValue{{Name: "UNKNOWN", Tag: 0}, {Name: "VIEW", Tag: 5}}}, CodeReservedTag},
+		{"Reserved name used", Enum{Name: "Kind", ReservedValues: []Reserved{ReservedName{Name: "VIEW"}}, Values: []EnumValue{{Name: "UNKNOWN", Tag: 0}, {Name: "VIEW", Tag: 1}}}, CodeReservedName},
+		{"Reserved range of a single value", Enum{Name: "Kind", ReservedValues: []Reserved{ReservedTagRange{LowerTag: -5, UpperTag: -5}}, Values: []EnumValue{{Name: "UNKNOWN", Tag: 0}}}, ""},
+		{"Reserved range inverted", Enum{Name: "Kind", ReservedValues: []Reserved{ReservedTagRange{LowerTag: 3, UpperTag: 2}}, Values: []EnumValue{{Name: "UNKNOWN", Tag: 0}}}, CodeInvalidRange},
+		{"Declaration order", Enum{Name: "Kind", ReservedValues: []Reserved{ReservedTagValue{Tag: 2}}, Values: []EnumValue{{Name: "UNKNOWN", Tag: 0}}, Order: []Element{{Kind: ElementValue, Index: 0}, {Kind: ElementReserved, Index: 0}}}, ""},
+		{"Order out of range", Enum{Name: "Kind", Values: []EnumValue{{Name: "UNKNOWN", Tag: 0}}, Order: []Element{{Kind: ElementValue, Index: 1}}}, CodeInvalidOrder},
+		{"Order of a field", Enum{Name: "Kind", Values: []EnumValue{{Name: "UNKNOWN", Tag: 0}}, Order: []Element{{Kind: ElementField, Index: 0}}}, CodeInvalidOrder},
+		{"Order lists zero value first", Enum{Name: "Kind", Values: []EnumValue{{Name: "VIEW", Tag: 1}, {Name: "UNKNOWN", Tag: 0}}, Order: []Element{{Kind: ElementValue, Index: 1}, {Kind: ElementValue, Index: 0}}}, ""},
+		{"Order lists other value first", Enum{Name: "Kind", Values: []EnumValue{{Name: "UNKNOWN", Tag: 0}, {Name: "VIEW", Tag: 1}}, Order: []Element{{Kind: ElementValue, Index: 1}, {Kind: ElementValue, Index: 0}}}, CodeInvalidTag},
+		{"Order with layout markers", Enum{Name: "Kind", ReservedValues: []Reserved{ReservedTagValue{Tag: 2}, ReservedTagRange{LowerTag: 4, UpperTag: 6}}, Values: []EnumValue{{Name: "UNKNOWN", Tag: 0}}, Order: []Element{{Kind: ElementValue, Index: 0}, {Kind: ElementTrailing}, {Kind: ElementBlank}, {Kind: ElementReserved, Index: 0}, {Kind: ElementComma}, {Kind: ElementReserved, Index: 1}}}, ""},
+		{"Order joining a tag and a name", Enum{Name: "Kind", ReservedValues: []Reserved{ReservedTagValue{Tag: 2}, ReservedName{Name: "OLD"}}, Values: []EnumValue{{Name: "UNKNOWN", Tag: 0}}, Order: []Element{{Kind: ElementReserved, Index: 0}, {Kind: ElementComma}, {Kind: ElementReserved, Index: 1}}}, CodeInvalidOrder},
+		{"Order ending in a comma", Enum{Name: "Kind", ReservedValues: []Reserved{ReservedTagValue{Tag: 2}}, Values: []EnumValue{{Name: "UNKNOWN", Tag: 0}}, Order: []Element{{Kind: ElementReserved, Index: 0}, {Kind: ElementComma}}}, CodeInvalidOrder},
+		{"Reserved twice", Enum{Name: "Kind", ReservedValues: []Reserved{ReservedTagValue{Tag: 3}, ReservedTagRange{LowerTag: 2, UpperTag: 4}}, Values: []EnumValue{{Name: "UNKNOWN", Tag: 0}}}, CodeReservedOverlap},
+	}
+	for _, tt := range tests {
+		err := tt.enum.Validate()
+		errs, _ := err.(ValidationErrors)
+		if tt.wantCode == "" && err != nil || tt.wantCode != "" && (len(errs) != 1 || errs[0].Code != tt.wantCode) {
+			t.Errorf("%q. Enum.Validate() error = %v, want code %q", tt.name, err, tt.wantCode)
+		}
+	}
+
+	spec := &Spec{
+		Package:  "mux",
+		Messages: []Message{{Name: "Beacon"}},
+		Enums: []Enum{
+			{Name: "Kind", Values: []EnumValue{{Name: "UNKNOWN", Tag: 0}, {Name: "Beacon", Tag: 1}}},
+			{Name: "Level", Values: []EnumValue{{Name: "UNKNOWN", Tag: 0}}},
+		},
+	}
+	errs, _ := spec.Validate().(ValidationErrors)
+	if len(errs) != 2 || errs[0].Path != "mux.Kind.Beacon" || errs[1].Path != "mux.Level.UNKNOWN" {
+		t.Errorf("Spec.Validate() error = %v, want enum values conflicting with mux.Beacon and mux.Kind.UNKNOWN", errs)
+	}
+}
+
 func TestSpec_Write(t *testing.T) {
 	type fields struct {
 		Package  string
@@ -0,0 +1,536 @@
+package proto3
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// yamlKind identifies the kind of a node within a YAML document.
+type yamlKind uint8
+
+const (
+	yamlScalar yamlKind = iota
+	yamlMapping
+	yamlSequence
+)
+
+// yamlNode is a scalar, mapping or sequence within a YAML document, along with its position in the source.
+type yamlNode struct {
+	kind   yamlKind
+	line   int
+	column int
+	value  string      // value of a scalar
+	quoted bool        // the scalar was quoted, so it is always a string
+	keys   []*yamlNode // keys of a mapping, in order
+	values []*yamlNode // values of a mapping, or the items of a sequence
+}
+
+// isNull reports whether the node is an empty or null scalar.
+func (n *yamlNode) isNull() bool {
+	return n.kind == yamlScalar && !n.quoted && (n.value == "" || n.value == "null" || n.value == "~")
+}
+
+// yamlParser reads the subset of YAML used by definition files: block mappings and sequences, flow
+// mappings and sequences, and plain, single-quoted and double-quoted scalars. As JSON is written entirely
+// in flow style, JSON documents are read by the same parser. Anchors, aliases, tags, block scalars and
+// multiple documents are not supported.
+type yamlParser struct {
+	src    []byte
+	pos    int
+	line   int
+	column int
+}
+
+// parseYAML reads a single YAML or JSON document.
+func parseYAML(src []byte) (*yamlNode, error) {
+	p := &yamlParser{src: src, line: 1, column: 1}
+	if err := p.skipInsignificant(); err != nil {
+		return nil, err
+	}
+	if p.atMarker("---") {
+		p.advanceN(3)
+	}
+	root, err := p.parseValue(-1, false, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.skipInsignificant(); err != nil {
+		return nil, err
+	}
+	if p.atMarker("...") {
+		p.advanceN(3)
+		if err := p.skipInsignificant(); err != nil {
+			return nil, err
+		}
+	}
+	if !p.eof() {
+		return nil, p.errorf("unexpected content; check the indentation")
+	}
+	return root, nil
+}
+
+func (p *yamlParser) errorf(format string, args ...interface{}) error {
+	return &ParseError{Line: p.line, Column: p.column, Message: fmt.Sprintf(format, args...)}
+}
+
+func (p *yamlParser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *yamlParser) peekByte(offset int) byte {
+	if p.pos+offset < len(p.src) {
+		return p.src[p.pos+offset]
+	}
+	return 0
+}
+
+func (p *yamlParser) advance() {
+	if p.src[p.pos] == '\n' {
+		p.line++
+		p.column = 1
+	} else {
+		p.column++
+	}
+	p.pos++
+}
+
+func (p *yamlParser) advanceN(n int) {
+	for i := 0; i < n && !p.eof(); i++ {
+		p.advance()
+	}
+}
+
+// atLineEnd reports whether the byte at the given offset ends a line.
+func (p *yamlParser) atLineEnd(offset int) bool {
+	c := p.peekByte(offset)
+	return c == '\n' || c == '\r' || c == 0
+}
+
+// atSpace reports whether the byte at the given offset is whitespace or ends a line.
+func (p *yamlParser) atSpace(offset int) bool {
+	c := p.peekByte(offset)
+	return c == ' ' || c == '\t' || p.atLineEnd(offset)
+}
+
+// atMarker reports whether a document marker such as --- starts the current line.
+func (p *yamlParser) atMarker(marker string) bool {
+	return p.column == 1 && bytes.HasPrefix(p.src[p.pos:], []byte(marker)) && p.atSpace(len(marker))
+}
+
+// atSequenceEntry reports whether a block sequence entry starts at the current position.
+func (p *yamlParser) atSequenceEntry() bool {
+	return p.peekByte(0) == '-' && p.atSpace(1)
+}
+
+// skipInsignificant skips whitespace, line breaks and comments. Tabs cannot be used for indentation.
+func (p *yamlParser) skipInsignificant() error {
+	return p.skip(false)
+}
+
+// skipFlowSpace skips whitespace, line breaks and comments within a flow collection, where tabs are
+// permitted.
+func (p *yamlParser) skipFlowSpace() error {
+	return p.skip(true)
+}
+
+func (p *yamlParser) skip(flow bool) error {
+	indenting := p.column == 1 && !flow
+	for !p.eof() {
+		switch c := p.src[p.pos]; {
+		case c == '\n':
+			indenting = !flow
+			p.advance()
+		case c == ' ' || c == '\r':
+			p.advance()
+		case c == '\t':
+			if indenting {
+				return p.errorf("tabs cannot be used for indentation")
+			}
+			p.advance()
+		case c == '#':
+			for !p.eof() && p.src[p.pos] != '\n' {
+				p.advance()
+			}
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// expectLineEnd checks that nothing but whitespace or a comment follows a value on the current line.
+func (p *yamlParser) expectLineEnd() error {
+	for p.peekByte(0) == ' ' || p.peekByte(0) == '\t' || p.peekByte(0) == '\r' {
+		p.advance()
+	}
+	if p.peekByte(0) == '#' || p.atLineEnd(0) {
+		return nil
+	}
+	return p.errorf("unexpected %q after value", p.src[p.pos])
+}
+
+// parseValue reads the block value that follows a mapping key or sequence entry indented at the given
+// level. A value on the same line as a mapping key (inline) can only be a scalar or flow collection. A
+// value on a following line must be indented further, except that the value of a mapping key may be a
+// sequence at the same indentation (sequenceAtIndent). Missing values are read as null.
+func (p *yamlParser) parseValue(indent int, inline, sequenceAtIndent bool) (*yamlNode, error) {
+	line, column := p.line, p.column
+	if err := p.skipInsignificant(); err != nil {
+		return nil, err
+	}
+	null := &yamlNode{kind: yamlScalar, line: line, column: column}
+	if p.eof() || p.atMarker("...") {
+		return null, nil
+	}
+	if p.line != line {
+		inline = false
+		current := p.column - 1
+		if current < indent || (current == indent && !(sequenceAtIndent && p.atSequenceEntry())) {
+			return null, nil
+		}
+	}
+
+	switch {
+	case p.atSequenceEntry():
+		if inline {
+			return nil, p.errorf("a block sequence cannot start on the same line as its key")
+		}
+		return p.parseSequence(p.column - 1)
+	case p.peekByte(0) == '[' || p.peekByte(0) == '{':
+		n, err := p.parseFlowValue()
+		if err != nil {
+			return nil, err
+		}
+		return n, p.expectLineEnd()
+	case !inline && p.atMappingKey():
+		return p.parseMapping(p.column - 1)
+	default:
+		n, err := p.parseScalar(false)
+		if err != nil {
+			return nil, err
+		}
+		return n, p.expectLineEnd()
+	}
+}
+
+// parseMapping reads the entries of a block mapping whose keys are indented at the given level.
+func (p *yamlParser) parseMapping(indent int) (*yamlNode, error) {
+	n := &yamlNode{kind: yamlMapping, line: p.line, column: p.column}
+	for {
+		if !p.atMappingKey() {
+			return nil, p.errorf("expected a mapping key")
+		}
+		key, err := p.parseKey(false)
+		if err != nil {
+			return nil, err
+		}
+		for p.peekByte(0) == ' ' || p.peekByte(0) == '\t' {
+			p.advance()
+		}
+		p.advance() // the ':' found by atMappingKey
+		value, err := p.parseValue(indent, true, true)
+		if err != nil {
+			return nil, err
+		}
+		if err := n.addEntry(key, value); err != nil {
+			return nil, err
+		}
+
+		if err := p.skipInsignificant(); err != nil {
+			return nil, err
+		}
+		if p.eof() || p.column-1 < indent || p.atMarker("...") {
+			return n, nil
+		}
+		if p.column-1 > indent {
+			return nil, p.errorf("unexpected indentation")
+		}
+	}
+}
+
+// parseSequence reads the entries of a block sequence whose dashes are indented at the given level.
+func (p *yamlParser) parseSequence(indent int) (*yamlNode, error) {
+	n := &yamlNode{kind: yamlSequence, line: p.line, column: p.column}
+	for {
+		p.advance() // the '-' found by atSequenceEntry
+		item, err := p.parseValue(indent, false, false)
+		if err != nil {
+			return nil, err
+		}
+		n.values = append(n.values, item)
+
+		if err := p.skipInsignificant(); err != nil {
+			return nil, err
+		}
+		if p.eof() || p.column-1 < indent || p.atMarker("...") {
+			return n, nil
+		}
+		if p.column-1 > indent {
+			return nil, p.errorf("unexpected indentation")
+		}
+		if !p.atSequenceEntry() {
+			return n, nil
+		}
+	}
+}
+
+// atMappingKey reports whether the current line holds a block mapping key followed by a colon.
+func (p *yamlParser) atMappingKey() bool {
+	i := p.pos
+	if c := p.peekByte(0); c == '"' || c == '\'' {
+		for i++; i < len(p.src) && p.src[i] != c && p.src[i] != '\n'; i++ {
+			if c == '"' && p.src[i] == '\\' {
+				i++
+			}
+		}
+		if i >= len(p.src) || p.src[i] != c {
+			return false
+		}
+		for i++; i < len(p.src) && (p.src[i] == ' ' || p.src[i] == '\t'); i++ {
+		}
+		return i < len(p.src) && p.src[i] == ':' && p.atSpace(i+1-p.pos)
+	}
+	if c := p.peekByte(0); c == '[' || c == '{' || c == '#' {
+		return false
+	}
+	for ; i < len(p.src) && p.src[i] != '\n'; i++ {
+		switch {
+		case p.src[i] == ':' && p.atSpace(i+1-p.pos):
+			return true
+		case p.src[i] == '#' && i > p.pos && (p.src[i-1] == ' ' || p.src[i-1] == '\t'):
+			return false
+		}
+	}
+	return false
+}
+
+// parseKey reads a mapping key, leaving the position at the colon or whitespace that follows it.
+func (p *yamlParser) parseKey(flow bool) (*yamlNode, error) {
+	if c := p.peekByte(0); c == '"' || c == '\'' {
+		return p.parseQuoted()
+	}
+	return p.parsePlain(flow)
+}
+
+// addEntry appends a key and value to a mapping, rejecting duplicate keys.
+func (n *yamlNode) addEntry(key, value *yamlNode) error {
+	if key.kind != yamlScalar {
+		return &ParseError{Line: key.line, Column: key.column, Message: "mapping keys must be scalars"}
+	}
+	for _, k := range n.keys {
+		if k.value == key.value {
+			return &ParseError{Line: key.line, Column: key.column, Message: fmt.Sprintf("duplicate key %q", key.value)}
+		}
+	}
+	n.keys = append(n.keys, key)
+	n.values = append(n.values, value)
+	return nil
+}
+
+// parseScalar reads a quoted or plain scalar.
+func (p *yamlParser) parseScalar(flow bool) (*yamlNode, error) {
+	switch p.peekByte(0) {
+	case '"', '\'':
+		return p.parseQuoted()
+	case '&', '*', '!':
+		return nil, p.errorf("anchors, aliases and tags are not supported")
+	case '|', '>':
+		return nil, p.errorf("block scalars are not supported; use a quoted string")
+	case '@', '`', '%':
+		return nil, p.errorf("unexpected %q at start of value", p.src[p.pos])
+	}
+	return p.parsePlain(flow)
+}
+
+// parsePlain reads an unquoted scalar, which ends at the end of the line, at a comment, or at a colon
+// followed by whitespace. Within a flow collection it also ends at a flow indicator.
+func (p *yamlParser) parsePlain(flow bool) (*yamlNode, error) {
+	n := &yamlNode{kind: yamlScalar, line: p.line, column: p.column}
+	start, end := p.pos, p.pos
+	for !p.eof() && !p.atLineEnd(0) {
+		c := p.src[p.pos]
+		if c == ':' && (p.atSpace(1) || (flow && isFlowIndicator(p.peekByte(1)))) {
+			break
+		}
+		if c == '#' && p.pos > start && (p.src[p.pos-1] == ' ' || p.src[p.pos-1] == '\t') {
+			break
+		}
+		if flow && isFlowIndicator(c) {
+			break
+		}
+		p.advance()
+		if c != ' ' && c != '\t' {
+			end = p.pos
+		}
+	}
+	n.value = string(p.src[start:end])
+	return n, nil
+}
+
+func isFlowIndicator(c byte) bool {
+	return c == ',' || c == '[' || c == ']' || c == '{' || c == '}'
+}
+
+// parseQuoted reads a single- or double-quoted scalar. Double-quoted scalars support the escapes of both
+// YAML and JSON. Quoted scalars cannot span lines.
+func (p *yamlParser) parseQuoted() (*yamlNode, error) {
+	n := &yamlNode{kind: yamlScalar, line: p.line, column: p.column, quoted: true}
+	quote := p.src[p.pos]
+	p.advance()
+	var buffer bytes.Buffer
+	for {
+		if p.eof() || p.atLineEnd(0) {
+			return nil, &ParseError{Line: n.line, Column: n.column, Message: "unterminated quoted string"}
+		}
+		c := p.src[p.pos]
+		switch {
+		case c == quote && quote == '\'' && p.peekByte(1) == '\'':
+			buffer.WriteByte('\'')
+			p.advanceN(2)
+		case c == quote:
+			p.advance()
+			n.value = buffer.String()
+			return n, nil
+		case c == '\\' && quote == '"':
+			if err := p.parseEscape(&buffer); err != nil {
+				return nil, err
+			}
+		default:
+			buffer.WriteByte(c)
+			p.advance()
+		}
+	}
+}
+
+// parseEscape reads an escape sequence within a double-quoted scalar.
+func (p *yamlParser) parseEscape(buffer *bytes.Buffer) error {
+	p.advance() // the backslash
+	c := p.peekByte(0)
+	simple := map[byte]byte{'0': 0, 'a': '\a', 'b': '\b', 't': '\t', 'n': '\n', 'v': '\v', 'f': '\f', 'r': '\r',
+		'e': 0x1b, ' ': ' ', '"': '"', '/': '/', '\\': '\\'}
+	if v, ok := simple[c]; ok {
+		buffer.WriteByte(v)
+		p.advance()
+		return nil
+	}
+	digits := map[byte]int{'x': 2, 'u': 4, 'U': 8}[c]
+	if digits == 0 {
+		return p.errorf("unknown escape sequence \\%c", c)
+	}
+	r, err := p.parseHexRune(digits)
+	if err != nil {
+		return err
+	}
+	if utf16.IsSurrogate(r) && p.peekByte(0) == '\\' && p.peekByte(1) == 'u' {
+		p.advance()
+		low, err := p.parseHexRune(4)
+		if err != nil {
+			return err
+		}
+		r = utf16.DecodeRune(r, low)
+	}
+	if digits == 2 {
+		buffer.WriteByte(byte(r))
+	} else {
+		var encoded [utf8.UTFMax]byte
+		buffer.Write(encoded[:utf8.EncodeRune(encoded[:], r)])
+	}
+	return nil
+}
+
+// parseHexRune reads the escape letter followed by the given number of hexadecimal digits.
+func (p *yamlParser) parseHexRune(digits int) (rune, error) {
+	p.advance() // the escape letter
+	if p.pos+digits > len(p.src) {
+		return 0, p.errorf("incomplete escape sequence")
+	}
+	v, err := strconv.ParseUint(string(p.src[p.pos:p.pos+digits]), 16, 32)
+	if err != nil {
+		return 0, p.errorf("invalid escape sequence")
+	}
+	p.advanceN(digits)
+	return rune(v), nil
+}
+
+// parseFlowValue reads a value within, or starting, a flow collection. Flow collections may span lines
+// regardless of indentation.
+func (p *yamlParser) parseFlowValue() (*yamlNode, error) {
+	if err := p.skipFlowSpace(); err != nil {
+		return nil, err
+	}
+	switch p.peekByte(0) {
+	case '{':
+		return p.parseFlowCollection(yamlMapping, '}')
+	case '[':
+		return p.parseFlowCollection(yamlSequence, ']')
+	default:
+		return p.parseScalar(true)
+	}
+}
+
+// parseFlowCollection reads a flow mapping or sequence, including its brackets.
+func (p *yamlParser) parseFlowCollection(kind yamlKind, closing byte) (*yamlNode, error) {
+	n := &yamlNode{kind: kind, line: p.line, column: p.column}
+	p.advance() // the opening bracket
+	for {
+		if err := p.skipFlowSpace(); err != nil {
+			return nil, err
+		}
+		if p.eof() {
+			return nil, &ParseError{Line: n.line, Column: n.column, Message: fmt.Sprintf("unterminated flow collection, expected %q", closing)}
+		}
+		if p.peekByte(0) == closing {
+			p.advance()
+			return n, nil
+		}
+
+		if kind == yamlSequence {
+			item, err := p.parseFlowValue()
+			if err != nil {
+				return nil, err
+			}
+			n.values = append(n.values, item)
+		} else {
+			key, err := p.parseKey(true)
+			if err != nil {
+				return nil, err
+			}
+			if err := p.skipFlowSpace(); err != nil {
+				return nil, err
+			}
+			if p.peekByte(0) != ':' {
+				return nil, p.errorf("expected ':' after mapping key %q", key.value)
+			}
+			p.advance()
+			if err := p.skipFlowSpace(); err != nil {
+				return nil, err
+			}
+			value := &yamlNode{kind: yamlScalar, line: p.line, column: p.column}
+			if c := p.peekByte(0); c != ',' && c != closing {
+				if value, err = p.parseFlowValue(); err != nil {
+					return nil, err
+				}
+			}
+			if err := n.addEntry(key, value); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := p.skipFlowSpace(); err != nil {
+			return nil, err
+		}
+		switch p.peekByte(0) {
+		case ',':
+			p.advance()
+		case closing:
+		default:
+			if p.eof() {
+				return nil, &ParseError{Line: n.line, Column: n.column, Message: fmt.Sprintf("unterminated flow collection, expected %q", closing)}
+			}
+			return nil, p.errorf("expected ',' or %q", closing)
+		}
+	}
+}